@@ -13,7 +13,8 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "ch-resourve-service-server"
 	app.Usage = "Resource-service for managing kubernetes resources"
-	app.Flags = flags
+	app.Flags = append(flags, callPolicyFlags("kube", "KUBE_API", "kube-api")...)
+	app.Flags = append(app.Flags, callPolicyFlags("permissions", "PERMISSIONS", "permissions service")...)
 
 	fmt.Printf("Starting %v %v\n", app.Name, app.Version)
 
@@ -12,15 +12,15 @@ import (
 	"fmt"
 	"text/tabwriter"
 
+	"git.containerum.net/ch/resource-service/pkg/db"
 	"git.containerum.net/ch/resource-service/pkg/router"
 	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server/impl"
 	"git.containerum.net/ch/resource-service/pkg/util/validation"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
-const dbversion = "1.2"
-
 func initServer(c *cli.Context) error {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent|tabwriter.Debug)
 	for _, f := range c.GlobalFlagNames() {
@@ -30,25 +30,89 @@ func initServer(c *cli.Context) error {
 
 	setupLogs(c)
 
+	if migrationRequested(c) {
+		return migrateStorage(c)
+	}
+
 	translate := setupTranslator()
 	validate := validation.StandardResourceValidator(translate)
 
 	tv := &m.TranslateValidate{UniversalTranslator: translate, Validate: validate}
 
-	mongo, err := setupMongo(c)
+	storage, err := setupStorage(c)
 	exitOnError(err)
 
-	err = mongo.Init(dbversion, c.Bool("force"))
-	exitOnError(err)
+	defer storage.Close()
 
-	defer mongo.Close()
+	// all writes go through event storage, so watchers see changes made by journal, trash and acme too
+	events := db.NewEventStorage(storage, db.NewEventBus(c.Int("watch_history")))
+	storage = events
 
 	kube, err := setupKube(c)
 	exitOnError(err)
 
 	permissions := setupPermissions(c)
 
-	app := router.CreateRouter(mongo, permissions, kube, tv, c.Bool("cors"))
+	dns, err := setupDNS(c)
+	exitOnError(err)
+
+	ingressSuffixes, err := setupIngressSuffixes(c)
+	exitOnError(err)
+
+	acme, err := setupACME(c, storage, kube)
+	exitOnError(err)
+
+	staleAfter, err := impl.OperationStaleAfter(setupCallPolicy(c, "kube"))
+	exitOnError(err)
+	journal := impl.NewJournalActionsImpl(storage, kube, staleAfter)
+	journalCtx, stopJournal := context.WithCancel(context.Background())
+	defer stopJournal()
+	go journal.Run(journalCtx, c.Duration("journal_check_period"))
+
+	trash := impl.NewTrashActionsImpl(storage, permissions, kube)
+	trashCtx, stopTrash := context.WithCancel(context.Background())
+	defer stopTrash()
+	go trash.RunPurge(trashCtx, c.Duration("trash_purge_period"), c.Duration("trash_retention"))
+
+	drift := impl.NewDriftActionsImpl(storage, kube)
+	if period := c.Duration("drift_check_period"); period > 0 {
+		driftCtx, stopDrift := context.WithCancel(context.Background())
+		defer stopDrift()
+		go drift.Run(driftCtx, period)
+	}
+
+	if period := c.Duration("reconcile_period"); period > 0 {
+		reconciler := impl.NewReconcileActionsImpl(storage, kube, impl.ReconcileOptions{
+			ExcludedNamespaces: c.StringSlice("reconcile_exclude"),
+			RepairLimit:        c.Int("reconcile_limit"),
+			Backoff:            c.Duration("reconcile_backoff"),
+			MaxBackoff:         c.Duration("reconcile_max_backoff"),
+		})
+		reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+		defer stopReconcile()
+		go reconciler.Run(reconcileCtx, period)
+	}
+
+	app := router.CreateRouter(storage, permissions, kube, dns, acme, ingressSuffixes, events, drift, tv, c.Bool("cors"))
+
+	if acme != nil {
+		acmeCtx, stopACME := context.WithCancel(context.Background())
+		defer stopACME()
+		go acme.Run(acmeCtx, c.Duration("acme_check_period"))
+
+		if addr := c.String("acme_http_addr"); addr != "" {
+			acmeSrv := &http.Server{
+				Addr:    addr,
+				Handler: router.CreateACMEChallengeRouter(acme, tv),
+			}
+			go func() {
+				if err := acmeSrv.ListenAndServe(); err != http.ErrServerClosed {
+					exitOnError(err)
+				}
+			}()
+			defer acmeSrv.Close()
+		}
+	}
 
 	srv := &http.Server{
 		Addr:    ":" + c.String("port"),
@@ -0,0 +1,282 @@
+package clients
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+)
+
+// ACMEChallengeResponder publishes HTTP-01 challenge responses while authorization is pending
+type ACMEChallengeResponder interface {
+	Present(ctx context.Context, host, token, keyAuth string) error
+	CleanUp(ctx context.Context, token string) error
+}
+
+// ACME is an interface to ACME certificate authority
+type ACME interface {
+	// ObtainCertificate orders certificate for hosts using HTTP-01 challenges and returns PEM encoded chain and private key
+	ObtainCertificate(ctx context.Context, hosts []string, responder ACMEChallengeResponder) (certPEM, keyPEM string, err error)
+}
+
+// ACMEConfig -- ACME client configuration
+type ACMEConfig struct {
+	// DirectoryURL is ACME directory, e.g. https://acme-v02.api.letsencrypt.org/directory
+	DirectoryURL string
+	// Email is used as account contact, optional
+	Email string
+	// AccountKey signs account requests. If nil new ECDSA key is generated.
+	AccountKey crypto.Signer
+	// CACert is PEM bundle trusted for directory TLS connections in addition to system roots, e.g. Pebble test CA
+	CACert []byte
+}
+
+type acmeClient struct {
+	client *acme.Client
+	log    *logrus.Entry
+}
+
+// NewACMEHTTP creates ACME client and registers account in certificate authority
+func NewACMEHTTP(ctx context.Context, cfg ACMEConfig) (ACME, error) {
+	var log = logrus.WithField("component", "acme_client")
+
+	var key = cfg.AccountKey
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	var httpClient = &http.Client{Timeout: 30 * time.Second}
+	if len(cfg.CACert) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(cfg.CACert) {
+			return nil, errors.New("unable to parse ACME directory CA certificate")
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}
+	}
+
+	var client = &acme.Client{
+		Key:          key,
+		DirectoryURL: cfg.DirectoryURL,
+		HTTPClient:   httpClient,
+		UserAgent:    "resource-service",
+	}
+
+	var account = &acme.Account{}
+	if cfg.Email != "" {
+		account.Contact = []string{"mailto:" + cfg.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, fmt.Errorf("unable to register ACME account: %v", err)
+	}
+
+	return acmeClient{
+		client: client,
+		log:    log,
+	}, nil
+}
+
+// ParseACMEAccountKey reads PEM encoded EC or RSA private key from file
+func ParseACMEAccountKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseACMEAccountKeyPEM(data)
+}
+
+// GenerateACMEAccountKey generates new ECDSA account key and returns it PEM encoded
+func GenerateACMEAccountKey() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseACMEAccountKeyPEM parses PEM encoded EC or RSA private key
+func ParseACMEAccountKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data in ACME account key file")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported ACME account key type")
+	}
+	return signer, nil
+}
+
+func (client acmeClient) ObtainCertificate(ctx context.Context, hosts []string, responder ACMEChallengeResponder) (string, string, error) {
+	client.log.WithField("hosts", hosts).Debug("obtain certificate")
+
+	order, err := client.client.AuthorizeOrder(ctx, acme.DomainIDs(hosts...))
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := client.authorize(ctx, authzURL, responder); err != nil {
+			return "", "", err
+		}
+	}
+
+	if order, err = client.client.WaitOrder(ctx, order.URI); err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: hosts[0]},
+		DNSNames: hosts,
+	}, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	chain, _, err := client.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", err
+	}
+
+	return encodeCertificate(chain, key)
+}
+
+func (client acmeClient) authorize(ctx context.Context, authzURL string, responder ACMEChallengeResponder) error {
+	authz, err := client.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	keyAuth, err := client.client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	if err := responder.Present(ctx, authz.Identifier.Value, challenge.Token, keyAuth); err != nil {
+		return err
+	}
+	defer func() {
+		if err := responder.CleanUp(ctx, challenge.Token); err != nil {
+			client.log.WithError(err).Warn("unable to clean up http-01 challenge")
+		}
+	}()
+
+	if _, err := client.client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	_, err = client.client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+func (client acmeClient) String() string {
+	return fmt.Sprintf("acme client for %s", client.client.DirectoryURL)
+}
+
+func encodeCertificate(chain [][]byte, key *ecdsa.PrivateKey) (string, string, error) {
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM), nil
+}
+
+// Dummy implementation
+
+type acmeDummy struct {
+	log *logrus.Entry
+}
+
+// NewDummyACME creates ACME stand-in which issues self-signed certificates without any challenges.
+func NewDummyACME() ACME {
+	return acmeDummy{log: logrus.WithField("component", "acme_stub")}
+}
+
+func (client acmeDummy) ObtainCertificate(ctx context.Context, hosts []string, _ ACMEChallengeResponder) (string, string, error) {
+	client.log.WithField("hosts", hosts).Debug("obtain certificate")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	var now = time.Now()
+	var template = &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return "", "", err
+	}
+	return encodeCertificate([][]byte{der}, key)
+}
+
+func (acmeDummy) String() string {
+	return "acme dummy"
+}
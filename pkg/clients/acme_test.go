@@ -0,0 +1,86 @@
+package clients
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testResponder struct {
+	mu        sync.Mutex
+	responses map[string]string
+}
+
+func (r *testResponder) Present(_ context.Context, _, token, keyAuth string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[token] = keyAuth
+	return nil
+}
+
+func (r *testResponder) CleanUp(_ context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.responses, token)
+	return nil
+}
+
+func (r *testResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keyAuth, ok := r.responses[strings.TrimPrefix(req.URL.Path, "/.well-known/acme-challenge/")]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Write([]byte(keyAuth))
+}
+
+func TestDummyACME(t *testing.T) {
+	certPEM, keyPEM, err := NewDummyACME().ObtainCertificate(context.Background(), []string{"test.hub.containerum.io"}, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, certPEM, "BEGIN CERTIFICATE")
+	assert.Contains(t, keyPEM, "BEGIN EC PRIVATE KEY")
+}
+
+// TestACMEPebble runs against local Pebble started with "pebble -config test/config/pebble-config.json",
+// CH_RESOURCE_TEST_ACME_DIRECTORY=https://localhost:14000/dir and CH_RESOURCE_TEST_ACME_CA_CERT=test/certs/pebble.minica.pem.
+// Pebble must resolve test host to this machine, e.g. with PEBBLE_VA_ALWAYS_VALID=1.
+func TestACMEPebble(t *testing.T) {
+	directory := os.Getenv("CH_RESOURCE_TEST_ACME_DIRECTORY")
+	if directory == "" {
+		t.Skip("CH_RESOURCE_TEST_ACME_DIRECTORY is not set")
+	}
+
+	cfg := ACMEConfig{DirectoryURL: directory}
+	if caFile := os.Getenv("CH_RESOURCE_TEST_ACME_CA_CERT"); caFile != "" {
+		var err error
+		cfg.CACert, err = ioutil.ReadFile(caFile)
+		assert.NoError(t, err)
+	}
+
+	responder := &testResponder{responses: make(map[string]string)}
+	srv := &http.Server{Addr: ":5002", Handler: responder}
+	go srv.ListenAndServe()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client, err := NewACMEHTTP(ctx, cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	certPEM, keyPEM, err := client.ObtainCertificate(ctx, []string{"test.example.com"}, responder)
+	assert.NoError(t, err)
+	assert.Contains(t, certPEM, "BEGIN CERTIFICATE")
+	assert.Contains(t, keyPEM, "BEGIN EC PRIVATE KEY")
+}
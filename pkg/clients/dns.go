@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DNS is an interface to dns resolver used to verify domain ownership
+type DNS interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type dnsResolver struct {
+	resolver *net.Resolver
+	log      *logrus.Entry
+}
+
+// NewDNSResolver creates dns resolver. If server address is empty system resolver is used.
+func NewDNSResolver(server string) DNS {
+	var resolver = &net.Resolver{}
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resolver.PreferGo = true
+		resolver.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer = net.Dialer{Timeout: 5 * time.Second}
+			return dialer.DialContext(ctx, network, server)
+		}
+	}
+	return dnsResolver{
+		resolver: resolver,
+		log:      logrus.WithField("component", "dns_resolver"),
+	}
+}
+
+func (dns dnsResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	dns.log.WithField("name", name).Debug("lookup TXT record")
+	return dns.resolver.LookupTXT(ctx, name)
+}
+
+func (dnsResolver) String() string {
+	return "dns resolver"
+}
+
+// Dummy implementation
+
+type dnsDummy struct {
+	records map[string][]string
+	log     *logrus.Entry
+}
+
+// NewDummyDNS creates local dns stand-in which resolves TXT records from provided map.
+func NewDummyDNS(records map[string][]string) DNS {
+	var normalized = make(map[string][]string, len(records))
+	for name, values := range records {
+		normalized[strings.TrimSuffix(strings.ToLower(name), ".")] = values
+	}
+	return dnsDummy{
+		records: normalized,
+		log:     logrus.WithField("component", "dns_stub"),
+	}
+}
+
+func (dns dnsDummy) LookupTXT(_ context.Context, name string) ([]string, error) {
+	dns.log.WithField("name", name).Debug("lookup TXT record")
+
+	values, ok := dns.records[strings.TrimSuffix(strings.ToLower(name), ".")]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name}
+	}
+	return values, nil
+}
+
+func (dnsDummy) String() string {
+	return "dns dummy"
+}
+
+// VerifyTXT checks that one of TXT records with given name contains token
+func VerifyTXT(ctx context.Context, dns DNS, name, token string) error {
+	values, err := dns.LookupTXT(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if strings.TrimSpace(value) == token {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT record %s does not contain verification token", name)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"net/url"
 
-	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	"github.com/containerum/cherry"
 	"github.com/containerum/cherry/adaptors/cherrylog"
 	kubtypes "github.com/containerum/kube-client/pkg/model"
@@ -23,8 +23,8 @@ type Kube interface {
 	SetDeploymentReplicas(ctx context.Context, nsID, deplName string, replicas int) error
 	SetContainerImage(ctx context.Context, nsID, deplName string, container kubtypes.UpdateImage) error
 
-	CreateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error
-	UpdateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error
+	CreateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error
+	UpdateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error
 	DeleteIngress(ctx context.Context, nsID, ingressName string) error
 
 	CreateSecret(ctx context.Context, nsID string, secret kubtypes.Secret) error
@@ -33,6 +33,10 @@ type Kube interface {
 	CreateService(ctx context.Context, nsID string, service kubtypes.Service) error
 	UpdateService(ctx context.Context, nsID string, service kubtypes.Service) error
 	DeleteService(ctx context.Context, nsID, serviceName string) error
+
+	GetDeploymentList(ctx context.Context, nsID string) ([]kubtypes.Deployment, error)
+	GetServiceList(ctx context.Context, nsID string) ([]kubtypes.Service, error)
+	GetIngressList(ctx context.Context, nsID string) ([]ingress.KubeIngress, error)
 }
 
 type kube struct {
@@ -41,7 +45,8 @@ type kube struct {
 }
 
 // NewKubeHTTP creates http client to kube-api service.
-func NewKubeHTTP(u *url.URL) Kube {
+// Requests are retried and rejected by circuit breaker according to policy.
+func NewKubeHTTP(u *url.URL, policy CallPolicy) Kube {
 	log := logrus.WithField("component", "kube_client")
 	client := resty.New().
 		SetHostURL(u.String()).
@@ -52,6 +57,7 @@ func NewKubeHTTP(u *url.URL) Kube {
 		SetHeader("Accept", "application/json")
 	client.JSONMarshal = jsoniter.Marshal
 	client.JSONUnmarshal = jsoniter.Unmarshal
+	withCallPolicy(client, policy, log)
 	return kube{
 		client: client,
 		log:    cherrylog.NewLogrusAdapter(log),
@@ -67,7 +73,7 @@ func (kub kube) CreateDeployment(ctx context.Context, nsID string, deploy kubtyp
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
 		Post(fmt.Sprintf("/namespaces/%s/deployments", nsID))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -86,7 +92,7 @@ func (kub kube) DeleteDeployment(ctx context.Context, nsID, deplName string) err
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
 		Delete(fmt.Sprintf("/namespaces/%s/deployments/%s", nsID, deplName))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -105,7 +111,7 @@ func (kub kube) UpdateDeployment(ctx context.Context, nsID string, deploy kubtyp
 		SetBody(deploy).
 		Put(fmt.Sprintf("/namespaces/%s/deployments/%s", nsID, deploy.Name))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -126,7 +132,7 @@ func (kub kube) SetDeploymentReplicas(ctx context.Context, nsID, deplName string
 		SetBody(kubtypes.UpdateReplicas{Replicas: replicas}).
 		Put(fmt.Sprintf("/namespaces/%s/deployments/%s/replicas", nsID, deplName))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -148,7 +154,7 @@ func (kub kube) SetContainerImage(ctx context.Context, nsID, deplName string, co
 		SetBody(container).
 		Put(fmt.Sprintf("/namespaces/%s/deployments/%s/image", nsID, deplName))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -156,18 +162,18 @@ func (kub kube) SetContainerImage(ctx context.Context, nsID, deplName string, co
 	return nil
 }
 
-func (kub kube) CreateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error {
+func (kub kube) CreateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
 	kub.log.WithFields(logrus.Fields{
 		"ns_id": nsID,
-	}).Debugf("create ingress %+v", ingress)
+	}).Debugf("create ingress %+v", ingr)
 
 	resp, err := kub.client.R().
 		SetContext(ctx).
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
-		SetBody(ingress).
+		SetBody(ingr).
 		Post(fmt.Sprintf("/namespaces/%s/ingresses", nsID))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -175,19 +181,19 @@ func (kub kube) CreateIngress(ctx context.Context, nsID string, ingress kubtypes
 	return nil
 }
 
-func (kub kube) UpdateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error {
+func (kub kube) UpdateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
 	kub.log.WithFields(logrus.Fields{
 		"ns_id":        nsID,
-		"ingress_name": ingress.Name,
-	}).Debugf("update ingress to %+v", ingress)
+		"ingress_name": ingr.Name,
+	}).Debugf("update ingress to %+v", ingr)
 
 	resp, err := kub.client.R().
 		SetContext(ctx).
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
-		SetBody(ingress).
-		Put(fmt.Sprintf("/namespaces/%s/ingresses/%s", nsID, ingress.Name))
+		SetBody(ingr).
+		Put(fmt.Sprintf("/namespaces/%s/ingresses/%s", nsID, ingr.Name))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -206,7 +212,7 @@ func (kub kube) DeleteIngress(ctx context.Context, nsID, ingressName string) err
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
 		Delete(fmt.Sprintf("/namespaces/%s/ingresses/%s", nsID, ingressName))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -225,7 +231,7 @@ func (kub kube) CreateSecret(ctx context.Context, nsID string, secret kubtypes.S
 		SetBody(secret).
 		Post(fmt.Sprintf("/namespaces/%s/secrets", nsID))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -244,7 +250,7 @@ func (kub kube) DeleteSecret(ctx context.Context, nsID, secretName string) error
 		SetHeaders(httputil.RequestXHeadersMap(ctx)).
 		Delete(fmt.Sprintf("/namespaces/%s/secrets/%s", nsID, secretName))
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -263,7 +269,7 @@ func (kub kube) CreateService(ctx context.Context, nsID string, service kubtypes
 		Post(fmt.Sprintf("/namespaces/%s/services", nsID))
 
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -285,7 +291,7 @@ func (kub kube) UpdateService(ctx context.Context, nsID string, service kubtypes
 		Put(fmt.Sprintf("/namespaces/%s/services/%s", nsID, service.Name))
 
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -306,7 +312,7 @@ func (kub kube) DeleteService(ctx context.Context, nsID, serviceName string) err
 		Delete(fmt.Sprintf("/namespaces/%s/services/%s", nsID, serviceName))
 
 	if err != nil {
-		return rserrors.ErrInternal().Log(err, kub.log)
+		return requestError(err, "kube-api", kub.log)
 	}
 	if resp.Error() != nil {
 		return resp.Error().(*cherry.Err)
@@ -315,6 +321,65 @@ func (kub kube) DeleteService(ctx context.Context, nsID, serviceName string) err
 	return nil
 }
 
+func (kub kube) GetDeploymentList(ctx context.Context, nsID string) ([]kubtypes.Deployment, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get deployment list")
+
+	var list kubtypes.DeploymentsList
+	resp, err := kub.client.R().
+		SetContext(ctx).
+		SetHeaders(httputil.RequestXHeadersMap(ctx)).
+		SetResult(&list).
+		Get(fmt.Sprintf("/namespaces/%s/deployments", nsID))
+	if err != nil {
+		return nil, requestError(err, "kube-api", kub.log)
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error().(*cherry.Err)
+	}
+	return list.Deployments, nil
+}
+
+func (kub kube) GetServiceList(ctx context.Context, nsID string) ([]kubtypes.Service, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get service list")
+
+	var list kubtypes.ServicesList
+	resp, err := kub.client.R().
+		SetContext(ctx).
+		SetHeaders(httputil.RequestXHeadersMap(ctx)).
+		SetResult(&list).
+		Get(fmt.Sprintf("/namespaces/%s/services", nsID))
+	if err != nil {
+		return nil, requestError(err, "kube-api", kub.log)
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error().(*cherry.Err)
+	}
+	return list.Services, nil
+}
+
+// kubeIngressList -- ingresses list returned by kube-api, ingresses have annotations
+type kubeIngressList struct {
+	Ingresses []ingress.KubeIngress `json:"ingresses"`
+}
+
+func (kub kube) GetIngressList(ctx context.Context, nsID string) ([]ingress.KubeIngress, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get ingress list")
+
+	var list kubeIngressList
+	resp, err := kub.client.R().
+		SetContext(ctx).
+		SetHeaders(httputil.RequestXHeadersMap(ctx)).
+		SetResult(&list).
+		Get(fmt.Sprintf("/namespaces/%s/ingresses", nsID))
+	if err != nil {
+		return nil, requestError(err, "kube-api", kub.log)
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error().(*cherry.Err)
+	}
+	return list.Ingresses, nil
+}
+
 func (kub kube) String() string {
 	return fmt.Sprintf("kube api http client: url=%v", kub.client.HostURL)
 }
@@ -331,7 +396,7 @@ func NewDummyKube() Kube {
 }
 
 func (kub kubeDummy) CreateDeployment(_ context.Context, nsID string, deploy kubtypes.Deployment) error {
-	kub.log.WithField("ns_id", nsID).Debug("create deployment %+v", deploy)
+	kub.log.WithField("ns_id", nsID).Debugf("create deployment %+v", deploy)
 
 	return nil
 }
@@ -353,15 +418,6 @@ func (kub kubeDummy) DeleteDeployment(ctx context.Context, nsID, deplName string
 	return nil
 }
 
-func (kub kubeDummy) ReplaceDeployment(ctx context.Context, nsID string, deploy kubtypes.Deployment) error {
-	kub.log.WithFields(logrus.Fields{
-		"ns_id":       nsID,
-		"deploy_name": deploy.Name,
-	}).Debugf("replace deployment %+v", deploy)
-
-	return nil
-}
-
 func (kub kubeDummy) SetDeploymentReplicas(ctx context.Context, nsID, deplName string, replicas int) error {
 	kub.log.WithFields(logrus.Fields{
 		"ns_id":       nsID,
@@ -383,19 +439,19 @@ func (kub kubeDummy) SetContainerImage(ctx context.Context, nsID, deplName strin
 	return nil
 }
 
-func (kub kubeDummy) CreateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error {
+func (kub kubeDummy) CreateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
 	kub.log.WithFields(logrus.Fields{
 		"ns_id": nsID,
-	}).Debugf("create ingress %+v", ingress)
+	}).Debugf("create ingress %+v", ingr)
 
 	return nil
 }
 
-func (kub kubeDummy) UpdateIngress(ctx context.Context, nsID string, ingress kubtypes.Ingress) error {
+func (kub kubeDummy) UpdateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
 	kub.log.WithFields(logrus.Fields{
 		"ns_id":        nsID,
-		"ingress_name": ingress.Name,
-	}).Debugf("update ingress to %+v", ingress)
+		"ingress_name": ingr.Name,
+	}).Debugf("update ingress to %+v", ingr)
 
 	return nil
 }
@@ -450,6 +506,24 @@ func (kub kubeDummy) DeleteService(ctx context.Context, nsID, serviceName string
 	return nil
 }
 
+func (kub kubeDummy) GetDeploymentList(ctx context.Context, nsID string) ([]kubtypes.Deployment, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get deployment list")
+
+	return nil, nil
+}
+
+func (kub kubeDummy) GetServiceList(ctx context.Context, nsID string) ([]kubtypes.Service, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get service list")
+
+	return nil, nil
+}
+
+func (kub kubeDummy) GetIngressList(ctx context.Context, nsID string) ([]ingress.KubeIngress, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get ingress list")
+
+	return nil, nil
+}
+
 func (kubeDummy) String() string {
 	return "kube api dummy"
 }
@@ -0,0 +1,356 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+// kubeDirect -- client to kubernetes API without kube-api service.
+// Resources of namespace are stored in kubernetes namespace with the same name.
+type kubeDirect struct {
+	client kubernetes.Interface
+	host   string
+	log    *cherrylog.LogrusAdapter
+}
+
+// KubeRESTConfig loads config of kubernetes API client from kubeconfig file.
+// Context of kubeconfig is current one if empty. In-cluster config is used if kubeconfig path is empty.
+func KubeRESTConfig(kubeconfig, context string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+}
+
+// NewKubeDirect creates client to kubernetes API.
+// Requests are retried and rejected by circuit breaker according to policy.
+func NewKubeDirect(config *rest.Config, policy CallPolicy) (Kube, error) {
+	log := logrus.WithField("component", "kube_direct_client")
+	config = rest.CopyConfig(config)
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return newPolicyTransport(next, policy, log)
+	})
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return kubeDirect{
+		client: client,
+		host:   config.Host,
+		log:    cherrylog.NewLogrusAdapter(log),
+	}, nil
+}
+
+// NewKubeClientset creates client to kubernetes API using clientset, e.g. fake one in tests
+func NewKubeClientset(client kubernetes.Interface) Kube {
+	return kubeDirect{
+		client: client,
+		log:    cherrylog.NewLogrusAdapter(logrus.WithField("component", "kube_direct_client")),
+	}
+}
+
+// directError converts error of kubernetes API to error like kube-api service returns
+func (kub kubeDirect) directError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errCircuitOpen):
+		// client-go wraps transport errors, so circuit breaker rejection is not a plain url.Error
+		return rserrors.ErrServiceUnavailable().AddDetails("kubernetes API is unavailable, try again later")
+	case apierrors.IsNotFound(err):
+		return rserrors.ErrResourceNotExists().AddDetailsErr(err)
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		return rserrors.ErrResourceAlreadyExists().AddDetailsErr(err)
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return rserrors.ErrValidation().AddDetailsErr(err)
+	case apierrors.IsServiceUnavailable(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err):
+		return rserrors.ErrServiceUnavailable().AddDetails("kubernetes API is unavailable, try again later")
+	default:
+		return requestError(err, "kubernetes API", kub.log)
+	}
+}
+
+// directSplitsError rejects ingress with traffic splits, weighted routing needs ingress controller specific objects
+func directSplitsError(ingr ingress.KubeIngress) error {
+	if len(ingr.Splits) > 0 {
+		return rserrors.ErrValidation().AddDetailF("ingress %s: traffic splits are not supported by direct kubernetes API client", ingr.Name)
+	}
+	return nil
+}
+
+func (kub kubeDirect) CreateDeployment(ctx context.Context, nsID string, deploy kubtypes.Deployment) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create deployment %+v", deploy)
+
+	_, err := kub.client.AppsV1().Deployments(nsID).Create(ctx, deploymentToDirect(nsID, deploy), metav1.CreateOptions{})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) DeleteDeployment(ctx context.Context, nsID, deplName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+	}).Debug("delete deployment")
+
+	var propagation = metav1.DeletePropagationBackground
+	err := kub.client.AppsV1().Deployments(nsID).Delete(ctx, deplName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	return kub.directError(err)
+}
+
+// UpdateDeployment replaces pod template and replicas of deployment, selector can't be changed
+func (kub kubeDirect) UpdateDeployment(ctx context.Context, nsID string, deploy kubtypes.Deployment) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id": nsID,
+	}).Debugf("update deployment %+v", deploy)
+
+	var desired = deploymentToDirect(nsID, deploy)
+	var deployments = kub.client.AppsV1().Deployments(nsID)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := deployments.Get(ctx, deploy.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Labels = mergeStrings(current.Labels, desired.Labels)
+		current.Annotations = mergeStrings(current.Annotations, desired.Annotations)
+		current.Spec.Replicas = desired.Spec.Replicas
+		current.Spec.Template.Labels = mergeStrings(current.Spec.Template.Labels, desired.Spec.Template.Labels)
+		current.Spec.Template.Spec.Containers = desired.Spec.Template.Spec.Containers
+		current.Spec.Template.Spec.Volumes = desired.Spec.Template.Spec.Volumes
+		_, err = deployments.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) SetDeploymentReplicas(ctx context.Context, nsID, deplName string, replicas int) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+		"replicas":    replicas,
+	}).Debug("change replicas")
+
+	var deployments = kub.client.AppsV1().Deployments(nsID)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := deployments.Get(ctx, deplName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var count = int32(replicas)
+		current.Spec.Replicas = &count
+		_, err = deployments.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) SetContainerImage(ctx context.Context, nsID, deplName string, container kubtypes.UpdateImage) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+		"container":   container.Container,
+		"image":       container.Image,
+	}).Debug("set container image")
+
+	var deployments = kub.client.AppsV1().Deployments(nsID)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := deployments.Get(ctx, deplName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var containers = current.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == container.Container {
+				containers[i].Image = container.Image
+				_, err = deployments.Update(ctx, current, metav1.UpdateOptions{})
+				return err
+			}
+		}
+		return rserrors.ErrResourceNotExists().AddDetailF("container %s not found", container.Container)
+	})
+	if _, ok := err.(*cherry.Err); ok {
+		return err
+	}
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) CreateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id": nsID,
+	}).Debugf("create ingress %+v", ingr)
+
+	if err := directSplitsError(ingr); err != nil {
+		return err
+	}
+	_, err := kub.client.NetworkingV1().Ingresses(nsID).Create(ctx, ingressToDirect(nsID, ingr), metav1.CreateOptions{})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) UpdateIngress(ctx context.Context, nsID string, ingr ingress.KubeIngress) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"ingress_name": ingr.Name,
+	}).Debugf("update ingress to %+v", ingr)
+
+	if err := directSplitsError(ingr); err != nil {
+		return err
+	}
+	var desired = ingressToDirect(nsID, ingr)
+	var ingresses = kub.client.NetworkingV1().Ingresses(nsID)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := ingresses.Get(ctx, ingr.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Labels = mergeStrings(current.Labels, desired.Labels)
+		// annotations are options of ingress, so they are replaced
+		current.Annotations = desired.Annotations
+		current.Spec.Rules = desired.Spec.Rules
+		current.Spec.TLS = desired.Spec.TLS
+		_, err = ingresses.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) DeleteIngress(ctx context.Context, nsID, ingressName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"ingress_name": ingressName,
+	}).Debug("delete ingress")
+
+	err := kub.client.NetworkingV1().Ingresses(nsID).Delete(ctx, ingressName, metav1.DeleteOptions{})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) CreateSecret(ctx context.Context, nsID string, secret kubtypes.Secret) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id": nsID,
+	}).Debugf("create secret %s", secret.Name)
+
+	_, err := kub.client.CoreV1().Secrets(nsID).Create(ctx, secretToDirect(nsID, secret), metav1.CreateOptions{})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) DeleteSecret(ctx context.Context, nsID, secretName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"secret_name": secretName,
+	}).Debug("delete secret")
+
+	err := kub.client.CoreV1().Secrets(nsID).Delete(ctx, secretName, metav1.DeleteOptions{})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) CreateService(ctx context.Context, nsID string, service kubtypes.Service) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create service %+v", service)
+
+	var converted = serviceToDirect(nsID, service)
+	converted.Annotations = mergeStrings(nil, converted.Annotations)
+	_, err := kub.client.CoreV1().Services(nsID).Create(ctx, converted, metav1.CreateOptions{})
+	return kub.directError(err)
+}
+
+// UpdateService replaces selector, ports and external IPs of service, cluster IP is kept
+func (kub kubeDirect) UpdateService(ctx context.Context, nsID string, service kubtypes.Service) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"service_name": service.Name,
+	}).Debugf("update service to %+v", service)
+
+	var desired = serviceToDirect(nsID, service)
+	var services = kub.client.CoreV1().Services(nsID)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := services.Get(ctx, service.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Labels = mergeStrings(current.Labels, desired.Labels)
+		current.Annotations = mergeStrings(current.Annotations, desired.Annotations)
+		current.Spec.Selector = desired.Spec.Selector
+		current.Spec.Ports = desired.Spec.Ports
+		current.Spec.ExternalIPs = desired.Spec.ExternalIPs
+		_, err = services.Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	return kub.directError(err)
+}
+
+func (kub kubeDirect) DeleteService(ctx context.Context, nsID, serviceName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"service_name": serviceName,
+	}).Debug("delete service")
+
+	err := kub.client.CoreV1().Services(nsID).Delete(ctx, serviceName, metav1.DeleteOptions{})
+	return kub.directError(err)
+}
+
+// GetDeploymentList returns namespace deployments sorted by name
+func (kub kubeDirect) GetDeploymentList(ctx context.Context, nsID string) ([]kubtypes.Deployment, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get deployment list")
+
+	list, err := kub.client.AppsV1().Deployments(nsID).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, kub.directError(err)
+	}
+	var ret = make([]kubtypes.Deployment, 0, len(list.Items))
+	for _, deploy := range list.Items {
+		ret = append(ret, deploymentFromDirect(deploy))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// GetServiceList returns namespace services sorted by name
+func (kub kubeDirect) GetServiceList(ctx context.Context, nsID string) ([]kubtypes.Service, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get service list")
+
+	list, err := kub.client.CoreV1().Services(nsID).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, kub.directError(err)
+	}
+	var ret = make([]kubtypes.Service, 0, len(list.Items))
+	for _, svc := range list.Items {
+		ret = append(ret, serviceFromDirect(svc))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// GetIngressList returns namespace ingresses sorted by name
+func (kub kubeDirect) GetIngressList(ctx context.Context, nsID string) ([]ingress.KubeIngress, error) {
+	kub.log.WithField("ns_id", nsID).Debug("get ingress list")
+
+	list, err := kub.client.NetworkingV1().Ingresses(nsID).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, kub.directError(err)
+	}
+	var ret = make([]ingress.KubeIngress, 0, len(list.Items))
+	for _, ingr := range list.Items {
+		ret = append(ret, ingressFromDirect(ingr))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+func (kub kubeDirect) String() string {
+	return fmt.Sprintf("kube direct client: host=%v", kub.host)
+}
@@ -0,0 +1,447 @@
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"github.com/blang/semver"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// DirectAppLabel -- label selecting pods of deployment, services select deployment by this label
+	DirectAppLabel = "app"
+	// DirectOwnerLabel -- label with ID of resource owner
+	DirectOwnerLabel = "owner"
+
+	// annotations keeping fields which have no place in kubernetes objects
+	directAnnotationPrefix = "resource-service/"
+	directVersionKey       = directAnnotationPrefix + "version"
+	directDomainKey        = directAnnotationPrefix + "domain"
+	// names of service ports created without port number, port number is equal to target port for them
+	directUnnumberedPortsKey = directAnnotationPrefix + "unnumbered-ports"
+
+	directConfigMapVolumePrefix = "configmap-"
+	// mode of config map volume files set by kubernetes if it is not specified
+	directDefaultConfigMapMode = 0644
+	mebibyte                   = 1 << 20
+)
+
+func directObjectMeta(nsID, name, owner string) metav1.ObjectMeta {
+	var meta = metav1.ObjectMeta{
+		Name:      name,
+		Namespace: nsID,
+		Labels:    map[string]string{},
+	}
+	if owner != "" {
+		meta.Labels[DirectOwnerLabel] = owner
+	}
+	return meta
+}
+
+func directCreatedAt(meta metav1.ObjectMeta) *string {
+	if meta.CreationTimestamp.IsZero() {
+		return nil
+	}
+	var createdAt = meta.CreationTimestamp.UTC().Format(time.RFC3339)
+	return &createdAt
+}
+
+// mergeStrings sets keys of src in dst, keys with empty values are removed
+func mergeStrings(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for key, value := range src {
+		if value == "" {
+			delete(dst, key)
+		} else {
+			dst[key] = value
+		}
+	}
+	return dst
+}
+
+func deploymentToDirect(nsID string, deploy kubtypes.Deployment) *appsv1.Deployment {
+	var replicas = int32(deploy.Replicas)
+	var labels = map[string]string{DirectAppLabel: deploy.Name}
+	var meta = directObjectMeta(nsID, deploy.Name, deploy.Owner)
+	meta.Labels[DirectAppLabel] = deploy.Name
+	meta.Annotations = map[string]string{directVersionKey: deploy.Version.String()}
+
+	var spec corev1.PodSpec
+	var volumes = make(map[string]corev1.Volume)
+	for _, container := range deploy.Containers {
+		spec.Containers = append(spec.Containers, containerToDirect(container, volumes))
+	}
+	for _, volume := range volumes {
+		spec.Volumes = append(spec.Volumes, volume)
+	}
+	sort.Slice(spec.Volumes, func(i, j int) bool { return spec.Volumes[i].Name < spec.Volumes[j].Name })
+
+	return &appsv1.Deployment{
+		ObjectMeta: meta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       spec,
+			},
+		},
+	}
+}
+
+// containerToDirect converts container, volumes used by container are added to volumes map.
+// Requests are equal to limits like in platform namespaces.
+func containerToDirect(container kubtypes.Container, volumes map[string]corev1.Volume) corev1.Container {
+	var ret = corev1.Container{
+		Name:    container.Name,
+		Image:   container.Image,
+		Command: append([]string(nil), container.Commands...),
+	}
+	if container.Limits.CPU > 0 || container.Limits.Memory > 0 {
+		var limits = corev1.ResourceList{}
+		if container.Limits.CPU > 0 {
+			limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(container.Limits.CPU), resource.DecimalSI)
+		}
+		if container.Limits.Memory > 0 {
+			limits[corev1.ResourceMemory] = *resource.NewQuantity(int64(container.Limits.Memory)*mebibyte, resource.BinarySI)
+		}
+		ret.Resources = corev1.ResourceRequirements{Limits: limits, Requests: limits.DeepCopy()}
+	}
+	for _, env := range container.Env {
+		ret.Env = append(ret.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	for _, port := range container.Ports {
+		ret.Ports = append(ret.Ports, corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: int32(port.Port),
+			Protocol:      corev1.Protocol(port.Protocol),
+		})
+	}
+	for _, mount := range container.VolumeMounts {
+		var claim = mount.Name
+		if mount.PersistentVolumeClaimName != nil {
+			claim = *mount.PersistentVolumeClaimName
+		}
+		volumes[mount.Name] = corev1.Volume{
+			Name: mount.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			},
+		}
+		ret.VolumeMounts = append(ret.VolumeMounts, volumeMountToDirect(mount.Name, mount))
+	}
+	for _, mount := range container.ConfigMaps {
+		var source = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: mount.Name},
+		}
+		if mount.Mode != nil {
+			if mode, err := strconv.ParseInt(*mount.Mode, 8, 32); err == nil {
+				var defaultMode = int32(mode)
+				source.DefaultMode = &defaultMode
+			}
+		}
+		var name = directConfigMapVolumePrefix + mount.Name
+		volumes[name] = corev1.Volume{
+			Name:         name,
+			VolumeSource: corev1.VolumeSource{ConfigMap: source},
+		}
+		ret.VolumeMounts = append(ret.VolumeMounts, volumeMountToDirect(name, mount))
+	}
+	return ret
+}
+
+func volumeMountToDirect(volume string, mount kubtypes.ContainerVolume) corev1.VolumeMount {
+	var ret = corev1.VolumeMount{
+		Name:      volume,
+		MountPath: mount.MountPath,
+	}
+	if mount.SubPath != nil {
+		ret.SubPath = *mount.SubPath
+	}
+	return ret
+}
+
+func deploymentFromDirect(deploy appsv1.Deployment) kubtypes.Deployment {
+	var ret = kubtypes.Deployment{
+		Name:   deploy.Name,
+		Owner:  deploy.Labels[DirectOwnerLabel],
+		Active: true,
+		Status: &kubtypes.DeploymentStatus{
+			Replicas:            int(deploy.Status.Replicas),
+			ReadyReplicas:       int(deploy.Status.ReadyReplicas),
+			AvailableReplicas:   int(deploy.Status.AvailableReplicas),
+			UnavailableReplicas: int(deploy.Status.UnavailableReplicas),
+			UpdatedReplicas:     int(deploy.Status.UpdatedReplicas),
+		},
+	}
+	if createdAt := directCreatedAt(deploy.ObjectMeta); createdAt != nil {
+		ret.Status.CreatedAt = *createdAt
+	}
+	// kubernetes defaults replicas to 1
+	ret.Replicas = 1
+	if deploy.Spec.Replicas != nil {
+		ret.Replicas = int(*deploy.Spec.Replicas)
+	}
+	if version, err := semver.Parse(deploy.Annotations[directVersionKey]); err == nil {
+		ret.Version = version
+	}
+
+	var volumes = make(map[string]corev1.Volume, len(deploy.Spec.Template.Spec.Volumes))
+	for _, volume := range deploy.Spec.Template.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	for _, container := range deploy.Spec.Template.Spec.Containers {
+		ret.Containers = append(ret.Containers, containerFromDirect(container, volumes))
+	}
+	return ret
+}
+
+// containerFromDirect converts container, mounts of volumes other than persistent volume claims and config maps are skipped
+func containerFromDirect(container corev1.Container, volumes map[string]corev1.Volume) kubtypes.Container {
+	var ret = kubtypes.Container{
+		Name:     container.Name,
+		Image:    container.Image,
+		Commands: append(append([]string(nil), container.Command...), container.Args...),
+	}
+	if len(ret.Commands) == 0 {
+		ret.Commands = nil
+	}
+	if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		ret.Limits.CPU = uint(cpu.MilliValue())
+	}
+	if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		ret.Limits.Memory = uint((memory.Value() + mebibyte - 1) / mebibyte)
+	}
+	for _, env := range container.Env {
+		ret.Env = append(ret.Env, kubtypes.Env{Name: env.Name, Value: env.Value})
+	}
+	for _, port := range container.Ports {
+		var protocol = kubtypes.Protocol(port.Protocol)
+		if protocol == "" {
+			protocol = kubtypes.TCP
+		}
+		ret.Ports = append(ret.Ports, kubtypes.ContainerPort{
+			Name:     port.Name,
+			Port:     int(port.ContainerPort),
+			Protocol: protocol,
+		})
+	}
+	for _, mount := range container.VolumeMounts {
+		var volume, ok = volumes[mount.Name]
+		if !ok {
+			continue
+		}
+		var converted = kubtypes.ContainerVolume{MountPath: mount.MountPath}
+		if mount.SubPath != "" {
+			var subPath = mount.SubPath
+			converted.SubPath = &subPath
+		}
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			converted.Name = volume.Name
+			if claim := volume.PersistentVolumeClaim.ClaimName; claim != volume.Name {
+				converted.PersistentVolumeClaimName = &claim
+			}
+			ret.VolumeMounts = append(ret.VolumeMounts, converted)
+		case volume.ConfigMap != nil:
+			converted.Name = volume.ConfigMap.Name
+			if mode := volume.ConfigMap.DefaultMode; mode != nil && *mode != directDefaultConfigMapMode {
+				var formatted = fmt.Sprintf("%#o", *mode)
+				converted.Mode = &formatted
+			}
+			ret.ConfigMaps = append(ret.ConfigMaps, converted)
+		}
+	}
+	return ret
+}
+
+// serviceToDirect converts service. External services get IPs of domain as external IPs, domain is kept in annotation.
+// Annotations with empty values are returned, so they are removed from updated service.
+func serviceToDirect(nsID string, svc kubtypes.Service) *corev1.Service {
+	var ret = &corev1.Service{
+		ObjectMeta: directObjectMeta(nsID, svc.Name, svc.Owner),
+		Spec: corev1.ServiceSpec{
+			Type:        corev1.ServiceTypeClusterIP,
+			ExternalIPs: append([]string(nil), svc.IPs...),
+		},
+	}
+	ret.Annotations = map[string]string{directDomainKey: svc.Domain}
+	if svc.Deploy != "" {
+		ret.Spec.Selector = map[string]string{DirectAppLabel: svc.Deploy}
+	}
+	var unnumbered []string
+	for _, port := range svc.Ports {
+		var number = port.TargetPort
+		if port.Port != nil {
+			number = *port.Port
+		} else {
+			unnumbered = append(unnumbered, port.Name)
+		}
+		ret.Spec.Ports = append(ret.Spec.Ports, corev1.ServicePort{
+			Name:       port.Name,
+			Protocol:   corev1.Protocol(port.Protocol),
+			Port:       int32(number),
+			TargetPort: intstr.FromInt(port.TargetPort),
+		})
+	}
+	ret.Annotations[directUnnumberedPortsKey] = strings.Join(unnumbered, ",")
+	return ret
+}
+
+func serviceFromDirect(svc corev1.Service) kubtypes.Service {
+	var ret = kubtypes.Service{
+		Name:      svc.Name,
+		CreatedAt: directCreatedAt(svc.ObjectMeta),
+		Deploy:    svc.Spec.Selector[DirectAppLabel],
+		Domain:    svc.Annotations[directDomainKey],
+		Owner:     svc.Labels[DirectOwnerLabel],
+	}
+	if len(svc.Spec.ExternalIPs) > 0 {
+		ret.IPs = append([]string(nil), svc.Spec.ExternalIPs...)
+	}
+	var unnumbered = make(map[string]bool)
+	for _, name := range strings.Split(svc.Annotations[directUnnumberedPortsKey], ",") {
+		unnumbered[name] = true
+	}
+	for _, port := range svc.Spec.Ports {
+		var protocol = kubtypes.Protocol(port.Protocol)
+		if protocol == "" {
+			protocol = kubtypes.TCP
+		}
+		var converted = kubtypes.ServicePort{
+			Name:       port.Name,
+			TargetPort: port.TargetPort.IntValue(),
+			Protocol:   protocol,
+		}
+		if converted.TargetPort == 0 {
+			converted.TargetPort = int(port.Port)
+		}
+		if !unnumbered[port.Name] {
+			var number = int(port.Port)
+			converted.Port = &number
+		}
+		ret.Ports = append(ret.Ports, converted)
+	}
+	return ret
+}
+
+// ingressToDirect converts ingress without traffic splits, weighted routing is specific to ingress controller
+func ingressToDirect(nsID string, ingr ingress.KubeIngress) *networkingv1.Ingress {
+	var ret = &networkingv1.Ingress{
+		ObjectMeta: directObjectMeta(nsID, ingr.Name, ingr.Owner),
+	}
+	ret.Annotations = make(map[string]string, len(ingr.Annotations))
+	for key, value := range ingr.Annotations {
+		ret.Annotations[key] = value
+	}
+
+	var pathType = networkingv1.PathTypePrefix
+	var tlsIndex = make(map[string]int)
+	for _, rule := range ingr.Rules {
+		var http = &networkingv1.HTTPIngressRuleValue{}
+		for _, path := range rule.Path {
+			http.Paths = append(http.Paths, networkingv1.HTTPIngressPath{
+				Path:     path.Path,
+				PathType: &pathType,
+				Backend: networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: path.ServiceName,
+						Port: networkingv1.ServiceBackendPort{Number: int32(path.ServicePort)},
+					},
+				},
+			})
+		}
+		ret.Spec.Rules = append(ret.Spec.Rules, networkingv1.IngressRule{
+			Host:             rule.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{HTTP: http},
+		})
+		if rule.TLSSecret != nil {
+			if i, ok := tlsIndex[*rule.TLSSecret]; ok {
+				ret.Spec.TLS[i].Hosts = append(ret.Spec.TLS[i].Hosts, rule.Host)
+			} else {
+				tlsIndex[*rule.TLSSecret] = len(ret.Spec.TLS)
+				ret.Spec.TLS = append(ret.Spec.TLS, networkingv1.IngressTLS{Hosts: []string{rule.Host}, SecretName: *rule.TLSSecret})
+			}
+		}
+	}
+	return ret
+}
+
+// ingressFromDirect converts ingress, paths without service backend are skipped
+func ingressFromDirect(ingr networkingv1.Ingress) ingress.KubeIngress {
+	var ret = ingress.KubeIngress{
+		Ingress: kubtypes.Ingress{
+			Name:      ingr.Name,
+			CreatedAt: directCreatedAt(ingr.ObjectMeta),
+			Owner:     ingr.Labels[DirectOwnerLabel],
+		},
+	}
+	for key, value := range ingr.Annotations {
+		if strings.HasPrefix(key, directAnnotationPrefix) {
+			continue
+		}
+		if ret.Annotations == nil {
+			ret.Annotations = make(map[string]string)
+		}
+		ret.Annotations[key] = value
+	}
+
+	var secrets = make(map[string]string)
+	for _, tls := range ingr.Spec.TLS {
+		for _, host := range tls.Hosts {
+			secrets[host] = tls.SecretName
+		}
+	}
+	for _, rule := range ingr.Spec.Rules {
+		var converted = kubtypes.Rule{Host: rule.Host, Path: []kubtypes.Path{}}
+		if secret, ok := secrets[rule.Host]; ok {
+			converted.TLSSecret = &secret
+		}
+		if rule.HTTP != nil {
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service == nil {
+					continue
+				}
+				converted.Path = append(converted.Path, kubtypes.Path{
+					Path:        path.Path,
+					ServiceName: path.Backend.Service.Name,
+					ServicePort: int(path.Backend.Service.Port.Number),
+				})
+			}
+		}
+		ret.Rules = append(ret.Rules, converted)
+	}
+	return ret
+}
+
+// secretToDirect converts secret, secrets with certificate and private key get TLS type
+func secretToDirect(nsID string, secret kubtypes.Secret) *corev1.Secret {
+	var ret = &corev1.Secret{
+		ObjectMeta: directObjectMeta(nsID, secret.Name, secret.Owner),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       make(map[string][]byte, len(secret.Data)),
+	}
+	for key, value := range secret.Data {
+		ret.Data[key] = []byte(value)
+	}
+	_, hasCert := secret.Data[certificate.SecretCertKey]
+	_, hasKey := secret.Data[certificate.SecretPrivateKey]
+	if hasCert && hasKey {
+		ret.Type = corev1.SecretTypeTLS
+	}
+	return ret
+}
@@ -0,0 +1,159 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/blang/semver"
+	"github.com/containerum/cherry"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKubeDirect(t *testing.T) {
+	var ctx = context.Background()
+	var clientset = fake.NewSimpleClientset()
+	var kube = NewKubeClientset(clientset)
+
+	var status = func(err error) int {
+		if cherr, ok := err.(*cherry.Err); ok {
+			return cherr.StatusHTTP
+		}
+		return 0
+	}
+	var claim, mode, subPath = "data-claim", "0600", "conf"
+	var depl = kubtypes.Deployment{
+		Name:     "web",
+		Replicas: 2,
+		Owner:    "owner",
+		Version:  semver.MustParse("1.2.0"),
+		Active:   true,
+		Containers: []kubtypes.Container{{
+			Name:     "nginx",
+			Image:    "nginx",
+			Limits:   kubtypes.Resource{CPU: 250, Memory: 128},
+			Env:      []kubtypes.Env{{Name: "MODE", Value: "prod"}},
+			Commands: []string{"nginx", "-g", "daemon off;"},
+			Ports:    []kubtypes.ContainerPort{{Name: "http", Port: 80, Protocol: kubtypes.TCP}},
+			VolumeMounts: []kubtypes.ContainerVolume{
+				{Name: "data", MountPath: "/data", PersistentVolumeClaimName: &claim},
+				{Name: "cache", MountPath: "/cache"},
+			},
+			ConfigMaps: []kubtypes.ContainerVolume{{Name: "config", Mode: &mode, MountPath: "/etc/nginx", SubPath: &subPath}},
+		}},
+	}
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+	assert.Equal(t, http.StatusConflict, status(kube.CreateDeployment(ctx, "ns", depl)))
+
+	list, err := kube.GetDeploymentList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, list, 1) {
+		list[0].Status = nil
+		assert.Equal(t, depl, list[0])
+	}
+
+	assert.NoError(t, kube.SetDeploymentReplicas(ctx, "ns", "web", 3))
+	assert.NoError(t, kube.SetContainerImage(ctx, "ns", "web", kubtypes.UpdateImage{Container: "nginx", Image: "nginx:2"}))
+	assert.Equal(t, http.StatusNotFound, status(kube.SetContainerImage(ctx, "ns", "web", kubtypes.UpdateImage{Container: "app", Image: "app"})))
+	assert.Equal(t, http.StatusNotFound, status(kube.UpdateDeployment(ctx, "ns", kubtypes.Deployment{Name: "missing"})))
+	list, err = kube.GetDeploymentList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, list, 1) {
+		assert.Equal(t, 3, list[0].Replicas)
+		assert.Equal(t, "nginx:2", list[0].Containers[0].Image)
+	}
+
+	var port = 30080
+	var services = []kubtypes.Service{
+		{Name: "external", Deploy: "web", Domain: "example.com", IPs: []string{"192.0.2.1"}, Owner: "owner",
+			Ports: []kubtypes.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: kubtypes.TCP}}},
+		{Name: "internal", Deploy: "web",
+			Ports: []kubtypes.ServicePort{{Name: "http", TargetPort: 80, Protocol: kubtypes.TCP}}},
+	}
+	for _, svc := range services {
+		assert.NoError(t, kube.CreateService(ctx, "ns", svc))
+	}
+	gotServices, err := kube.GetServiceList(ctx, "ns")
+	if assert.NoError(t, err) {
+		assert.Equal(t, services, gotServices)
+	}
+	// external service becomes internal, domain annotation is removed
+	services[0].Domain, services[0].IPs = "", nil
+	assert.NoError(t, kube.UpdateService(ctx, "ns", services[0]))
+	gotServices, err = kube.GetServiceList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, gotServices, 2) {
+		assert.Equal(t, services[0], gotServices[0])
+	}
+
+	var secret = "web-tls"
+	var ingr = ingress.KubeIngress{
+		Ingress: kubtypes.Ingress{Name: "web", Rules: []kubtypes.Rule{
+			{Host: "web.example.com", TLSSecret: &secret, Path: []kubtypes.Path{{Path: "/", ServiceName: "external", ServicePort: 30080}}},
+			{Host: "api.example.com", Path: []kubtypes.Path{{Path: "/v1", ServiceName: "internal", ServicePort: 80}}},
+		}},
+		Annotations: map[string]string{ingress.AnnotationPrefix + "rewrite-target": "/"},
+	}
+	// weighted routing is not supported, ingress with splits is rejected before request to kubernetes API
+	var split = ingr
+	split.Splits = []ingress.Split{{Host: "web.example.com", Path: "/", Backends: []ingress.Backend{
+		{ServiceName: "external", ServicePort: 30080, Weight: 90},
+		{ServiceName: "internal", ServicePort: 80, Weight: 10},
+	}}}
+	assert.True(t, cherry.Equals(kube.CreateIngress(ctx, "ns", split), rserrors.ErrValidation()))
+	ingresses, err := kube.GetIngressList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, ingresses)
+
+	assert.NoError(t, kube.CreateIngress(ctx, "ns", ingr))
+	ingresses, err = kube.GetIngressList(ctx, "ns")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ingress.KubeIngress{ingr}, ingresses)
+	}
+	assert.True(t, cherry.Equals(kube.UpdateIngress(ctx, "ns", split), rserrors.ErrValidation()))
+	ingr.Annotations = nil
+	assert.NoError(t, kube.UpdateIngress(ctx, "ns", ingr))
+	ingresses, err = kube.GetIngressList(ctx, "ns")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ingress.KubeIngress{ingr}, ingresses)
+	}
+
+	assert.NoError(t, kube.CreateSecret(ctx, "ns", kubtypes.Secret{Name: secret, Data: map[string]string{
+		certificate.SecretCertKey:    "cert",
+		certificate.SecretPrivateKey: "key",
+	}}))
+	created, err := clientset.CoreV1().Secrets("ns").Get(ctx, secret, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, corev1.SecretTypeTLS, created.Type)
+		assert.Equal(t, []byte("cert"), created.Data[certificate.SecretCertKey])
+	}
+
+	assert.NoError(t, kube.DeleteSecret(ctx, "ns", secret))
+	assert.NoError(t, kube.DeleteIngress(ctx, "ns", "web"))
+	assert.NoError(t, kube.DeleteService(ctx, "ns", "internal"))
+	assert.NoError(t, kube.DeleteDeployment(ctx, "ns", "web"))
+	assert.Equal(t, http.StatusNotFound, status(kube.DeleteDeployment(ctx, "ns", "web")))
+	list, err = kube.GetDeploymentList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestKubeDirectCircuitOpen(t *testing.T) {
+	var clientset = fake.NewSimpleClientset()
+	// client-go wraps transport errors of requests
+	clientset.PrependReactor("*", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("request failed: %w", &url.Error{Op: "Get", URL: "https://kubernetes", Err: errCircuitOpen})
+	})
+	var kube = NewKubeClientset(clientset)
+
+	_, err := kube.GetDeploymentList(context.Background(), "ns")
+	assert.True(t, cherry.Equals(err, rserrors.ErrServiceUnavailable()), "%v", err)
+}
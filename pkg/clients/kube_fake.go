@@ -0,0 +1,400 @@
+package clients
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+// FakeKube -- in-memory client to kube-api for tests.
+// Unlike dummy client it stores resources, so they can be read back and changed behind resource-service.
+// Like kube-api it returns conflict error on creation of existing resource and not found error on change of missing one.
+// Faults may be injected to make method calls fail.
+type FakeKube struct {
+	mu  sync.RWMutex
+	log *logrus.Entry
+	// namespace -> name -> resource
+	deployments map[string]map[string]kubtypes.Deployment
+	services    map[string]map[string]kubtypes.Service
+	ingresses   map[string]map[string]ingress.KubeIngress
+	secrets     map[string]map[string]kubtypes.Secret
+
+	// method -> number of calls
+	calls  map[string]int
+	faults []fakeFault
+}
+
+// fakeFault -- error returned instead of method call
+type fakeFault struct {
+	method string
+	// number of failed call counting from 1, every call fails if 0
+	call int
+	err  error
+}
+
+var _ Kube = &FakeKube{}
+
+// NewFakeKube creates empty in-memory client to kube-api
+func NewFakeKube() *FakeKube {
+	return &FakeKube{
+		log:         logrus.WithField("component", "kube_fake"),
+		deployments: make(map[string]map[string]kubtypes.Deployment),
+		services:    make(map[string]map[string]kubtypes.Service),
+		ingresses:   make(map[string]map[string]ingress.KubeIngress),
+		secrets:     make(map[string]map[string]kubtypes.Secret),
+		calls:       make(map[string]int),
+	}
+}
+
+// FailMethod makes every call of method, e.g. "CreateDeployment", return err until faults are cleared
+func (kub *FakeKube) FailMethod(method string, err error) {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	kub.faults = append(kub.faults, fakeFault{method: method, err: err})
+}
+
+// FailCall makes call of method with number counting from 1 return err.
+// Calls of all methods are counted together if method is empty.
+func (kub *FakeKube) FailCall(method string, call int, err error) {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	kub.faults = append(kub.faults, fakeFault{method: method, call: call, err: err})
+}
+
+// ClearFaults removes injected faults and resets call counters
+func (kub *FakeKube) ClearFaults() {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	kub.faults = nil
+	kub.calls = make(map[string]int)
+}
+
+// Calls returns number of calls of method including failed ones, calls of all methods if method is empty
+func (kub *FakeKube) Calls(method string) int {
+	kub.mu.RLock()
+	defer kub.mu.RUnlock()
+	return kub.calls[method]
+}
+
+// call counts method call and returns injected fault, kub.mu must be locked
+func (kub *FakeKube) call(method string) error {
+	kub.calls[method]++
+	kub.calls[""]++
+	for _, fault := range kub.faults {
+		if fault.method != "" && fault.method != method {
+			continue
+		}
+		if fault.call == 0 || fault.call == kub.calls[fault.method] {
+			kub.log.WithError(fault.err).Debugf("injected fault of %s", method)
+			return fault.err
+		}
+	}
+	return nil
+}
+
+func fakeNotFound(kind, name string) error {
+	return rserrors.ErrResourceNotExists().AddDetailF("%s %s not found", kind, name)
+}
+
+func fakeAlreadyExists(kind, name string) error {
+	return rserrors.ErrResourceAlreadyExists().AddDetailF("%s %s already exists", kind, name)
+}
+
+func (kub *FakeKube) CreateDeployment(_ context.Context, nsID string, deploy kubtypes.Deployment) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create deployment %+v", deploy)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("CreateDeployment"); err != nil {
+		return err
+	}
+	if _, exists := kub.deployments[nsID][deploy.Name]; exists {
+		return fakeAlreadyExists("deployment", deploy.Name)
+	}
+	if kub.deployments[nsID] == nil {
+		kub.deployments[nsID] = make(map[string]kubtypes.Deployment)
+	}
+	kub.deployments[nsID][deploy.Name] = copyKubeDeployment(deploy)
+	return nil
+}
+
+func (kub *FakeKube) UpdateDeployment(_ context.Context, nsID string, deploy kubtypes.Deployment) error {
+	kub.log.WithField("ns_id", nsID).Debugf("update deployment %+v", deploy)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("UpdateDeployment"); err != nil {
+		return err
+	}
+	if _, exists := kub.deployments[nsID][deploy.Name]; !exists {
+		return fakeNotFound("deployment", deploy.Name)
+	}
+	kub.deployments[nsID][deploy.Name] = copyKubeDeployment(deploy)
+	return nil
+}
+
+func (kub *FakeKube) DeleteDeployment(_ context.Context, nsID, deplName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+	}).Debug("delete deployment")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("DeleteDeployment"); err != nil {
+		return err
+	}
+	if _, exists := kub.deployments[nsID][deplName]; !exists {
+		return fakeNotFound("deployment", deplName)
+	}
+	delete(kub.deployments[nsID], deplName)
+	return nil
+}
+
+func (kub *FakeKube) SetDeploymentReplicas(_ context.Context, nsID, deplName string, replicas int) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+		"replicas":    replicas,
+	}).Debug("change replicas")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("SetDeploymentReplicas"); err != nil {
+		return err
+	}
+	deploy, exists := kub.deployments[nsID][deplName]
+	if !exists {
+		return fakeNotFound("deployment", deplName)
+	}
+	deploy.Replicas = replicas
+	kub.deployments[nsID][deplName] = deploy
+	return nil
+}
+
+func (kub *FakeKube) SetContainerImage(_ context.Context, nsID, deplName string, container kubtypes.UpdateImage) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+		"container":   container.Container,
+		"image":       container.Image,
+	}).Debug("set container image")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("SetContainerImage"); err != nil {
+		return err
+	}
+	deploy, exists := kub.deployments[nsID][deplName]
+	if !exists {
+		return fakeNotFound("deployment", deplName)
+	}
+	deploy = copyKubeDeployment(deploy)
+	var found bool
+	for i := range deploy.Containers {
+		if deploy.Containers[i].Name == container.Container {
+			deploy.Containers[i].Image = container.Image
+			found = true
+		}
+	}
+	if !found {
+		return fakeNotFound("container", container.Container)
+	}
+	kub.deployments[nsID][deplName] = deploy
+	return nil
+}
+
+func (kub *FakeKube) CreateIngress(_ context.Context, nsID string, ingr ingress.KubeIngress) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create ingress %+v", ingr)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("CreateIngress"); err != nil {
+		return err
+	}
+	if _, exists := kub.ingresses[nsID][ingr.Name]; exists {
+		return fakeAlreadyExists("ingress", ingr.Name)
+	}
+	if kub.ingresses[nsID] == nil {
+		kub.ingresses[nsID] = make(map[string]ingress.KubeIngress)
+	}
+	kub.ingresses[nsID][ingr.Name] = ingr
+	return nil
+}
+
+func (kub *FakeKube) UpdateIngress(_ context.Context, nsID string, ingr ingress.KubeIngress) error {
+	kub.log.WithField("ns_id", nsID).Debugf("update ingress %+v", ingr)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("UpdateIngress"); err != nil {
+		return err
+	}
+	if _, exists := kub.ingresses[nsID][ingr.Name]; !exists {
+		return fakeNotFound("ingress", ingr.Name)
+	}
+	kub.ingresses[nsID][ingr.Name] = ingr
+	return nil
+}
+
+func (kub *FakeKube) DeleteIngress(_ context.Context, nsID, ingressName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"ingress_name": ingressName,
+	}).Debug("delete ingress")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("DeleteIngress"); err != nil {
+		return err
+	}
+	if _, exists := kub.ingresses[nsID][ingressName]; !exists {
+		return fakeNotFound("ingress", ingressName)
+	}
+	delete(kub.ingresses[nsID], ingressName)
+	return nil
+}
+
+func (kub *FakeKube) CreateSecret(_ context.Context, nsID string, secret kubtypes.Secret) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create secret %s", secret.Name)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("CreateSecret"); err != nil {
+		return err
+	}
+	if _, exists := kub.secrets[nsID][secret.Name]; exists {
+		return fakeAlreadyExists("secret", secret.Name)
+	}
+	if kub.secrets[nsID] == nil {
+		kub.secrets[nsID] = make(map[string]kubtypes.Secret)
+	}
+	kub.secrets[nsID][secret.Name] = secret
+	return nil
+}
+
+func (kub *FakeKube) DeleteSecret(_ context.Context, nsID, secretName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":       nsID,
+		"secret_name": secretName,
+	}).Debug("delete secret")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("DeleteSecret"); err != nil {
+		return err
+	}
+	if _, exists := kub.secrets[nsID][secretName]; !exists {
+		return fakeNotFound("secret", secretName)
+	}
+	delete(kub.secrets[nsID], secretName)
+	return nil
+}
+
+func (kub *FakeKube) CreateService(_ context.Context, nsID string, service kubtypes.Service) error {
+	kub.log.WithField("ns_id", nsID).Debugf("create service %+v", service)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("CreateService"); err != nil {
+		return err
+	}
+	if _, exists := kub.services[nsID][service.Name]; exists {
+		return fakeAlreadyExists("service", service.Name)
+	}
+	if kub.services[nsID] == nil {
+		kub.services[nsID] = make(map[string]kubtypes.Service)
+	}
+	service.Ports = append([]kubtypes.ServicePort(nil), service.Ports...)
+	kub.services[nsID][service.Name] = service
+	return nil
+}
+
+func (kub *FakeKube) UpdateService(_ context.Context, nsID string, service kubtypes.Service) error {
+	kub.log.WithField("ns_id", nsID).Debugf("update service %+v", service)
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("UpdateService"); err != nil {
+		return err
+	}
+	if _, exists := kub.services[nsID][service.Name]; !exists {
+		return fakeNotFound("service", service.Name)
+	}
+	service.Ports = append([]kubtypes.ServicePort(nil), service.Ports...)
+	kub.services[nsID][service.Name] = service
+	return nil
+}
+
+func (kub *FakeKube) DeleteService(_ context.Context, nsID, serviceName string) error {
+	kub.log.WithFields(logrus.Fields{
+		"ns_id":        nsID,
+		"service_name": serviceName,
+	}).Debug("delete service")
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("DeleteService"); err != nil {
+		return err
+	}
+	if _, exists := kub.services[nsID][serviceName]; !exists {
+		return fakeNotFound("service", serviceName)
+	}
+	delete(kub.services[nsID], serviceName)
+	return nil
+}
+
+// GetDeploymentList returns namespace deployments sorted by name
+func (kub *FakeKube) GetDeploymentList(_ context.Context, nsID string) ([]kubtypes.Deployment, error) {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("GetDeploymentList"); err != nil {
+		return nil, err
+	}
+	var list = make([]kubtypes.Deployment, 0, len(kub.deployments[nsID]))
+	for _, deploy := range kub.deployments[nsID] {
+		list = append(list, copyKubeDeployment(deploy))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetServiceList returns namespace services sorted by name
+func (kub *FakeKube) GetServiceList(_ context.Context, nsID string) ([]kubtypes.Service, error) {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("GetServiceList"); err != nil {
+		return nil, err
+	}
+	var list = make([]kubtypes.Service, 0, len(kub.services[nsID]))
+	for _, service := range kub.services[nsID] {
+		service.Ports = append([]kubtypes.ServicePort(nil), service.Ports...)
+		list = append(list, service)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetIngressList returns namespace ingresses sorted by name
+func (kub *FakeKube) GetIngressList(_ context.Context, nsID string) ([]ingress.KubeIngress, error) {
+	kub.mu.Lock()
+	defer kub.mu.Unlock()
+	if err := kub.call("GetIngressList"); err != nil {
+		return nil, err
+	}
+	var list = make([]ingress.KubeIngress, 0, len(kub.ingresses[nsID]))
+	for _, ingr := range kub.ingresses[nsID] {
+		list = append(list, ingr)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetSecret returns stored secret, ok is false if there is no such secret
+func (kub *FakeKube) GetSecret(nsID, secretName string) (secret kubtypes.Secret, ok bool) {
+	kub.mu.RLock()
+	defer kub.mu.RUnlock()
+	secret, ok = kub.secrets[nsID][secretName]
+	return secret, ok
+}
+
+func (kub *FakeKube) String() string {
+	return "kube api fake"
+}
+
+func copyKubeDeployment(deploy kubtypes.Deployment) kubtypes.Deployment {
+	deploy.Containers = append([]kubtypes.Container(nil), deploy.Containers...)
+	return deploy
+}
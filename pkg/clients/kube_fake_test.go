@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/containerum/cherry"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeKube(t *testing.T) {
+	var ctx = context.Background()
+	var kube = NewFakeKube()
+	var depl = kubtypes.Deployment{Name: "web", Replicas: 1, Containers: []kubtypes.Container{{Name: "nginx", Image: "nginx"}}}
+
+	var status = func(err error) int {
+		if cherr, ok := err.(*cherry.Err); ok {
+			return cherr.StatusHTTP
+		}
+		return 0
+	}
+	assert.Equal(t, http.StatusNotFound, status(kube.UpdateDeployment(ctx, "ns", depl)))
+	assert.Equal(t, http.StatusNotFound, status(kube.DeleteService(ctx, "ns", "web")))
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+	assert.Equal(t, http.StatusConflict, status(kube.CreateDeployment(ctx, "ns", depl)))
+	assert.Equal(t, http.StatusNotFound, status(kube.SetContainerImage(ctx, "ns", "web", kubtypes.UpdateImage{Container: "app", Image: "app"})))
+
+	// every call of method fails
+	var fault = errors.New("fault")
+	kube.FailMethod("SetDeploymentReplicas", fault)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, fault, kube.SetDeploymentReplicas(ctx, "ns", "web", 2))
+	}
+	assert.NoError(t, kube.SetContainerImage(ctx, "ns", "web", kubtypes.UpdateImage{Container: "nginx", Image: "nginx:2"}))
+
+	// only second call of any method fails
+	kube.ClearFaults()
+	kube.FailCall("", 2, fault)
+	assert.NoError(t, kube.SetDeploymentReplicas(ctx, "ns", "web", 2))
+	_, err := kube.GetDeploymentList(ctx, "ns")
+	assert.Equal(t, fault, err)
+	list, err := kube.GetDeploymentList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, list, 1) {
+		assert.Equal(t, 2, list[0].Replicas)
+		assert.Equal(t, "nginx:2", list[0].Containers[0].Image)
+	}
+	assert.Equal(t, 3, kube.Calls(""))
+	assert.Equal(t, 2, kube.Calls("GetDeploymentList"))
+}
@@ -14,6 +14,7 @@ import (
 
 type Permissions interface {
 	GetNamespaceLimits(ctx context.Context, namespaceID string) (kubtypes.Namespace, error)
+	WildcardHostsAllowed(ctx context.Context, namespaceID string) (bool, error)
 }
 
 type permissions struct {
@@ -21,7 +22,9 @@ type permissions struct {
 	logger logrus.FieldLogger
 }
 
-func NewPermissionsHTTP(permissionsHost string) Permissions {
+// NewPermissionsHTTP creates http client to permissions service.
+// Requests are retried and rejected by circuit breaker according to policy.
+func NewPermissionsHTTP(permissionsHost string, policy CallPolicy) Permissions {
 	log := logrus.WithField("component", "permissions_client")
 	var client = resty.New().
 		SetHostURL(permissionsHost).
@@ -31,6 +34,7 @@ func NewPermissionsHTTP(permissionsHost string) Permissions {
 		SetHeader("Accept", "application/json")
 	client.JSONMarshal = jsoniter.Marshal
 	client.JSONUnmarshal = jsoniter.Unmarshal
+	withCallPolicy(client, policy, log)
 	return permissions{
 		logger: log,
 		resty:  client,
@@ -54,6 +58,43 @@ func (client permissions) GetNamespaceLimits(ctx context.Context, namespaceID st
 
 	return ret, func() error {
 		if err != nil {
+			if unavailable := unavailableError(err, "permissions"); unavailable != nil {
+				return unavailable
+			}
+			return err
+		}
+		if errResult.ID != (cherry.ErrID{}) {
+			return &errResult
+		}
+		return nil
+	}()
+}
+
+// namespaceFeatures -- namespace flags returned by permissions service along with limits
+type namespaceFeatures struct {
+	AllowWildcardHosts bool `json:"allow_wildcard_hosts"`
+}
+
+func (client permissions) WildcardHostsAllowed(ctx context.Context, namespaceID string) (bool, error) {
+	client.logger.
+		WithField("namespace_id", namespaceID).
+		Debugf("checking if wildcard hosts are allowed")
+	var ret namespaceFeatures
+	var errResult cherry.Err
+	_, err := client.resty.R().
+		SetContext(ctx).
+		SetResult(&ret).
+		SetError(&errResult).
+		SetPathParams(map[string]string{
+			"namespace": namespaceID,
+		}).SetHeaders(httputil.RequestXHeadersMap(ctx)).
+		Get("/namespaces/{namespace}")
+
+	return ret.AllowWildcardHosts, func() error {
+		if err != nil {
+			if unavailable := unavailableError(err, "permissions"); unavailable != nil {
+				return unavailable
+			}
 			return err
 		}
 		if errResult.ID != (cherry.ErrID{}) {
@@ -0,0 +1,263 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/resty.v1"
+)
+
+// IdempotencyKeyHeader -- requests with this header are retried even if method is not idempotent
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// CallPolicy -- retries, timeouts and circuit breaker of HTTP client to other service
+type CallPolicy struct {
+	// number of retries of failed request, only idempotent requests and requests with idempotency key are retried
+	Retries int
+	// delay before first retry, doubled before every next retry up to MaxBackoff, random jitter is added
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// timeout of every request attempt, 0 means no timeout
+	Timeout time.Duration
+	// number of consecutive failures which opens circuit, 0 disables circuit breaker
+	BreakerThreshold int
+	// time requests are rejected by open circuit before trial request is sent
+	BreakerCooldown time.Duration
+}
+
+// MaxCallDuration returns the longest time of request with all retries and backoff delays, 0 if timeout is disabled and time is unbounded
+func (policy CallPolicy) MaxCallDuration() time.Duration {
+	if policy.Timeout <= 0 {
+		return 0
+	}
+	var total = time.Duration(policy.Retries+1) * policy.Timeout
+	var delay = policy.Backoff
+	for i := 0; i < policy.Retries; i++ {
+		total += delay
+		if policy.MaxBackoff <= 0 || delay < policy.MaxBackoff {
+			delay *= 2
+		}
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+	}
+	return total
+}
+
+// errCircuitOpen is returned by transport without sending request while circuit is open
+var errCircuitOpen = errors.New("circuit is open")
+
+// policyTransport applies call policy to requests of underlying transport.
+// Connection errors and gateway errors (502, 503, 504) are failures, they are retried and counted by circuit breaker.
+type policyTransport struct {
+	next    http.RoundTripper
+	policy  CallPolicy
+	breaker *circuitBreaker
+	log     *logrus.Entry
+}
+
+// withCallPolicy applies call policy to requests of resty client
+func withCallPolicy(client *resty.Client, policy CallPolicy, log *logrus.Entry) *resty.Client {
+	var next = client.GetClient().Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return client.SetTransport(newPolicyTransport(next, policy, log))
+}
+
+func newPolicyTransport(next http.RoundTripper, policy CallPolicy, log *logrus.Entry) *policyTransport {
+	return &policyTransport{
+		next:    next,
+		policy:  policy,
+		breaker: &circuitBreaker{threshold: policy.BreakerThreshold, cooldown: policy.BreakerCooldown, now: time.Now},
+		log:     log,
+	}
+}
+
+func (tr *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var retryable = isIdempotent(req)
+	for attempt := 0; ; attempt++ {
+		if !tr.breaker.allow() {
+			return nil, errCircuitOpen
+		}
+		resp, err := tr.roundTrip(req)
+		if err == nil && !isGatewayError(resp.StatusCode) {
+			tr.breaker.success()
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			// request is cancelled by caller, it says nothing about service
+			tr.breaker.cancel()
+			return resp, err
+		}
+		tr.breaker.failure()
+
+		if !retryable || attempt >= tr.policy.Retries {
+			return resp, err
+		}
+		var delay = tr.backoff(attempt)
+		tr.log.WithFields(logrus.Fields{
+			"method":  req.Method,
+			"url":     req.URL.String(),
+			"attempt": attempt + 1,
+			"delay":   delay,
+		}).WithError(err).Debug("request failed, retrying")
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("request body can't be sent again")
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req.Body = body
+		}
+
+		var timer = time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// roundTrip sends request attempt with timeout, timeout is cancelled when response body is closed
+func (tr *policyTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if tr.policy.Timeout <= 0 {
+		return tr.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), tr.policy.Timeout)
+	resp, err := tr.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff returns exponential delay before retry with jitter, delay is between half and full backoff
+func (tr *policyTransport) backoff(attempt int) time.Duration {
+	var delay = tr.policy.Backoff
+	for i := 0; i < attempt && (tr.policy.MaxBackoff <= 0 || delay < tr.policy.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if tr.policy.MaxBackoff > 0 && delay > tr.policy.MaxBackoff {
+		delay = tr.policy.MaxBackoff
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+func isGatewayError(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+func drainBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, 4096))
+	body.Close()
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
+
+// circuitBreaker rejects requests after threshold of consecutive failures.
+// After cooldown one trial request is allowed, circuit is closed if it succeeds.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (cb *circuitBreaker) allow() bool {
+	if cb.threshold <= 0 {
+		return true
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.failures < cb.threshold {
+		return true
+	}
+	if cb.now().Before(cb.openUntil) || cb.trial {
+		return false
+	}
+	cb.trial = true
+	return true
+}
+
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures, cb.trial = 0, false
+}
+
+// cancel releases trial request which result is unknown
+func (cb *circuitBreaker) cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trial = false
+}
+
+func (cb *circuitBreaker) failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.trial = false
+	if cb.threshold > 0 && cb.failures >= cb.threshold {
+		cb.openUntil = cb.now().Add(cb.cooldown)
+	}
+}
+
+// unavailableError returns ErrServiceUnavailable if request was rejected by open circuit, otherwise nil
+func unavailableError(err error, service string) *cherry.Err {
+	if urlErr, ok := err.(*url.Error); ok && urlErr.Err == errCircuitOpen {
+		return rserrors.ErrServiceUnavailable().AddDetailF("%s is unavailable, try again later", service)
+	}
+	return nil
+}
+
+// requestError converts error of request to other service to ErrInternal or ErrServiceUnavailable
+func requestError(err error, service string, log *cherrylog.LogrusAdapter) *cherry.Err {
+	if unavailable := unavailableError(err, service); unavailable != nil {
+		return unavailable
+	}
+	return rserrors.ErrInternal().Log(err, log)
+}
@@ -0,0 +1,116 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/cherry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallPolicyRetries(t *testing.T) {
+	var calls int32
+	// every request fails twice before success
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var client = &http.Client{Transport: newPolicyTransport(http.DefaultTransport, CallPolicy{
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+		Timeout:    time.Second,
+	}, logrus.WithField("component", "test"))}
+
+	resp, err := client.Get(srv.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	// not idempotent request is sent once
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// request with idempotency key is retried with the same body
+	atomic.StoreInt32(&calls, 0)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("{}"))
+	req.Header.Set(IdempotencyKeyHeader, "key")
+	resp, err = client.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestCallPolicyCircuitBreaker(t *testing.T) {
+	var calls int32
+	var down int32 = 1
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var transport = newPolicyTransport(http.DefaultTransport, CallPolicy{
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	}, logrus.WithField("component", "test"))
+	var now = time.Now()
+	transport.breaker.now = func() time.Time { return now }
+	var client = &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}
+	_, err := client.Get(srv.URL)
+	assert.True(t, cherry.Equals(unavailableError(err, "test"), rserrors.ErrServiceUnavailable()), "open circuit must fail fast")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	// trial request after cooldown closes circuit
+	atomic.StoreInt32(&down, 0)
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+}
+
+func TestCallPolicyMaxCallDuration(t *testing.T) {
+	// 4 attempts of 10s and backoffs of 100ms, 200ms and 300ms limited by max backoff
+	assert.Equal(t, 40*time.Second+600*time.Millisecond,
+		CallPolicy{Retries: 3, Timeout: 10 * time.Second, Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}.MaxCallDuration())
+	assert.Equal(t, 10*time.Second, CallPolicy{Timeout: 10 * time.Second, Backoff: time.Second}.MaxCallDuration())
+	assert.Equal(t, 3*time.Second+3*time.Second, CallPolicy{Retries: 2, Timeout: time.Second, Backoff: time.Second}.MaxCallDuration())
+	// call without timeout is unbounded
+	assert.Zero(t, CallPolicy{Retries: 3, Backoff: time.Second}.MaxCallDuration())
+}
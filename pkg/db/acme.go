@@ -0,0 +1,88 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// acmeAccountKeyID -- db collection document with PEM encoded ACME account key, postgres settings row name
+const acmeAccountKeyID = "acme_account_key"
+
+func (mongo *MongoStorage) CreateACMEChallenge(challenge certificate.ACMEChallenge) error {
+	mongo.logger.Debugf("creating acme challenge")
+	var collection = mongo.db.C(CollectionACMEChallenge)
+	if _, err := collection.UpsertId(challenge.Token, challenge); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to create acme challenge")
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
+
+func (mongo *MongoStorage) GetACMEChallenge(token string) (certificate.ACMEChallenge, error) {
+	mongo.logger.Debugf("getting acme challenge")
+	var collection = mongo.db.C(CollectionACMEChallenge)
+	var challenge certificate.ACMEChallenge
+	if err := collection.FindId(token).One(&challenge); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get acme challenge")
+		if err == mgo.ErrNotFound {
+			return challenge, rserrors.ErrResourceNotExists().AddDetails(token)
+		}
+		return challenge, PipErr{err}.ToMongerr().Extract()
+	}
+	return challenge, nil
+}
+
+// DeleteACMEChallenge removes challenge completely, challenges are not kept after authorization
+func (mongo *MongoStorage) DeleteACMEChallenge(token string) error {
+	mongo.logger.Debugf("deleting acme challenge")
+	var collection = mongo.db.C(CollectionACMEChallenge)
+	if err := collection.RemoveId(token); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to delete acme challenge")
+		return PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return nil
+}
+
+func (mongo *MongoStorage) GetACMEIngressList() (ingress.IngressList, error) {
+	mongo.logger.Debugf("getting ingresses with acme enabled")
+	var collection = mongo.db.C(CollectionIngress)
+	var list ingress.IngressList
+	if err := collection.Find(ingress.ACMESelectQuery()).All(&list); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get ingresses with acme enabled")
+		return list, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return list, nil
+}
+
+func (mongo *MongoStorage) UpdateIngressACME(ingr ingress.IngressResource) error {
+	mongo.logger.Debugf("updating ingress acme status")
+	var collection = mongo.db.C(CollectionIngress)
+	if err := collection.Update(ingr.OneSelectQuery(), ingr.ACMEUpdateQuery()); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to update ingress acme status")
+		if err == mgo.ErrNotFound {
+			return rserrors.ErrResourceNotExists().AddDetails(ingr.Name)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
+
+func (mongo *MongoStorage) InitACMEAccountKey(keyPEM string) (string, error) {
+	mongo.logger.Debugf("initializing acme account key")
+	var collection = mongo.db.C(CollectionDB)
+	if err := collection.Insert(bson.M{"_id": acmeAccountKeyID, "key": keyPEM}); err != nil && !mgo.IsDup(err) {
+		mongo.logger.WithError(err).Errorf("unable to store acme account key")
+		return "", PipErr{err}.ToMongerr().Extract()
+	}
+	var stored struct {
+		Key string `bson:"key"`
+	}
+	if err := collection.FindId(acmeAccountKeyID).One(&stored); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get acme account key")
+		return "", PipErr{err}.ToMongerr().Extract()
+	}
+	return stored.Key, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+)
+
+// If ID is empty, then generates UUID4 and uses it
+func (mongo *MongoStorage) CreateCertificate(cert certificate.Certificate) (certificate.Certificate, error) {
+	mongo.logger.Debugf("creating certificate")
+	var collection = mongo.db.C(CollectionCertificate)
+	if cert.ID == "" {
+		cert.ID = uuid.New().String()
+	}
+	if err := collection.Insert(cert); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to create certificate")
+		if mgo.IsDup(err) {
+			return cert, rserrors.ErrResourceAlreadyExists().AddDetailsErr(err)
+		}
+		return cert, PipErr{err}.ToMongerr().Extract()
+	}
+	return cert, nil
+}
+
+func (mongo *MongoStorage) GetCertificate(namespaceID, name string) (certificate.Certificate, error) {
+	mongo.logger.Debugf("getting certificate")
+	var collection = mongo.db.C(CollectionCertificate)
+	var result certificate.Certificate
+	if err := collection.Find(certificate.OneSelectQuery(namespaceID, name)).One(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get certificate")
+		if err == mgo.ErrNotFound {
+			return result, rserrors.ErrResourceNotExists().AddDetails(name)
+		}
+		return result, PipErr{err}.ToMongerr().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) GetCertificateList(namespaceID string) (certificate.CertificateList, error) {
+	mongo.logger.Debugf("getting certificate list")
+	var collection = mongo.db.C(CollectionCertificate)
+	var result certificate.CertificateList
+	if err := collection.Find(certificate.ListSelectQuery(namespaceID)).All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get certificate list")
+		return result, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) DeleteCertificate(namespaceID, name string) error {
+	mongo.logger.Debugf("deleting certificate")
+	var collection = mongo.db.C(CollectionCertificate)
+	err := collection.Update(certificate.OneSelectQuery(namespaceID, name),
+		bson.M{
+			"$set": bson.M{"deleted": true},
+		})
+	if err != nil {
+		mongo.logger.WithError(err).Errorf("unable to delete certificate")
+		if err == mgo.ErrNotFound {
+			return rserrors.ErrResourceNotExists().AddDetails(name)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
+
+func (mongo *MongoStorage) RestoreCertificate(namespaceID, name string) error {
+	mongo.logger.Debugf("restoring certificate")
+	var collection = mongo.db.C(CollectionCertificate)
+	err := collection.Update(certificate.OneSelectDeletedQuery(namespaceID, name),
+		bson.M{
+			"$set": bson.M{"deleted": false},
+		})
+	if err != nil {
+		mongo.logger.WithError(err).Errorf("unable to restore certificate")
+		if err == mgo.ErrNotFound {
+			return rserrors.ErrResourceNotExists().AddDetails(name)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
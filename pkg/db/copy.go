@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/globalsign/mgo"
+)
+
+// CopyStats -- number of copied documents by mongo collection
+type CopyStats map[string]int
+
+// mongoCopier inserts documents of one mongo collection into postgres
+type mongoCopier struct {
+	collection string
+	table      string
+	insert     func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error)
+}
+
+var mongoCopiers = []mongoCopier{
+	{CollectionDeployment, "deployments", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc deployment.DeploymentResource
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertDeployment(tx, doc)
+	}},
+	{CollectionService, "services", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc service.ServiceResource
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertService(tx, doc)
+	}},
+	{CollectionIngress, "ingresses", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc ingress.IngressResource
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertIngress(tx, doc)
+	}},
+	{CollectionDomain, "domains", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc domain.Domain
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertDomain(tx, doc)
+	}},
+	{CollectionCustomDomain, "custom_domains", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc customdomain.CustomDomain
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertCustomDomain(tx, doc)
+	}},
+	{CollectionCertificate, "certificates", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc certificate.Certificate
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertCertificate(tx, doc)
+	}},
+	{CollectionACMEChallenge, "acme_challenges", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc certificate.ACMEChallenge
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertACMEChallenge(tx, doc)
+	}},
+	{CollectionOperation, "operations", func(pg *PostgresStorage, tx *sql.Tx, iter *mgo.Iter) (bool, error) {
+		var doc operation.Operation
+		if !iter.Next(&doc) {
+			return false, nil
+		}
+		return true, pg.insertOperation(tx, doc)
+	}},
+}
+
+// CopyMongoToPostgres copies all documents, including deleted ones, to migrated and empty postgres storage.
+// Everything is copied in one transaction, so nothing is written if any document violates postgres constraints.
+func CopyMongoToPostgres(mongo *MongoStorage, pg *PostgresStorage) (CopyStats, error) {
+	var stats = make(CopyStats, len(mongoCopiers))
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		for _, copier := range mongoCopiers {
+			var count int
+			if err := tx.QueryRow(`SELECT count(*) FROM ` + copier.table).Scan(&count); err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("postgres table %s is not empty", copier.table)
+			}
+		}
+		for _, copier := range mongoCopiers {
+			pg.logger.Infof("copying %s", copier.collection)
+			var iter = mongo.db.C(copier.collection).Find(nil).Iter()
+			for {
+				ok, err := copier.insert(pg, tx, iter)
+				if err != nil {
+					iter.Close()
+					return fmt.Errorf("unable to copy %s: %v", copier.collection, err)
+				}
+				if !ok {
+					break
+				}
+				stats[copier.collection]++
+			}
+			if err := iter.Close(); err != nil {
+				return fmt.Errorf("unable to read %s: %v", copier.collection, err)
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+)
+
+// If ID is empty, then generates UUID4 and uses it
+func (mongo *MongoStorage) CreateCustomDomain(domain customdomain.CustomDomain) (customdomain.CustomDomain, error) {
+	mongo.logger.Debugf("creating custom domain")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	if domain.ID == "" {
+		domain.ID = uuid.New().String()
+	}
+	if err := collection.Insert(domain); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to create custom domain")
+		if mgo.IsDup(err) {
+			return domain, rserrors.ErrResourceAlreadyExists().AddDetailsErr(err)
+		}
+		return domain, PipErr{err}.ToMongerr().Extract()
+	}
+	return domain, nil
+}
+
+func (mongo *MongoStorage) GetCustomDomain(namespaceID, domain string) (customdomain.CustomDomain, error) {
+	mongo.logger.Debugf("getting custom domain")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	var result customdomain.CustomDomain
+	if err := collection.Find(customdomain.OneSelectQuery(namespaceID, domain)).One(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get custom domain")
+		if err == mgo.ErrNotFound {
+			return result, rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return result, PipErr{err}.ToMongerr().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) GetCustomDomainList(namespaceID string) (customdomain.CustomDomainList, error) {
+	mongo.logger.Debugf("getting custom domain list")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	var result customdomain.CustomDomainList
+	if err := collection.Find(customdomain.ListSelectQuery(namespaceID)).All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get custom domain list")
+		return result, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
+// GetVerifiedCustomDomain returns verified domain from any namespace
+func (mongo *MongoStorage) GetVerifiedCustomDomain(domain string) (customdomain.CustomDomain, error) {
+	mongo.logger.Debugf("getting verified custom domain")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	var result customdomain.CustomDomain
+	if err := collection.Find(customdomain.VerifiedSelectQuery(domain)).One(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get verified custom domain")
+		if err == mgo.ErrNotFound {
+			return result, rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return result, PipErr{err}.ToMongerr().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) VerifyCustomDomain(namespaceID, domain, verifiedAt string) error {
+	mongo.logger.Debugf("verifying custom domain")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	err := collection.Update(customdomain.OneSelectQuery(namespaceID, domain),
+		bson.M{
+			"$set": bson.M{
+				"verified":   true,
+				"verifiedat": verifiedAt,
+			},
+		})
+	if err != nil {
+		mongo.logger.WithError(err).Errorf("unable to verify custom domain")
+		switch {
+		case err == mgo.ErrNotFound:
+			return rserrors.ErrResourceNotExists().AddDetails(domain)
+		case mgo.IsDup(err):
+			return rserrors.ErrResourceAlreadyExists().AddDetailF("domain %s is already verified in another namespace", domain)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
+
+func (mongo *MongoStorage) DeleteCustomDomain(namespaceID, domain string) error {
+	mongo.logger.Debugf("deleting custom domain")
+	var collection = mongo.db.C(CollectionCustomDomain)
+	err := collection.Update(customdomain.OneSelectQuery(namespaceID, domain),
+		bson.M{
+			"$set": bson.M{"deleted": true},
+		})
+	if err != nil {
+		mongo.logger.WithError(err).Errorf("unable to delete custom domain")
+		if err == mgo.ErrNotFound {
+			return rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
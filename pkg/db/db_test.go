@@ -1,14 +1,20 @@
 package db
 
 import (
+	"os"
 	"testing"
 
 	"github.com/globalsign/mgo"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestDBConnection requires mongo, its address is set in CH_RESOURCE_TEST_MONGO_ADDR, e.g. localhost:27017
 func TestDBConnection(t *testing.T) {
-	dialInfo := mgo.DialInfo{Addrs: []string{"localhost:27017"}}
+	addr := os.Getenv("CH_RESOURCE_TEST_MONGO_ADDR")
+	if addr == "" {
+		t.Skip("CH_RESOURCE_TEST_MONGO_ADDR is not set")
+	}
+	dialInfo := mgo.DialInfo{Addrs: []string{addr}}
 	cfg := MongoConfig{DialInfo: dialInfo}
 
 	_, err := NewMongo(cfg)
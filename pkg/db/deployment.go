@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"git.containerum.net/ch/resource-service/pkg/models/deployment"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"github.com/blang/semver"
@@ -101,6 +103,9 @@ func (mongo *MongoStorage) CreateDeployment(deployment deployment.DeploymentReso
 	if deployment.ID == "" {
 		deployment.ID = uuid.New().String()
 	}
+	if deployment.Revision == 0 {
+		deployment.Revision = 1
+	}
 	if err := collection.Insert(deployment); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to create deployment")
 		if mgo.IsDup(err) {
@@ -114,26 +119,41 @@ func (mongo *MongoStorage) CreateDeployment(deployment deployment.DeploymentReso
 func (mongo *MongoStorage) UpdateActiveDeployment(upd deployment.DeploymentResource) error {
 	mongo.logger.Debugf("updating deployment")
 	var collection = mongo.db.C(CollectionDeployment)
-	err := collection.Update(upd.OneSelectQuery(), upd.UpdateQuery())
+	err := collection.Update(upd.OneRevisionSelectQuery(), upd.UpdateQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to update deployment")
+		if err == mgo.ErrNotFound && upd.Revision != 0 {
+			return revisionConflict(collection, upd.OneSelectQuery(), upd.Name)
+		}
 	}
 	return PipErr{err}.ToMongerr().Extract()
 }
 
-func (mongo *MongoStorage) UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version) error {
+func (mongo *MongoStorage) UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version, revision int64) error {
 	mongo.logger.Debugf("updating deployment version")
 	var collection = mongo.db.C(CollectionDeployment)
-	err := collection.Update(bson.M{
+	var query = bson.M{
 		"namespaceid":        namespace,
 		"deleted":            false,
 		"deployment.name":    name,
 		"deployment.version": oldversion,
-	}, bson.M{
+	}
+	var revisionQuery = bson.M{}
+	for key, value := range query {
+		revisionQuery[key] = value
+	}
+	if revision != 0 {
+		revisionQuery["revision"] = revision
+	}
+	err := collection.Update(revisionQuery, bson.M{
 		"$set": bson.M{"deployment.version": newversion},
+		"$inc": bson.M{"revision": 1},
 	})
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to update deployment version")
+		if err == mgo.ErrNotFound && revision != 0 {
+			err = revisionConflict(collection, query, name)
+		}
 		if err == mgo.ErrNotFound {
 			return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, oldversion.String())
 		}
@@ -149,10 +169,7 @@ func (mongo *MongoStorage) DeleteDeployment(namespace, name string) error {
 			Name: name,
 		},
 		NamespaceID: namespace,
-	}.OneSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.OneSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployment")
 		if err == mgo.ErrNotFound {
@@ -175,6 +192,7 @@ func (mongo *MongoStorage) ActivateDeployment(namespace, name string, version se
 	}.OneAnyVersionSelectQuery(),
 		bson.M{
 			"$set": bson.M{"deployment.active": true},
+			"$inc": bson.M{"revision": 1},
 		})
 
 	if err != nil {
@@ -187,18 +205,24 @@ func (mongo *MongoStorage) ActivateDeployment(namespace, name string, version se
 	return nil
 }
 
-func (mongo *MongoStorage) DeactivateDeployment(namespace, name string) error {
+func (mongo *MongoStorage) DeactivateDeployment(namespace, name string, revision int64) error {
 	mongo.logger.Debugf("deactivating deployment")
 	var collection = mongo.db.C(CollectionDeployment)
-	_, err := collection.UpdateAll(deployment.DeploymentResource{
+	var active = deployment.DeploymentResource{
 		Deployment: model.Deployment{
 			Name: name,
 		},
 		NamespaceID: namespace,
-	}.OneSelectQuery(),
+		Revision:    revision,
+	}
+	info, err := collection.UpdateAll(active.OneRevisionSelectQuery(),
 		bson.M{
 			"$set": bson.M{"deployment.active": false},
+			"$inc": bson.M{"revision": 1},
 		})
+	if err == nil && info.Updated == 0 && revision != 0 {
+		err = revisionConflict(collection, active.OneSelectQuery(), name)
+	}
 
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to deactivate deployment")
@@ -220,10 +244,7 @@ func (mongo *MongoStorage) DeleteDeploymentVersion(namespace, name string, versi
 			Active:  false,
 		},
 		NamespaceID: namespace,
-	}.OneInactiveSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.OneInactiveVersionSelectQuery(), softDeleteQuery())
 
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployment version")
@@ -238,15 +259,17 @@ func (mongo *MongoStorage) DeleteDeploymentVersion(namespace, name string, versi
 func (mongo *MongoStorage) RestoreDeployment(namespace, name string) error {
 	mongo.logger.Debugf("restoring deployment")
 	var collection = mongo.db.C(CollectionDeployment)
-	err := collection.Update(deployment.DeploymentResource{
+	// the latest deleted resource is restored
+	var deleted deployment.DeploymentResource
+	err := collection.Find(deployment.DeploymentResource{
 		Deployment: model.Deployment{
 			Name: name,
 		},
 		NamespaceID: namespace,
-	}.OneSelectDeletedQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": false},
-		})
+	}.OneSelectDeletedQuery()).Sort("-deletedat", "-deployment.active").One(&deleted)
+	if err == nil {
+		err = collection.UpdateId(deleted.ID, restoreQuery())
+	}
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to restore deployment")
 		if err == mgo.ErrNotFound {
@@ -257,15 +280,41 @@ func (mongo *MongoStorage) RestoreDeployment(namespace, name string) error {
 	return nil
 }
 
+func (mongo *MongoStorage) RestoreDeploymentVersions(namespace, name string, deletedAt time.Time) error {
+	mongo.logger.Debugf("restoring deployment versions")
+	var collection = mongo.db.C(CollectionDeployment)
+	var alive deployment.DeploymentList
+	if err := collection.Find(bson.M{
+		"namespaceid":     namespace,
+		"deleted":         false,
+		"deployment.name": name,
+	}).All(&alive); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get deployment versions")
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	var versions = make([]semver.Version, 0, len(alive))
+	for _, depl := range alive {
+		versions = append(versions, depl.Version)
+	}
+	_, err := collection.UpdateAll(bson.M{
+		"namespaceid":        namespace,
+		"deployment.name":    name,
+		"deleted":            true,
+		"deletedat":          deletedAt,
+		"deployment.version": bson.M{"$nin": versions},
+	}, restoreQuery())
+	if err != nil {
+		mongo.logger.WithError(err).Errorf("unable to restore deployment versions")
+	}
+	return PipErr{err}.ToMongerr().Extract()
+}
+
 func (mongo *MongoStorage) DeleteAllDeploymentsInNamespace(namespace string) error {
 	mongo.logger.Debugf("deleting all deployments in namespace")
 	var collection = mongo.db.C(CollectionDeployment)
 	_, err := collection.UpdateAll(deployment.DeploymentResource{
 		NamespaceID: namespace,
-	}.AllSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployments")
 	}
@@ -277,10 +326,7 @@ func (mongo *MongoStorage) DeleteAllDeploymentsByOwner(owner string) error {
 	var collection = mongo.db.C(CollectionDeployment)
 	_, err := collection.UpdateAll(deployment.DeploymentResource{
 		Deployment: model.Deployment{Owner: owner},
-	}.AllSelectOwnerQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectOwnerQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployments")
 	}
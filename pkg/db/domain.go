@@ -31,6 +31,17 @@ func (mongo *MongoStorage) GetRandomDomain() (*domain.Domain, error) {
 	return &result, nil
 }
 
+func (mongo *MongoStorage) GetDomainGroup(domainGroup string) (domain.DomainList, error) {
+	mongo.logger.Debugf("getting domain group")
+	var collection = mongo.db.C(CollectionDomain)
+	var result domain.DomainList
+	if err := collection.Find(bson.M{"domaingroup": domainGroup}).All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get domain group")
+		return nil, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
 // GetDomainsList supports pagination
 func (mongo *MongoStorage) GetDomainsList(pages *PageInfo) ([]domain.Domain, error) {
 	mongo.logger.Debugf("getting domain list")
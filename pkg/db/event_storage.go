@@ -0,0 +1,247 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/blang/semver"
+	"github.com/sirupsen/logrus"
+)
+
+// EventStorage -- storage which publishes changes of deployments, services and ingresses to event bus.
+// Event is published after successful write, resource state is read from storage.
+// Changes of owner resources in all namespaces (Delete*ByOwner) are not published.
+type EventStorage struct {
+	Storage
+	bus    *EventBus
+	logger logrus.FieldLogger
+}
+
+// NewEventStorage wraps storage to publish resource changes to bus
+func NewEventStorage(storage Storage, bus *EventBus) *EventStorage {
+	return &EventStorage{
+		Storage: storage,
+		bus:     bus,
+		logger:  logrus.WithField("component", "event_storage"),
+	}
+}
+
+// Watch subscribes to namespace events, see EventBus.Watch
+func (es *EventStorage) Watch(namespaceID, lastEventID string) (<-chan event.Event, func()) {
+	return es.bus.Watch(namespaceID, lastEventID)
+}
+
+func (es *EventStorage) publishDeployment(typ event.Type, namespaceID, name string) {
+	var ev = event.Event{Type: typ, Kind: event.KindDeployment, NamespaceID: namespaceID, Name: name}
+	if typ != event.Deleted {
+		depl, err := es.Storage.GetDeployment(namespaceID, name)
+		if err != nil {
+			es.logger.WithError(err).Warnf("unable to get changed deployment %s", name)
+			return
+		}
+		ev.Deployment = &depl
+	}
+	es.bus.Publish(ev)
+}
+
+func (es *EventStorage) publishService(typ event.Type, namespaceID, name string) {
+	var ev = event.Event{Type: typ, Kind: event.KindService, NamespaceID: namespaceID, Name: name}
+	if typ != event.Deleted {
+		svc, err := es.Storage.GetService(namespaceID, name)
+		if err != nil {
+			es.logger.WithError(err).Warnf("unable to get changed service %s", name)
+			return
+		}
+		ev.Service = &svc
+	}
+	es.bus.Publish(ev)
+}
+
+func (es *EventStorage) publishIngress(typ event.Type, namespaceID, name string) {
+	var ev = event.Event{Type: typ, Kind: event.KindIngress, NamespaceID: namespaceID, Name: name}
+	if typ != event.Deleted {
+		ingr, err := es.Storage.GetIngress(namespaceID, name)
+		if err != nil {
+			es.logger.WithError(err).Warnf("unable to get changed ingress %s", name)
+			return
+		}
+		ev.Ingress = &ingr
+	}
+	es.bus.Publish(ev)
+}
+
+// CreateDeployment publishes update if deployment already had versions, new active version replaces old one
+func (es *EventStorage) CreateDeployment(depl deployment.DeploymentResource) (deployment.DeploymentResource, error) {
+	created, err := es.Storage.CreateDeployment(depl)
+	if err != nil || !created.Active {
+		return created, err
+	}
+	var typ = event.Created
+	if versions, err := es.Storage.GetDeploymentVersionsList(created.NamespaceID, created.Name); err == nil && len(versions) > 1 {
+		typ = event.Updated
+	}
+	es.publishDeployment(typ, created.NamespaceID, created.Name)
+	return created, nil
+}
+
+func (es *EventStorage) UpdateActiveDeployment(upd deployment.DeploymentResource) error {
+	if err := es.Storage.UpdateActiveDeployment(upd); err != nil {
+		return err
+	}
+	es.publishDeployment(event.Updated, upd.NamespaceID, upd.Name)
+	return nil
+}
+
+// UpdateDeploymentVersion publishes update only if active version is renamed
+func (es *EventStorage) UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version, revision int64) error {
+	if err := es.Storage.UpdateDeploymentVersion(namespace, name, oldversion, newversion, revision); err != nil {
+		return err
+	}
+	if active, err := es.Storage.GetDeployment(namespace, name); err == nil && active.Version.Equals(newversion) {
+		es.publishDeployment(event.Updated, namespace, name)
+	}
+	return nil
+}
+
+func (es *EventStorage) DeleteDeployment(namespace, name string) error {
+	if err := es.Storage.DeleteDeployment(namespace, name); err != nil {
+		return err
+	}
+	es.publishDeployment(event.Deleted, namespace, name)
+	return nil
+}
+
+func (es *EventStorage) ActivateDeployment(namespace, name string, version semver.Version) error {
+	if err := es.Storage.ActivateDeployment(namespace, name, version); err != nil {
+		return err
+	}
+	es.publishDeployment(event.Activated, namespace, name)
+	return nil
+}
+
+func (es *EventStorage) RestoreDeployment(namespace, name string) error {
+	if err := es.Storage.RestoreDeployment(namespace, name); err != nil {
+		return err
+	}
+	es.publishDeployment(event.Created, namespace, name)
+	return nil
+}
+
+func (es *EventStorage) DeleteAllDeploymentsInNamespace(namespace string) error {
+	list, err := es.Storage.GetDeploymentList(namespace)
+	if err != nil {
+		return err
+	}
+	if err := es.Storage.DeleteAllDeploymentsInNamespace(namespace); err != nil {
+		return err
+	}
+	for _, depl := range list {
+		es.publishDeployment(event.Deleted, namespace, depl.Name)
+	}
+	return nil
+}
+
+func (es *EventStorage) CreateService(svc service.ServiceResource) (service.ServiceResource, error) {
+	created, err := es.Storage.CreateService(svc)
+	if err != nil {
+		return created, err
+	}
+	es.publishService(event.Created, created.NamespaceID, created.Name)
+	return created, nil
+}
+
+func (es *EventStorage) UpdateService(upd service.ServiceResource) (service.ServiceResource, error) {
+	updated, err := es.Storage.UpdateService(upd)
+	if err != nil {
+		return updated, err
+	}
+	es.publishService(event.Updated, upd.NamespaceID, upd.Name)
+	return updated, nil
+}
+
+func (es *EventStorage) DeleteService(namespaceID, name string) error {
+	if err := es.Storage.DeleteService(namespaceID, name); err != nil {
+		return err
+	}
+	es.publishService(event.Deleted, namespaceID, name)
+	return nil
+}
+
+func (es *EventStorage) RestoreService(namespaceID, name string) error {
+	if err := es.Storage.RestoreService(namespaceID, name); err != nil {
+		return err
+	}
+	es.publishService(event.Created, namespaceID, name)
+	return nil
+}
+
+func (es *EventStorage) DeleteAllServicesInNamespace(namespaceID string) error {
+	list, err := es.Storage.GetServiceList(namespaceID)
+	if err != nil {
+		return err
+	}
+	if err := es.Storage.DeleteAllServicesInNamespace(namespaceID); err != nil {
+		return err
+	}
+	for _, svc := range list {
+		es.publishService(event.Deleted, namespaceID, svc.Name)
+	}
+	return nil
+}
+
+func (es *EventStorage) CreateIngress(ingr ingress.IngressResource) (ingress.IngressResource, error) {
+	created, err := es.Storage.CreateIngress(ingr)
+	if err != nil {
+		return created, err
+	}
+	es.publishIngress(event.Created, created.NamespaceID, created.Name)
+	return created, nil
+}
+
+func (es *EventStorage) UpdateIngress(upd ingress.IngressResource) (ingress.IngressResource, error) {
+	updated, err := es.Storage.UpdateIngress(upd)
+	if err != nil {
+		return updated, err
+	}
+	es.publishIngress(event.Updated, upd.NamespaceID, upd.Name)
+	return updated, nil
+}
+
+func (es *EventStorage) UpdateIngressACME(ingr ingress.IngressResource) error {
+	if err := es.Storage.UpdateIngressACME(ingr); err != nil {
+		return err
+	}
+	es.publishIngress(event.Updated, ingr.NamespaceID, ingr.Name)
+	return nil
+}
+
+func (es *EventStorage) DeleteIngress(namespaceID, name string) error {
+	if err := es.Storage.DeleteIngress(namespaceID, name); err != nil {
+		return err
+	}
+	es.publishIngress(event.Deleted, namespaceID, name)
+	return nil
+}
+
+func (es *EventStorage) RestoreIngress(namespaceID, name string) error {
+	if err := es.Storage.RestoreIngress(namespaceID, name); err != nil {
+		return err
+	}
+	es.publishIngress(event.Created, namespaceID, name)
+	return nil
+}
+
+func (es *EventStorage) DeleteAllIngressesInNamespace(namespace string) error {
+	list, err := es.Storage.GetIngressList(namespace)
+	if err != nil {
+		return err
+	}
+	if err := es.Storage.DeleteAllIngressesInNamespace(namespace); err != nil {
+		return err
+	}
+	for _, ingr := range list {
+		es.publishIngress(event.Deleted, namespace, ingr.Name)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+)
+
+const (
+	// events buffered for each watcher, watcher which doesn't read events is disconnected and should resume
+	watcherBuffer = 64
+)
+
+type eventSubscription struct {
+	namespaceID string
+	events      chan event.Event
+}
+
+// EventBus -- in-process log of resource changes with subscriptions.
+// Last events are kept, so watchers may resume after reconnection.
+// Events are seen only by watchers connected to the same process.
+type EventBus struct {
+	mu          sync.Mutex
+	next        uint64
+	history     []event.Event
+	historySize int
+	subs        map[*eventSubscription]struct{}
+}
+
+// NewEventBus creates bus keeping historySize last events.
+// Event IDs start from current time, so IDs received from previous process run are reset instead of resumed.
+func NewEventBus(historySize int) *EventBus {
+	return &EventBus{
+		next:        uint64(time.Now().UnixNano()),
+		historySize: historySize,
+		subs:        make(map[*eventSubscription]struct{}),
+	}
+}
+
+// Publish assigns ID to event and sends it to watchers of event namespace
+func (bus *EventBus) Publish(ev event.Event) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	ev.ID = strconv.FormatUint(bus.next, 10)
+	bus.next++
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	bus.history = append(bus.history, ev)
+	if len(bus.history) > bus.historySize {
+		bus.history = append(bus.history[:0:0], bus.history[len(bus.history)-bus.historySize:]...)
+	}
+	for sub := range bus.subs {
+		if sub.namespaceID != ev.NamespaceID {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			// slow watcher is disconnected, it may resume from last received event
+			delete(bus.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Watch subscribes to namespace events. If lastEventID is set, kept events after it are sent first.
+// If events after lastEventID are not kept anymore, Reset event is sent first.
+// Events channel is closed after cancel call or if watcher doesn't read events.
+func (bus *EventBus) Watch(namespaceID, lastEventID string) (<-chan event.Event, func()) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	var missed []event.Event
+	if lastEventID != "" {
+		var resumed bool
+		missed, resumed = bus.eventsAfter(namespaceID, lastEventID)
+		if !resumed {
+			missed = []event.Event{{
+				ID:          strconv.FormatUint(bus.next-1, 10),
+				Type:        event.Reset,
+				NamespaceID: namespaceID,
+				Time:        time.Now().UTC(),
+			}}
+		}
+	}
+
+	var sub = &eventSubscription{
+		namespaceID: namespaceID,
+		events:      make(chan event.Event, len(missed)+watcherBuffer),
+	}
+	for _, ev := range missed {
+		sub.events <- ev
+	}
+	bus.subs[sub] = struct{}{}
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			bus.mu.Lock()
+			defer bus.mu.Unlock()
+			if _, ok := bus.subs[sub]; ok {
+				delete(bus.subs, sub)
+				close(sub.events)
+			}
+		})
+	}
+}
+
+// eventsAfter returns kept namespace events published after event with ID.
+// Returns false if events after ID are not kept or ID was not issued by this bus.
+func (bus *EventBus) eventsAfter(namespaceID, id string) ([]event.Event, bool) {
+	last, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || last >= bus.next {
+		return nil, false
+	}
+	var first = bus.next - uint64(len(bus.history))
+	if last+1 < first {
+		return nil, false
+	}
+	var events []event.Event
+	for _, ev := range bus.history[last+1-first:] {
+		if ev.NamespaceID == namespaceID {
+			events = append(events, ev)
+		}
+	}
+	return events, true
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus(2)
+
+	events, cancel := bus.Watch("ns", "")
+	bus.Publish(event.Event{Type: event.Created, NamespaceID: "ns", Name: "a"})
+	bus.Publish(event.Event{Type: event.Created, NamespaceID: "other", Name: "b"})
+	first := <-events
+	assert.Equal(t, "a", first.Name)
+	assert.Len(t, events, 0, "events of other namespace received")
+	cancel()
+	_, ok := <-events
+	assert.False(t, ok, "events channel must be closed after cancel")
+
+	bus.Publish(event.Event{Type: event.Deleted, NamespaceID: "ns", Name: "a"})
+	resumed, cancel := bus.Watch("ns", first.ID)
+	defer cancel()
+	if assert.Len(t, resumed, 1) {
+		ev := <-resumed
+		assert.Equal(t, event.Deleted, ev.Type)
+	}
+
+	bus.Publish(event.Event{Type: event.Created, NamespaceID: "ns", Name: "c"})
+	reset, cancel := bus.Watch("ns", first.ID)
+	defer cancel()
+	if assert.Len(t, reset, 1) {
+		ev := <-reset
+		assert.Equal(t, event.Reset, ev.Type)
+	}
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"regexp"
+
 	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"github.com/containerum/kube-client/pkg/model"
@@ -15,6 +17,9 @@ func (mongo *MongoStorage) CreateIngress(ingress ingress.IngressResource) (ingre
 	if ingress.ID == "" {
 		ingress.ID = uuid.New().String()
 	}
+	if ingress.Revision == 0 {
+		ingress.Revision = 1
+	}
 	if err := collection.Insert(ingress); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to create ingress")
 		if mgo.IsDup(err) {
@@ -44,9 +49,12 @@ func (mongo *MongoStorage) GetIngressByService(namespaceID, serviceName string)
 	var collection = mongo.db.C(CollectionIngress)
 	var ingr ingress.IngressResource
 	if err := collection.Find(bson.M{
-		"namespaceid":                    namespaceID,
-		"deleted":                        false,
-		"ingress.rules.path.servicename": serviceName,
+		"namespaceid": namespaceID,
+		"deleted":     false,
+		"$or": []bson.M{
+			{"ingress.rules.path.servicename": serviceName},
+			{"splits.backends.servicename": serviceName},
+		},
 	}).One(&ingr); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to get ingress")
 		if err == mgo.ErrNotFound {
@@ -57,6 +65,43 @@ func (mongo *MongoStorage) GetIngressByService(namespaceID, serviceName string)
 	return ingr, nil
 }
 
+// GetIngressByDomain returns ingress using domain or any of its subdomains, including wildcard ones
+func (mongo *MongoStorage) GetIngressByDomain(namespaceID, domain string) (ingress.IngressResource, error) {
+	mongo.logger.Debugf("getting ingress by domain")
+	var collection = mongo.db.C(CollectionIngress)
+	var ingr ingress.IngressResource
+	if err := collection.Find(bson.M{
+		"namespaceid":        namespaceID,
+		"deleted":            false,
+		"ingress.rules.host": bson.RegEx{Pattern: `^(.+\.)?` + regexp.QuoteMeta(domain) + `$`},
+	}).One(&ingr); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get ingress")
+		if err == mgo.ErrNotFound {
+			return ingr, rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return ingr, PipErr{err}.ToMongerr().Extract()
+	}
+	return ingr, nil
+}
+
+func (mongo *MongoStorage) GetIngressByTLSSecret(namespaceID, secretName string) (ingress.IngressResource, error) {
+	mongo.logger.Debugf("getting ingress by tls secret")
+	var collection = mongo.db.C(CollectionIngress)
+	var ingr ingress.IngressResource
+	if err := collection.Find(bson.M{
+		"namespaceid":             namespaceID,
+		"deleted":                 false,
+		"ingress.rules.tlssecret": secretName,
+	}).One(&ingr); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get ingress")
+		if err == mgo.ErrNotFound {
+			return ingr, rserrors.ErrResourceNotExists().AddDetails(secretName)
+		}
+		return ingr, PipErr{err}.ToMongerr().Extract()
+	}
+	return ingr, nil
+}
+
 func (mongo *MongoStorage) GetIngressList(namespaceID string) (ingress.IngressList, error) {
 	mongo.logger.Debugf("getting ingress")
 	var collection = mongo.db.C(CollectionIngress)
@@ -71,10 +116,18 @@ func (mongo *MongoStorage) GetIngressList(namespaceID string) (ingress.IngressLi
 func (mongo *MongoStorage) UpdateIngress(upd ingress.IngressResource) (ingress.IngressResource, error) {
 	mongo.logger.Debugf("updating ingress")
 	var collection = mongo.db.C(CollectionIngress)
-	if err := collection.Update(upd.OneSelectQuery(), upd.UpdateQuery()); err != nil {
+	var stored ingress.IngressResource
+	if _, err := collection.Find(upd.OneRevisionSelectQuery()).Apply(mgo.Change{
+		Update:    upd.UpdateQuery(),
+		ReturnNew: true,
+	}, &stored); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to update ingress")
+		if err == mgo.ErrNotFound && upd.Revision != 0 {
+			return upd, revisionConflict(collection, upd.OneSelectQuery(), upd.Name)
+		}
 		return upd, PipErr{err}.ToMongerr().Extract()
 	}
+	upd.Revision = stored.Revision
 	return upd, nil
 }
 
@@ -86,10 +139,7 @@ func (mongo *MongoStorage) DeleteIngress(namespaceID, name string) error {
 			Name: name,
 		},
 		NamespaceID: namespaceID,
-	}.OneSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.OneSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete ingress")
 		if err == mgo.ErrNotFound {
@@ -103,15 +153,17 @@ func (mongo *MongoStorage) DeleteIngress(namespaceID, name string) error {
 func (mongo *MongoStorage) RestoreIngress(namespaceID, name string) error {
 	mongo.logger.Debugf("restoring ingress")
 	var collection = mongo.db.C(CollectionIngress)
-	err := collection.Update(ingress.IngressResource{
+	// the latest deleted resource is restored
+	var deleted ingress.IngressResource
+	err := collection.Find(ingress.IngressResource{
 		Ingress: model.Ingress{
 			Name: name,
 		},
 		NamespaceID: namespaceID,
-	}.OneSelectDeletedQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": false},
-		})
+	}.OneSelectDeletedQuery()).Sort("-deletedat").One(&deleted)
+	if err == nil {
+		err = collection.UpdateId(deleted.ID, restoreQuery())
+	}
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to restore ingress")
 		if err == mgo.ErrNotFound {
@@ -127,10 +179,7 @@ func (mongo *MongoStorage) DeleteAllIngressesInNamespace(namespace string) error
 	var collection = mongo.db.C(CollectionIngress)
 	_, err := collection.UpdateAll(ingress.IngressResource{
 		NamespaceID: namespace,
-	}.AllSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployment")
 	}
@@ -142,10 +191,7 @@ func (mongo *MongoStorage) DeleteAllIngressesByOwner(owner string) error {
 	var collection = mongo.db.C(CollectionIngress)
 	_, err := collection.UpdateAll(ingress.IngressResource{
 		Ingress: model.Ingress{Owner: owner},
-	}.AllSelectOwnerQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectOwnerQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete deployments")
 	}
@@ -164,3 +210,85 @@ func (mongo *MongoStorage) CountIngresses(owner string) (int, error) {
 		return n, nil
 	}
 }
+
+// GetIngressesByHostsOverlap returns ingresses with hosts equal to one of hosts or overlapping it by wildcard
+func (mongo *MongoStorage) GetIngressesByHostsOverlap(hosts []string) (ingress.IngressList, error) {
+	mongo.logger.Debugf("getting ingresses by overlapping hosts")
+	var collection = mongo.db.C(CollectionIngress)
+	var exact = make([]string, 0, len(hosts))
+	var patterns []bson.M
+	for _, host := range hosts {
+		exact = append(exact, host)
+		if parent, ok := ingress.WildcardParent(host); ok {
+			exact = append(exact, parent)
+		}
+		if base, wildcard := ingress.WildcardBase(host); wildcard {
+			patterns = append(patterns, bson.M{
+				"ingress.rules.host": bson.RegEx{Pattern: `^[^.]+\.` + regexp.QuoteMeta(base) + `$`},
+			})
+		}
+	}
+	var list ingress.IngressList
+	if err := collection.Find(bson.M{
+		"deleted": false,
+		"$or": append(patterns, bson.M{
+			"ingress.rules.host": bson.M{"$in": exact},
+		}),
+	}).All(&list); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get ingresses by overlapping hosts")
+		return list, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return list, nil
+}
+
+// GetIngressHostConflicts returns hosts used by more than one ingress
+func (mongo *MongoStorage) GetIngressHostConflicts() (ingress.HostConflictList, error) {
+	mongo.logger.Debugf("getting ingress host conflicts")
+	var collection = mongo.db.C(CollectionIngress)
+	var conflicts = make(ingress.HostConflictList, 0)
+	if err := collection.Pipe([]bson.M{
+		{
+			"$match": bson.M{
+				"deleted": false,
+			},
+		},
+		{
+			"$unwind": "$ingress.rules",
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"host":        "$ingress.rules.host",
+					"namespaceid": "$namespaceid",
+					"ingress":     "$ingress.name",
+				},
+				"owner": bson.M{"$first": "$ingress.owner"},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": "$_id.host",
+				"ingresses": bson.M{
+					"$push": bson.M{
+						"namespaceid": "$_id.namespaceid",
+						"ingress":     "$_id.ingress",
+						"owner":       "$owner",
+					},
+				},
+				"count": bson.M{"$sum": 1},
+			},
+		},
+		{
+			"$match": bson.M{
+				"count": bson.M{"$gt": 1},
+			},
+		},
+		{
+			"$sort": bson.M{"_id": 1},
+		},
+	}).All(&conflicts); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get ingress host conflicts")
+		return conflicts, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const lockRecordType = "lock"
+
+func lockRecordID(name string) string {
+	return lockRecordType + "/" + name
+}
+
+// AcquireLock takes lock document in db collection if it is free, expired or already held by owner.
+// Lock held by other owner causes duplicate key error on upsert.
+func (mongo *MongoStorage) AcquireLock(name, owner string, ttl time.Duration) (bool, error) {
+	mongo.logger.Debugf("acquiring lock")
+	var collection = mongo.db.C(CollectionDB)
+	var now = time.Now().UTC()
+	_, err := collection.Upsert(bson.M{
+		"_id": lockRecordID(name),
+		"$or": []bson.M{
+			{"expiresat": bson.M{"$lt": now}},
+			{"owner": owner},
+		},
+	}, bson.M{
+		"_id":       lockRecordID(name),
+		"type":      lockRecordType,
+		"owner":     owner,
+		"expiresat": now.Add(ttl),
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case mgo.IsDup(err):
+		return false, nil
+	default:
+		mongo.logger.WithError(err).Errorf("unable to acquire lock")
+		return false, PipErr{err}.ToMongerr().Extract()
+	}
+}
+
+func (mongo *MongoStorage) ReleaseLock(name, owner string) error {
+	mongo.logger.Debugf("releasing lock")
+	var collection = mongo.db.C(CollectionDB)
+	if err := collection.Remove(bson.M{"_id": lockRecordID(name), "owner": owner}); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to release lock")
+		return PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo/bson"
+	"github.com/sirupsen/logrus"
+)
+
+// MemoryStorage -- storage which keeps everything in process memory.
+// It follows MongoStorage semantics: resources are soft deleted,
+// unique indexes are checked on every write and returned errors are the same.
+// Documents are stored as bson round-tripped copies, so fields not stored in mongo are not stored here too.
+type MemoryStorage struct {
+	logger logrus.FieldLogger
+	mu     sync.RWMutex
+	closed bool
+
+	deployments    []deployment.DeploymentResource
+	services       []service.ServiceResource
+	ingresses      []ingress.IngressResource
+	domains        []domain.Domain
+	customDomains  []customdomain.CustomDomain
+	certificates   []certificate.Certificate
+	acmeChallenges map[string]certificate.ACMEChallenge
+	acmeAccountKey string
+	operations     []operation.Operation
+	locks          map[string]memoryLock
+}
+
+// NewMemory creates empty in-memory storage
+func NewMemory() *MemoryStorage {
+	return &MemoryStorage{
+		logger:         logrus.WithField("component", "memory_storage"),
+		acmeChallenges: make(map[string]certificate.ACMEChallenge),
+		locks:          make(map[string]memoryLock),
+	}
+}
+
+func (mem *MemoryStorage) Close() error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if mem.closed {
+		return fmt.Errorf("memory storage already closed")
+	}
+	mem.closed = true
+	return nil
+}
+
+func (mem *MemoryStorage) IsClosed() bool {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.closed
+}
+
+// clone deep copies document through bson the same way it is stored in mongo
+func clone(in, out interface{}) {
+	data, err := bson.Marshal(in)
+	if err != nil {
+		panic(fmt.Sprintf("unable to marshal %T: %v", in, err))
+	}
+	if err := bson.Unmarshal(data, out); err != nil {
+		panic(fmt.Sprintf("unable to unmarshal %T: %v", out, err))
+	}
+}
+
+// uniqueKeys keeps keys of unique index and reports first duplicate
+type uniqueKeys struct {
+	index string
+	keys  map[string]struct{}
+}
+
+func newUniqueKeys(index string) *uniqueKeys {
+	return &uniqueKeys{
+		index: index,
+		keys:  make(map[string]struct{}),
+	}
+}
+
+// add adds document keys and returns error if any of them is already used by another document.
+// Equal keys of one document are allowed, like in mongo multikey indexes.
+func (unique *uniqueKeys) add(keys ...string) error {
+	var documentKeys = make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := documentKeys[key]; ok {
+			continue
+		}
+		if _, ok := unique.keys[key]; ok {
+			return rserrors.ErrResourceAlreadyExists().AddDetailF("duplicate key %s in index %s", key, unique.index)
+		}
+		documentKeys[key] = struct{}{}
+	}
+	for key := range documentKeys {
+		unique.keys[key] = struct{}{}
+	}
+	return nil
+}
+
+// indexKey formats fields as Go values, so numbers are not confused with characters they encode
+func indexKey(fields ...interface{}) string {
+	return fmt.Sprintf("%#v", fields)
+}
+
+// revisionMatches reports if stored revision is expected one, zero expected revision matches any
+func revisionMatches(expected, stored int64) bool {
+	return expected == 0 || expected == stored
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/google/uuid"
+)
+
+func checkCertificateIndexes(certs []certificate.Certificate) error {
+	var ids = newUniqueKeys("_id")
+	var alive = newUniqueKeys("alive_" + CollectionCertificate)
+	for _, cert := range certs {
+		if err := ids.add(cert.ID); err != nil {
+			return err
+		}
+		if cert.Deleted {
+			continue
+		}
+		if err := alive.add(indexKey(cert.Name, cert.NamespaceID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCertificateDeleted sets deleted flag of first certificate with opposite flag and saves it if indexes are not violated
+func (mem *MemoryStorage) setCertificateDeleted(namespaceID, name string, deleted bool) (bool, error) {
+	var updated = append(make([]certificate.Certificate, 0, len(mem.certificates)), mem.certificates...)
+	for i, cert := range updated {
+		if cert.Deleted == deleted || cert.NamespaceID != namespaceID || cert.Name != name {
+			continue
+		}
+		updated[i].Deleted = deleted
+		if err := checkCertificateIndexes(updated); err != nil {
+			return true, err
+		}
+		mem.certificates = updated
+		return true, nil
+	}
+	return false, nil
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (mem *MemoryStorage) CreateCertificate(cert certificate.Certificate) (certificate.Certificate, error) {
+	mem.logger.Debugf("creating certificate")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if cert.ID == "" {
+		cert.ID = uuid.New().String()
+	}
+	var stored certificate.Certificate
+	clone(cert, &stored)
+	var updated = append(append(make([]certificate.Certificate, 0, len(mem.certificates)+1), mem.certificates...), stored)
+	if err := checkCertificateIndexes(updated); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create certificate")
+		return cert, err
+	}
+	mem.certificates = updated
+	return cert, nil
+}
+
+func (mem *MemoryStorage) GetCertificate(namespaceID, name string) (certificate.Certificate, error) {
+	mem.logger.Debugf("getting certificate")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	for _, cert := range mem.certificates {
+		if !cert.Deleted && cert.NamespaceID == namespaceID && cert.Name == name {
+			var result certificate.Certificate
+			clone(cert, &result)
+			return result, nil
+		}
+	}
+	return certificate.Certificate{}, rserrors.ErrResourceNotExists().AddDetails(name)
+}
+
+func (mem *MemoryStorage) GetCertificateList(namespaceID string) (certificate.CertificateList, error) {
+	mem.logger.Debugf("getting certificate list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var result certificate.CertificateList
+	for _, cert := range mem.certificates {
+		if !cert.Deleted && cert.NamespaceID == namespaceID {
+			var cp certificate.Certificate
+			clone(cert, &cp)
+			result = append(result, cp)
+		}
+	}
+	return result, nil
+}
+
+func (mem *MemoryStorage) DeleteCertificate(namespaceID, name string) error {
+	mem.logger.Debugf("deleting certificate")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	found, err := mem.setCertificateDeleted(namespaceID, name, true)
+	switch {
+	case !found:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	case err != nil:
+		return err
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) RestoreCertificate(namespaceID, name string) error {
+	mem.logger.Debugf("restoring certificate")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	found, err := mem.setCertificateDeleted(namespaceID, name, false)
+	switch {
+	case !found:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to restore certificate")
+		return err
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) CreateACMEChallenge(challenge certificate.ACMEChallenge) error {
+	mem.logger.Debugf("creating acme challenge")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	mem.acmeChallenges[challenge.Token] = challenge
+	return nil
+}
+
+func (mem *MemoryStorage) GetACMEChallenge(token string) (certificate.ACMEChallenge, error) {
+	mem.logger.Debugf("getting acme challenge")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	challenge, ok := mem.acmeChallenges[token]
+	if !ok {
+		return challenge, rserrors.ErrResourceNotExists().AddDetails(token)
+	}
+	return challenge, nil
+}
+
+// DeleteACMEChallenge removes challenge completely, challenges are not kept after authorization
+func (mem *MemoryStorage) DeleteACMEChallenge(token string) error {
+	mem.logger.Debugf("deleting acme challenge")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	delete(mem.acmeChallenges, token)
+	return nil
+}
+
+func (mem *MemoryStorage) InitACMEAccountKey(keyPEM string) (string, error) {
+	mem.logger.Debugf("initializing acme account key")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if mem.acmeAccountKey == "" {
+		mem.acmeAccountKey = keyPEM
+	}
+	return mem.acmeAccountKey, nil
+}
@@ -0,0 +1,434 @@
+package db
+
+import (
+	"time"
+
+	"sort"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/blang/semver"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+func checkDeploymentIndexes(deployments []deployment.DeploymentResource) error {
+	var ids = newUniqueKeys("_id")
+	var alive = newUniqueKeys("alive_" + CollectionDeployment)
+	var versions = newUniqueKeys("unique_version_" + CollectionDeployment)
+	for _, depl := range deployments {
+		if err := ids.add(depl.ID); err != nil {
+			return err
+		}
+		if depl.Deleted {
+			continue
+		}
+		if err := versions.add(indexKey(depl.Name, depl.NamespaceID, depl.Version.String())); err != nil {
+			return err
+		}
+		if depl.Active {
+			if err := alive.add(indexKey(depl.Name, depl.NamespaceID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateDeployments applies update to copies of deployments matching filter and saves them if indexes are not violated.
+// If all is false only first matching deployment is updated. Returns number of matched deployments.
+func (mem *MemoryStorage) updateDeployments(filter func(deployment.DeploymentResource) bool, update func(*deployment.DeploymentResource), all bool) (int, error) {
+	var updated = append(make([]deployment.DeploymentResource, 0, len(mem.deployments)), mem.deployments...)
+	var matched int
+	for i, depl := range updated {
+		if !filter(depl) {
+			continue
+		}
+		matched++
+		update(&updated[i])
+		if !all {
+			break
+		}
+	}
+	if matched == 0 {
+		return 0, nil
+	}
+	if err := checkDeploymentIndexes(updated); err != nil {
+		return 0, err
+	}
+	mem.deployments = updated
+	return matched, nil
+}
+
+func (mem *MemoryStorage) findDeployments(filter func(deployment.DeploymentResource) bool) deployment.DeploymentList {
+	var list deployment.DeploymentList
+	for _, depl := range mem.deployments {
+		if filter(depl) {
+			var cp deployment.DeploymentResource
+			clone(depl, &cp)
+			list = append(list, cp)
+		}
+	}
+	return list
+}
+
+func activeDeployment(namespaceID, name string) func(deployment.DeploymentResource) bool {
+	return func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.Active && depl.NamespaceID == namespaceID && depl.Name == name
+	}
+}
+
+func deploymentVersion(namespaceID, name string, version semver.Version) func(deployment.DeploymentResource) bool {
+	return func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.NamespaceID == namespaceID && depl.Name == name && depl.Version.String() == version.String()
+	}
+}
+
+func sortDeploymentsByVersionDesc(list deployment.DeploymentList) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Version.GT(list[j].Version)
+	})
+}
+
+func (mem *MemoryStorage) GetDeployment(namespaceID, deploymentName string) (deployment.DeploymentResource, error) {
+	mem.logger.Debugf("getting deployment by name")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDeployments(activeDeployment(namespaceID, deploymentName))
+	if len(list) == 0 {
+		return deployment.DeploymentResource{}, rserrors.ErrResourceNotExists().AddDetails(deploymentName)
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) GetDeploymentVersion(namespaceID, deploymentName string, version semver.Version) (deployment.DeploymentResource, error) {
+	mem.logger.Debugf("getting deployment version by name")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDeployments(deploymentVersion(namespaceID, deploymentName, version))
+	if len(list) == 0 {
+		return deployment.DeploymentResource{}, rserrors.ErrResourceNotExists().AddDetailF("%v %v", deploymentName, version.String())
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) GetDeploymentLatestVersion(namespaceID, deploymentName string) (deployment.DeploymentResource, error) {
+	mem.logger.Debugf("getting deployment latest version by name")
+	list, _ := mem.GetDeploymentVersionsList(namespaceID, deploymentName)
+	if len(list) == 0 {
+		return deployment.DeploymentResource{}, rserrors.ErrResourceNotExists().AddDetails(deploymentName)
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) GetDeploymentVersionsList(namespaceID string, deploymentName string) (deployment.DeploymentList, error) {
+	mem.logger.Debugf("getting deployment versions list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDeployments(func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.NamespaceID == namespaceID && depl.Name == deploymentName
+	})
+	sortDeploymentsByVersionDesc(list)
+	return list, nil
+}
+
+func (mem *MemoryStorage) GetDeploymentList(namespaceID string) (deployment.DeploymentList, error) {
+	mem.logger.Debugf("getting deployment list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findDeployments(func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.Active && depl.NamespaceID == namespaceID
+	}), nil
+}
+
+// If ID is empty when use UUID4 to generate one
+func (mem *MemoryStorage) CreateDeployment(depl deployment.DeploymentResource) (deployment.DeploymentResource, error) {
+	mem.logger.Debugf("creating deployment")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if depl.ID == "" {
+		depl.ID = uuid.New().String()
+	}
+	if depl.Revision == 0 {
+		depl.Revision = 1
+	}
+	var stored deployment.DeploymentResource
+	clone(depl, &stored)
+	var updated = append(append(make([]deployment.DeploymentResource, 0, len(mem.deployments)+1), mem.deployments...), stored)
+	if err := checkDeploymentIndexes(updated); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create deployment")
+		return depl, err
+	}
+	mem.deployments = updated
+	return depl, nil
+}
+
+func (mem *MemoryStorage) UpdateActiveDeployment(upd deployment.DeploymentResource) error {
+	mem.logger.Debugf("updating deployment")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var stored deployment.DeploymentResource
+	clone(upd, &stored)
+	var active = activeDeployment(upd.NamespaceID, upd.Name)
+	matched, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return active(depl) && revisionMatches(upd.Revision, depl.Revision)
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deployment = stored.Deployment
+		depl.Revision++
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to update deployment")
+		return err
+	case matched == 0 && len(mem.findDeployments(active)) > 0:
+		return rserrors.ErrResourceModified().AddDetails(upd.Name)
+	case matched == 0:
+		return mgo.ErrNotFound
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version, revision int64) error {
+	mem.logger.Debugf("updating deployment version")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var version = deploymentVersion(namespace, name, oldversion)
+	matched, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return version(depl) && revisionMatches(revision, depl.Revision)
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Version = newversion
+		depl.Revision++
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to update deployment version")
+		return err
+	case matched == 0 && len(mem.findDeployments(version)) > 0:
+		return rserrors.ErrResourceModified().AddDetails(name)
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, oldversion.String())
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) DeleteDeployment(namespace, name string) error {
+	mem.logger.Debugf("deleting deployment")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateDeployments(activeDeployment(namespace, name), func(depl *deployment.DeploymentResource) {
+		depl.Deleted = true
+		depl.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) ActivateDeployment(namespace, name string, version semver.Version) error {
+	mem.logger.Debugf("activating deployment")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	matched, err := mem.updateDeployments(deploymentVersion(namespace, name, version), func(depl *deployment.DeploymentResource) {
+		depl.Active = true
+		depl.Revision++
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to activate deployment")
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, version.String())
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) DeactivateDeployment(namespace, name string, revision int64) error {
+	mem.logger.Debugf("deactivating deployment")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var active = activeDeployment(namespace, name)
+	matched, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return active(depl) && revisionMatches(revision, depl.Revision)
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Active = false
+		depl.Revision++
+	}, true)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to deactivate deployment")
+		return err
+	case matched == 0 && revision != 0 && len(mem.findDeployments(active)) > 0:
+		return rserrors.ErrResourceModified().AddDetails(name)
+	case matched == 0 && revision != 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+// DeleteDeploymentVersion deletes inactive version of deployment
+func (mem *MemoryStorage) DeleteDeploymentVersion(namespace, name string, version semver.Version) error {
+	mem.logger.Debugf("deleting deployment version")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	matched, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return deploymentVersion(namespace, name, version)(depl) && !depl.Active
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deleted = true
+		depl.DeletedAt = &deletedAt
+	}, false)
+	switch {
+	case err != nil:
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, version.String())
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) RestoreDeployment(namespace, name string) error {
+	mem.logger.Debugf("restoring deployment")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var id = mem.latestDeletedDeployment(namespace, name)
+	matched, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return id != "" && depl.ID == id
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deleted = false
+		depl.DeletedAt = nil
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to restore deployment")
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) RestoreDeploymentVersions(namespace, name string, deletedAt time.Time) error {
+	mem.logger.Debugf("restoring deployment versions")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	// deletion time is compared with precision kept by bson, as in mongo
+	deletedAt = deletedAt.Truncate(time.Millisecond)
+	var alive = make(map[string]struct{})
+	for _, depl := range mem.findDeployments(func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.NamespaceID == namespace && depl.Name == name
+	}) {
+		alive[depl.Version.String()] = struct{}{}
+	}
+	_, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		_, isAlive := alive[depl.Version.String()]
+		return depl.Deleted && depl.NamespaceID == namespace && depl.Name == name && !isAlive &&
+			depl.DeletedAt != nil && depl.DeletedAt.Truncate(time.Millisecond).Equal(deletedAt)
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deleted = false
+		depl.DeletedAt = nil
+	}, true)
+	if err != nil {
+		mem.logger.WithError(err).Errorf("unable to restore deployment versions")
+	}
+	return err
+}
+
+func (mem *MemoryStorage) DeleteAllDeploymentsInNamespace(namespace string) error {
+	mem.logger.Debugf("deleting all deployments in namespace")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.NamespaceID == namespace
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deleted = true
+		depl.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) DeleteAllDeploymentsByOwner(owner string) error {
+	mem.logger.Debugf("deleting all user deployments")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateDeployments(func(depl deployment.DeploymentResource) bool {
+		return !depl.Deleted && depl.Owner == owner
+	}, func(depl *deployment.DeploymentResource) {
+		depl.Deleted = true
+		depl.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) CountDeployments(owner string) (int, error) {
+	mem.logger.Debugf("counting deployment")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var count int
+	for _, depl := range mem.deployments {
+		if !depl.Deleted && depl.Active && depl.Owner == owner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (mem *MemoryStorage) CountReplicas(owner string) (int, error) {
+	mem.logger.Debugf("counting deployment replicas")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var count int
+	for _, depl := range mem.deployments {
+		if !depl.Deleted && depl.Active && depl.Owner == owner {
+			count += depl.Replicas
+		}
+	}
+	return count, nil
+}
+
+func (mem *MemoryStorage) GetNamespaceResourcesLimits(namespaceID string) (model.Resource, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var res model.Resource
+	for _, depl := range mem.deployments {
+		if depl.Deleted || !depl.Active || depl.NamespaceID != namespaceID {
+			continue
+		}
+		var cpu, memory uint
+		for _, container := range depl.Containers {
+			cpu += container.Limits.CPU
+			memory += container.Limits.Memory
+		}
+		res.CPU += cpu * uint(depl.Replicas)
+		res.Memory += memory * uint(depl.Replicas)
+	}
+	return res, nil
+}
+
+func (mem *MemoryStorage) GetNamespacesList() ([]string, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var namespaces = make(map[string]struct{})
+	for _, depl := range mem.deployments {
+		if !depl.Deleted && depl.Active {
+			namespaces[depl.NamespaceID] = struct{}{}
+		}
+	}
+	for _, svc := range mem.services {
+		if !svc.Deleted {
+			namespaces[svc.NamespaceID] = struct{}{}
+		}
+	}
+	for _, ingr := range mem.ingresses {
+		if !ingr.Deleted {
+			namespaces[ingr.NamespaceID] = struct{}{}
+		}
+	}
+	var ret = make([]string, 0, len(namespaces))
+	for id := range namespaces {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+func (mem *MemoryStorage) findDomains(filter func(domain.Domain) bool) domain.DomainList {
+	var list domain.DomainList
+	for _, dom := range mem.domains {
+		if filter(dom) {
+			var cp domain.Domain
+			clone(dom, &cp)
+			list = append(list, cp)
+		}
+	}
+	return list
+}
+
+func (mem *MemoryStorage) GetDomain(domainName string, pages ...uint) (*domain.Domain, error) {
+	mem.logger.Debugf("getting domain")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDomains(func(dom domain.Domain) bool {
+		return dom.Domain == domainName
+	})
+	if len(list) == 0 {
+		return nil, mgo.ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (mem *MemoryStorage) GetRandomDomain() (*domain.Domain, error) {
+	mem.logger.Debugf("getting random domain")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	if len(mem.domains) == 0 {
+		return nil, mgo.ErrNotFound
+	}
+	var result domain.Domain
+	clone(mem.domains[rnd.Intn(len(mem.domains))], &result)
+	return &result, nil
+}
+
+func (mem *MemoryStorage) GetDomainGroup(domainGroup string) (domain.DomainList, error) {
+	mem.logger.Debugf("getting domain group")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findDomains(func(dom domain.Domain) bool {
+		return dom.DomainGroup == domainGroup
+	}), nil
+}
+
+// GetDomainsList supports pagination
+func (mem *MemoryStorage) GetDomainsList(pages *PageInfo) ([]domain.Domain, error) {
+	mem.logger.Debugf("getting domain list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDomains(func(domain.Domain) bool { return true })
+	if pages != nil {
+		var limit, offset = pages.Init()
+		if offset > len(list) {
+			offset = len(list)
+		}
+		list = list[offset:]
+		if limit < len(list) {
+			list = list[:limit]
+		}
+	}
+	return list, nil
+}
+
+func (mem *MemoryStorage) CreateDomain(dom domain.Domain) (*domain.Domain, error) {
+	mem.logger.Debugf("creating domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if dom.ID == "" {
+		dom.ID = uuid.New().String()
+	}
+	for _, existing := range mem.domains {
+		if existing.ID == dom.ID {
+			return nil, rserrors.ErrResourceAlreadyExists().AddDetailF("duplicate key %s in index _id", dom.ID)
+		}
+	}
+	var stored domain.Domain
+	clone(dom, &stored)
+	mem.domains = append(mem.domains, stored)
+	return &dom, nil
+}
+
+func (mem *MemoryStorage) UpdateDomain(dom domain.Domain) (*domain.Domain, error) {
+	mem.logger.Debugf("updating domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	for i, existing := range mem.domains {
+		if existing.Domain != dom.Domain {
+			continue
+		}
+		var stored domain.Domain
+		clone(dom, &stored)
+		stored.ID = existing.ID
+		var updated = append(make([]domain.Domain, 0, len(mem.domains)), mem.domains...)
+		updated[i] = stored
+		mem.domains = updated
+		return &dom, nil
+	}
+	return nil, mgo.ErrNotFound
+}
+
+func (mem *MemoryStorage) DeleteDomain(domainName string) error {
+	mem.logger.Debugf("deleting domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	for i, existing := range mem.domains {
+		if existing.Domain == domainName {
+			mem.domains = append(append(make([]domain.Domain, 0, len(mem.domains)-1), mem.domains[:i]...), mem.domains[i+1:]...)
+			return nil
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func checkCustomDomainIndexes(domains []customdomain.CustomDomain) error {
+	var ids = newUniqueKeys("_id")
+	var alive = newUniqueKeys("alive_" + CollectionCustomDomain)
+	var verified = newUniqueKeys("verified_" + CollectionCustomDomain)
+	for _, dom := range domains {
+		if err := ids.add(dom.ID); err != nil {
+			return err
+		}
+		if dom.Deleted {
+			continue
+		}
+		if err := alive.add(indexKey(dom.Domain, dom.NamespaceID)); err != nil {
+			return err
+		}
+		if dom.Verified {
+			if err := verified.add(dom.Domain); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateCustomDomain applies update to copy of first custom domain matching filter and saves it if indexes are not violated
+func (mem *MemoryStorage) updateCustomDomain(filter func(customdomain.CustomDomain) bool, update func(*customdomain.CustomDomain)) (bool, error) {
+	var updated = append(make([]customdomain.CustomDomain, 0, len(mem.customDomains)), mem.customDomains...)
+	for i, dom := range updated {
+		if !filter(dom) {
+			continue
+		}
+		update(&updated[i])
+		if err := checkCustomDomainIndexes(updated); err != nil {
+			return true, err
+		}
+		mem.customDomains = updated
+		return true, nil
+	}
+	return false, nil
+}
+
+func (mem *MemoryStorage) findCustomDomains(filter func(customdomain.CustomDomain) bool) customdomain.CustomDomainList {
+	var list customdomain.CustomDomainList
+	for _, dom := range mem.customDomains {
+		if filter(dom) {
+			var cp customdomain.CustomDomain
+			clone(dom, &cp)
+			list = append(list, cp)
+		}
+	}
+	return list
+}
+
+func aliveCustomDomain(namespaceID, domain string) func(customdomain.CustomDomain) bool {
+	return func(dom customdomain.CustomDomain) bool {
+		return !dom.Deleted && dom.NamespaceID == namespaceID && dom.Domain == domain
+	}
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (mem *MemoryStorage) CreateCustomDomain(dom customdomain.CustomDomain) (customdomain.CustomDomain, error) {
+	mem.logger.Debugf("creating custom domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if dom.ID == "" {
+		dom.ID = uuid.New().String()
+	}
+	var stored customdomain.CustomDomain
+	clone(dom, &stored)
+	var updated = append(append(make([]customdomain.CustomDomain, 0, len(mem.customDomains)+1), mem.customDomains...), stored)
+	if err := checkCustomDomainIndexes(updated); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create custom domain")
+		return dom, err
+	}
+	mem.customDomains = updated
+	return dom, nil
+}
+
+func (mem *MemoryStorage) GetCustomDomain(namespaceID, domain string) (customdomain.CustomDomain, error) {
+	mem.logger.Debugf("getting custom domain")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findCustomDomains(aliveCustomDomain(namespaceID, domain))
+	if len(list) == 0 {
+		return customdomain.CustomDomain{}, rserrors.ErrResourceNotExists().AddDetails(domain)
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) GetCustomDomainList(namespaceID string) (customdomain.CustomDomainList, error) {
+	mem.logger.Debugf("getting custom domain list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findCustomDomains(func(dom customdomain.CustomDomain) bool {
+		return !dom.Deleted && dom.NamespaceID == namespaceID
+	}), nil
+}
+
+// GetVerifiedCustomDomain returns verified domain from any namespace
+func (mem *MemoryStorage) GetVerifiedCustomDomain(domain string) (customdomain.CustomDomain, error) {
+	mem.logger.Debugf("getting verified custom domain")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findCustomDomains(func(dom customdomain.CustomDomain) bool {
+		return !dom.Deleted && dom.Verified && dom.Domain == domain
+	})
+	if len(list) == 0 {
+		return customdomain.CustomDomain{}, rserrors.ErrResourceNotExists().AddDetails(domain)
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) VerifyCustomDomain(namespaceID, domain, verifiedAt string) error {
+	mem.logger.Debugf("verifying custom domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	found, err := mem.updateCustomDomain(aliveCustomDomain(namespaceID, domain), func(dom *customdomain.CustomDomain) {
+		dom.Verified = true
+		dom.VerifiedAt = &verifiedAt
+	})
+	switch {
+	case !found:
+		return rserrors.ErrResourceNotExists().AddDetails(domain)
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to verify custom domain")
+		return rserrors.ErrResourceAlreadyExists().AddDetailF("domain %s is already verified in another namespace", domain)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) DeleteCustomDomain(namespaceID, domain string) error {
+	mem.logger.Debugf("deleting custom domain")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	found, err := mem.updateCustomDomain(aliveCustomDomain(namespaceID, domain), func(dom *customdomain.CustomDomain) {
+		dom.Deleted = true
+	})
+	switch {
+	case !found:
+		return rserrors.ErrResourceNotExists().AddDetails(domain)
+	case err != nil:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package db
+
+import (
+	"time"
+
+	"sort"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+func checkIngressIndexes(ingresses []ingress.IngressResource) error {
+	var ids = newUniqueKeys("_id")
+	var alive = newUniqueKeys("alive_" + CollectionIngress)
+	var hosts = newUniqueKeys("unique_host_" + CollectionIngress)
+	for _, ingr := range ingresses {
+		if err := ids.add(ingr.ID); err != nil {
+			return err
+		}
+		if ingr.Deleted {
+			continue
+		}
+		if err := alive.add(indexKey(ingr.Name, ingr.NamespaceID)); err != nil {
+			return err
+		}
+		if err := hosts.add(ingr.Hosts()...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateIngresses applies update to copies of ingresses matching filter and saves them if indexes are not violated.
+// If all is false only first matching ingress is updated. Returns number of matched ingresses.
+func (mem *MemoryStorage) updateIngresses(filter func(ingress.IngressResource) bool, update func(*ingress.IngressResource), all bool) (int, error) {
+	var updated = append(make([]ingress.IngressResource, 0, len(mem.ingresses)), mem.ingresses...)
+	var matched int
+	for i, ingr := range updated {
+		if !filter(ingr) {
+			continue
+		}
+		matched++
+		update(&updated[i])
+		if !all {
+			break
+		}
+	}
+	if matched == 0 {
+		return 0, nil
+	}
+	if err := checkIngressIndexes(updated); err != nil {
+		return 0, err
+	}
+	mem.ingresses = updated
+	return matched, nil
+}
+
+func (mem *MemoryStorage) findIngresses(filter func(ingress.IngressResource) bool) ingress.IngressList {
+	var list ingress.IngressList
+	for _, ingr := range mem.ingresses {
+		if filter(ingr) {
+			var cp ingress.IngressResource
+			clone(ingr, &cp)
+			list = append(list, cp)
+		}
+	}
+	return list
+}
+
+func (mem *MemoryStorage) findIngress(filter func(ingress.IngressResource) bool, details string) (ingress.IngressResource, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findIngresses(filter)
+	if len(list) == 0 {
+		return ingress.IngressResource{}, rserrors.ErrResourceNotExists().AddDetails(details)
+	}
+	return list[0], nil
+}
+
+func aliveIngress(namespaceID, name string) func(ingress.IngressResource) bool {
+	return func(ingr ingress.IngressResource) bool {
+		return !ingr.Deleted && ingr.NamespaceID == namespaceID && ingr.Name == name
+	}
+}
+
+func (mem *MemoryStorage) CreateIngress(ingr ingress.IngressResource) (ingress.IngressResource, error) {
+	mem.logger.Debugf("creating ingress")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if ingr.ID == "" {
+		ingr.ID = uuid.New().String()
+	}
+	if ingr.Revision == 0 {
+		ingr.Revision = 1
+	}
+	var stored ingress.IngressResource
+	clone(ingr, &stored)
+	var updated = append(append(make([]ingress.IngressResource, 0, len(mem.ingresses)+1), mem.ingresses...), stored)
+	if err := checkIngressIndexes(updated); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create ingress")
+		return ingr, err
+	}
+	mem.ingresses = updated
+	return ingr, nil
+}
+
+func (mem *MemoryStorage) GetIngress(namespaceID, name string) (ingress.IngressResource, error) {
+	mem.logger.Debugf("getting ingress")
+	return mem.findIngress(aliveIngress(namespaceID, name), name)
+}
+
+func (mem *MemoryStorage) GetIngressByService(namespaceID, serviceName string) (ingress.IngressResource, error) {
+	mem.logger.Debugf("getting ingress by service")
+	return mem.findIngress(func(ingr ingress.IngressResource) bool {
+		if ingr.Deleted || ingr.NamespaceID != namespaceID {
+			return false
+		}
+		for _, path := range ingr.Paths() {
+			if path.ServiceName == serviceName {
+				return true
+			}
+		}
+		for _, split := range ingr.Splits {
+			for _, backend := range split.Backends {
+				if backend.ServiceName == serviceName {
+					return true
+				}
+			}
+		}
+		return false
+	}, serviceName)
+}
+
+// GetIngressByDomain returns ingress using domain or any of its subdomains, including wildcard ones
+func (mem *MemoryStorage) GetIngressByDomain(namespaceID, domain string) (ingress.IngressResource, error) {
+	mem.logger.Debugf("getting ingress by domain")
+	return mem.findIngress(func(ingr ingress.IngressResource) bool {
+		if ingr.Deleted || ingr.NamespaceID != namespaceID {
+			return false
+		}
+		for _, ingrHost := range ingr.Hosts() {
+			if ingress.HostInDomain(ingrHost, domain) {
+				return true
+			}
+		}
+		return false
+	}, domain)
+}
+
+func (mem *MemoryStorage) GetIngressByTLSSecret(namespaceID, secretName string) (ingress.IngressResource, error) {
+	mem.logger.Debugf("getting ingress by tls secret")
+	return mem.findIngress(func(ingr ingress.IngressResource) bool {
+		if ingr.Deleted || ingr.NamespaceID != namespaceID {
+			return false
+		}
+		for _, rule := range ingr.Rules {
+			if rule.TLSSecret != nil && *rule.TLSSecret == secretName {
+				return true
+			}
+		}
+		return false
+	}, secretName)
+}
+
+func (mem *MemoryStorage) GetIngressList(namespaceID string) (ingress.IngressList, error) {
+	mem.logger.Debugf("getting ingress")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findIngresses(func(ingr ingress.IngressResource) bool {
+		return !ingr.Deleted && ingr.NamespaceID == namespaceID
+	}), nil
+}
+
+func (mem *MemoryStorage) UpdateIngress(upd ingress.IngressResource) (ingress.IngressResource, error) {
+	mem.logger.Debugf("updating ingress")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var stored ingress.IngressResource
+	clone(upd, &stored)
+	var alive = aliveIngress(upd.NamespaceID, upd.Name)
+	matched, err := mem.updateIngresses(func(ingr ingress.IngressResource) bool {
+		return alive(ingr) && revisionMatches(upd.Revision, ingr.Revision)
+	}, func(ingr *ingress.IngressResource) {
+		ingr.Ingress = stored.Ingress
+		ingr.Options = stored.Options
+		ingr.Splits = stored.Splits
+		ingr.Revision++
+		upd.Revision = ingr.Revision
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to update ingress")
+		return upd, err
+	case matched == 0 && len(mem.findIngresses(alive)) > 0:
+		return upd, rserrors.ErrResourceModified().AddDetails(upd.Name)
+	case matched == 0:
+		return upd, mgo.ErrNotFound
+	}
+	return upd, nil
+}
+
+func (mem *MemoryStorage) DeleteIngress(namespaceID, name string) error {
+	mem.logger.Debugf("deleting ingress")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	matched, err := mem.updateIngresses(aliveIngress(namespaceID, name), func(ingr *ingress.IngressResource) {
+		ingr.Deleted = true
+		ingr.DeletedAt = &deletedAt
+	}, false)
+	switch {
+	case err != nil:
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) RestoreIngress(namespaceID, name string) error {
+	mem.logger.Debugf("restoring ingress")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var id = mem.latestDeletedIngress(namespaceID, name)
+	matched, err := mem.updateIngresses(func(ingr ingress.IngressResource) bool {
+		return id != "" && ingr.ID == id
+	}, func(ingr *ingress.IngressResource) {
+		ingr.Deleted = false
+		ingr.DeletedAt = nil
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to restore ingress")
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) DeleteAllIngressesInNamespace(namespace string) error {
+	mem.logger.Debugf("deleting all ingresses in namespace")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateIngresses(func(ingr ingress.IngressResource) bool {
+		return !ingr.Deleted && ingr.NamespaceID == namespace
+	}, func(ingr *ingress.IngressResource) {
+		ingr.Deleted = true
+		ingr.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) DeleteAllIngressesByOwner(owner string) error {
+	mem.logger.Debugf("deleting all user ingresses")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateIngresses(func(ingr ingress.IngressResource) bool {
+		return !ingr.Deleted && ingr.Owner == owner
+	}, func(ingr *ingress.IngressResource) {
+		ingr.Deleted = true
+		ingr.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) CountIngresses(owner string) (int, error) {
+	mem.logger.Debugf("counting ingresses")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var count int
+	for _, ingr := range mem.ingresses {
+		if !ingr.Deleted && ingr.Owner == owner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetIngressesByHostsOverlap returns ingresses with hosts equal to one of hosts or overlapping it by wildcard
+func (mem *MemoryStorage) GetIngressesByHostsOverlap(hosts []string) (ingress.IngressList, error) {
+	mem.logger.Debugf("getting ingresses by overlapping hosts")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findIngresses(func(ingr ingress.IngressResource) bool {
+		if ingr.Deleted {
+			return false
+		}
+		for _, ingrHost := range ingr.Hosts() {
+			for _, host := range hosts {
+				if ingress.HostsOverlap(ingrHost, host) {
+					return true
+				}
+			}
+		}
+		return false
+	}), nil
+}
+
+// GetIngressHostConflicts returns hosts used by more than one ingress
+func (mem *MemoryStorage) GetIngressHostConflicts() (ingress.HostConflictList, error) {
+	mem.logger.Debugf("getting ingress host conflicts")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var users = make(map[string][]ingress.HostUser)
+	var hosts []string
+	for _, ingr := range mem.ingresses {
+		if ingr.Deleted {
+			continue
+		}
+		for _, host := range ingr.Hosts() {
+			if _, ok := users[host]; !ok {
+				hosts = append(hosts, host)
+			}
+			users[host] = append(users[host], ingress.HostUser{
+				NamespaceID: ingr.NamespaceID,
+				Ingress:     ingr.Name,
+				Owner:       ingr.Owner,
+			})
+		}
+	}
+	sort.Strings(hosts)
+	var conflicts = make(ingress.HostConflictList, 0)
+	for _, host := range hosts {
+		if len(users[host]) > 1 {
+			conflicts = append(conflicts, ingress.HostConflict{
+				Host:      host,
+				Ingresses: users[host],
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+func (mem *MemoryStorage) GetACMEIngressList() (ingress.IngressList, error) {
+	mem.logger.Debugf("getting ingresses with acme enabled")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findIngresses(func(ingr ingress.IngressResource) bool {
+		return !ingr.Deleted && ingr.ACME != nil && ingr.ACME.Enabled
+	}), nil
+}
+
+func (mem *MemoryStorage) UpdateIngressACME(upd ingress.IngressResource) error {
+	mem.logger.Debugf("updating ingress acme status")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var stored ingress.IngressResource
+	clone(upd, &stored)
+	matched, err := mem.updateIngresses(aliveIngress(upd.NamespaceID, upd.Name), func(ingr *ingress.IngressResource) {
+		ingr.ACME = stored.ACME
+		ingr.Revision++
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to update ingress acme status")
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(upd.Name)
+	}
+	return nil
+}
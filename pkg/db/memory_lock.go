@@ -0,0 +1,32 @@
+package db
+
+import (
+	"time"
+)
+
+type memoryLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+func (mem *MemoryStorage) AcquireLock(name, owner string, ttl time.Duration) (bool, error) {
+	mem.logger.Debugf("acquiring lock")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var now = time.Now().UTC()
+	if lock, ok := mem.locks[name]; ok && lock.owner != owner && !lock.expiresAt.Before(now) {
+		return false, nil
+	}
+	mem.locks[name] = memoryLock{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (mem *MemoryStorage) ReleaseLock(name, owner string) error {
+	mem.logger.Debugf("releasing lock")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if lock, ok := mem.locks[name]; ok && lock.owner == owner {
+		delete(mem.locks, name)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"sort"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/google/uuid"
+)
+
+// If ID is empty, then generates UUID4 and uses it
+func (mem *MemoryStorage) CreateOperation(op operation.Operation) (operation.Operation, error) {
+	mem.logger.Debugf("creating operation")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if op.ID == "" {
+		op.ID = uuid.New().String()
+	}
+	var ids = newUniqueKeys("_id")
+	for _, stored := range mem.operations {
+		ids.add(stored.ID)
+	}
+	if err := ids.add(op.ID); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create operation")
+		return op, err
+	}
+	var stored operation.Operation
+	clone(op, &stored)
+	mem.operations = append(mem.operations, stored)
+	return op, nil
+}
+
+func (mem *MemoryStorage) GetOperation(id string) (operation.Operation, error) {
+	mem.logger.Debugf("getting operation")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	for _, op := range mem.operations {
+		if op.ID == id {
+			var result operation.Operation
+			clone(op, &result)
+			return result, nil
+		}
+	}
+	return operation.Operation{}, rserrors.ErrResourceNotExists().AddDetails(id)
+}
+
+func (mem *MemoryStorage) SetOperationStatus(id string, status operation.Status, opErr string) error {
+	mem.logger.Debugf("setting operation status")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	for i, op := range mem.operations {
+		if op.ID == id {
+			mem.operations[i].Status = status
+			mem.operations[i].Error = opErr
+			mem.operations[i].UpdatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+	return rserrors.ErrResourceNotExists().AddDetails(id)
+}
+
+// GetStaleOperations returns not finished operations not updated since time, oldest first
+func (mem *MemoryStorage) GetStaleOperations(before time.Time) (operation.OperationList, error) {
+	mem.logger.Debugf("getting stale operations")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var result operation.OperationList
+	for _, op := range mem.operations {
+		if op.Status.IsActive() && op.UpdatedAt.Before(before) {
+			var stale operation.Operation
+			clone(op, &stale)
+			result = append(result, stale)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// GetActiveOperations returns not finished operations, oldest first
+func (mem *MemoryStorage) GetActiveOperations() (operation.OperationList, error) {
+	mem.logger.Debugf("getting active operations")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var result operation.OperationList
+	for _, op := range mem.operations {
+		if op.Status.IsActive() {
+			var active operation.Operation
+			clone(op, &active)
+			result = append(result, active)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// ClaimOperation atomically increments attempts of operation if it is still stale.
+// Returns false if operation was finished or claimed by someone else.
+func (mem *MemoryStorage) ClaimOperation(id string, before time.Time) (bool, error) {
+	mem.logger.Debugf("claiming operation")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	for i, op := range mem.operations {
+		if op.ID == id && op.Status.IsActive() && op.UpdatedAt.Before(before) {
+			mem.operations[i].Attempts++
+			mem.operations[i].UpdatedAt = time.Now().UTC()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteFinishedOperations removes finished operations completely, journal keeps only recent history
+func (mem *MemoryStorage) DeleteFinishedOperations(before time.Time) error {
+	mem.logger.Debugf("deleting finished operations")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var kept = make([]operation.Operation, 0, len(mem.operations))
+	for _, op := range mem.operations {
+		if op.Status.IsActive() || !op.UpdatedAt.Before(before) {
+			kept = append(kept, op)
+		}
+	}
+	mem.operations = kept
+	return nil
+}
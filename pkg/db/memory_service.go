@@ -0,0 +1,294 @@
+package db
+
+import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/models/stats"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+func checkServiceIndexes(services []service.ServiceResource) error {
+	var ids = newUniqueKeys("_id")
+	var alive = newUniqueKeys("alive_" + CollectionService)
+	var ports = newUniqueKeys("alive_" + CollectionService + "_with_ports")
+	for _, svc := range services {
+		if err := ids.add(svc.ID); err != nil {
+			return err
+		}
+		if svc.Deleted {
+			continue
+		}
+		if err := alive.add(indexKey(svc.Name, svc.NamespaceID)); err != nil {
+			return err
+		}
+		var portKeys = make([]string, 0, len(svc.Ports))
+		for _, port := range svc.Ports {
+			var portNumber interface{}
+			if port.Port != nil {
+				portNumber = *port.Port
+			}
+			portKeys = append(portKeys, indexKey(svc.Domain, portNumber, port.Protocol))
+		}
+		if err := ports.add(portKeys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateServices applies update to copies of services matching filter and saves them if indexes are not violated.
+// If all is false only first matching service is updated. Returns number of matched services.
+func (mem *MemoryStorage) updateServices(filter func(service.ServiceResource) bool, update func(*service.ServiceResource), all bool) (int, error) {
+	var updated = append(make([]service.ServiceResource, 0, len(mem.services)), mem.services...)
+	var matched int
+	for i, svc := range updated {
+		if !filter(svc) {
+			continue
+		}
+		matched++
+		update(&updated[i])
+		if !all {
+			break
+		}
+	}
+	if matched == 0 {
+		return 0, nil
+	}
+	if err := checkServiceIndexes(updated); err != nil {
+		return 0, err
+	}
+	mem.services = updated
+	return matched, nil
+}
+
+func (mem *MemoryStorage) findServices(filter func(service.ServiceResource) bool) service.ServiceList {
+	var list service.ServiceList
+	for _, svc := range mem.services {
+		if filter(svc) {
+			var cp service.ServiceResource
+			clone(svc, &cp)
+			list = append(list, cp)
+		}
+	}
+	return list
+}
+
+func aliveService(namespaceID, name string) func(service.ServiceResource) bool {
+	return func(svc service.ServiceResource) bool {
+		return !svc.Deleted && svc.NamespaceID == namespaceID && svc.Name == name
+	}
+}
+
+func (mem *MemoryStorage) GetService(namespaceID, serviceName string) (service.ServiceResource, error) {
+	mem.logger.Debugf("getting service")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findServices(aliveService(namespaceID, serviceName))
+	if len(list) == 0 {
+		return service.ServiceResource{}, rserrors.ErrResourceNotExists().AddDetails(serviceName)
+	}
+	return list[0], nil
+}
+
+func (mem *MemoryStorage) GetServiceList(namespaceID string) (service.ServiceList, error) {
+	mem.logger.Debugf("getting service list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.findServices(func(svc service.ServiceResource) bool {
+		return !svc.Deleted && svc.NamespaceID == namespaceID
+	}), nil
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (mem *MemoryStorage) CreateService(svc service.ServiceResource) (service.ServiceResource, error) {
+	mem.logger.Debugf("creating service")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if svc.ID == "" {
+		svc.ID = uuid.New().String()
+	}
+	if svc.Revision == 0 {
+		svc.Revision = 1
+	}
+	var stored service.ServiceResource
+	clone(svc, &stored)
+	var updated = append(append(make([]service.ServiceResource, 0, len(mem.services)+1), mem.services...), stored)
+	if err := checkServiceIndexes(updated); err != nil {
+		mem.logger.WithError(err).Errorf("unable to create service")
+		return svc, err
+	}
+	mem.services = updated
+	return svc, nil
+}
+
+func (mem *MemoryStorage) UpdateService(upd service.ServiceResource) (service.ServiceResource, error) {
+	mem.logger.Debugf("updating service")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var stored service.ServiceResource
+	clone(upd, &stored)
+	var alive = aliveService(upd.NamespaceID, upd.Name)
+	matched, err := mem.updateServices(func(svc service.ServiceResource) bool {
+		return alive(svc) && revisionMatches(upd.Revision, svc.Revision)
+	}, func(svc *service.ServiceResource) {
+		svc.Service = stored.Service
+		svc.Revision++
+		upd.Revision = svc.Revision
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to update service")
+		return upd, err
+	case matched == 0 && len(mem.findServices(alive)) > 0:
+		return upd, rserrors.ErrResourceModified().AddDetails(upd.Name)
+	case matched == 0:
+		return upd, mgo.ErrNotFound
+	}
+	return upd, nil
+}
+
+func (mem *MemoryStorage) DeleteService(namespaceID, name string) error {
+	mem.logger.Debugf("deleting service")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	matched, err := mem.updateServices(aliveService(namespaceID, name), func(svc *service.ServiceResource) {
+		svc.Deleted = true
+		svc.DeletedAt = &deletedAt
+	}, false)
+	switch {
+	case err != nil:
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) RestoreService(namespaceID, name string) error {
+	mem.logger.Debugf("restoring service")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var id = mem.latestDeletedService(namespaceID, name)
+	matched, err := mem.updateServices(func(svc service.ServiceResource) bool {
+		return id != "" && svc.ID == id
+	}, func(svc *service.ServiceResource) {
+		svc.Deleted = false
+		svc.DeletedAt = nil
+	}, false)
+	switch {
+	case err != nil:
+		mem.logger.WithError(err).Errorf("unable to restore service")
+		return err
+	case matched == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (mem *MemoryStorage) DeleteAllServicesInNamespace(namespaceID string) error {
+	mem.logger.Debugf("deleting all services in namespace")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateServices(func(svc service.ServiceResource) bool {
+		return !svc.Deleted && svc.NamespaceID == namespaceID
+	}, func(svc *service.ServiceResource) {
+		svc.Deleted = true
+		svc.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+func (mem *MemoryStorage) DeleteAllServicesByOwner(owner string) error {
+	mem.logger.Debugf("deleting all user services")
+	var deletedAt = time.Now().UTC()
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	_, err := mem.updateServices(func(svc service.ServiceResource) bool {
+		return !svc.Deleted && svc.Owner == owner
+	}, func(svc *service.ServiceResource) {
+		svc.Deleted = true
+		svc.DeletedAt = &deletedAt
+	}, true)
+	return err
+}
+
+// countServices counts services without domain as external, like MongoStorage does
+func (mem *MemoryStorage) countServices(filter func(service.ServiceResource) bool) stats.Service {
+	var serviceStats stats.Service
+	for _, svc := range mem.services {
+		if svc.Deleted || !filter(svc) {
+			continue
+		}
+		if svc.Domain == "" {
+			serviceStats.External++
+		} else {
+			serviceStats.Internal++
+		}
+	}
+	return serviceStats
+}
+
+func (mem *MemoryStorage) CountServices(owner string) (stats.Service, error) {
+	mem.logger.Debugf("counting services")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.countServices(func(svc service.ServiceResource) bool {
+		return svc.Owner == owner
+	}), nil
+}
+
+func (mem *MemoryStorage) CountServicesInNamespace(namespaceID string) (stats.Service, error) {
+	mem.logger.Debugf("counting services in namespace")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return mem.countServices(func(svc service.ServiceResource) bool {
+		return svc.NamespaceID == namespaceID
+	}), nil
+}
+
+func (mem *MemoryStorage) GetFreePort(domain string, protocol model.Protocol) (int, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var used = make(map[int]struct{})
+	for _, svc := range mem.services {
+		if svc.Deleted || svc.Domain != domain {
+			continue
+		}
+		for _, port := range svc.Ports {
+			if port.Port != nil && port.Protocol == protocol {
+				used[*port.Port] = struct{}{}
+			}
+		}
+	}
+	if len(used) >= maxPort-minPort {
+		return -1, rserrors.ErrInternal().AddDetailF("no free %s ports on domain %s", protocol, domain)
+	}
+	for {
+		var port = rnd.Intn(maxPort-minPort) + minPort
+		if _, ok := used[port]; !ok {
+			return port, nil
+		}
+	}
+}
+
+func (mem *MemoryStorage) IsPortFree(domain string, port int, protocol model.Protocol) (bool, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	for _, svc := range mem.services {
+		if svc.Deleted || svc.Domain != domain {
+			continue
+		}
+		for _, svcPort := range svc.Ports {
+			if svcPort.Port != nil && *svcPort.Port == port && svcPort.Protocol == protocol {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
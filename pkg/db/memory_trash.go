@@ -0,0 +1,150 @@
+package db
+
+import (
+	"sort"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+)
+
+// deletedLater reports if resource deleted at a was deleted later than resource deleted at b.
+// Resources without deletion time were deleted before it was stored, so they are the oldest.
+func deletedLater(a, b *time.Time) bool {
+	switch {
+	case a == nil:
+		return false
+	case b == nil:
+		return true
+	default:
+		return a.After(*b)
+	}
+}
+
+// deletedBefore reports if resource was deleted before time, like purgeSelectQuery does
+func deletedBefore(deleted bool, deletedAt *time.Time, before time.Time) bool {
+	return deleted && deletedAt != nil && deletedAt.Before(before)
+}
+
+// latestDeletedDeployment returns ID of the latest deleted deployment, versions which were active are preferred
+func (mem *MemoryStorage) latestDeletedDeployment(namespaceID, name string) string {
+	var latest *deployment.DeploymentResource
+	for i, depl := range mem.deployments {
+		if !depl.Deleted || depl.NamespaceID != namespaceID || depl.Name != name {
+			continue
+		}
+		if latest == nil || deletedLater(depl.DeletedAt, latest.DeletedAt) ||
+			(!deletedLater(latest.DeletedAt, depl.DeletedAt) && depl.Active && !latest.Active) {
+			latest = &mem.deployments[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.ID
+}
+
+func (mem *MemoryStorage) latestDeletedService(namespaceID, name string) string {
+	var latest *service.ServiceResource
+	for i, svc := range mem.services {
+		if !svc.Deleted || svc.NamespaceID != namespaceID || svc.Name != name {
+			continue
+		}
+		if latest == nil || deletedLater(svc.DeletedAt, latest.DeletedAt) {
+			latest = &mem.services[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.ID
+}
+
+func (mem *MemoryStorage) latestDeletedIngress(namespaceID, name string) string {
+	var latest *ingress.IngressResource
+	for i, ingr := range mem.ingresses {
+		if !ingr.Deleted || ingr.NamespaceID != namespaceID || ingr.Name != name {
+			continue
+		}
+		if latest == nil || deletedLater(ingr.DeletedAt, latest.DeletedAt) {
+			latest = &mem.ingresses[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.ID
+}
+
+func (mem *MemoryStorage) GetDeletedDeploymentList(namespaceID string) (deployment.DeploymentList, error) {
+	mem.logger.Debugf("getting deleted deployment list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findDeployments(func(depl deployment.DeploymentResource) bool {
+		return depl.Deleted && depl.Active && depl.NamespaceID == namespaceID
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return deletedLater(list[i].DeletedAt, list[j].DeletedAt)
+	})
+	return list, nil
+}
+
+func (mem *MemoryStorage) GetDeletedServiceList(namespaceID string) (service.ServiceList, error) {
+	mem.logger.Debugf("getting deleted service list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findServices(func(svc service.ServiceResource) bool {
+		return svc.Deleted && svc.NamespaceID == namespaceID
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return deletedLater(list[i].DeletedAt, list[j].DeletedAt)
+	})
+	return list, nil
+}
+
+func (mem *MemoryStorage) GetDeletedIngressList(namespaceID string) (ingress.IngressList, error) {
+	mem.logger.Debugf("getting deleted ingress list")
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	var list = mem.findIngresses(func(ingr ingress.IngressResource) bool {
+		return ingr.Deleted && ingr.NamespaceID == namespaceID
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return deletedLater(list[i].DeletedAt, list[j].DeletedAt)
+	})
+	return list, nil
+}
+
+func (mem *MemoryStorage) PurgeDeleted(before time.Time) (int, error) {
+	mem.logger.Debugf("purging deleted resources")
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var removed int
+	var deployments = make([]deployment.DeploymentResource, 0, len(mem.deployments))
+	for _, depl := range mem.deployments {
+		if deletedBefore(depl.Deleted, depl.DeletedAt, before) {
+			removed++
+			continue
+		}
+		deployments = append(deployments, depl)
+	}
+	var services = make([]service.ServiceResource, 0, len(mem.services))
+	for _, svc := range mem.services {
+		if deletedBefore(svc.Deleted, svc.DeletedAt, before) {
+			removed++
+			continue
+		}
+		services = append(services, svc)
+	}
+	var ingresses = make([]ingress.IngressResource, 0, len(mem.ingresses))
+	for _, ingr := range mem.ingresses {
+		if deletedBefore(ingr.Deleted, ingr.DeletedAt, before) {
+			removed++
+			continue
+		}
+		ingresses = append(ingresses, ingr)
+	}
+	mem.deployments, mem.services, mem.ingresses = deployments, services, ingresses
+	return removed, nil
+}
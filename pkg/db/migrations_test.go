@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/globalsign/mgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMongoMigrationsOrder(t *testing.T) {
+	for i, migration := range mongoMigrations {
+		assert.NotNil(t, migration.Up, migration.Name)
+		assert.NotNil(t, migration.Down, migration.Name)
+		if i > 0 {
+			assert.True(t, mongoMigrations[i-1].Name < migration.Name, "migration %s must be after %s", migration.Name, mongoMigrations[i-1].Name)
+		}
+	}
+}
+
+func TestPgMigrationsOrder(t *testing.T) {
+	for i, migration := range pgMigrations {
+		assert.NotEmpty(t, migration.Up, migration.String())
+		assert.NotEmpty(t, migration.Down, migration.String())
+		assert.Equal(t, i+1, migration.Version, migration.String())
+	}
+}
+
+func TestMongoMigrationIndexesDisjoint(t *testing.T) {
+	// every index is created by one migration, so reverting migration drops only its own indexes
+	var created = make(map[string]string)
+	var add = func(migration, collection string, indexes []mgo.Index) {
+		for _, index := range indexes {
+			key := collection + "/" + index.Name + fmt.Sprint(index.Key)
+			if previous, ok := created[key]; ok {
+				t.Errorf("index %s is created by %s and %s", key, previous, migration)
+			}
+			created[key] = migration
+		}
+	}
+	for collection, indexes := range initialIndexes() {
+		add("0001", collection, indexes)
+	}
+	add("0002", CollectionOperation, operationIndexes())
+	for _, collection := range trashCollections() {
+		add("0004", collection, trashIndexes())
+	}
+	add("0006", CollectionIngress, uniqueHostIndexes())
+	add("0007", CollectionCustomDomain, customDomainIndexes())
+	add("0008", CollectionCertificate, certificateIndexes())
+	add("0009", CollectionIngress, acmeIndexes())
+}
@@ -19,6 +19,11 @@ const (
 	CollectionDomain     = "domain"
 	CollectionIngress    = "ingress"
 	CollectionDB         = "db"
+
+	CollectionCustomDomain  = "custom_domain"
+	CollectionCertificate   = "certificate"
+	CollectionACMEChallenge = "acme_challenge"
+	CollectionOperation     = "operation"
 )
 
 func CollectionsNames() []string {
@@ -28,6 +33,10 @@ func CollectionsNames() []string {
 		CollectionDomain,
 		CollectionIngress,
 		CollectionDB,
+		CollectionCustomDomain,
+		CollectionCertificate,
+		CollectionACMEChallenge,
+		CollectionOperation,
 	}
 }
 
@@ -63,7 +72,8 @@ func (mongo *MongoStorage) IsClosed() bool {
 	return mongo.closed
 }
 
-func (mongo *MongoStorage) Init(dbversion string, forceupdate bool) error {
+// Init creates missing collections and applies pending migrations
+func (mongo *MongoStorage) Init() error {
 	dbCollections, err := mongo.db.CollectionNames()
 	if err != nil {
 		return err
@@ -75,10 +85,7 @@ func (mongo *MongoStorage) Init(dbversion string, forceupdate bool) error {
 			return err
 		}
 	}
-	if err := mongo.InitIndexes(dbversion, forceupdate); err != nil {
-		return err
-	}
-	return nil
+	return mongo.Migrate()
 }
 
 func NewMongo(config MongoConfig) (*MongoStorage, error) {
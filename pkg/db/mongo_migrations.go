@@ -0,0 +1,531 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// MongoMigration -- named change of mongo indexes or documents.
+// Migration may be interrupted after its changes but before its record is saved, so Up and Down must be idempotent.
+type MongoMigration struct {
+	Name string
+	Up   func(mongo *MongoStorage) error
+	Down func(mongo *MongoStorage) error
+}
+
+// mongoMigrations are applied in order and recorded in db collection.
+// Applied migrations must never be changed or reordered, add new one instead.
+var mongoMigrations = []MongoMigration{
+	{
+		Name: "0001_initial_indexes",
+		Up: func(mongo *MongoStorage) error {
+			for collection, indexes := range initialIndexes() {
+				if err := mongo.ensureIndexes(collection, indexes...); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(mongo *MongoStorage) error {
+			for collection, indexes := range initialIndexes() {
+				if err := mongo.dropIndexes(collection, indexes...); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Name: "0002_operation_indexes",
+		Up: func(mongo *MongoStorage) error {
+			return mongo.ensureIndexes(CollectionOperation, operationIndexes()...)
+		},
+		Down: func(mongo *MongoStorage) error {
+			return mongo.dropIndexes(CollectionOperation, operationIndexes()...)
+		},
+	},
+	{
+		Name: "0003_remove_db_version",
+		Up: func(mongo *MongoStorage) error {
+			// db version document was used before migrations, it is replaced by migration records
+			_, err := mongo.db.C(CollectionDB).RemoveAll(bson.M{"version": bson.M{"$exists": true}})
+			return err
+		},
+		Down: func(mongo *MongoStorage) error {
+			_, err := mongo.db.C(CollectionDB).Upsert(bson.M{"version": bson.M{"$exists": true}}, bson.M{"version": "1.7"})
+			return err
+		},
+	},
+	{
+		Name: "0004_deleted_at",
+		Up: func(mongo *MongoStorage) error {
+			for _, collection := range trashCollections() {
+				// resources deleted before deletion time was stored are kept for full retention period since now
+				if _, err := mongo.db.C(collection).UpdateAll(bson.M{
+					"deleted":   true,
+					"deletedat": nil,
+				}, bson.M{
+					"$set": bson.M{"deletedat": time.Now().UTC()},
+				}); err != nil {
+					return err
+				}
+				if err := mongo.ensureIndexes(collection, trashIndexes()...); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(mongo *MongoStorage) error {
+			for _, collection := range trashCollections() {
+				if err := mongo.dropIndexes(collection, trashIndexes()...); err != nil {
+					return err
+				}
+				if _, err := mongo.db.C(collection).UpdateAll(bson.M{
+					"deletedat": bson.M{"$exists": true},
+				}, bson.M{
+					"$unset": bson.M{"deletedat": ""},
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Name: "0005_revision",
+		Up: func(mongo *MongoStorage) error {
+			// resources created before revisions were stored are treated as first revision
+			for _, collection := range trashCollections() {
+				if _, err := mongo.db.C(collection).UpdateAll(bson.M{
+					"revision": bson.M{"$exists": false},
+				}, bson.M{
+					"$set": bson.M{"revision": 1},
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(mongo *MongoStorage) error {
+			for _, collection := range trashCollections() {
+				if _, err := mongo.db.C(collection).UpdateAll(bson.M{
+					"revision": bson.M{"$exists": true},
+				}, bson.M{
+					"$unset": bson.M{"revision": ""},
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	// indexes of features added after db version 1.6, they were created by 0001 before it was limited to 1.6 indexes,
+	// so these migrations may find them existing
+	{
+		Name: "0006_unique_ingress_hosts",
+		Up: func(mongo *MongoStorage) error {
+			return mongo.ensureIndexes(CollectionIngress, uniqueHostIndexes()...)
+		},
+		Down: func(mongo *MongoStorage) error {
+			return mongo.dropIndexes(CollectionIngress, uniqueHostIndexes()...)
+		},
+	},
+	{
+		Name: "0007_custom_domain_indexes",
+		Up: func(mongo *MongoStorage) error {
+			return mongo.ensureIndexes(CollectionCustomDomain, customDomainIndexes()...)
+		},
+		Down: func(mongo *MongoStorage) error {
+			return mongo.dropIndexes(CollectionCustomDomain, customDomainIndexes()...)
+		},
+	},
+	{
+		Name: "0008_certificate_indexes",
+		Up: func(mongo *MongoStorage) error {
+			return mongo.ensureIndexes(CollectionCertificate, certificateIndexes()...)
+		},
+		Down: func(mongo *MongoStorage) error {
+			return mongo.dropIndexes(CollectionCertificate, certificateIndexes()...)
+		},
+	},
+	{
+		Name: "0009_acme_index",
+		Up: func(mongo *MongoStorage) error {
+			return mongo.ensureIndexes(CollectionIngress, acmeIndexes()...)
+		},
+		Down: func(mongo *MongoStorage) error {
+			return mongo.dropIndexes(CollectionIngress, acmeIndexes()...)
+		},
+	},
+}
+
+const (
+	migrationRecordType = "migration"
+	migrationLockID     = "migration_lock"
+	// lock of crashed replica expires after this time
+	migrationLockTTL = 10 * time.Minute
+	// replica waits for other replica to finish migrations this time
+	migrationLockWait = 15 * time.Minute
+)
+
+// migrationRecord -- applied migration stored in db collection
+type migrationRecord struct {
+	ID        string    `bson:"_id"`
+	Type      string    `bson:"type"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedat"`
+}
+
+func migrationRecordID(name string) string {
+	return migrationRecordType + "/" + name
+}
+
+// appliedMigrations returns names of applied migrations
+func (mongo *MongoStorage) appliedMigrations() (map[string]struct{}, error) {
+	var records []migrationRecord
+	if err := mongo.db.C(CollectionDB).Find(bson.M{"type": migrationRecordType}).All(&records); err != nil {
+		return nil, err
+	}
+	var applied = make(map[string]struct{}, len(records))
+	for _, record := range records {
+		applied[record.Name] = struct{}{}
+	}
+	return applied, nil
+}
+
+// pendingMigrations returns migrations not applied yet.
+// Migrations unknown to application mean db was migrated by newer application.
+func (mongo *MongoStorage) pendingMigrations() ([]MongoMigration, error) {
+	applied, err := mongo.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []MongoMigration
+	for _, migration := range mongoMigrations {
+		if _, ok := applied[migration.Name]; ok {
+			delete(applied, migration.Name)
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	if len(applied) > 0 {
+		var unknown []string
+		for name := range applied {
+			unknown = append(unknown, name)
+		}
+		return nil, fmt.Errorf("db has migrations unknown to application %v, use newer application or migrate down with it", unknown)
+	}
+	return pending, nil
+}
+
+// PendingMigrations lists names of migrations not applied yet
+func (mongo *MongoStorage) PendingMigrations() ([]string, error) {
+	pending, err := mongo.pendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var names = make([]string, 0, len(pending))
+	for _, migration := range pending {
+		names = append(names, migration.Name)
+	}
+	return names, nil
+}
+
+// Migrate applies pending migrations in order. Replicas wait for each other, so every migration is applied once.
+func (mongo *MongoStorage) Migrate() error {
+	return mongo.withMigrationLock(func(refresh func() error) error {
+		pending, err := mongo.pendingMigrations()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			mongo.logger.Infoln("no need to migrate db")
+			return nil
+		}
+		for _, migration := range pending {
+			mongo.logger.Infof("applying migration %s", migration.Name)
+			if err := migration.Up(mongo); err != nil {
+				mongo.logger.WithError(err).Errorf("unable to apply migration %s", migration.Name)
+				return err
+			}
+			if err := mongo.db.C(CollectionDB).Insert(migrationRecord{
+				ID:        migrationRecordID(migration.Name),
+				Type:      migrationRecordType,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}); err != nil && !mgo.IsDup(err) {
+				return err
+			}
+			if err := refresh(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts last applied migrations in reverse order, returns names of reverted migrations
+func (mongo *MongoStorage) MigrateDown(steps int) ([]string, error) {
+	var reverted []string
+	err := mongo.withMigrationLock(func(refresh func() error) error {
+		if _, err := mongo.pendingMigrations(); err != nil {
+			return err
+		}
+		applied, err := mongo.appliedMigrations()
+		if err != nil {
+			return err
+		}
+		for i := len(mongoMigrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			var migration = mongoMigrations[i]
+			if _, ok := applied[migration.Name]; !ok {
+				continue
+			}
+			mongo.logger.Infof("reverting migration %s", migration.Name)
+			if err := migration.Down(mongo); err != nil {
+				mongo.logger.WithError(err).Errorf("unable to revert migration %s", migration.Name)
+				return err
+			}
+			if err := mongo.db.C(CollectionDB).RemoveId(migrationRecordID(migration.Name)); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration.Name)
+			if err := refresh(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// withMigrationLock runs fn holding lock document in db collection.
+// Lock of crashed replica expires, so fn must refresh lock after every step.
+func (mongo *MongoStorage) withMigrationLock(fn func(refresh func() error) error) error {
+	var collection = mongo.db.C(CollectionDB)
+	var owner = bson.NewObjectId().Hex()
+	var deadline = time.Now().Add(migrationLockWait)
+	for {
+		var now = time.Now().UTC()
+		// lock is either inserted or taken over if expired, held lock causes duplicate key error on insert
+		_, err := collection.Upsert(bson.M{
+			"_id":       migrationLockID,
+			"expiresat": bson.M{"$lt": now},
+		}, bson.M{
+			"_id":       migrationLockID,
+			"owner":     owner,
+			"expiresat": now.Add(migrationLockTTL),
+		})
+		if err == nil {
+			break
+		}
+		if !mgo.IsDup(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("migrations are locked by another replica for more than %v", migrationLockWait)
+		}
+		mongo.logger.Infoln("waiting for another replica to finish migrations")
+		time.Sleep(time.Second)
+	}
+	defer func() {
+		if err := collection.Remove(bson.M{"_id": migrationLockID, "owner": owner}); err != nil {
+			mongo.logger.WithError(err).Warnln("unable to release migration lock")
+		}
+	}()
+	return fn(func() error {
+		err := collection.Update(bson.M{"_id": migrationLockID, "owner": owner}, bson.M{
+			"$set": bson.M{"expiresat": time.Now().UTC().Add(migrationLockTTL)},
+		})
+		if err == mgo.ErrNotFound {
+			return fmt.Errorf("migration lock expired and was taken by another replica")
+		}
+		return err
+	})
+}
+
+func (mongo *MongoStorage) ensureIndexes(collectionName string, indexes ...mgo.Index) error {
+	var collection = mongo.db.C(collectionName)
+	for _, index := range indexes {
+		if err := collection.EnsureIndex(index); err != nil {
+			if index.Name == "unique_host_"+CollectionIngress && mgo.IsDup(err) {
+				// hosts are still checked by application, conflicts are listed in admin report
+				mongo.logger.WithError(err).Warnln("existing ingresses have conflicting hosts, unique host index is not created")
+				continue
+			}
+			return fmt.Errorf("unable to create index %v in %s: %v", index.Key, collectionName, err)
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops indexes matching name or keys of unnamed index, missing indexes are skipped
+func (mongo *MongoStorage) dropIndexes(collectionName string, indexes ...mgo.Index) error {
+	var collection = mongo.db.C(collectionName)
+	existing, err := collection.Indexes()
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		for _, candidate := range existing {
+			if (index.Name != "" && candidate.Name == index.Name) ||
+				(index.Name == "" && reflect.DeepEqual(candidate.Key, index.Key)) {
+				if err := collection.DropIndexName(candidate.Name); err != nil {
+					return fmt.Errorf("unable to drop index %s in %s: %v", candidate.Name, collectionName, err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// initialIndexes -- indexes created by db version 1.6, before migrations were introduced
+func initialIndexes() map[string][]mgo.Index {
+	var indexes = make(map[string][]mgo.Index)
+	for _, collectionName := range []string{CollectionDeployment, CollectionService, CollectionIngress} {
+		indexes[collectionName] = []mgo.Index{
+			{Key: []string{collectionName + ".owner"}},
+			{Key: []string{collectionName + ".name"}},
+			{Key: []string{"namespaceid"}},
+			{Key: []string{"deleted"}},
+		}
+	}
+	indexes[CollectionDeployment] = append(indexes[CollectionDeployment],
+		mgo.Index{
+			Name: "alive_" + CollectionDeployment,
+			Key:  []string{CollectionDeployment + ".name", "namespaceid"},
+			PartialFilter: bson.M{
+				"deleted":           false,
+				"deployment.active": true,
+			},
+			Unique: true,
+		},
+		mgo.Index{Key: []string{"active"}},
+		mgo.Index{
+			Name: "unique_version_" + CollectionDeployment,
+			Key:  []string{CollectionDeployment + ".name", "namespaceid", CollectionDeployment + ".version"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+	)
+	indexes[CollectionIngress] = append(indexes[CollectionIngress],
+		mgo.Index{
+			Name: "alive_" + CollectionIngress,
+			Key:  []string{CollectionIngress + ".name", "namespaceid"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+	)
+	indexes[CollectionService] = append(indexes[CollectionService],
+		mgo.Index{Key: []string{CollectionService + "_deployment"}},
+		mgo.Index{Key: []string{CollectionService + "_domain"}},
+		mgo.Index{
+			Name: "alive_" + CollectionService,
+			Key:  []string{CollectionService + ".name", "namespaceid"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+		mgo.Index{
+			Name: "alive_" + CollectionService + "_with_ports",
+			Key: []string{
+				CollectionService + ".domain",
+				CollectionService + ".ports.port",
+				CollectionService + ".ports.protocol",
+			},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+	)
+	indexes[CollectionDomain] = []mgo.Index{
+		{Key: []string{"domain"}},
+		{Key: []string{"domain_group"}},
+		{Key: []string{"domain_group", "domain"}},
+	}
+	return indexes
+}
+
+func uniqueHostIndexes() []mgo.Index {
+	return []mgo.Index{
+		{
+			Name: "unique_host_" + CollectionIngress,
+			Key:  []string{CollectionIngress + ".rules.host"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+	}
+}
+
+func customDomainIndexes() []mgo.Index {
+	return []mgo.Index{
+		{Key: []string{"namespaceid"}},
+		{
+			Name: "alive_" + CollectionCustomDomain,
+			Key:  []string{"domain", "namespaceid"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+		{
+			Name: "verified_" + CollectionCustomDomain,
+			Key:  []string{"domain"},
+			PartialFilter: bson.M{
+				"deleted":  false,
+				"verified": true,
+			},
+			Unique: true,
+		},
+	}
+}
+
+func certificateIndexes() []mgo.Index {
+	return []mgo.Index{
+		{Key: []string{"namespaceid"}},
+		{
+			Name: "alive_" + CollectionCertificate,
+			Key:  []string{"name", "namespaceid"},
+			PartialFilter: bson.M{
+				"deleted": false,
+			},
+			Unique: true,
+		},
+	}
+}
+
+func acmeIndexes() []mgo.Index {
+	return []mgo.Index{
+		{Key: []string{"acme.enabled"}},
+	}
+}
+
+func operationIndexes() []mgo.Index {
+	return []mgo.Index{
+		{Key: []string{"status", "updatedat"}},
+	}
+}
+
+// trashCollections -- collections of resources which may be restored from trash
+func trashCollections() []string {
+	return []string{CollectionDeployment, CollectionService, CollectionIngress}
+}
+
+func trashIndexes() []mgo.Index {
+	return []mgo.Index{
+		{Key: []string{"namespaceid", "deleted", "-deletedat"}},
+		{Key: []string{"deleted", "deletedat"}},
+	}
+}
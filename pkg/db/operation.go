@@ -0,0 +1,112 @@
+package db
+
+import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+)
+
+// If ID is empty, then generates UUID4 and uses it
+func (mongo *MongoStorage) CreateOperation(op operation.Operation) (operation.Operation, error) {
+	mongo.logger.Debugf("creating operation")
+	var collection = mongo.db.C(CollectionOperation)
+	if op.ID == "" {
+		op.ID = uuid.New().String()
+	}
+	if err := collection.Insert(op); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to create operation")
+		return op, PipErr{err}.ToMongerr().Extract()
+	}
+	return op, nil
+}
+
+func (mongo *MongoStorage) GetOperation(id string) (operation.Operation, error) {
+	mongo.logger.Debugf("getting operation")
+	var collection = mongo.db.C(CollectionOperation)
+	var result operation.Operation
+	if err := collection.FindId(id).One(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get operation")
+		if err == mgo.ErrNotFound {
+			return result, rserrors.ErrResourceNotExists().AddDetails(id)
+		}
+		return result, PipErr{err}.ToMongerr().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) SetOperationStatus(id string, status operation.Status, opErr string) error {
+	mongo.logger.Debugf("setting operation status")
+	var collection = mongo.db.C(CollectionOperation)
+	if err := collection.UpdateId(id, bson.M{
+		"$set": bson.M{
+			"status":    status,
+			"error":     opErr,
+			"updatedat": time.Now().UTC(),
+		},
+	}); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to set operation status")
+		if err == mgo.ErrNotFound {
+			return rserrors.ErrResourceNotExists().AddDetails(id)
+		}
+		return PipErr{err}.ToMongerr().Extract()
+	}
+	return nil
+}
+
+// GetStaleOperations returns not finished operations not updated since time, oldest first
+func (mongo *MongoStorage) GetStaleOperations(before time.Time) (operation.OperationList, error) {
+	mongo.logger.Debugf("getting stale operations")
+	var collection = mongo.db.C(CollectionOperation)
+	var list operation.OperationList
+	if err := collection.Find(operation.StaleSelectQuery(before)).Sort("createdat").All(&list); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get stale operations")
+		return list, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return list, nil
+}
+
+// GetActiveOperations returns not finished operations, oldest first
+func (mongo *MongoStorage) GetActiveOperations() (operation.OperationList, error) {
+	mongo.logger.Debugf("getting active operations")
+	var collection = mongo.db.C(CollectionOperation)
+	var list operation.OperationList
+	if err := collection.Find(operation.ActiveSelectQuery()).Sort("createdat").All(&list); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get active operations")
+		return list, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return list, nil
+}
+
+// ClaimOperation atomically increments attempts of operation if it is still stale.
+// Returns false if operation was finished or claimed by someone else.
+func (mongo *MongoStorage) ClaimOperation(id string, before time.Time) (bool, error) {
+	mongo.logger.Debugf("claiming operation")
+	var collection = mongo.db.C(CollectionOperation)
+	err := collection.Update(operation.ClaimSelectQuery(id, before), bson.M{
+		"$set": bson.M{"updatedat": time.Now().UTC()},
+		"$inc": bson.M{"attempts": 1},
+	})
+	switch {
+	case err == mgo.ErrNotFound:
+		return false, nil
+	case err != nil:
+		mongo.logger.WithError(err).Errorf("unable to claim operation")
+		return false, PipErr{err}.ToMongerr().Extract()
+	}
+	return true, nil
+}
+
+// DeleteFinishedOperations removes finished operations completely, journal keeps only recent history
+func (mongo *MongoStorage) DeleteFinishedOperations(before time.Time) error {
+	mongo.logger.Debugf("deleting finished operations")
+	var collection = mongo.db.C(CollectionOperation)
+	if _, err := collection.RemoveAll(operation.FinishedSelectQuery(before)); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to delete finished operations")
+		return PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return nil
+}
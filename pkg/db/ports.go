@@ -37,3 +37,20 @@ func (mongo *MongoStorage) GetFreePort(domain string, protocol model.Protocol) (
 	}
 	return -1, nil
 }
+
+// IsPortFree reports if external port is not used by not deleted services on domain
+func (mongo *MongoStorage) IsPortFree(domain string, port int, protocol model.Protocol) (bool, error) {
+	var collection = mongo.db.C(CollectionService)
+	n, err := collection.Find(bson.M{
+		"service.domain": domain,
+		"deleted":        false,
+		"service.ports": bson.M{"$elemMatch": bson.M{
+			"port":     port,
+			"protocol": protocol,
+		}},
+	}).Count()
+	if err != nil {
+		return false, PipErr{err}.ToMongerr().Extract()
+	}
+	return n == 0, nil
+}
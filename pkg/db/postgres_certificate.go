@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/google/uuid"
+)
+
+func (pg *PostgresStorage) insertCertificate(q pgQuerier, cert certificate.Certificate) error {
+	var stored certificate.Certificate
+	var data = pgDocument(cert, &stored)
+	_, err := pg.exec(q, `INSERT INTO certificates (id, namespace_id, name, deleted, data) VALUES ($1, $2, $3, $4, $5)`,
+		stored.ID, stored.NamespaceID, stored.Name, stored.Deleted, string(data))
+	return err
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (pg *PostgresStorage) CreateCertificate(cert certificate.Certificate) (certificate.Certificate, error) {
+	pg.logger.Debugf("creating certificate")
+	if cert.ID == "" {
+		cert.ID = uuid.New().String()
+	}
+	if err := pg.insertCertificate(pg.db, cert); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create certificate")
+		return cert, err
+	}
+	return cert, nil
+}
+
+func (pg *PostgresStorage) GetCertificate(namespaceID, name string) (certificate.Certificate, error) {
+	pg.logger.Debugf("getting certificate")
+	var result certificate.Certificate
+	if err := pg.queryDocument(pg.db, &result, `SELECT data FROM certificates
+		WHERE namespace_id = $1 AND name = $2 AND NOT deleted`, namespaceID, name); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get certificate")
+		if err == sql.ErrNoRows {
+			return result, rserrors.ErrResourceNotExists().AddDetails(name)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresStorage) GetCertificateList(namespaceID string) (certificate.CertificateList, error) {
+	pg.logger.Debugf("getting certificate list")
+	var list certificate.CertificateList
+	err := pg.queryDocuments(pg.db, func(data []byte) error {
+		var cert certificate.Certificate
+		if err := json.Unmarshal(data, &cert); err != nil {
+			return err
+		}
+		list = append(list, cert)
+		return nil
+	}, `SELECT data FROM certificates WHERE namespace_id = $1 AND NOT deleted ORDER BY seq`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get certificate list")
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) DeleteCertificate(namespaceID, name string) error {
+	pg.logger.Debugf("deleting certificate")
+	n, err := pg.exec(pg.db, `UPDATE certificates SET deleted = TRUE, data = jsonb_set(data, '{deleted}', 'true')
+		WHERE namespace_id = $1 AND name = $2 AND NOT deleted`, namespaceID, name)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete certificate")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) RestoreCertificate(namespaceID, name string) error {
+	pg.logger.Debugf("restoring certificate")
+	n, err := pg.exec(pg.db, `UPDATE certificates SET deleted = FALSE, data = jsonb_set(data, '{deleted}', 'false')
+		WHERE id = (SELECT id FROM certificates WHERE namespace_id = $1 AND name = $2 AND deleted ORDER BY seq LIMIT 1)`,
+		namespaceID, name)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to restore certificate")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) insertACMEChallenge(q pgQuerier, challenge certificate.ACMEChallenge) error {
+	var stored certificate.ACMEChallenge
+	var data = pgDocument(challenge, &stored)
+	_, err := pg.exec(q, `INSERT INTO acme_challenges (token, data) VALUES ($1, $2)
+		ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data`, stored.Token, string(data))
+	return err
+}
+
+func (pg *PostgresStorage) CreateACMEChallenge(challenge certificate.ACMEChallenge) error {
+	pg.logger.Debugf("creating acme challenge")
+	if err := pg.insertACMEChallenge(pg.db, challenge); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create acme challenge")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) GetACMEChallenge(token string) (certificate.ACMEChallenge, error) {
+	pg.logger.Debugf("getting acme challenge")
+	var challenge certificate.ACMEChallenge
+	if err := pg.queryDocument(pg.db, &challenge, `SELECT data FROM acme_challenges WHERE token = $1`, token); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get acme challenge")
+		if err == sql.ErrNoRows {
+			return challenge, rserrors.ErrResourceNotExists().AddDetails(token)
+		}
+		return challenge, err
+	}
+	return challenge, nil
+}
+
+// DeleteACMEChallenge removes challenge completely, challenges are not kept after authorization
+func (pg *PostgresStorage) DeleteACMEChallenge(token string) error {
+	pg.logger.Debugf("deleting acme challenge")
+	if _, err := pg.exec(pg.db, `DELETE FROM acme_challenges WHERE token = $1`, token); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete acme challenge")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) InitACMEAccountKey(keyPEM string) (string, error) {
+	pg.logger.Debugf("initializing acme account key")
+	if _, err := pg.exec(pg.db, `INSERT INTO settings (name, value) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`,
+		acmeAccountKeyID, keyPEM); err != nil {
+		pg.logger.WithError(err).Errorf("unable to store acme account key")
+		return "", err
+	}
+	var stored string
+	if err := pg.db.QueryRow(`SELECT value FROM settings WHERE name = $1`, acmeAccountKeyID).Scan(&stored); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get acme account key")
+		return "", err
+	}
+	return stored, nil
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/blang/semver"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+const pgDeploymentVersionsOrder = `ORDER BY version_major DESC, version_minor DESC, version_patch DESC, version DESC`
+
+func (pg *PostgresStorage) queryDeployments(q pgQuerier, query string, args ...interface{}) (deployment.DeploymentList, error) {
+	var list deployment.DeploymentList
+	err := pg.queryDocuments(q, func(data []byte) error {
+		var depl deployment.DeploymentResource
+		if err := json.Unmarshal(data, &depl); err != nil {
+			return err
+		}
+		list = append(list, depl)
+		return nil
+	}, query, args...)
+	return list, err
+}
+
+func (pg *PostgresStorage) insertDeployment(q pgQuerier, depl deployment.DeploymentResource) error {
+	var stored deployment.DeploymentResource
+	var data = pgDocument(depl, &stored)
+	_, err := pg.exec(q, `INSERT INTO deployments (id, namespace_id, name, owner, version, version_major, version_minor, version_patch, active, deleted, deleted_at, revision, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		stored.ID, stored.NamespaceID, stored.Name, stored.Owner,
+		stored.Version.String(), int64(stored.Version.Major), int64(stored.Version.Minor), int64(stored.Version.Patch),
+		stored.Active, stored.Deleted, stored.DeletedAt, stored.Revision, string(data))
+	return err
+}
+
+func (pg *PostgresStorage) GetDeployment(namespaceID, deploymentName string) (deployment.DeploymentResource, error) {
+	pg.logger.Debugf("getting deployment by name")
+	var depl deployment.DeploymentResource
+	if err := pg.queryDocument(pg.db, &depl, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted`, namespaceID, deploymentName); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deployment by name")
+		if err == sql.ErrNoRows {
+			return depl, rserrors.ErrResourceNotExists().AddDetails(deploymentName)
+		}
+		return depl, err
+	}
+	return depl, nil
+}
+
+func (pg *PostgresStorage) GetDeploymentVersion(namespaceID, deploymentName string, version semver.Version) (deployment.DeploymentResource, error) {
+	pg.logger.Debugf("getting deployment version by name")
+	var depl deployment.DeploymentResource
+	if err := pg.queryDocument(pg.db, &depl, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND name = $2 AND version = $3 AND NOT deleted`, namespaceID, deploymentName, version.String()); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deployment version by name")
+		if err == sql.ErrNoRows {
+			return depl, rserrors.ErrResourceNotExists().AddDetailF("%v %v", deploymentName, version.String())
+		}
+		return depl, err
+	}
+	return depl, nil
+}
+
+func (pg *PostgresStorage) GetDeploymentLatestVersion(namespaceID, deploymentName string) (deployment.DeploymentResource, error) {
+	pg.logger.Debugf("getting deployment latest version by name")
+	var depl deployment.DeploymentResource
+	if err := pg.queryDocument(pg.db, &depl, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND name = $2 AND NOT deleted `+pgDeploymentVersionsOrder+` LIMIT 1`, namespaceID, deploymentName); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deployment by name")
+		if err == sql.ErrNoRows {
+			return depl, rserrors.ErrResourceNotExists().AddDetails(deploymentName)
+		}
+		return depl, err
+	}
+	return depl, nil
+}
+
+func (pg *PostgresStorage) GetDeploymentVersionsList(namespaceID string, deploymentName string) (deployment.DeploymentList, error) {
+	pg.logger.Debugf("getting deployment versions list")
+	list, err := pg.queryDeployments(pg.db, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND name = $2 AND NOT deleted `+pgDeploymentVersionsOrder, namespaceID, deploymentName)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deployment %v", deploymentName)
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) GetDeploymentList(namespaceID string) (deployment.DeploymentList, error) {
+	pg.logger.Debugf("getting deployment list")
+	list, err := pg.queryDeployments(pg.db, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND active AND NOT deleted ORDER BY seq`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deployment")
+	}
+	return list, err
+}
+
+// If ID is empty when use UUID4 to generate one
+func (pg *PostgresStorage) CreateDeployment(depl deployment.DeploymentResource) (deployment.DeploymentResource, error) {
+	pg.logger.Debugf("creating deployment")
+	if depl.ID == "" {
+		depl.ID = uuid.New().String()
+	}
+	if depl.Revision == 0 {
+		depl.Revision = 1
+	}
+	if err := pg.insertDeployment(pg.db, depl); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create deployment")
+		return depl, err
+	}
+	return depl, nil
+}
+
+func (pg *PostgresStorage) UpdateActiveDeployment(upd deployment.DeploymentResource) error {
+	pg.logger.Debugf("updating deployment")
+	var stored deployment.DeploymentResource
+	var data = pgDocument(deployment.DeploymentResource{
+		Deployment:  upd.Deployment,
+		NamespaceID: upd.NamespaceID,
+	}, &stored)
+	n, err := pg.exec(pg.db, `UPDATE deployments SET
+			owner = $3, version = $4, version_major = $5, version_minor = $6, version_patch = $7, active = $8,
+			revision = revision + 1, data = `+pgWithRevision(`jsonb_set($9::jsonb, '{_id}', to_jsonb(id))`)+`
+		WHERE id = (SELECT id FROM deployments WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted LIMIT 1)
+			AND ($10::bigint = 0 OR revision = $10)`,
+		upd.NamespaceID, upd.Name, stored.Owner,
+		stored.Version.String(), int64(stored.Version.Major), int64(stored.Version.Minor), int64(stored.Version.Patch),
+		stored.Active, string(data), upd.Revision)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to update deployment")
+		return err
+	case n == 0:
+		return pg.revisionConflict(pg.db, upd.Name, `SELECT 1 FROM deployments WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted`,
+			upd.NamespaceID, upd.Name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version, revision int64) error {
+	pg.logger.Debugf("updating deployment version")
+	n, err := pg.exec(pg.db, `UPDATE deployments SET
+			version = $4, version_major = $5, version_minor = $6, version_patch = $7,
+			revision = revision + 1, data = `+pgWithRevision(`jsonb_set(data, '{version}', to_jsonb($4::text))`)+`
+		WHERE id = (SELECT id FROM deployments WHERE namespace_id = $1 AND name = $2 AND version = $3 AND NOT deleted LIMIT 1)
+			AND ($8::bigint = 0 OR revision = $8)`,
+		namespace, name, oldversion.String(),
+		newversion.String(), int64(newversion.Major), int64(newversion.Minor), int64(newversion.Patch), revision)
+	if err == nil && n == 0 {
+		err = pg.revisionConflict(pg.db, name, `SELECT 1 FROM deployments WHERE namespace_id = $1 AND name = $2 AND version = $3 AND NOT deleted`,
+			namespace, name, oldversion.String())
+	}
+	switch {
+	case err == mgo.ErrNotFound:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, oldversion.String())
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to update deployment version")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteDeployment(namespace, name string) error {
+	pg.logger.Debugf("deleting deployment")
+	if _, err := pg.exec(pg.db, `UPDATE deployments SET `+pgSoftDelete+`
+		WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted`, namespace, name); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete deployment")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) ActivateDeployment(namespace, name string, version semver.Version) error {
+	pg.logger.Debugf("activating deployment")
+	n, err := pg.exec(pg.db, `UPDATE deployments SET active = TRUE,
+			revision = revision + 1, data = `+pgWithRevision(`jsonb_set(data, '{active}', 'true')`)+`
+		WHERE id = (SELECT id FROM deployments WHERE namespace_id = $1 AND name = $2 AND version = $3 AND NOT deleted LIMIT 1)`,
+		namespace, name, version.String())
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to activate deployment")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, version.String())
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeactivateDeployment(namespace, name string, revision int64) error {
+	pg.logger.Debugf("deactivating deployment")
+	n, err := pg.exec(pg.db, `UPDATE deployments SET active = FALSE,
+			revision = revision + 1, data = `+pgWithRevision(`jsonb_set(data, '{active}', 'false')`)+`
+		WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted AND ($3::bigint = 0 OR revision = $3)`, namespace, name, revision)
+	if err == nil && n == 0 && revision != 0 {
+		err = pg.revisionConflict(pg.db, name, `SELECT 1 FROM deployments WHERE namespace_id = $1 AND name = $2 AND active AND NOT deleted`,
+			namespace, name)
+	}
+	switch {
+	case err == mgo.ErrNotFound:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to deactivate deployment")
+		return err
+	}
+	return nil
+}
+
+// DeleteDeploymentVersion deletes inactive version of deployment
+func (pg *PostgresStorage) DeleteDeploymentVersion(namespace, name string, version semver.Version) error {
+	pg.logger.Debugf("deleting deployment version")
+	n, err := pg.exec(pg.db, `UPDATE deployments SET `+pgSoftDelete+`
+		WHERE id = (SELECT id FROM deployments WHERE namespace_id = $1 AND name = $2 AND version = $3 AND NOT active AND NOT deleted LIMIT 1)`,
+		namespace, name, version.String())
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete deployment version")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetailF("%v %v", name, version.String())
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) RestoreDeployment(namespace, name string) error {
+	pg.logger.Debugf("restoring deployment")
+	n, err := pg.exec(pg.db, `UPDATE deployments SET `+pgRestore+`
+		WHERE id = (SELECT id FROM deployments WHERE namespace_id = $1 AND name = $2 AND deleted ORDER BY deleted_at DESC NULLS LAST, active DESC, seq DESC LIMIT 1)`,
+		namespace, name)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to restore deployment")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) RestoreDeploymentVersions(namespace, name string, deletedAt time.Time) error {
+	pg.logger.Debugf("restoring deployment versions")
+	if _, err := pg.exec(pg.db, `UPDATE deployments d SET `+pgRestore+`
+		WHERE d.namespace_id = $1 AND d.name = $2 AND d.deleted AND d.deleted_at = $3 AND NOT EXISTS (
+			SELECT 1 FROM deployments alive WHERE alive.namespace_id = $1 AND alive.name = $2 AND NOT alive.deleted AND alive.version = d.version)`,
+		namespace, name, deletedAt); err != nil {
+		pg.logger.WithError(err).Errorf("unable to restore deployment versions")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllDeploymentsInNamespace(namespace string) error {
+	pg.logger.Debugf("deleting all deployments in namespace")
+	if _, err := pg.exec(pg.db, `UPDATE deployments SET `+pgSoftDelete+`
+		WHERE namespace_id = $1 AND NOT deleted`, namespace); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete deployments")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllDeploymentsByOwner(owner string) error {
+	pg.logger.Debugf("deleting all user deployments")
+	if _, err := pg.exec(pg.db, `UPDATE deployments SET `+pgSoftDelete+`
+		WHERE owner = $1 AND NOT deleted`, owner); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete deployments")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) CountDeployments(owner string) (int, error) {
+	pg.logger.Debugf("counting deployment")
+	var count int
+	err := pg.db.QueryRow(`SELECT count(*) FROM deployments WHERE owner = $1 AND active AND NOT deleted`, owner).Scan(&count)
+	return count, err
+}
+
+func (pg *PostgresStorage) CountReplicas(owner string) (int, error) {
+	pg.logger.Debugf("counting deployment replicas")
+	var count int
+	err := pg.db.QueryRow(`SELECT COALESCE(SUM((data->>'replicas')::BIGINT), 0) FROM deployments
+		WHERE owner = $1 AND active AND NOT deleted`, owner).Scan(&count)
+	return count, err
+}
+
+// GetNamespaceResourcesLimits sums containers limits multiplied by replicas of active deployments
+func (pg *PostgresStorage) GetNamespaceResourcesLimits(namespaceID string) (model.Resource, error) {
+	var res model.Resource
+	var cpu, memory int64
+	err := pg.db.QueryRow(`SELECT
+			COALESCE(SUM((data->>'replicas')::BIGINT * (container->'limits'->>'cpu')::BIGINT), 0),
+			COALESCE(SUM((data->>'replicas')::BIGINT * (container->'limits'->>'memory')::BIGINT), 0)
+		FROM deployments, jsonb_array_elements(`+pgArray(`data->'containers'`)+`) AS container
+		WHERE namespace_id = $1 AND active AND NOT deleted`, namespaceID).Scan(&cpu, &memory)
+	res.CPU, res.Memory = uint(cpu), uint(memory)
+	return res, err
+}
+
+func (pg *PostgresStorage) GetNamespacesList() ([]string, error) {
+	pg.logger.Debugf("getting namespaces list")
+	const query = `SELECT namespace_id FROM deployments WHERE active AND NOT deleted
+		UNION SELECT namespace_id FROM services WHERE NOT deleted
+		UNION SELECT namespace_id FROM ingresses WHERE NOT deleted
+		ORDER BY namespace_id`
+	pg.debugQuery(query)
+	rows, err := pg.db.Query(query)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get namespaces list")
+		return nil, pgErr(err)
+	}
+	defer rows.Close()
+	var ret []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ret = append(ret, id)
+	}
+	return ret, rows.Err()
+}
@@ -0,0 +1,239 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/cherry"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+func (pg *PostgresStorage) queryDomains(q pgQuerier, query string, args ...interface{}) (domain.DomainList, error) {
+	var list domain.DomainList
+	err := pg.queryDocuments(q, func(data []byte) error {
+		var dom domain.Domain
+		if err := json.Unmarshal(data, &dom); err != nil {
+			return err
+		}
+		list = append(list, dom)
+		return nil
+	}, query, args...)
+	return list, err
+}
+
+func (pg *PostgresStorage) insertDomain(q pgQuerier, dom domain.Domain) error {
+	var stored domain.Domain
+	var data = pgDocument(dom, &stored)
+	_, err := pg.exec(q, `INSERT INTO domains (id, domain, domain_group, data) VALUES ($1, $2, $3, $4)`,
+		stored.ID, stored.Domain, stored.DomainGroup, string(data))
+	return err
+}
+
+// queryDomain returns mgo.ErrNotFound if there is no domain, like MongoStorage does
+func (pg *PostgresStorage) queryDomain(query string, args ...interface{}) (*domain.Domain, error) {
+	var result domain.Domain
+	if err := pg.queryDocument(pg.db, &result, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, mgo.ErrNotFound
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (pg *PostgresStorage) GetDomain(domainName string, pages ...uint) (*domain.Domain, error) {
+	pg.logger.Debugf("getting domain")
+	result, err := pg.queryDomain(`SELECT data FROM domains WHERE domain = $1 ORDER BY seq LIMIT 1`, domainName)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get domain")
+	}
+	return result, err
+}
+
+func (pg *PostgresStorage) GetRandomDomain() (*domain.Domain, error) {
+	pg.logger.Debugf("getting random domain")
+	result, err := pg.queryDomain(`SELECT data FROM domains ORDER BY random() LIMIT 1`)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get random domain")
+	}
+	return result, err
+}
+
+func (pg *PostgresStorage) GetDomainGroup(domainGroup string) (domain.DomainList, error) {
+	pg.logger.Debugf("getting domain group")
+	list, err := pg.queryDomains(pg.db, `SELECT data FROM domains WHERE domain_group = $1 ORDER BY seq`, domainGroup)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get domain group")
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetDomainsList supports pagination
+func (pg *PostgresStorage) GetDomainsList(pages *PageInfo) ([]domain.Domain, error) {
+	pg.logger.Debugf("getting domain list")
+	var query = `SELECT data FROM domains ORDER BY seq`
+	var args []interface{}
+	if pages != nil {
+		var limit, offset = pages.Init()
+		query += ` LIMIT $1 OFFSET $2`
+		args = append(args, limit, offset)
+	}
+	list, err := pg.queryDomains(pg.db, query, args...)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get domain list")
+		return nil, err
+	}
+	return list, nil
+}
+
+func (pg *PostgresStorage) CreateDomain(dom domain.Domain) (*domain.Domain, error) {
+	pg.logger.Debugf("creating domain")
+	if dom.ID == "" {
+		dom.ID = uuid.New().String()
+	}
+	if err := pg.insertDomain(pg.db, dom); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create domain")
+		return nil, err
+	}
+	return &dom, nil
+}
+
+func (pg *PostgresStorage) UpdateDomain(dom domain.Domain) (*domain.Domain, error) {
+	pg.logger.Debugf("updating domain")
+	var stored domain.Domain
+	var data = pgDocument(dom, &stored)
+	n, err := pg.exec(pg.db, `UPDATE domains SET domain_group = $2, data = jsonb_set($3::jsonb, '{_id}', to_jsonb(id))
+		WHERE id = (SELECT id FROM domains WHERE domain = $1 ORDER BY seq LIMIT 1)`,
+		dom.Domain, stored.DomainGroup, string(data))
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to update domain")
+		return nil, err
+	case n == 0:
+		return nil, mgo.ErrNotFound
+	}
+	return &dom, nil
+}
+
+func (pg *PostgresStorage) DeleteDomain(domainName string) error {
+	pg.logger.Debugf("deleting domain")
+	n, err := pg.exec(pg.db, `DELETE FROM domains WHERE id = (SELECT id FROM domains WHERE domain = $1 ORDER BY seq LIMIT 1)`, domainName)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete domain")
+		return err
+	case n == 0:
+		return mgo.ErrNotFound
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) queryCustomDomains(q pgQuerier, query string, args ...interface{}) (customdomain.CustomDomainList, error) {
+	var list customdomain.CustomDomainList
+	err := pg.queryDocuments(q, func(data []byte) error {
+		var dom customdomain.CustomDomain
+		if err := json.Unmarshal(data, &dom); err != nil {
+			return err
+		}
+		list = append(list, dom)
+		return nil
+	}, query, args...)
+	return list, err
+}
+
+func (pg *PostgresStorage) insertCustomDomain(q pgQuerier, dom customdomain.CustomDomain) error {
+	var stored customdomain.CustomDomain
+	var data = pgDocument(dom, &stored)
+	_, err := pg.exec(q, `INSERT INTO custom_domains (id, namespace_id, domain, verified, deleted, data) VALUES ($1, $2, $3, $4, $5, $6)`,
+		stored.ID, stored.NamespaceID, stored.Domain, stored.Verified, stored.Deleted, string(data))
+	return err
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (pg *PostgresStorage) CreateCustomDomain(dom customdomain.CustomDomain) (customdomain.CustomDomain, error) {
+	pg.logger.Debugf("creating custom domain")
+	if dom.ID == "" {
+		dom.ID = uuid.New().String()
+	}
+	if err := pg.insertCustomDomain(pg.db, dom); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create custom domain")
+		return dom, err
+	}
+	return dom, nil
+}
+
+func (pg *PostgresStorage) GetCustomDomain(namespaceID, domain string) (customdomain.CustomDomain, error) {
+	pg.logger.Debugf("getting custom domain")
+	var result customdomain.CustomDomain
+	if err := pg.queryDocument(pg.db, &result, `SELECT data FROM custom_domains
+		WHERE namespace_id = $1 AND domain = $2 AND NOT deleted`, namespaceID, domain); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get custom domain")
+		if err == sql.ErrNoRows {
+			return result, rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresStorage) GetCustomDomainList(namespaceID string) (customdomain.CustomDomainList, error) {
+	pg.logger.Debugf("getting custom domain list")
+	list, err := pg.queryCustomDomains(pg.db, `SELECT data FROM custom_domains
+		WHERE namespace_id = $1 AND NOT deleted ORDER BY seq`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get custom domain list")
+	}
+	return list, err
+}
+
+// GetVerifiedCustomDomain returns verified domain from any namespace
+func (pg *PostgresStorage) GetVerifiedCustomDomain(domain string) (customdomain.CustomDomain, error) {
+	pg.logger.Debugf("getting verified custom domain")
+	var result customdomain.CustomDomain
+	if err := pg.queryDocument(pg.db, &result, `SELECT data FROM custom_domains
+		WHERE domain = $1 AND verified AND NOT deleted`, domain); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get verified custom domain")
+		if err == sql.ErrNoRows {
+			return result, rserrors.ErrResourceNotExists().AddDetails(domain)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresStorage) VerifyCustomDomain(namespaceID, domain, verifiedAt string) error {
+	pg.logger.Debugf("verifying custom domain")
+	n, err := pg.exec(pg.db, `UPDATE custom_domains
+		SET verified = TRUE, data = jsonb_set(jsonb_set(data, '{verified}', 'true'), '{verified_at}', to_jsonb($3::text))
+		WHERE namespace_id = $1 AND domain = $2 AND NOT deleted`, namespaceID, domain, verifiedAt)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to verify custom domain")
+		if cherry.Equals(err, rserrors.ErrResourceAlreadyExists()) {
+			return rserrors.ErrResourceAlreadyExists().AddDetailF("domain %s is already verified in another namespace", domain)
+		}
+		return err
+	}
+	if n == 0 {
+		return rserrors.ErrResourceNotExists().AddDetails(domain)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteCustomDomain(namespaceID, domain string) error {
+	pg.logger.Debugf("deleting custom domain")
+	n, err := pg.exec(pg.db, `UPDATE custom_domains SET deleted = TRUE, data = jsonb_set(data, '{deleted}', 'true')
+		WHERE namespace_id = $1 AND domain = $2 AND NOT deleted`, namespaceID, domain)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete custom domain")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(domain)
+	}
+	return nil
+}
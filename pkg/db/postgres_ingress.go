@@ -0,0 +1,306 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"regexp"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (pg *PostgresStorage) queryIngresses(q pgQuerier, query string, args ...interface{}) (ingress.IngressList, error) {
+	var list ingress.IngressList
+	err := pg.queryDocuments(q, func(data []byte) error {
+		var ingr ingress.IngressResource
+		if err := json.Unmarshal(data, &ingr); err != nil {
+			return err
+		}
+		list = append(list, ingr)
+		return nil
+	}, query, args...)
+	return list, err
+}
+
+// insertIngressHosts fills ingress_hosts, which keeps unique index of hosts of not deleted ingresses
+func (pg *PostgresStorage) insertIngressHosts(q pgQuerier, ingr ingress.IngressResource) error {
+	var inserted = make(map[string]struct{})
+	for _, host := range ingr.Hosts() {
+		// equal keys of one document are allowed, like in mongo multikey indexes
+		if _, ok := inserted[host]; ok {
+			continue
+		}
+		inserted[host] = struct{}{}
+		if _, err := pg.exec(q, `INSERT INTO ingress_hosts (ingress_id, host) VALUES ($1, $2)`, ingr.ID, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) insertIngress(q pgQuerier, ingr ingress.IngressResource) error {
+	var stored ingress.IngressResource
+	var data = pgDocument(ingr, &stored)
+	if _, err := pg.exec(q, `INSERT INTO ingresses (id, namespace_id, name, owner, acme_enabled, deleted, deleted_at, revision, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		stored.ID, stored.NamespaceID, stored.Name, stored.Owner, stored.ACME != nil && stored.ACME.Enabled, stored.Deleted, stored.DeletedAt, stored.Revision, string(data)); err != nil {
+		return err
+	}
+	if stored.Deleted {
+		return nil
+	}
+	return pg.insertIngressHosts(q, stored)
+}
+
+// getIngress returns first not deleted ingress in namespace matching condition
+func (pg *PostgresStorage) getIngress(details, condition string, args ...interface{}) (ingress.IngressResource, error) {
+	var ingr ingress.IngressResource
+	if err := pg.queryDocument(pg.db, &ingr, `SELECT data FROM ingresses
+		WHERE namespace_id = $1 AND NOT deleted AND `+condition+` ORDER BY seq LIMIT 1`, args...); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get ingress")
+		if err == sql.ErrNoRows {
+			return ingr, rserrors.ErrResourceNotExists().AddDetails(details)
+		}
+		return ingr, err
+	}
+	return ingr, nil
+}
+
+func (pg *PostgresStorage) CreateIngress(ingr ingress.IngressResource) (ingress.IngressResource, error) {
+	pg.logger.Debugf("creating ingress")
+	if ingr.ID == "" {
+		ingr.ID = uuid.New().String()
+	}
+	if ingr.Revision == 0 {
+		ingr.Revision = 1
+	}
+	if err := pg.pgTx(func(tx *sql.Tx) error {
+		return pg.insertIngress(tx, ingr)
+	}); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create ingress")
+		return ingr, err
+	}
+	return ingr, nil
+}
+
+func (pg *PostgresStorage) GetIngress(namespaceID, name string) (ingress.IngressResource, error) {
+	pg.logger.Debugf("getting ingress")
+	return pg.getIngress(name, `name = $2`, namespaceID, name)
+}
+
+func (pg *PostgresStorage) GetIngressByService(namespaceID, serviceName string) (ingress.IngressResource, error) {
+	pg.logger.Debugf("getting ingress by service")
+	var backend = []map[string]string{{"service_name": serviceName}}
+	return pg.getIngress(serviceName, `(data @> $2::jsonb OR data @> $3::jsonb)`, namespaceID,
+		pgJSON(map[string]interface{}{"rules": []interface{}{map[string]interface{}{"path": backend}}}),
+		pgJSON(map[string]interface{}{"splits": []interface{}{map[string]interface{}{"backends": backend}}}))
+}
+
+// GetIngressByDomain returns ingress using domain or any of its subdomains, including wildcard ones
+func (pg *PostgresStorage) GetIngressByDomain(namespaceID, domain string) (ingress.IngressResource, error) {
+	pg.logger.Debugf("getting ingress by domain")
+	return pg.getIngress(domain, `id IN (SELECT ingress_id FROM ingress_hosts WHERE host = $2 OR right(host, length($3::text)) = $3::text)`,
+		namespaceID, domain, "."+domain)
+}
+
+func (pg *PostgresStorage) GetIngressByTLSSecret(namespaceID, secretName string) (ingress.IngressResource, error) {
+	pg.logger.Debugf("getting ingress by tls secret")
+	return pg.getIngress(secretName, `data @> $2::jsonb`, namespaceID,
+		pgJSON(map[string]interface{}{"rules": []interface{}{map[string]string{"tls_secret": secretName}}}))
+}
+
+func (pg *PostgresStorage) GetIngressList(namespaceID string) (ingress.IngressList, error) {
+	pg.logger.Debugf("getting ingress")
+	list, err := pg.queryIngresses(pg.db, `SELECT data FROM ingresses
+		WHERE namespace_id = $1 AND NOT deleted ORDER BY seq`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get ingress list")
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) UpdateIngress(upd ingress.IngressResource) (ingress.IngressResource, error) {
+	pg.logger.Debugf("updating ingress")
+	var stored ingress.IngressResource
+	clone(upd, &stored)
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		var current ingress.IngressResource
+		if err := pg.queryDocument(tx, &current, `SELECT data FROM ingresses
+			WHERE namespace_id = $1 AND name = $2 AND NOT deleted FOR UPDATE`, upd.NamespaceID, upd.Name); err != nil {
+			if err == sql.ErrNoRows {
+				return mgo.ErrNotFound // same error as MongoStorage returns
+			}
+			return err
+		}
+		if upd.Revision != 0 && upd.Revision != current.Revision {
+			return rserrors.ErrResourceModified().AddDetails(upd.Name)
+		}
+		current.Ingress = stored.Ingress
+		current.Options = stored.Options
+		current.Splits = stored.Splits
+		current.Revision++
+		upd.Revision = current.Revision
+		if _, err := pg.exec(tx, `UPDATE ingresses SET owner = $2, revision = $3, data = $4 WHERE id = $1`,
+			current.ID, current.Owner, current.Revision, pgJSON(current)); err != nil {
+			return err
+		}
+		if _, err := pg.exec(tx, `DELETE FROM ingress_hosts WHERE ingress_id = $1`, current.ID); err != nil {
+			return err
+		}
+		return pg.insertIngressHosts(tx, current)
+	})
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to update ingress")
+		return upd, err
+	}
+	return upd, nil
+}
+
+// deleteIngresses marks ingresses matching condition as deleted and frees their hosts. Returns number of deleted ingresses.
+func (pg *PostgresStorage) deleteIngresses(condition string, args ...interface{}) (int64, error) {
+	var n int64
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		if _, err := pg.exec(tx, `DELETE FROM ingress_hosts WHERE ingress_id IN (SELECT id FROM ingresses WHERE `+condition+`)`, args...); err != nil {
+			return err
+		}
+		var err error
+		n, err = pg.exec(tx, `UPDATE ingresses SET `+pgSoftDelete+` WHERE `+condition, args...)
+		return err
+	})
+	return n, err
+}
+
+func (pg *PostgresStorage) DeleteIngress(namespaceID, name string) error {
+	pg.logger.Debugf("deleting ingress")
+	n, err := pg.deleteIngresses(`id = (SELECT id FROM ingresses WHERE namespace_id = $1 AND name = $2 AND NOT deleted LIMIT 1)`, namespaceID, name)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete ingress")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) RestoreIngress(namespaceID, name string) error {
+	pg.logger.Debugf("restoring ingress")
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		var ingr ingress.IngressResource
+		if err := pg.queryDocument(tx, &ingr, `UPDATE ingresses SET `+pgRestore+`
+			WHERE id = (SELECT id FROM ingresses WHERE namespace_id = $1 AND name = $2 AND deleted ORDER BY deleted_at DESC NULLS LAST, seq DESC LIMIT 1)
+			RETURNING data`, namespaceID, name); err != nil {
+			if err == sql.ErrNoRows {
+				return rserrors.ErrResourceNotExists().AddDetails(name)
+			}
+			return err
+		}
+		return pg.insertIngressHosts(tx, ingr)
+	})
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to restore ingress")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllIngressesInNamespace(namespace string) error {
+	pg.logger.Debugf("deleting all ingresses in namespace")
+	if _, err := pg.deleteIngresses(`namespace_id = $1 AND NOT deleted`, namespace); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete ingresses")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllIngressesByOwner(owner string) error {
+	pg.logger.Debugf("deleting all user ingresses")
+	if _, err := pg.deleteIngresses(`owner = $1 AND NOT deleted`, owner); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete ingresses")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) CountIngresses(owner string) (int, error) {
+	pg.logger.Debugf("counting ingresses")
+	var count int
+	err := pg.db.QueryRow(`SELECT count(*) FROM ingresses WHERE owner = $1 AND NOT deleted`, owner).Scan(&count)
+	return count, err
+}
+
+// GetIngressesByHostsOverlap returns ingresses with hosts equal to one of hosts or overlapping it by wildcard
+func (pg *PostgresStorage) GetIngressesByHostsOverlap(hosts []string) (ingress.IngressList, error) {
+	pg.logger.Debugf("getting ingresses by overlapping hosts")
+	var exact = make([]string, 0, len(hosts))
+	var patterns = make([]string, 0)
+	for _, host := range hosts {
+		exact = append(exact, host)
+		if parent, ok := ingress.WildcardParent(host); ok {
+			exact = append(exact, parent)
+		}
+		if base, wildcard := ingress.WildcardBase(host); wildcard {
+			patterns = append(patterns, `^[^.]+\.`+regexp.QuoteMeta(base)+`$`)
+		}
+	}
+	list, err := pg.queryIngresses(pg.db, `SELECT data FROM ingresses
+		WHERE NOT deleted AND id IN (SELECT ingress_id FROM ingress_hosts WHERE host = ANY($1) OR host ~ ANY($2))
+		ORDER BY seq`, pq.Array(exact), pq.Array(patterns))
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get ingresses by overlapping hosts")
+	}
+	return list, err
+}
+
+// GetIngressHostConflicts returns hosts used by more than one ingress
+func (pg *PostgresStorage) GetIngressHostConflicts() (ingress.HostConflictList, error) {
+	pg.logger.Debugf("getting ingress host conflicts")
+	var conflicts = make(ingress.HostConflictList, 0)
+	err := pg.queryDocuments(pg.db, func(data []byte) error {
+		var conflict ingress.HostConflict
+		if err := json.Unmarshal(data, &conflict); err != nil {
+			return err
+		}
+		conflicts = append(conflicts, conflict)
+		return nil
+	}, `SELECT jsonb_build_object('host', host, 'ingresses',
+			jsonb_agg(jsonb_build_object('namespaceid', namespace_id, 'ingress', name, 'owner', owner) ORDER BY namespace_id, name))
+		FROM (SELECT DISTINCT rule->>'host' AS host, namespace_id, name, owner
+			FROM ingresses, jsonb_array_elements(`+pgArray(`data->'rules'`)+`) AS rule
+			WHERE NOT deleted) AS hosts
+		GROUP BY host HAVING count(*) > 1 ORDER BY host`)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get ingress host conflicts")
+	}
+	return conflicts, err
+}
+
+func (pg *PostgresStorage) GetACMEIngressList() (ingress.IngressList, error) {
+	pg.logger.Debugf("getting ingresses with acme enabled")
+	list, err := pg.queryIngresses(pg.db, `SELECT data FROM ingresses WHERE acme_enabled AND NOT deleted ORDER BY seq`)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get ingresses with acme enabled")
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) UpdateIngressACME(ingr ingress.IngressResource) error {
+	pg.logger.Debugf("updating ingress acme status")
+	var stored ingress.IngressResource
+	clone(ingr, &stored)
+	n, err := pg.exec(pg.db, `UPDATE ingresses SET acme_enabled = $3,
+			revision = revision + 1, data = `+pgWithRevision(`jsonb_set(data, '{acme}', $4::jsonb)`)+`
+		WHERE id = (SELECT id FROM ingresses WHERE namespace_id = $1 AND name = $2 AND NOT deleted LIMIT 1)`,
+		ingr.NamespaceID, ingr.Name, stored.ACME != nil && stored.ACME.Enabled, pgJSON(stored.ACME))
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to update ingress acme status")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(ingr.Name)
+	}
+	return nil
+}
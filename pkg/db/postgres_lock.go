@@ -0,0 +1,28 @@
+package db
+
+import (
+	"time"
+)
+
+// AcquireLock inserts lock row or takes it over if it is expired or already held by owner
+func (pg *PostgresStorage) AcquireLock(name, owner string, ttl time.Duration) (bool, error) {
+	pg.logger.Debugf("acquiring lock")
+	var now = time.Now().UTC()
+	n, err := pg.exec(pg.db, `INSERT INTO locks (name, owner, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+		WHERE locks.owner = EXCLUDED.owner OR locks.expires_at < $4`, name, owner, now.Add(ttl), now)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to acquire lock")
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (pg *PostgresStorage) ReleaseLock(name, owner string) error {
+	pg.logger.Debugf("releasing lock")
+	if _, err := pg.exec(pg.db, `DELETE FROM locks WHERE name = $1 AND owner = $2`, name, owner); err != nil {
+		pg.logger.WithError(err).Errorf("unable to release lock")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// pgMigrationsLock is key of advisory lock held while migrations are applied,
+// so several instances may be started simultaneously
+const pgMigrationsLock = 0x7265736f75726365
+
+const errPgSchemaVersion = "db schema version (%v) is newer than application schema version (%v)"
+
+type pgMigration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// pgMigrations are applied in order, each in the same transaction with its record in schema_migrations.
+// Applied migrations must never be changed, add new one instead.
+var pgMigrations = []pgMigration{
+	{
+		Version: 1,
+		Name:    "initial",
+		Up: `
+CREATE TABLE deployments (
+	seq           BIGSERIAL,
+	id            TEXT PRIMARY KEY,
+	namespace_id  TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	owner         TEXT NOT NULL DEFAULT '',
+	version       TEXT NOT NULL,
+	version_major BIGINT NOT NULL,
+	version_minor BIGINT NOT NULL,
+	version_patch BIGINT NOT NULL,
+	active        BOOLEAN NOT NULL DEFAULT FALSE,
+	deleted       BOOLEAN NOT NULL DEFAULT FALSE,
+	data          JSONB NOT NULL
+);
+CREATE INDEX deployments_namespace ON deployments (namespace_id);
+CREATE INDEX deployments_owner ON deployments (owner);
+CREATE UNIQUE INDEX alive_deployment ON deployments (name, namespace_id) WHERE NOT deleted AND active;
+CREATE UNIQUE INDEX unique_version_deployment ON deployments (name, namespace_id, version) WHERE NOT deleted;
+
+CREATE TABLE services (
+	seq          BIGSERIAL,
+	id           TEXT PRIMARY KEY,
+	namespace_id TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	owner        TEXT NOT NULL DEFAULT '',
+	domain       TEXT NOT NULL DEFAULT '',
+	deleted      BOOLEAN NOT NULL DEFAULT FALSE,
+	data         JSONB NOT NULL
+);
+CREATE INDEX services_namespace ON services (namespace_id);
+CREATE INDEX services_owner ON services (owner);
+CREATE UNIQUE INDEX alive_service ON services (name, namespace_id) WHERE NOT deleted;
+
+-- ports of not deleted services, rows are removed on soft delete and inserted back on restore
+CREATE TABLE service_ports (
+	service_id TEXT NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+	domain     TEXT NOT NULL,
+	port       INTEGER,
+	protocol   TEXT NOT NULL
+);
+CREATE INDEX service_ports_service ON service_ports (service_id);
+CREATE UNIQUE INDEX alive_service_with_ports ON service_ports (domain, COALESCE(port, -1), protocol);
+
+CREATE TABLE ingresses (
+	seq          BIGSERIAL,
+	id           TEXT PRIMARY KEY,
+	namespace_id TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	owner        TEXT NOT NULL DEFAULT '',
+	acme_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	deleted      BOOLEAN NOT NULL DEFAULT FALSE,
+	data         JSONB NOT NULL
+);
+CREATE INDEX ingresses_namespace ON ingresses (namespace_id);
+CREATE INDEX ingresses_owner ON ingresses (owner);
+CREATE INDEX ingresses_acme ON ingresses (acme_enabled) WHERE NOT deleted;
+CREATE UNIQUE INDEX alive_ingress ON ingresses (name, namespace_id) WHERE NOT deleted;
+
+-- hosts of not deleted ingresses, rows are removed on soft delete and inserted back on restore
+CREATE TABLE ingress_hosts (
+	ingress_id TEXT NOT NULL REFERENCES ingresses (id) ON DELETE CASCADE,
+	host       TEXT NOT NULL
+);
+CREATE INDEX ingress_hosts_ingress ON ingress_hosts (ingress_id);
+CREATE UNIQUE INDEX unique_host_ingress ON ingress_hosts (host);
+
+CREATE TABLE domains (
+	seq          BIGSERIAL,
+	id           TEXT PRIMARY KEY,
+	domain       TEXT NOT NULL,
+	domain_group TEXT NOT NULL DEFAULT '',
+	data         JSONB NOT NULL
+);
+CREATE INDEX domains_domain ON domains (domain);
+CREATE INDEX domains_domain_group ON domains (domain_group, domain);
+
+CREATE TABLE custom_domains (
+	seq          BIGSERIAL,
+	id           TEXT PRIMARY KEY,
+	namespace_id TEXT NOT NULL,
+	domain       TEXT NOT NULL,
+	verified     BOOLEAN NOT NULL DEFAULT FALSE,
+	deleted      BOOLEAN NOT NULL DEFAULT FALSE,
+	data         JSONB NOT NULL
+);
+CREATE INDEX custom_domains_namespace ON custom_domains (namespace_id);
+CREATE UNIQUE INDEX alive_custom_domain ON custom_domains (domain, namespace_id) WHERE NOT deleted;
+CREATE UNIQUE INDEX verified_custom_domain ON custom_domains (domain) WHERE NOT deleted AND verified;
+
+CREATE TABLE certificates (
+	seq          BIGSERIAL,
+	id           TEXT PRIMARY KEY,
+	namespace_id TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	deleted      BOOLEAN NOT NULL DEFAULT FALSE,
+	data         JSONB NOT NULL
+);
+CREATE INDEX certificates_namespace ON certificates (namespace_id);
+CREATE UNIQUE INDEX alive_certificate ON certificates (name, namespace_id) WHERE NOT deleted;
+
+CREATE TABLE acme_challenges (
+	token TEXT PRIMARY KEY,
+	data  JSONB NOT NULL
+);
+`,
+		Down: `
+DROP TABLE acme_challenges;
+DROP TABLE certificates;
+DROP TABLE custom_domains;
+DROP TABLE domains;
+DROP TABLE ingress_hosts;
+DROP TABLE ingresses;
+DROP TABLE service_ports;
+DROP TABLE services;
+DROP TABLE deployments;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "operations",
+		Up: `
+CREATE TABLE operations (
+	seq        BIGSERIAL,
+	id         TEXT PRIMARY KEY,
+	status     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	data       JSONB NOT NULL
+);
+CREATE INDEX operations_status ON operations (status, updated_at);
+`,
+		Down: `
+DROP TABLE operations;
+`,
+	},
+	{
+		Version: 3,
+		Name:    "deleted_at",
+		Up: `
+ALTER TABLE deployments ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE services ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE ingresses ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- resources deleted before deletion time was stored are kept for full retention period since now
+UPDATE deployments SET deleted_at = now(), data = data || jsonb_build_object('deleted_at', now()) WHERE deleted;
+UPDATE services SET deleted_at = now(), data = data || jsonb_build_object('deleted_at', now()) WHERE deleted;
+UPDATE ingresses SET deleted_at = now(), data = data || jsonb_build_object('deleted_at', now()) WHERE deleted;
+
+CREATE INDEX deployments_deleted_at ON deployments (namespace_id, deleted_at) WHERE deleted;
+CREATE INDEX services_deleted_at ON services (namespace_id, deleted_at) WHERE deleted;
+CREATE INDEX ingresses_deleted_at ON ingresses (namespace_id, deleted_at) WHERE deleted;
+`,
+		Down: `
+UPDATE deployments SET data = data - 'deleted_at' WHERE deleted;
+UPDATE services SET data = data - 'deleted_at' WHERE deleted;
+UPDATE ingresses SET data = data - 'deleted_at' WHERE deleted;
+
+ALTER TABLE deployments DROP COLUMN deleted_at;
+ALTER TABLE services DROP COLUMN deleted_at;
+ALTER TABLE ingresses DROP COLUMN deleted_at;
+`,
+	},
+	{
+		Version: 4,
+		Name:    "revision",
+		Up: `
+ALTER TABLE deployments ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE services ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE ingresses ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
+
+-- resources created before revisions were stored are treated as first revision
+UPDATE deployments SET data = data || '{"revision": 1}';
+UPDATE services SET data = data || '{"revision": 1}';
+UPDATE ingresses SET data = data || '{"revision": 1}';
+`,
+		Down: `
+UPDATE deployments SET data = data - 'revision';
+UPDATE services SET data = data - 'revision';
+UPDATE ingresses SET data = data - 'revision';
+
+ALTER TABLE deployments DROP COLUMN revision;
+ALTER TABLE services DROP COLUMN revision;
+ALTER TABLE ingresses DROP COLUMN revision;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "locks",
+		Up: `
+-- locks of periodic jobs running only in one replica at once
+CREATE TABLE locks (
+	name       TEXT PRIMARY KEY,
+	owner      TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- values shared by replicas, e.g. ACME account key
+CREATE TABLE settings (
+	name  TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`,
+		Down: `
+DROP TABLE settings;
+DROP TABLE locks;
+`,
+	},
+}
+
+// pgMigrationsTx runs fn in transaction holding migrations lock, fn gets version of last applied migration
+func (pg *PostgresStorage) pgMigrationsTx(fn func(tx *sql.Tx, current int) error) error {
+	return pg.pgTx(func(tx *sql.Tx) error {
+		if _, err := pg.exec(tx, `SELECT pg_advisory_xact_lock($1)`, pgMigrationsLock); err != nil {
+			return err
+		}
+		if _, err := pg.exec(tx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+			return err
+		}
+		var current int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+			return err
+		}
+		if latest := pgMigrations[len(pgMigrations)-1].Version; current > latest {
+			return fmt.Errorf(errPgSchemaVersion, current, latest)
+		}
+		return fn(tx, current)
+	})
+}
+
+func (migration pgMigration) String() string {
+	return fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+}
+
+// errPgRollback rolls back transaction without reporting error
+var errPgRollback = errors.New("rollback")
+
+// PendingMigrations lists names of migrations not applied yet, nothing is changed in db
+func (pg *PostgresStorage) PendingMigrations() ([]string, error) {
+	var pending []string
+	err := pg.pgMigrationsTx(func(tx *sql.Tx, current int) error {
+		for _, migration := range pgMigrations {
+			if migration.Version > current {
+				pending = append(pending, migration.String())
+			}
+		}
+		return errPgRollback
+	})
+	if err != nil && err != errPgRollback {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// Migrate applies migrations not recorded in schema_migrations table
+func (pg *PostgresStorage) Migrate() error {
+	return pg.pgMigrationsTx(func(tx *sql.Tx, current int) error {
+		if current == pgMigrations[len(pgMigrations)-1].Version {
+			pg.logger.Infoln("no need to migrate db")
+			return nil
+		}
+		for _, migration := range pgMigrations {
+			if migration.Version <= current {
+				continue
+			}
+			pg.logger.Infof("applying migration %s", migration)
+			if _, err := pg.exec(tx, migration.Up); err != nil {
+				pg.logger.WithError(err).Errorf("unable to apply migration %s", migration)
+				return err
+			}
+			if _, err := pg.exec(tx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts last applied migrations in reverse order, returns names of reverted migrations.
+// All migrations are reverted in one transaction.
+func (pg *PostgresStorage) MigrateDown(steps int) ([]string, error) {
+	var reverted []string
+	err := pg.pgMigrationsTx(func(tx *sql.Tx, current int) error {
+		for i := len(pgMigrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			var migration = pgMigrations[i]
+			if migration.Version > current {
+				continue
+			}
+			pg.logger.Infof("reverting migration %s", migration)
+			if _, err := pg.exec(tx, migration.Down); err != nil {
+				pg.logger.WithError(err).Errorf("unable to revert migration %s", migration)
+				return err
+			}
+			if _, err := pg.exec(tx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
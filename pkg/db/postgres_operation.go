@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (pg *PostgresStorage) insertOperation(q pgQuerier, op operation.Operation) error {
+	var stored operation.Operation
+	var data = pgDocument(op, &stored)
+	_, err := pg.exec(q, `INSERT INTO operations (id, status, created_at, updated_at, data) VALUES ($1, $2, $3, $4, $5)`,
+		stored.ID, string(stored.Status), stored.CreatedAt, stored.UpdatedAt, string(data))
+	return err
+}
+
+func pgStatuses(statuses []operation.Status) interface{} {
+	var list = make(pq.StringArray, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, string(status))
+	}
+	return list
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (pg *PostgresStorage) CreateOperation(op operation.Operation) (operation.Operation, error) {
+	pg.logger.Debugf("creating operation")
+	if op.ID == "" {
+		op.ID = uuid.New().String()
+	}
+	if err := pg.insertOperation(pg.db, op); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create operation")
+		return op, err
+	}
+	return op, nil
+}
+
+func (pg *PostgresStorage) GetOperation(id string) (operation.Operation, error) {
+	pg.logger.Debugf("getting operation")
+	var result operation.Operation
+	if err := pg.queryDocument(pg.db, &result, `SELECT data FROM operations WHERE id = $1`, id); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get operation")
+		if err == sql.ErrNoRows {
+			return result, rserrors.ErrResourceNotExists().AddDetails(id)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresStorage) SetOperationStatus(id string, status operation.Status, opErr string) error {
+	pg.logger.Debugf("setting operation status")
+	n, err := pg.exec(pg.db, `UPDATE operations SET status = $2, updated_at = $4,
+			data = data || jsonb_build_object('status', $2::text, 'error', $3::text, 'updated_at', $4::timestamptz)
+		WHERE id = $1`, id, string(status), opErr, time.Now().UTC())
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to set operation status")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(id)
+	}
+	return nil
+}
+
+// GetStaleOperations returns not finished operations not updated since time, oldest first
+func (pg *PostgresStorage) GetStaleOperations(before time.Time) (operation.OperationList, error) {
+	pg.logger.Debugf("getting stale operations")
+	var list operation.OperationList
+	err := pg.queryDocuments(pg.db, func(data []byte) error {
+		var op operation.Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return err
+		}
+		list = append(list, op)
+		return nil
+	}, `SELECT data FROM operations WHERE status = ANY($1) AND updated_at < $2 ORDER BY created_at, seq`,
+		pgStatuses(operation.ActiveStatuses()), before)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get stale operations")
+	}
+	return list, err
+}
+
+// GetActiveOperations returns not finished operations, oldest first
+func (pg *PostgresStorage) GetActiveOperations() (operation.OperationList, error) {
+	pg.logger.Debugf("getting active operations")
+	var list operation.OperationList
+	err := pg.queryDocuments(pg.db, func(data []byte) error {
+		var op operation.Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return err
+		}
+		list = append(list, op)
+		return nil
+	}, `SELECT data FROM operations WHERE status = ANY($1) ORDER BY created_at, seq`,
+		pgStatuses(operation.ActiveStatuses()))
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get active operations")
+	}
+	return list, err
+}
+
+// ClaimOperation atomically increments attempts of operation if it is still stale.
+// Returns false if operation was finished or claimed by someone else.
+func (pg *PostgresStorage) ClaimOperation(id string, before time.Time) (bool, error) {
+	pg.logger.Debugf("claiming operation")
+	n, err := pg.exec(pg.db, `UPDATE operations SET updated_at = $4,
+			data = data || jsonb_build_object('attempts', COALESCE((data->>'attempts')::INTEGER, 0) + 1, 'updated_at', $4::timestamptz)
+		WHERE id = $1 AND status = ANY($2) AND updated_at < $3`,
+		id, pgStatuses(operation.ActiveStatuses()), before, time.Now().UTC())
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to claim operation")
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteFinishedOperations removes finished operations completely, journal keeps only recent history
+func (pg *PostgresStorage) DeleteFinishedOperations(before time.Time) error {
+	pg.logger.Debugf("deleting finished operations")
+	if _, err := pg.exec(pg.db, `DELETE FROM operations WHERE NOT (status = ANY($1)) AND updated_at < $2`,
+		pgStatuses(operation.ActiveStatuses()), before); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete finished operations")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/models/stats"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+)
+
+func (pg *PostgresStorage) queryServices(q pgQuerier, query string, args ...interface{}) (service.ServiceList, error) {
+	var list service.ServiceList
+	err := pg.queryDocuments(q, func(data []byte) error {
+		var svc service.ServiceResource
+		if err := json.Unmarshal(data, &svc); err != nil {
+			return err
+		}
+		list = append(list, svc)
+		return nil
+	}, query, args...)
+	return list, err
+}
+
+// insertServicePorts fills service_ports, which keeps unique index of ports of not deleted services
+func (pg *PostgresStorage) insertServicePorts(q pgQuerier, id string, svc model.Service) error {
+	var inserted = make(map[string]struct{}, len(svc.Ports))
+	for _, port := range svc.Ports {
+		var portNumber interface{}
+		if port.Port != nil {
+			portNumber = *port.Port
+		}
+		// equal keys of one document are allowed, like in mongo multikey indexes
+		var key = indexKey(portNumber, port.Protocol)
+		if _, ok := inserted[key]; ok {
+			continue
+		}
+		inserted[key] = struct{}{}
+		if _, err := pg.exec(q, `INSERT INTO service_ports (service_id, domain, port, protocol) VALUES ($1, $2, $3, $4)`,
+			id, svc.Domain, portNumber, string(port.Protocol)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) insertService(q pgQuerier, svc service.ServiceResource) error {
+	var stored service.ServiceResource
+	var data = pgDocument(svc, &stored)
+	if _, err := pg.exec(q, `INSERT INTO services (id, namespace_id, name, owner, domain, deleted, deleted_at, revision, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		stored.ID, stored.NamespaceID, stored.Name, stored.Owner, stored.Domain, stored.Deleted, stored.DeletedAt, stored.Revision, string(data)); err != nil {
+		return err
+	}
+	if stored.Deleted {
+		return nil
+	}
+	return pg.insertServicePorts(q, stored.ID, stored.Service)
+}
+
+func (pg *PostgresStorage) GetService(namespaceID, serviceName string) (service.ServiceResource, error) {
+	pg.logger.Debugf("getting service")
+	var result service.ServiceResource
+	if err := pg.queryDocument(pg.db, &result, `SELECT data FROM services
+		WHERE namespace_id = $1 AND name = $2 AND NOT deleted`, namespaceID, serviceName); err != nil {
+		pg.logger.WithError(err).Errorf("unable to get service")
+		if err == sql.ErrNoRows {
+			return result, rserrors.ErrResourceNotExists().AddDetails(serviceName)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresStorage) GetServiceList(namespaceID string) (service.ServiceList, error) {
+	pg.logger.Debugf("getting service list")
+	list, err := pg.queryServices(pg.db, `SELECT data FROM services
+		WHERE namespace_id = $1 AND NOT deleted ORDER BY seq`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get service list")
+	}
+	return list, err
+}
+
+// If ID is empty, then generates UUID4 and uses it
+func (pg *PostgresStorage) CreateService(svc service.ServiceResource) (service.ServiceResource, error) {
+	pg.logger.Debugf("creating service")
+	if svc.ID == "" {
+		svc.ID = uuid.New().String()
+	}
+	if svc.Revision == 0 {
+		svc.Revision = 1
+	}
+	if err := pg.pgTx(func(tx *sql.Tx) error {
+		return pg.insertService(tx, svc)
+	}); err != nil {
+		pg.logger.WithError(err).Errorf("unable to create service")
+		return svc, err
+	}
+	return svc, nil
+}
+
+func (pg *PostgresStorage) UpdateService(upd service.ServiceResource) (service.ServiceResource, error) {
+	pg.logger.Debugf("updating service")
+	var stored service.ServiceResource
+	var data = pgDocument(service.ServiceResource{
+		Service:     upd.Service,
+		NamespaceID: upd.NamespaceID,
+	}, &stored)
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		var id string
+		if err := tx.QueryRow(`UPDATE services SET owner = $3, domain = $4,
+				revision = revision + 1, data = `+pgWithRevision(`jsonb_set($5::jsonb, '{_id}', to_jsonb(id))`)+`
+			WHERE id = (SELECT id FROM services WHERE namespace_id = $1 AND name = $2 AND NOT deleted LIMIT 1)
+				AND ($6::bigint = 0 OR revision = $6)
+			RETURNING id, revision`,
+			upd.NamespaceID, upd.Name, stored.Owner, stored.Domain, string(data), upd.Revision).Scan(&id, &upd.Revision); err != nil {
+			if err == sql.ErrNoRows {
+				return pg.revisionConflict(tx, upd.Name, `SELECT 1 FROM services WHERE namespace_id = $1 AND name = $2 AND NOT deleted`,
+					upd.NamespaceID, upd.Name)
+			}
+			return pgErr(err)
+		}
+		if _, err := pg.exec(tx, `DELETE FROM service_ports WHERE service_id = $1`, id); err != nil {
+			return err
+		}
+		return pg.insertServicePorts(tx, id, stored.Service)
+	})
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to update service")
+		return upd, err
+	}
+	return upd, nil
+}
+
+// deleteServices marks services matching condition as deleted and frees their ports. Returns number of deleted services.
+func (pg *PostgresStorage) deleteServices(condition string, args ...interface{}) (int64, error) {
+	var n int64
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		if _, err := pg.exec(tx, `DELETE FROM service_ports WHERE service_id IN (SELECT id FROM services WHERE `+condition+`)`, args...); err != nil {
+			return err
+		}
+		var err error
+		n, err = pg.exec(tx, `UPDATE services SET `+pgSoftDelete+` WHERE `+condition, args...)
+		return err
+	})
+	return n, err
+}
+
+func (pg *PostgresStorage) DeleteService(namespaceID, name string) error {
+	pg.logger.Debugf("deleting service")
+	n, err := pg.deleteServices(`id = (SELECT id FROM services WHERE namespace_id = $1 AND name = $2 AND NOT deleted LIMIT 1)`, namespaceID, name)
+	switch {
+	case err != nil:
+		pg.logger.WithError(err).Errorf("unable to delete service")
+		return err
+	case n == 0:
+		return rserrors.ErrResourceNotExists().AddDetails(name)
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) RestoreService(namespaceID, name string) error {
+	pg.logger.Debugf("restoring service")
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		var svc service.ServiceResource
+		if err := pg.queryDocument(tx, &svc, `UPDATE services SET `+pgRestore+`
+			WHERE id = (SELECT id FROM services WHERE namespace_id = $1 AND name = $2 AND deleted ORDER BY deleted_at DESC NULLS LAST, seq DESC LIMIT 1)
+			RETURNING data`, namespaceID, name); err != nil {
+			if err == sql.ErrNoRows {
+				return rserrors.ErrResourceNotExists().AddDetails(name)
+			}
+			return err
+		}
+		return pg.insertServicePorts(tx, svc.ID, svc.Service)
+	})
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to restore service")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllServicesInNamespace(namespaceID string) error {
+	pg.logger.Debugf("deleting all services in namespace")
+	if _, err := pg.deleteServices(`namespace_id = $1 AND NOT deleted`, namespaceID); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete service")
+		return err
+	}
+	return nil
+}
+
+func (pg *PostgresStorage) DeleteAllServicesByOwner(owner string) error {
+	pg.logger.Debugf("deleting all services in namespace")
+	if _, err := pg.deleteServices(`owner = $1 AND NOT deleted`, owner); err != nil {
+		pg.logger.WithError(err).Errorf("unable to delete services")
+		return err
+	}
+	return nil
+}
+
+// countServices counts services without domain as external, like MongoStorage does
+func (pg *PostgresStorage) countServices(condition string, args ...interface{}) (stats.Service, error) {
+	var serviceStats stats.Service
+	err := pg.db.QueryRow(`SELECT
+			count(*) FILTER (WHERE domain = ''),
+			count(*) FILTER (WHERE domain <> '')
+		FROM services WHERE NOT deleted AND `+condition, args...).Scan(&serviceStats.External, &serviceStats.Internal)
+	return serviceStats, err
+}
+
+func (pg *PostgresStorage) CountServices(owner string) (stats.Service, error) {
+	pg.logger.Debugf("counting services")
+	return pg.countServices(`owner = $1`, owner)
+}
+
+func (pg *PostgresStorage) CountServicesInNamespace(namespaceID string) (stats.Service, error) {
+	pg.logger.Debugf("counting services in namespace")
+	return pg.countServices(`namespace_id = $1`, namespaceID)
+}
+
+// GetFreePort returns random port not used by services on domain
+func (pg *PostgresStorage) GetFreePort(domain string, protocol model.Protocol) (int, error) {
+	var port int
+	err := pg.db.QueryRow(`SELECT candidate FROM generate_series($3::INTEGER, $4::INTEGER - 1) AS candidate
+		WHERE NOT EXISTS (SELECT 1 FROM service_ports WHERE domain = $1 AND protocol = $2 AND port = candidate)
+		ORDER BY random() LIMIT 1`, domain, string(protocol), minPort, maxPort).Scan(&port)
+	switch {
+	case err == sql.ErrNoRows:
+		return -1, rserrors.ErrInternal().AddDetailF("no free %s ports on domain %s", protocol, domain)
+	case err != nil:
+		return -1, err
+	}
+	return port, nil
+}
+
+// IsPortFree reports if external port is not used by not deleted services on domain
+func (pg *PostgresStorage) IsPortFree(domain string, port int, protocol model.Protocol) (bool, error) {
+	var used bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM service_ports WHERE domain = $1 AND port = $2 AND protocol = $3)`,
+		domain, port, string(protocol)).Scan(&used)
+	return !used, err
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+)
+
+func (pg *PostgresStorage) GetDeletedDeploymentList(namespaceID string) (deployment.DeploymentList, error) {
+	pg.logger.Debugf("getting deleted deployment list")
+	list, err := pg.queryDeployments(pg.db, `SELECT data FROM deployments
+		WHERE namespace_id = $1 AND active AND deleted ORDER BY deleted_at DESC NULLS LAST, seq DESC`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deleted deployment list")
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) GetDeletedServiceList(namespaceID string) (service.ServiceList, error) {
+	pg.logger.Debugf("getting deleted service list")
+	list, err := pg.queryServices(pg.db, `SELECT data FROM services
+		WHERE namespace_id = $1 AND deleted ORDER BY deleted_at DESC NULLS LAST, seq DESC`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deleted service list")
+	}
+	return list, err
+}
+
+func (pg *PostgresStorage) GetDeletedIngressList(namespaceID string) (ingress.IngressList, error) {
+	pg.logger.Debugf("getting deleted ingress list")
+	list, err := pg.queryIngresses(pg.db, `SELECT data FROM ingresses
+		WHERE namespace_id = $1 AND deleted ORDER BY deleted_at DESC NULLS LAST, seq DESC`, namespaceID)
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to get deleted ingress list")
+	}
+	return list, err
+}
+
+// PurgeDeleted removes deployments, services and ingresses deleted before time completely.
+// Returns number of removed rows.
+func (pg *PostgresStorage) PurgeDeleted(before time.Time) (int, error) {
+	pg.logger.Debugf("purging deleted resources")
+	var removed int64
+	err := pg.pgTx(func(tx *sql.Tx) error {
+		for _, table := range []string{"deployments", "services", "ingresses"} {
+			n, err := pg.exec(tx, `DELETE FROM `+table+` WHERE deleted AND deleted_at < $1`, before)
+			if err != nil {
+				return err
+			}
+			removed += n
+		}
+		return nil
+	})
+	if err != nil {
+		pg.logger.WithError(err).Errorf("unable to purge deleted resources")
+		return 0, err
+	}
+	return int(removed), nil
+}
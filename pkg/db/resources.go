@@ -1,6 +1,8 @@
 package db
 
 import (
+	"sort"
+
 	kubtypes "github.com/containerum/kube-client/pkg/model"
 	"github.com/globalsign/mgo/bson"
 )
@@ -31,3 +33,28 @@ func (mongo *MongoStorage) GetNamespaceResourcesLimits(namespaceID string) (kubt
 	}).One(&res)
 	return res, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
 }
+
+func (mongo *MongoStorage) GetNamespacesList() ([]string, error) {
+	mongo.logger.Debugf("getting namespaces list")
+	var namespaces = make(map[string]struct{})
+	for collection, query := range map[string]bson.M{
+		CollectionDeployment: {"deleted": false, "deployment.active": true},
+		CollectionService:    {"deleted": false},
+		CollectionIngress:    {"deleted": false},
+	} {
+		var ids []string
+		if err := mongo.db.C(collection).Find(query).Distinct("namespaceid", &ids); err != nil {
+			mongo.logger.WithError(err).Errorf("unable to get namespaces list")
+			return nil, PipErr{err}.ToMongerr().Extract()
+		}
+		for _, id := range ids {
+			namespaces[id] = struct{}{}
+		}
+	}
+	var ret = make([]string, 0, len(namespaces))
+	for id := range namespaces {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
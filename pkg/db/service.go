@@ -46,6 +46,9 @@ func (mongo *MongoStorage) CreateService(service service.ServiceResource) (servi
 	if service.ID == "" {
 		service.ID = uuid.New().String()
 	}
+	if service.Revision == 0 {
+		service.Revision = 1
+	}
 	if err := collection.Insert(service); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to create service")
 		if mgo.IsDup(err) {
@@ -59,10 +62,18 @@ func (mongo *MongoStorage) CreateService(service service.ServiceResource) (servi
 func (mongo *MongoStorage) UpdateService(upd service.ServiceResource) (service.ServiceResource, error) {
 	mongo.logger.Debugf("updating service")
 	var collection = mongo.db.C(CollectionService)
-	if err := collection.Update(upd.OneSelectQuery(), upd.UpdateQuery()); err != nil {
+	var stored service.ServiceResource
+	if _, err := collection.Find(upd.OneRevisionSelectQuery()).Apply(mgo.Change{
+		Update:    upd.UpdateQuery(),
+		ReturnNew: true,
+	}, &stored); err != nil {
 		mongo.logger.WithError(err).Errorf("unable to update service")
+		if err == mgo.ErrNotFound && upd.Revision != 0 {
+			return upd, revisionConflict(collection, upd.OneSelectQuery(), upd.Name)
+		}
 		return upd, PipErr{err}.ToMongerr().Extract()
 	}
+	upd.Revision = stored.Revision
 	return upd, nil
 }
 
@@ -74,10 +85,7 @@ func (mongo *MongoStorage) DeleteService(namespaceID, name string) error {
 			Name: name,
 		},
 		NamespaceID: namespaceID,
-	}.OneSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.OneSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete service")
 		if err == mgo.ErrNotFound {
@@ -91,15 +99,17 @@ func (mongo *MongoStorage) DeleteService(namespaceID, name string) error {
 func (mongo *MongoStorage) RestoreService(namespaceID, name string) error {
 	mongo.logger.Debugf("restoring service")
 	var collection = mongo.db.C(CollectionService)
-	err := collection.Update(service.ServiceResource{
+	// the latest deleted resource is restored
+	var deleted service.ServiceResource
+	err := collection.Find(service.ServiceResource{
 		Service: model.Service{
 			Name: name,
 		},
 		NamespaceID: namespaceID,
-	}.OneSelectDeletedQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.OneSelectDeletedQuery()).Sort("-deletedat").One(&deleted)
+	if err == nil {
+		err = collection.UpdateId(deleted.ID, restoreQuery())
+	}
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to restore service")
 		if err == mgo.ErrNotFound {
@@ -115,10 +125,7 @@ func (mongo *MongoStorage) DeleteAllServicesInNamespace(namespaceID string) erro
 	var collection = mongo.db.C(CollectionService)
 	_, err := collection.UpdateAll(service.ServiceResource{
 		NamespaceID: namespaceID,
-	}.AllSelectQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete service")
 		return PipErr{err}.ToMongerr().Extract()
@@ -131,10 +138,7 @@ func (mongo *MongoStorage) DeleteAllServicesByOwner(owner string) error {
 	var collection = mongo.db.C(CollectionService)
 	_, err := collection.UpdateAll(service.ServiceResource{
 		Service: model.Service{Owner: owner},
-	}.AllSelectOwnerQuery(),
-		bson.M{
-			"$set": bson.M{"deleted": true},
-		})
+	}.AllSelectOwnerQuery(), softDeleteQuery())
 	if err != nil {
 		mongo.logger.WithError(err).Errorf("unable to delete services")
 		return PipErr{err}.ToMongerr().Extract()
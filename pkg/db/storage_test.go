@@ -0,0 +1,436 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/blang/semver"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStorages returns storages to check: memory one and postgres one if CH_RESOURCE_TEST_PG_URL is set.
+// Each test uses new namespace, so postgres database may be reused.
+func testStorages(t *testing.T) map[string]Storage {
+	var storages = map[string]Storage{"memory": NewMemory()}
+	if url := os.Getenv("CH_RESOURCE_TEST_PG_URL"); url != "" {
+		pg, err := NewPostgres(PostgresConfig{URL: url})
+		if !assert.NoError(t, err) || !assert.NoError(t, pg.Init()) {
+			t.FailNow()
+		}
+		storages["postgres"] = pg
+	}
+	return storages
+}
+
+func TestStorage(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name+"/ServiceSoftDelete", func(t *testing.T) { testServiceSoftDelete(t, storage) })
+		t.Run(name+"/ServicePorts", func(t *testing.T) { testServicePorts(t, storage) })
+		t.Run(name+"/DeploymentVersions", func(t *testing.T) { testDeploymentVersions(t, storage) })
+		t.Run(name+"/Operations", func(t *testing.T) { testOperations(t, storage) })
+		t.Run(name+"/Trash", func(t *testing.T) { testTrash(t, storage) })
+		t.Run(name+"/RestoreDeploymentVersions", func(t *testing.T) { testRestoreDeploymentVersions(t, storage) })
+		t.Run(name+"/Revisions", func(t *testing.T) { testRevisions(t, storage) })
+		t.Run(name+"/DeploymentRevisions", func(t *testing.T) { testDeploymentRevisions(t, storage) })
+		t.Run(name+"/IngressByDomain", func(t *testing.T) { testIngressByDomain(t, storage) })
+		t.Run(name+"/IngressHostsOverlap", func(t *testing.T) { testIngressHostsOverlap(t, storage) })
+		t.Run(name+"/Locks", func(t *testing.T) { testLocks(t, storage) })
+		storage.Close()
+	}
+}
+
+func testServiceSoftDelete(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	svc := service.ServiceResource{NamespaceID: ns, Service: model.Service{Name: "svc"}}
+
+	_, err := storage.CreateService(svc)
+	assert.NoError(t, err)
+	_, err = storage.CreateService(svc)
+	assert.Error(t, err, "name must be unique among alive services")
+
+	assert.NoError(t, storage.DeleteService(ns, "svc"))
+	_, err = storage.GetService(ns, "svc")
+	assert.Error(t, err)
+
+	_, err = storage.CreateService(svc)
+	assert.NoError(t, err, "deleted service must not hold name")
+	assert.Error(t, storage.RestoreService(ns, "svc"), "restore must not violate unique name")
+
+	assert.NoError(t, storage.DeleteService(ns, "svc"))
+	assert.NoError(t, storage.RestoreService(ns, "svc"))
+	_, err = storage.GetService(ns, "svc")
+	assert.NoError(t, err)
+}
+
+func testServicePorts(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	domain := ns + ".example.com"
+	// ports in UTF-16 surrogates range must not be mixed up
+	for i, port := range []int{55296, 55297, 80} {
+		port := port
+		_, err := storage.CreateService(service.ServiceResource{NamespaceID: ns, Service: model.Service{
+			Name:   fmt.Sprintf("svc-%d", i),
+			Domain: domain,
+			Ports:  []model.ServicePort{{Name: "port", Port: &port, TargetPort: 80, Protocol: model.TCP}},
+		}})
+		assert.NoError(t, err)
+	}
+	port := 55296
+	_, err := storage.CreateService(service.ServiceResource{NamespaceID: ns, Service: model.Service{
+		Name:   "duplicate",
+		Domain: domain,
+		Ports:  []model.ServicePort{{Name: "port", Port: &port, TargetPort: 80, Protocol: model.TCP}},
+	}})
+	assert.Error(t, err, "port must be unique for domain")
+}
+
+func testDeploymentVersions(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	v1, v2 := semver.MustParse("1.0.0"), semver.MustParse("1.0.1")
+
+	_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns,
+		Deployment: model.Deployment{Name: "depl", Version: v1, Active: true}})
+	assert.NoError(t, err)
+	_, err = storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns,
+		Deployment: model.Deployment{Name: "depl", Version: v2, Active: true}})
+	assert.Error(t, err, "only one version may be active")
+
+	assert.NoError(t, storage.DeactivateDeployment(ns, "depl", 0))
+	_, err = storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns,
+		Deployment: model.Deployment{Name: "depl", Version: v2, Active: true}})
+	assert.NoError(t, err)
+
+	active, err := storage.GetDeployment(ns, "depl")
+	assert.NoError(t, err)
+	assert.Equal(t, v2.String(), active.Version.String())
+
+	latest, err := storage.GetDeploymentLatestVersion(ns, "depl")
+	assert.NoError(t, err)
+	assert.Equal(t, v2.String(), latest.Version.String())
+
+	versions, err := storage.GetDeploymentVersionsList(ns, "depl")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// only inactive version with specified number is deleted
+	v3 := semver.MustParse("1.0.2")
+	_, err = storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns,
+		Deployment: model.Deployment{Name: "depl", Version: v3}})
+	assert.NoError(t, err)
+	assert.Error(t, storage.DeleteDeploymentVersion(ns, "depl", v2), "active version must not be deleted")
+	assert.NoError(t, storage.DeleteDeploymentVersion(ns, "depl", v3))
+	_, err = storage.GetDeploymentVersion(ns, "depl", v1)
+	assert.NoError(t, err)
+	_, err = storage.GetDeploymentVersion(ns, "depl", v3)
+	assert.Error(t, err)
+}
+
+func testOperations(t *testing.T, storage Storage) {
+	var created = time.Now().UTC().Add(-time.Hour)
+	op, err := storage.CreateOperation(operation.Operation{
+		Kind:        operation.CreateService,
+		Status:      operation.StatusPending,
+		NamespaceID: uuid.New().String(),
+		Name:        "svc",
+		CreatedAt:   created,
+		UpdatedAt:   created,
+		Service:     &service.ServiceResource{Service: model.Service{Name: "svc"}},
+	})
+	assert.NoError(t, err)
+
+	contains := func(ops operation.OperationList, err error) bool {
+		assert.NoError(t, err)
+		for _, listed := range ops {
+			if listed.ID == op.ID {
+				return true
+			}
+		}
+		return false
+	}
+	isStale := func(before time.Time) bool {
+		return contains(storage.GetStaleOperations(before))
+	}
+
+	assert.False(t, isStale(created.Add(-time.Minute)), "fresh operation must not be stale")
+	assert.True(t, contains(storage.GetActiveOperations()), "fresh operation must be active")
+	claimed, err := storage.ClaimOperation(op.ID, created.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, claimed, "fresh operation must not be claimed")
+
+	var before = time.Now().UTC().Add(-time.Minute)
+	assert.True(t, isStale(before))
+	claimed, err = storage.ClaimOperation(op.ID, before)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = storage.ClaimOperation(op.ID, before)
+	assert.NoError(t, err)
+	assert.False(t, claimed, "claimed operation must not be claimed again until it is stale")
+
+	assert.NoError(t, storage.SetOperationStatus(op.ID, operation.StatusDone, ""))
+	stored, err := storage.GetOperation(op.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operation.StatusDone, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, "svc", stored.Service.Name)
+	assert.False(t, isStale(time.Now().UTC().Add(time.Minute)), "finished operation must not be stale")
+	assert.False(t, contains(storage.GetActiveOperations()), "finished operation must not be active")
+
+	assert.NoError(t, storage.DeleteFinishedOperations(time.Now().UTC().Add(time.Minute)))
+	_, err = storage.GetOperation(op.ID)
+	assert.Error(t, err)
+}
+
+func testTrash(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	_, err := storage.CreateService(service.ServiceResource{NamespaceID: ns, Service: model.Service{Name: "svc", Deploy: "first"}})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteService(ns, "svc"))
+	time.Sleep(10 * time.Millisecond)
+	second, err := storage.CreateService(service.ServiceResource{NamespaceID: ns, Service: model.Service{Name: "svc", Deploy: "second"}})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteService(ns, "svc"))
+
+	deleted, err := storage.GetDeletedServiceList(ns)
+	assert.NoError(t, err)
+	if assert.Len(t, deleted, 2) {
+		assert.Equal(t, second.ID, deleted[0].ID, "the latest deleted service must be first")
+		assert.NotNil(t, deleted[0].DeletedAt)
+	}
+
+	assert.NoError(t, storage.RestoreService(ns, "svc"))
+	restored, err := storage.GetService(ns, "svc")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", restored.Deploy, "the latest deleted service must be restored")
+	assert.Nil(t, restored.DeletedAt)
+
+	removed, err := storage.PurgeDeleted(time.Now().UTC().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, removed > 0)
+	deleted, err = storage.GetDeletedServiceList(ns)
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+	_, err = storage.GetService(ns, "svc")
+	assert.NoError(t, err, "not deleted service must not be purged")
+}
+
+func testRevisions(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	created, err := storage.CreateService(service.ServiceResource{NamespaceID: ns, Service: model.Service{Name: "svc", Deploy: "first"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, created.Revision)
+
+	upd := created
+	upd.Deploy = "second"
+	updated, err := storage.UpdateService(upd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, updated.Revision)
+
+	upd.Deploy = "stale"
+	_, err = storage.UpdateService(upd)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceModified()), "update of stale revision must fail, got %v", err)
+
+	upd.Revision = 0
+	updated, err = storage.UpdateService(upd)
+	assert.NoError(t, err, "update without revision must not be checked")
+	assert.EqualValues(t, 3, updated.Revision)
+
+	stored, err := storage.GetService(ns, "svc")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, stored.Revision)
+	assert.Equal(t, "stale", stored.Deploy)
+}
+
+func testDeploymentRevisions(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	v1, v2 := semver.MustParse("1.0.0"), semver.MustParse("1.0.1")
+	created, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns,
+		Deployment: model.Deployment{Name: "depl", Version: v1, Active: true}})
+	assert.NoError(t, err)
+
+	// concurrent update changes revision, so writes based on old one are rejected
+	upd := created
+	upd.Replicas = 2
+	assert.NoError(t, storage.UpdateActiveDeployment(upd))
+	err = storage.DeactivateDeployment(ns, "depl", created.Revision)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceModified()), "deactivation of stale revision must fail, got %v", err)
+	err = storage.UpdateDeploymentVersion(ns, "depl", v1, v2, created.Revision)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceModified()), "renaming of stale revision must fail, got %v", err)
+	err = storage.UpdateDeploymentVersion(ns, "depl", v2, v1, created.Revision)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+
+	stored, err := storage.GetDeployment(ns, "depl")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, v1.String(), stored.Version.String())
+	assert.NoError(t, storage.UpdateDeploymentVersion(ns, "depl", v1, v2, stored.Revision))
+	stored, err = storage.GetDeployment(ns, "depl")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, storage.DeactivateDeployment(ns, "depl", stored.Revision))
+	_, err = storage.GetDeployment(ns, "depl")
+	assert.Error(t, err)
+}
+
+func testIngressByDomain(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	domain := ns + ".example.com"
+	var rule = func(host string) model.Rule {
+		return model.Rule{Host: host, Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 80}}}
+	}
+	// name of other domain ending with domain is not its subdomain
+	_, err := storage.CreateIngress(ingress.IngressResource{NamespaceID: ns, Ingress: model.Ingress{Name: "other",
+		Rules: []model.Rule{rule("other-" + domain)}}})
+	assert.NoError(t, err)
+	_, err = storage.GetIngressByDomain(ns, domain)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+
+	for _, host := range []string{domain, "*." + domain, "a.b." + domain} {
+		name := uuid.New().String()
+		_, err := storage.CreateIngress(ingress.IngressResource{NamespaceID: ns, Ingress: model.Ingress{Name: name, Rules: []model.Rule{rule(host)}}})
+		assert.NoError(t, err)
+		ingr, err := storage.GetIngressByDomain(ns, domain)
+		if assert.NoError(t, err, host) {
+			assert.Equal(t, name, ingr.Name)
+		}
+		_, err = storage.GetIngressByDomain(uuid.New().String(), domain)
+		assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+		assert.NoError(t, storage.DeleteIngress(ns, name))
+	}
+}
+
+func testLocks(t *testing.T, storage Storage) {
+	name := uuid.New().String()
+	acquired, err := storage.AcquireLock(name, "first", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	// lock is held, but owner may prolong it
+	acquired, err = storage.AcquireLock(name, "second", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	acquired, err = storage.AcquireLock(name, "first", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// only owner releases lock
+	assert.NoError(t, storage.ReleaseLock(name, "second"))
+	acquired, err = storage.AcquireLock(name, "second", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	assert.NoError(t, storage.ReleaseLock(name, "first"))
+	acquired, err = storage.AcquireLock(name, "second", -time.Second)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	// expired lock is taken by another owner
+	acquired, err = storage.AcquireLock(name, "first", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// the first stored account key is kept
+	stored, err := storage.InitACMEAccountKey(uuid.New().String())
+	assert.NoError(t, err)
+	key, err := storage.InitACMEAccountKey(uuid.New().String())
+	assert.NoError(t, err)
+	assert.Equal(t, stored, key)
+}
+
+func testIngressHostsOverlap(t *testing.T, storage Storage) {
+	first, second := uuid.New().String(), uuid.New().String()
+	domain := first + ".example.com"
+	var create = func(ns, name, host string) error {
+		_, err := storage.CreateIngress(ingress.IngressResource{NamespaceID: ns, Ingress: model.Ingress{Name: name,
+			Rules: []model.Rule{{Host: host, Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 80}}}}}})
+		return err
+	}
+	var names = func(hosts ...string) []string {
+		list, err := storage.GetIngressesByHostsOverlap(hosts)
+		assert.NoError(t, err)
+		var ret []string
+		for _, ingr := range list {
+			ret = append(ret, ingr.NamespaceID+"/"+ingr.Name)
+		}
+		sort.Strings(ret)
+		return ret
+	}
+	assert.NoError(t, create(first, "exact", "a."+domain))
+	assert.NoError(t, create(second, "wildcard", "*."+domain))
+	assert.NoError(t, create(second, "deleted", "b."+domain))
+	assert.NoError(t, storage.DeleteIngress(second, "deleted"))
+
+	// hosts are unique among all namespaces
+	err := create(second, "duplicate", "a."+domain)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceAlreadyExists()), "%v", err)
+	// host of deleted ingress is free
+	assert.NoError(t, create(first, "reused", "b."+domain))
+	assert.NoError(t, storage.DeleteIngress(first, "reused"))
+
+	var both = []string{first + "/exact", second + "/wildcard"}
+	sort.Strings(both)
+	assert.Equal(t, both, names("a."+domain))
+	assert.Equal(t, both, names("*."+domain))
+	assert.Equal(t, []string{second + "/wildcard"}, names("c."+domain, "other-"+domain))
+	// wildcard covers only one label
+	assert.Empty(t, names("a.a."+domain, domain))
+}
+
+func testRestoreDeploymentVersions(t *testing.T, storage Storage) {
+	ns := uuid.New().String()
+	v1, v2, v3 := semver.MustParse("1.0.0"), semver.MustParse("1.0.1"), semver.MustParse("1.0.2")
+	for _, depl := range []model.Deployment{
+		{Name: "depl", Version: v1},
+		{Name: "depl", Version: v2},
+		{Name: "depl", Version: v3, Active: true},
+	} {
+		_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns, Deployment: depl})
+		assert.NoError(t, err)
+	}
+	var versions = func() []string {
+		list, err := storage.GetDeploymentVersionsList(ns, "depl")
+		assert.NoError(t, err)
+		var ret []string
+		for _, depl := range list {
+			ret = append(ret, depl.Version.String())
+		}
+		sort.Strings(ret)
+		return ret
+	}
+	// version deleted before is not restored with the others
+	assert.NoError(t, storage.DeleteDeploymentVersion(ns, "depl", v1))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, storage.DeleteAllDeploymentsInNamespace(ns))
+	assert.Empty(t, versions())
+
+	deleted, err := storage.GetDeletedDeploymentList(ns)
+	if !assert.NoError(t, err) || !assert.Len(t, deleted, 1) || !assert.NotNil(t, deleted[0].DeletedAt) {
+		return
+	}
+	// only the latest deleted version is restored by RestoreDeployment
+	assert.NoError(t, storage.RestoreDeployment(ns, "depl"))
+	assert.Equal(t, []string{v3.String()}, versions())
+	assert.NoError(t, storage.RestoreDeploymentVersions(ns, "depl", *deleted[0].DeletedAt))
+	assert.Equal(t, []string{v2.String(), v3.String()}, versions())
+
+	// deleted version which number is used again stays deleted
+	assert.NoError(t, storage.DeleteAllDeploymentsInNamespace(ns))
+	deleted, err = storage.GetDeletedDeploymentList(ns)
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, deleted) {
+		return
+	}
+	_, err = storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: ns, Deployment: model.Deployment{Name: "depl", Version: v2}})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.RestoreDeployment(ns, "depl"))
+	assert.NoError(t, storage.RestoreDeploymentVersions(ns, "depl", *deleted[0].DeletedAt))
+	assert.Equal(t, []string{v2.String(), v3.String()}, versions())
+}
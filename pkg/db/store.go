@@ -2,30 +2,199 @@ package db
 
 import (
 	"io"
+	"time"
 
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
 	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/models/stats"
+	"github.com/blang/semver"
 	"github.com/containerum/kube-client/pkg/model"
 )
 
-type Store interface {
+// Storage -- resource-service storage used by server actions.
+// Resources are deleted softly: deleted resources are kept with "deleted" flag and can be restored.
+// Names are unique among not deleted resources in namespace.
+type Storage interface {
 	io.Closer
-	Init() error
+	DeploymentStorage
+	ServiceStorage
+	IngressStorage
+	DomainStorage
+	CustomDomainStorage
+	CertificateStorage
+	ACMEStorage
+	ResourcesStorage
+	OperationStorage
+	TrashStorage
+	LockStorage
 }
 
+// DeploymentStorage keeps deployment versions. Only one version of deployment may be active.
 type DeploymentStorage interface {
-	Create(deployment deployment.DeploymentResource) (model.Deployment, error)
-	Update(deployment deployment.DeploymentResource) (model.Deployment, error)
-	Delete(ID string) error
+	GetDeployment(namespaceID, deploymentName string) (deployment.DeploymentResource, error)
+	GetDeploymentVersion(namespaceID, deploymentName string, version semver.Version) (deployment.DeploymentResource, error)
+	GetDeploymentLatestVersion(namespaceID, deploymentName string) (deployment.DeploymentResource, error)
+	GetDeploymentVersionsList(namespaceID string, deploymentName string) (deployment.DeploymentList, error)
+	GetDeploymentList(namespaceID string) (deployment.DeploymentList, error)
+	CreateDeployment(deployment deployment.DeploymentResource) (deployment.DeploymentResource, error)
+	UpdateActiveDeployment(upd deployment.DeploymentResource) error
+	// UpdateDeploymentVersion renames version, non-zero revision must match stored one, ErrResourceModified is returned otherwise
+	UpdateDeploymentVersion(namespace, name string, oldversion, newversion semver.Version, revision int64) error
+	DeleteDeployment(namespace, name string) error
+	ActivateDeployment(namespace, name string, version semver.Version) error
+	// DeactivateDeployment deactivates active version, non-zero revision must match stored one, ErrResourceModified is returned otherwise
+	DeactivateDeployment(namespace, name string, revision int64) error
+	DeleteDeploymentVersion(namespace, name string, version semver.Version) error
+	RestoreDeployment(namespace, name string) error
+	// RestoreDeploymentVersions restores versions deleted at deletedAt, versions which numbers are used again stay deleted
+	RestoreDeploymentVersions(namespace, name string, deletedAt time.Time) error
+	DeleteAllDeploymentsInNamespace(namespace string) error
+	DeleteAllDeploymentsByOwner(owner string) error
+	CountDeployments(owner string) (int, error)
+	CountReplicas(owner string) (int, error)
 }
 
+// ServiceStorage keeps services. External ports are unique for service domain.
 type ServiceStorage interface {
-	Create(service model.Service) (model.Service, error)
-	Update(service model.Service) (model.Service, error)
-	Delete(ID string) error
+	GetService(namespaceID, serviceName string) (service.ServiceResource, error)
+	GetServiceList(namespaceID string) (service.ServiceList, error)
+	CreateService(service service.ServiceResource) (service.ServiceResource, error)
+	UpdateService(upd service.ServiceResource) (service.ServiceResource, error)
+	DeleteService(namespaceID, name string) error
+	RestoreService(namespaceID, name string) error
+	DeleteAllServicesInNamespace(namespaceID string) error
+	DeleteAllServicesByOwner(owner string) error
+	CountServices(owner string) (stats.Service, error)
+	CountServicesInNamespace(namespaceID string) (stats.Service, error)
+	GetFreePort(domain string, protocol model.Protocol) (int, error)
+	IsPortFree(domain string, port int, protocol model.Protocol) (bool, error)
 }
 
+// IngressStorage keeps ingresses. Hosts are unique among all namespaces.
 type IngressStorage interface {
-	Create(ingress model.Ingress) (model.Ingress, error)
-	Update(ingress model.Ingress) (model.Ingress, error)
-	Delete(ID string) error
+	CreateIngress(ingress ingress.IngressResource) (ingress.IngressResource, error)
+	GetIngress(namespaceID, name string) (ingress.IngressResource, error)
+	GetIngressByService(namespaceID, serviceName string) (ingress.IngressResource, error)
+	GetIngressByDomain(namespaceID, domain string) (ingress.IngressResource, error)
+	GetIngressByTLSSecret(namespaceID, secretName string) (ingress.IngressResource, error)
+	GetIngressList(namespaceID string) (ingress.IngressList, error)
+	UpdateIngress(upd ingress.IngressResource) (ingress.IngressResource, error)
+	DeleteIngress(namespaceID, name string) error
+	RestoreIngress(namespaceID, name string) error
+	DeleteAllIngressesInNamespace(namespace string) error
+	DeleteAllIngressesByOwner(owner string) error
+	CountIngresses(owner string) (int, error)
+	GetIngressesByHostsOverlap(hosts []string) (ingress.IngressList, error)
+	GetIngressHostConflicts() (ingress.HostConflictList, error)
 }
+
+// DomainStorage keeps domains available for external services
+type DomainStorage interface {
+	GetDomain(domainName string, pages ...uint) (*domain.Domain, error)
+	GetRandomDomain() (*domain.Domain, error)
+	GetDomainGroup(domainGroup string) (domain.DomainList, error)
+	GetDomainsList(pages *PageInfo) ([]domain.Domain, error)
+	CreateDomain(domain domain.Domain) (*domain.Domain, error)
+	UpdateDomain(domain domain.Domain) (*domain.Domain, error)
+	DeleteDomain(domainName string) error
+}
+
+// CustomDomainStorage keeps user domains. Domain may be verified only in one namespace.
+type CustomDomainStorage interface {
+	CreateCustomDomain(domain customdomain.CustomDomain) (customdomain.CustomDomain, error)
+	GetCustomDomain(namespaceID, domain string) (customdomain.CustomDomain, error)
+	GetCustomDomainList(namespaceID string) (customdomain.CustomDomainList, error)
+	GetVerifiedCustomDomain(domain string) (customdomain.CustomDomain, error)
+	VerifyCustomDomain(namespaceID, domain, verifiedAt string) error
+	DeleteCustomDomain(namespaceID, domain string) error
+}
+
+// CertificateStorage keeps TLS certificates metadata
+type CertificateStorage interface {
+	CreateCertificate(cert certificate.Certificate) (certificate.Certificate, error)
+	GetCertificate(namespaceID, name string) (certificate.Certificate, error)
+	GetCertificateList(namespaceID string) (certificate.CertificateList, error)
+	DeleteCertificate(namespaceID, name string) error
+	RestoreCertificate(namespaceID, name string) error
+}
+
+// ACMEStorage keeps pending ACME challenges, ACME account key and automatic certificates state of ingresses
+type ACMEStorage interface {
+	CreateACMEChallenge(challenge certificate.ACMEChallenge) error
+	GetACMEChallenge(token string) (certificate.ACMEChallenge, error)
+	DeleteACMEChallenge(token string) error
+	GetACMEIngressList() (ingress.IngressList, error)
+	UpdateIngressACME(ingr ingress.IngressResource) error
+	// InitACMEAccountKey stores PEM encoded key if no key is stored yet and returns stored one, so all replicas use one account
+	InitACMEAccountKey(keyPEM string) (string, error)
+}
+
+// ResourcesStorage aggregates resources usage
+type ResourcesStorage interface {
+	GetNamespaceResourcesLimits(namespaceID string) (model.Resource, error)
+	// GetNamespacesList returns sorted IDs of namespaces with active deployments, services or ingresses
+	GetNamespacesList() ([]string, error)
+}
+
+// OperationStorage keeps journal of operations which write to both storage and kube-api.
+// Claiming operation updates its time, so stale operations are processed only by one worker at once.
+type OperationStorage interface {
+	CreateOperation(op operation.Operation) (operation.Operation, error)
+	GetOperation(id string) (operation.Operation, error)
+	SetOperationStatus(id string, status operation.Status, opErr string) error
+	GetStaleOperations(before time.Time) (operation.OperationList, error)
+	// GetActiveOperations returns not finished operations including running ones
+	GetActiveOperations() (operation.OperationList, error)
+	ClaimOperation(id string, before time.Time) (bool, error)
+	DeleteFinishedOperations(before time.Time) error
+}
+
+// TrashStorage lists softly deleted resources, the latest deleted first, and removes old ones completely.
+// Deleted resources are restored by Restore* methods, which restore the latest deleted resource with name.
+// Inactive deployment versions deleted with it are restored separately by RestoreDeploymentVersions.
+type TrashStorage interface {
+	GetDeletedDeploymentList(namespaceID string) (deployment.DeploymentList, error)
+	GetDeletedServiceList(namespaceID string) (service.ServiceList, error)
+	GetDeletedIngressList(namespaceID string) (ingress.IngressList, error)
+	PurgeDeleted(before time.Time) (int, error)
+}
+
+// LockStorage keeps named locks of periodic jobs which must run only in one replica at once.
+// Lock of crashed replica expires after its ttl, so owner must acquire lock again before ttl passes.
+type LockStorage interface {
+	// AcquireLock takes lock if it is free, expired or already held by owner, lock expiration is prolonged then
+	AcquireLock(name, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLock frees lock if it is held by owner
+	ReleaseLock(name, owner string) error
+}
+
+// Watcher streams changes of namespace resources, events after lastEventID are sent first if they are known.
+// Events channel is closed after cancel call or if watcher is too slow.
+type Watcher interface {
+	Watch(namespaceID, lastEventID string) (events <-chan event.Event, cancel func())
+}
+
+// Migrator -- storage with schema migrations.
+// Migrations are applied once even if several replicas are started at the same time.
+type Migrator interface {
+	PendingMigrations() ([]string, error)
+	Migrate() error
+	MigrateDown(steps int) ([]string, error)
+}
+
+var (
+	_ Storage = &MongoStorage{}
+	_ Storage = &MemoryStorage{}
+	_ Storage = &EventStorage{}
+
+	_ Watcher = &EventStorage{}
+
+	_ Migrator = &MongoStorage{}
+	_ Migrator = &PostgresStorage{}
+)
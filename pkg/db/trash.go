@@ -0,0 +1,73 @@
+package db
+
+import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/globalsign/mgo/bson"
+)
+
+// purgeSelectQuery selects documents deleted before time
+func purgeSelectQuery(before time.Time) interface{} {
+	return bson.M{
+		"deleted":   true,
+		"deletedat": bson.M{"$lt": before},
+	}
+}
+
+func (mongo *MongoStorage) GetDeletedDeploymentList(namespaceID string) (deployment.DeploymentList, error) {
+	mongo.logger.Debugf("getting deleted deployment list")
+	var collection = mongo.db.C(CollectionDeployment)
+	var result deployment.DeploymentList
+	if err := collection.Find(deployment.DeploymentResource{
+		NamespaceID: namespaceID,
+	}.AllSelectDeletedQuery()).Sort("-deletedat").All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get deleted deployment list")
+		return result, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) GetDeletedServiceList(namespaceID string) (service.ServiceList, error) {
+	mongo.logger.Debugf("getting deleted service list")
+	var collection = mongo.db.C(CollectionService)
+	var result service.ServiceList
+	if err := collection.Find(service.ServiceResource{
+		NamespaceID: namespaceID,
+	}.AllSelectDeletedQuery()).Sort("-deletedat").All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get deleted service list")
+		return result, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
+func (mongo *MongoStorage) GetDeletedIngressList(namespaceID string) (ingress.IngressList, error) {
+	mongo.logger.Debugf("getting deleted ingress list")
+	var collection = mongo.db.C(CollectionIngress)
+	var result ingress.IngressList
+	if err := collection.Find(ingress.IngressResource{
+		NamespaceID: namespaceID,
+	}.AllSelectDeletedQuery()).Sort("-deletedat").All(&result); err != nil {
+		mongo.logger.WithError(err).Errorf("unable to get deleted ingress list")
+		return result, PipErr{err}.ToMongerr().NotFoundToNil().Extract()
+	}
+	return result, nil
+}
+
+// PurgeDeleted removes deployments, services and ingresses deleted before time completely.
+// Returns number of removed documents.
+func (mongo *MongoStorage) PurgeDeleted(before time.Time) (int, error) {
+	mongo.logger.Debugf("purging deleted resources")
+	var removed int
+	for _, collectionName := range trashCollections() {
+		info, err := mongo.db.C(collectionName).RemoveAll(purgeSelectQuery(before))
+		if err != nil {
+			mongo.logger.WithError(err).Errorf("unable to purge deleted resources")
+			return removed, PipErr{err}.ToMongerr().Extract()
+		}
+		removed += info.Removed
+	}
+	return removed, nil
+}
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"git.containerum.net/ch/resource-service/pkg/util/mongerr"
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 )
 
 type PageInfo struct {
@@ -35,6 +39,37 @@ func Paginate(query *mgo.Query, info *PageInfo) *mgo.Query {
 	return query
 }
 
+// softDeleteQuery marks documents as deleted, deletion time is used to purge old deleted documents
+func softDeleteQuery() interface{} {
+	return bson.M{
+		"$set": bson.M{
+			"deleted":   true,
+			"deletedat": time.Now().UTC(),
+		},
+	}
+}
+
+// restoreQuery marks deleted document as not deleted
+func restoreQuery() interface{} {
+	return bson.M{
+		"$set":   bson.M{"deleted": false},
+		"$unset": bson.M{"deletedat": ""},
+	}
+}
+
+// revisionConflict is called when conditional update matched nothing:
+// if resource still exists, it was modified by another request
+func revisionConflict(collection *mgo.Collection, query interface{}, name string) error {
+	count, err := collection.Find(query).Count()
+	switch {
+	case err != nil:
+		return PipErr{err}.ToMongerr().Extract()
+	case count > 0:
+		return rserrors.ErrResourceModified().AddDetails(name)
+	}
+	return mgo.ErrNotFound
+}
+
 type PipErr struct {
 	error error
 }
@@ -0,0 +1,50 @@
+// Package metrics contains prometheus metrics of resource-service
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "resource_service"
+
+var (
+	// DriftResources -- number of resources drifted from storage records found by the last drift check
+	DriftResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drift_resources",
+		Help:      "Number of resources in kube-api drifted from active storage records, found by the last drift check.",
+	}, []string{"kind", "type"})
+
+	// DriftNamespaceErrors -- number of namespaces which could not be checked by the last drift check
+	DriftNamespaceErrors = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drift_namespace_errors",
+		Help:      "Number of namespaces which could not be checked by the last drift check.",
+	})
+
+	// DriftLastCheck -- time of the last drift check
+	DriftLastCheck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "drift_last_check_timestamp_seconds",
+		Help:      "Unix time of the last drift check.",
+	})
+
+	// ReconcileRepairs -- number of drifted resources repaired by reconciler
+	ReconcileRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_repairs_total",
+		Help:      "Number of repairs of kube-api resources drifted from storage records.",
+	}, []string{"kind", "type", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(DriftResources, DriftNamespaceErrors, DriftLastCheck, ReconcileRepairs)
+}
+
+// Handler serves registered metrics in prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,53 @@
+// Package adoption describes results of adoption of kube-api resources unknown to resource-service
+package adoption
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"github.com/containerum/cherry"
+)
+
+// Status -- adoption result of kube-api resource
+type Status string
+
+const (
+	// record is created for resource
+	Adopted Status = "adopted"
+	// active record with resource name already exists, it is not changed
+	Exists Status = "exists"
+	// resource can't be represented by record or record can't be stored
+	Skipped Status = "skipped"
+)
+
+// Resource -- adoption result of kube-api resource
+//
+// swagger:model AdoptedResource
+type Resource struct {
+	Kind   drift.Kind `json:"kind"`
+	Name   string     `json:"name"`
+	Status Status     `json:"status"`
+	// reason of skipping
+	Error *cherry.Err `json:"error,omitempty"`
+	// fields of kube-api resource which are not kept in record
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// Result -- result of namespace adoption
+//
+// swagger:model AdoptionResult
+type Result struct {
+	NamespaceID string `json:"namespace_id"`
+	// records were not created
+	DryRun    bool       `json:"dry_run,omitempty"`
+	Resources []Resource `json:"resources"`
+}
+
+// Count returns number of resources with adoption status
+func (result Result) Count(status Status) int {
+	var count int
+	for _, res := range result.Resources {
+		if res.Status == status {
+			count++
+		}
+	}
+	return count
+}
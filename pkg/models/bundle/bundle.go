@@ -0,0 +1,90 @@
+package bundle
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+)
+
+// FormatVersion -- version of bundle format written by export
+const FormatVersion = "v1"
+
+// Kind -- kind of bundle resource
+type Kind string
+
+const (
+	KindDeployment Kind = "deployment"
+	KindService    Kind = "service"
+	KindIngress    Kind = "ingress"
+)
+
+// Bundle -- portable snapshot of namespace active deployments, services and ingresses.
+// Owners and versions are not exported, they are assigned on import.
+//
+// swagger:model
+type Bundle struct {
+	// required: true
+	Version string `json:"version"`
+	// namespace bundle was exported from
+	NamespaceID string `json:"namespace_id,omitempty"`
+	//export date in RFC3339 format
+	ExportedAt  string             `json:"exported_at,omitempty"`
+	Deployments []model.Deployment `json:"deployments,omitempty" binding:"dive"`
+	Services    []Service          `json:"services,omitempty" binding:"dive"`
+	Ingresses   []Ingress          `json:"ingresses,omitempty" binding:"dive"`
+}
+
+// Service -- bundle service.
+// Ports and domain of external service are reallocated on import, ingress backends are updated to use new ports.
+//
+// swagger:model
+type Service struct {
+	model.Service
+	// determined by ports and domain if empty
+	Type service.ServiceType `json:"type,omitempty" binding:"omitempty,eq=internal|eq=external"`
+}
+
+// DroppedBasicAuth -- basic auth is not exported, because passwords are not stored
+const DroppedBasicAuth = "basic_auth"
+
+// Ingress -- bundle ingress.
+// Ingress with dropped settings is imported only if allow_unprotected is set.
+//
+// swagger:model
+type Ingress struct {
+	ingress.IngressRequest
+	// settings which were not exported
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// ImportedResource -- import result of bundle resource
+//
+// swagger:model
+type ImportedResource struct {
+	Kind Kind   `json:"kind"`
+	Name string `json:"name"`
+	// resource creation error, not set if resource was created
+	Error *cherry.Err `json:"error,omitempty"`
+	// settings which were dropped on export, so resource is created without them
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// ImportResult -- result of bundle import
+//
+// swagger:model
+type ImportResult struct {
+	Resources []ImportedResource `json:"resources"`
+	// fields of imported kubernetes manifests which were not converted
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// Failed reports if some resources were not imported
+func (result ImportResult) Failed() bool {
+	for _, res := range result.Resources {
+		if res.Error != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package certificate
+
+import (
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// SecretCertKey -- secret key holding PEM certificate chain
+	SecretCertKey = "tls.crt"
+	// SecretPrivateKey -- secret key holding PEM private key
+	SecretPrivateKey = "tls.key"
+)
+
+// CertificateRequest -- model for TLS certificate upload
+//
+// swagger:model
+type CertificateRequest struct {
+	// certificate name, also used as secret name in ingress rules
+	// required: true
+	Name string `json:"name"`
+	// PEM encoded certificate chain, leaf certificate first
+	// required: true
+	Cert string `json:"cert"`
+	// PEM encoded private key
+	// required: true
+	Key string `json:"key"`
+}
+
+// Certificate -- model for TLS certificate metadata for resource-service db.
+// Certificate itself and private key are stored only in kube secret.
+//
+// swagger:model
+type Certificate struct {
+	ID          string `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name        string `json:"name"`
+	NamespaceID string `json:"namespaceid"`
+	Owner       string `json:"owner,omitempty"`
+	// DNS names from certificate subject alternative names
+	Hosts []string `json:"hosts"`
+	// certificate issuer common name
+	Issuer string `json:"issuer"`
+	// hex encoded serial number
+	SerialNumber string `json:"serial_number"`
+	// sha256 fingerprint of leaf certificate
+	Fingerprint string `json:"fingerprint"`
+	//validity start date in RFC3339 format
+	NotBefore string `json:"not_before"`
+	//expiration date in RFC3339 format
+	NotAfter string `json:"not_after"`
+	//creation date in RFC3339 format
+	CreatedAt string `json:"created_at"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// CertificateList -- certificates list
+//
+// swagger:model
+type CertificateList []Certificate
+
+func OneSelectQuery(namespaceID, name string) interface{} {
+	return bson.M{
+		"namespaceid": namespaceID,
+		"deleted":     false,
+		"name":        name,
+	}
+}
+
+func OneSelectDeletedQuery(namespaceID, name string) interface{} {
+	return bson.M{
+		"namespaceid": namespaceID,
+		"deleted":     true,
+		"name":        name,
+	}
+}
+
+func ListSelectQuery(namespaceID string) interface{} {
+	return bson.M{
+		"namespaceid": namespaceID,
+		"deleted":     false,
+	}
+}
+
+// Covers checks if one of certificate hosts matches host. Wildcard hosts match exactly one leading label.
+func (cert Certificate) Covers(host string) bool {
+	host = strings.ToLower(host)
+	for _, certHost := range cert.Hosts {
+		certHost = strings.ToLower(certHost)
+		if certHost == host {
+			return true
+		}
+		if strings.HasPrefix(certHost, "*.") {
+			if dot := strings.Index(host, "."); dot > 0 && host[dot:] == certHost[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidAt checks if moment is inside certificate validity period
+func (cert Certificate) ValidAt(moment time.Time) bool {
+	notBefore, err := time.Parse(time.RFC3339, cert.NotBefore)
+	if err != nil {
+		return false
+	}
+	notAfter, err := time.Parse(time.RFC3339, cert.NotAfter)
+	if err != nil {
+		return false
+	}
+	return !moment.Before(notBefore) && !moment.After(notAfter)
+}
+
+// ACMEChallenge -- pending HTTP-01 challenge response
+type ACMEChallenge struct {
+	Token   string `json:"token" bson:"_id"`
+	Host    string `json:"host"`
+	KeyAuth string `json:"key_auth"`
+	//creation date in RFC3339 format
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package customdomain
+
+import (
+	"github.com/globalsign/mgo/bson"
+)
+
+// VerificationRecordPrefix -- prefix of TXT record name holding verification token
+const VerificationRecordPrefix = "_containerum-challenge."
+
+// CustomDomain -- model for user domain which may be used as ingress host after ownership verification
+//
+// swagger:model
+type CustomDomain struct {
+	ID string `json:"_id,omitempty" bson:"_id,omitempty"`
+	// required: true
+	Domain      string `json:"domain"`
+	NamespaceID string `json:"namespaceid"`
+	Owner       string `json:"owner,omitempty"`
+	// value of TXT record proving domain ownership
+	Token string `json:"token"`
+	// name of TXT record proving domain ownership
+	Record   string `json:"record"`
+	Verified bool   `json:"verified"`
+	//creation date in RFC3339 format
+	CreatedAt string `json:"created_at"`
+	//verification date in RFC3339 format
+	VerifiedAt *string `json:"verified_at,omitempty"`
+	Deleted    bool    `json:"deleted"`
+}
+
+// CustomDomainList -- custom domains list
+//
+// swagger:model
+type CustomDomainList []CustomDomain
+
+// VerificationRecord returns name of TXT record for domain ownership verification
+func VerificationRecord(domain string) string {
+	return VerificationRecordPrefix + domain
+}
+
+func OneSelectQuery(namespaceID, domain string) interface{} {
+	return bson.M{
+		"namespaceid": namespaceID,
+		"deleted":     false,
+		"domain":      domain,
+	}
+}
+
+func ListSelectQuery(namespaceID string) interface{} {
+	return bson.M{
+		"namespaceid": namespaceID,
+		"deleted":     false,
+	}
+}
+
+func VerifiedSelectQuery(domain string) interface{} {
+	return bson.M{
+		"deleted":  false,
+		"verified": true,
+		"domain":   domain,
+	}
+}
+
+func (list CustomDomainList) Domains() []string {
+	var domains = make([]string, 0, len(list))
+	for _, customDomain := range list {
+		domains = append(domains, customDomain.Domain)
+	}
+	return domains
+}
@@ -1,6 +1,8 @@
 package deployment
 
 import (
+	"time"
+
 	"github.com/containerum/kube-client/pkg/model"
 	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
@@ -11,9 +13,14 @@ import (
 // swagger:model
 type DeploymentResource struct {
 	model.Deployment
-	ID          string `json:"_id,omitempty" bson:"_id,omitempty"`
-	Deleted     bool   `json:"deleted"`
-	NamespaceID string `json:"namespaceid"`
+	ID          string     `json:"_id,omitempty" bson:"_id,omitempty"`
+	Deleted     bool       `json:"deleted"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	NamespaceID string     `json:"namespaceid"`
+	// incremented on each write, used as ETag
+	Revision int64 `json:"revision"`
+	//creation date of the first deployment version in RFC3339 format
+	CreatedAt *string `json:"created_at,omitempty"`
 }
 
 // Deployment -- deployments list
@@ -26,6 +33,9 @@ func (depl DeploymentResource) UpdateQuery() interface{} {
 		"$set": bson.M{
 			"deployment": depl.Deployment,
 		},
+		"$inc": bson.M{
+			"revision": 1,
+		},
 	}
 }
 
@@ -38,6 +48,16 @@ func (depl DeploymentResource) OneSelectQuery() interface{} {
 	}
 }
 
+// OneRevisionSelectQuery selects resource like OneSelectQuery,
+// non-zero revision must also match stored one
+func (depl DeploymentResource) OneRevisionSelectQuery() interface{} {
+	var query = depl.OneSelectQuery().(bson.M)
+	if depl.Revision != 0 {
+		query["revision"] = depl.Revision
+	}
+	return query
+}
+
 func (depl DeploymentResource) OneInactiveSelectQuery() interface{} {
 	return bson.M{
 		"namespaceid":       depl.NamespaceID,
@@ -47,6 +67,16 @@ func (depl DeploymentResource) OneInactiveSelectQuery() interface{} {
 	}
 }
 
+func (depl DeploymentResource) OneInactiveVersionSelectQuery() interface{} {
+	return bson.M{
+		"namespaceid":        depl.NamespaceID,
+		"deleted":            false,
+		"deployment.active":  false,
+		"deployment.name":    depl.Name,
+		"deployment.version": depl.Version,
+	}
+}
+
 func (depl DeploymentResource) OneAnyVersionSelectQuery() interface{} {
 	return bson.M{
 		"namespaceid":        depl.NamespaceID,
@@ -64,6 +94,15 @@ func (depl DeploymentResource) OneSelectDeletedQuery() interface{} {
 	}
 }
 
+// AllSelectDeletedQuery selects deleted deployments in namespace, only versions which were active are selected
+func (depl DeploymentResource) AllSelectDeletedQuery() interface{} {
+	return bson.M{
+		"namespaceid":       depl.NamespaceID,
+		"deleted":           true,
+		"deployment.active": true,
+	}
+}
+
 func (depl DeploymentResource) AllSelectQuery() interface{} {
 	return bson.M{
 		"namespaceid": depl.NamespaceID,
@@ -93,8 +132,9 @@ func (depl DeploymentResource) Copy() DeploymentResource {
 		var status = *cp.Status
 		cp.Status = &status
 	}
-	for i, container := range depl.Containers {
-		depl.Containers[i] = copyContainer(container)
+	cp.Containers = depl.Containers[:0:0]
+	for _, container := range depl.Containers {
+		cp.Containers = append(cp.Containers, copyContainer(container))
 	}
 	return cp
 }
@@ -148,20 +188,10 @@ func (list DeploymentList) Filter(pred func(deployment DeploymentResource) bool)
 
 func copyContainer(container model.Container) model.Container {
 	var cp = container
-	for i, env := range cp.Env {
-		cp.Env[i] = env
-	}
-	for i, command := range cp.Commands {
-		cp.Commands[i] = command
-	}
-	for i, port := range cp.Ports {
-		cp.Ports[i] = port
-	}
-	for i, volume := range cp.VolumeMounts {
-		cp.VolumeMounts[i] = volume
-	}
-	for i, config := range cp.ConfigMaps {
-		cp.ConfigMaps[i] = config
-	}
+	cp.Env = append(container.Env[:0:0], container.Env...)
+	cp.Commands = append(container.Commands[:0:0], container.Commands...)
+	cp.Ports = append(container.Ports[:0:0], container.Ports...)
+	cp.VolumeMounts = append(container.VolumeMounts[:0:0], container.VolumeMounts...)
+	cp.ConfigMaps = append(container.ConfigMaps[:0:0], container.ConfigMaps...)
 	return cp
 }
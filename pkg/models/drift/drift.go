@@ -0,0 +1,222 @@
+// Package drift describes differences between active resources in storage and resources in kube-api
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+)
+
+// Kind -- kind of drifted resource
+type Kind string
+
+const (
+	KindDeployment Kind = "deployment"
+	KindService    Kind = "service"
+	KindIngress    Kind = "ingress"
+)
+
+// Kinds -- all kinds of checked resources
+var Kinds = []Kind{KindDeployment, KindService, KindIngress}
+
+// Type -- type of drift
+type Type string
+
+const (
+	// active record has no resource in kube-api
+	Missing Type = "missing"
+	// kube-api resource has no active record
+	Extra Type = "extra"
+	// kube-api resource differs from active record
+	Differs Type = "differs"
+)
+
+// Types -- all types of drift
+var Types = []Type{Missing, Extra, Differs}
+
+// Resource -- drifted resource
+//
+// swagger:model DriftedResource
+type Resource struct {
+	NamespaceID string `json:"namespace_id"`
+	Kind        Kind   `json:"kind"`
+	Name        string `json:"name"`
+	Type        Type   `json:"type"`
+	// fields of kube-api resource which differ from record
+	Fields []string `json:"fields,omitempty"`
+}
+
+// NamespaceError -- namespace which could not be checked
+//
+// swagger:model
+type NamespaceError struct {
+	NamespaceID string      `json:"namespace_id"`
+	Error       *cherry.Err `json:"error"`
+}
+
+// Report -- result of drift detection
+//
+// swagger:model DriftReport
+type Report struct {
+	// check date in RFC3339 format
+	CheckedAt string `json:"checked_at"`
+	// number of checked namespaces
+	Namespaces int              `json:"namespaces"`
+	Resources  []Resource       `json:"resources"`
+	Errors     []NamespaceError `json:"errors,omitempty"`
+}
+
+// Count returns number of drifted resources of kind with drift type
+func (report Report) Count(kind Kind, typ Type) int {
+	var count int
+	for _, res := range report.Resources {
+		if res.Kind == kind && res.Type == typ {
+			count++
+		}
+	}
+	return count
+}
+
+// fields -- compared fields of resource
+type fields map[string]interface{}
+
+// CompareDeployments compares active deployments with kube-api deployments.
+// Replicas and containers are compared, containers are matched by name.
+func CompareDeployments(nsID string, records deployment.DeploymentList, actual []model.Deployment) []Resource {
+	var deploymentFields = func(depl model.Deployment) fields {
+		var ret = fields{"replicas": depl.Replicas}
+		for _, container := range depl.Containers {
+			ret[fmt.Sprintf("containers[%s]", container.Name)] = container
+		}
+		return ret
+	}
+	var want, got = make(map[string]fields), make(map[string]fields)
+	for _, depl := range records {
+		want[depl.Name] = deploymentFields(depl.Deployment)
+	}
+	for _, depl := range actual {
+		got[depl.Name] = deploymentFields(depl)
+	}
+	return compare(nsID, KindDeployment, want, got)
+}
+
+// CompareServices compares active services with kube-api services. Target deployment and ports are compared.
+func CompareServices(nsID string, records service.ServiceList, actual []model.Service) []Resource {
+	var serviceFields = func(svc model.Service) fields {
+		var ports = append([]model.ServicePort(nil), svc.Ports...)
+		sort.Slice(ports, func(i, j int) bool { return ports[i].Name < ports[j].Name })
+		return fields{
+			"deploy": svc.Deploy,
+			"ports":  ports,
+		}
+	}
+	var want, got = make(map[string]fields), make(map[string]fields)
+	for _, svc := range records {
+		want[svc.Name] = serviceFields(svc.Service)
+	}
+	for _, svc := range actual {
+		got[svc.Name] = serviceFields(svc)
+	}
+	return compare(nsID, KindService, want, got)
+}
+
+// CompareIngresses compares active ingresses with kube-api ingresses. Rules, annotations and splits are compared.
+func CompareIngresses(nsID string, records ingress.IngressList, actual []ingress.KubeIngress) []Resource {
+	var ingressFields = func(ingr ingress.KubeIngress) fields {
+		return fields{
+			"rules":       ingr.Rules,
+			"annotations": ingr.Annotations,
+			"splits":      ingr.Splits,
+		}
+	}
+	var want, got = make(map[string]fields), make(map[string]fields)
+	for _, ingr := range records {
+		want[ingr.Name] = ingressFields(ingr.ToKube())
+	}
+	for _, ingr := range actual {
+		got[ingr.Name] = ingressFields(ingr)
+	}
+	return compare(nsID, KindIngress, want, got)
+}
+
+// compare returns drifted resources sorted by name
+func compare(nsID string, kind Kind, want, got map[string]fields) []Resource {
+	var ret []Resource
+	for name, wantFields := range want {
+		gotFields, ok := got[name]
+		if !ok {
+			ret = append(ret, Resource{NamespaceID: nsID, Kind: kind, Name: name, Type: Missing})
+			continue
+		}
+		var differs []string
+		for field := range wantFields {
+			if !equal(wantFields[field], gotFields[field]) {
+				differs = append(differs, field)
+			}
+		}
+		for field := range gotFields {
+			if _, ok := wantFields[field]; !ok {
+				differs = append(differs, field)
+			}
+		}
+		if len(differs) > 0 {
+			sort.Strings(differs)
+			ret = append(ret, Resource{NamespaceID: nsID, Kind: kind, Name: name, Type: Differs, Fields: differs})
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			ret = append(ret, Resource{NamespaceID: nsID, Kind: kind, Name: name, Type: Extra})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// equal compares JSON representations of values, empty lists and maps are equal to absent ones
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return dropEmpty(generic)
+}
+
+func dropEmpty(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if item = dropEmpty(item); item == nil {
+				delete(typed, key)
+			} else {
+				typed[key] = item
+			}
+		}
+		if len(typed) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = dropEmpty(item)
+		}
+		if len(typed) == 0 {
+			return nil
+		}
+	}
+	return value
+}
@@ -0,0 +1,31 @@
+package drift
+
+import (
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareDeployments(t *testing.T) {
+	var container = model.Container{Name: "app", Image: "nginx:1.15", Limits: model.Resource{CPU: 100, Memory: 128}}
+	var records = deployment.DeploymentList{
+		{Deployment: model.Deployment{Name: "same", Replicas: 1, Containers: []model.Container{container}}},
+		{Deployment: model.Deployment{Name: "scaled", Replicas: 1, Containers: []model.Container{container}}},
+		{Deployment: model.Deployment{Name: "missing", Replicas: 1, Containers: []model.Container{container}}},
+	}
+	var changed = container
+	changed.Image = "nginx:1.16"
+	var actual = []model.Deployment{
+		{Name: "same", Replicas: 1, Containers: []model.Container{container}, Status: &model.DeploymentStatus{}},
+		{Name: "scaled", Replicas: 3, Containers: []model.Container{changed}},
+		{Name: "extra", Replicas: 1},
+	}
+
+	assert.Equal(t, []Resource{
+		{NamespaceID: "ns", Kind: KindDeployment, Name: "extra", Type: Extra},
+		{NamespaceID: "ns", Kind: KindDeployment, Name: "missing", Type: Missing},
+		{NamespaceID: "ns", Kind: KindDeployment, Name: "scaled", Type: Differs, Fields: []string{"containers[app]", "replicas"}},
+	}, CompareDeployments("ns", records, actual))
+}
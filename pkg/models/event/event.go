@@ -0,0 +1,47 @@
+package event
+
+import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+)
+
+// Type -- type of resource change
+type Type string
+
+const (
+	Created   Type = "created"
+	Updated   Type = "updated"
+	Deleted   Type = "deleted"
+	Activated Type = "activated"
+	// Reset -- events after requested event ID are not kept anymore, resources must be listed again
+	Reset Type = "reset"
+)
+
+// Kind -- kind of changed resource
+type Kind string
+
+const (
+	KindDeployment Kind = "deployment"
+	KindService    Kind = "service"
+	KindIngress    Kind = "ingress"
+)
+
+// Event -- change of resource in namespace
+//
+// swagger:model
+type Event struct {
+	ID          string    `json:"id"`
+	Type        Type      `json:"type"`
+	Kind        Kind      `json:"kind,omitempty"`
+	NamespaceID string    `json:"namespaceid"`
+	Name        string    `json:"name,omitempty"`
+	Time        time.Time `json:"time"`
+
+	// resource state after change, only field of event kind is set. Not set for deleted resources.
+	Deployment *deployment.DeploymentResource `json:"deployment,omitempty"`
+	Service    *service.ServiceResource       `json:"service,omitempty"`
+	Ingress    *ingress.IngressResource       `json:"ingress,omitempty"`
+}
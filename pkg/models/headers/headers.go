@@ -1,5 +1,12 @@
 package headers
 
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/json-iterator/go"
+)
+
 // represents header data for X-User-Namespace and X-User-Volume headers (encoded in base64)
 //
 //swagger:model
@@ -14,3 +21,26 @@ type UserHeaderData struct {
 	// required: true
 	Access string `json:"access"`
 }
+
+// DecodeUserHeaderData decodes base64 encoded json list from X-User-Namespace or X-User-Volume header
+func DecodeUserHeaderData(str string) ([]UserHeaderData, error) {
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.New("unable to decode user header data")
+	}
+	var userData []UserHeaderData
+	if err := jsoniter.Unmarshal(data, &userData); err != nil {
+		return nil, errors.New("unable to unmarshal user header data")
+	}
+	return userData, nil
+}
+
+// CanRead checks if access level allows reading object
+func (data UserHeaderData) CanRead() bool {
+	switch data.Access {
+	case "owner", "write", "read-delete", "read":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,101 @@
+package ingress
+
+import (
+	"sort"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// ACMEState -- state of automatic certificate issuance
+//
+// swagger:model
+type ACMEState string
+
+const (
+	// ACMEPending -- certificate is waiting for issuance
+	ACMEPending ACMEState = "pending"
+	// ACMEIssued -- certificate is issued and attached to ingress rules
+	ACMEIssued ACMEState = "issued"
+	// ACMEFailed -- last issuance attempt failed, it will be retried
+	ACMEFailed ACMEState = "failed"
+)
+
+// ACMEStatus -- automatic certificate issuance state of ingress
+//
+// swagger:model
+type ACMEStatus struct {
+	Enabled bool      `json:"enabled"`
+	State   ACMEState `json:"state,omitempty"`
+	// hosts covered by issued certificate
+	Hosts []string `json:"hosts,omitempty"`
+	// name of issued certificate
+	Certificate string `json:"certificate,omitempty"`
+	//expiration date of issued certificate in RFC3339 format
+	NotAfter string `json:"not_after,omitempty"`
+	// last issuance error
+	Error string `json:"error,omitempty"`
+	// number of failed issuance attempts in a row
+	Failures int `json:"failures,omitempty"`
+	//next issuance attempt date after failure in RFC3339 format
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	//last state change date in RFC3339 format
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// ACMEHosts returns sorted non-wildcard hosts of rules which have no manually set TLS secret or use ACME certificate
+func (ingr IngressResource) ACMEHosts() []string {
+	var certName string
+	if ingr.ACME != nil {
+		certName = ingr.ACME.Certificate
+	}
+	var seen = make(map[string]struct{})
+	var hosts []string
+	for _, rule := range ingr.Rules {
+		if rule.TLSSecret != nil && *rule.TLSSecret != "" && *rule.TLSSecret != certName {
+			continue
+		}
+		if _, wildcard := WildcardBase(rule.Host); wildcard {
+			// HTTP-01 challenge can't be used to issue wildcard certificates
+			continue
+		}
+		if _, ok := seen[rule.Host]; !ok {
+			seen[rule.Host] = struct{}{}
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// SetTLSSecret sets TLS secret for rules with listed hosts
+func (ingr *IngressResource) SetTLSSecret(secret string, hosts []string) {
+	var set = make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		set[host] = struct{}{}
+	}
+	for i, rule := range ingr.Rules {
+		if _, ok := set[rule.Host]; ok {
+			var name = secret
+			rule.TLSSecret = &name
+			ingr.Rules[i] = rule
+		}
+	}
+}
+
+func ACMESelectQuery() interface{} {
+	return bson.M{
+		"deleted":      false,
+		"acme.enabled": true,
+	}
+}
+
+func (ingr IngressResource) ACMEUpdateQuery() interface{} {
+	return bson.M{
+		"$set": bson.M{
+			"acme": ingr.ACME,
+		},
+		"$inc": bson.M{
+			"revision": 1,
+		},
+	}
+}
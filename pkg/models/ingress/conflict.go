@@ -0,0 +1,36 @@
+package ingress
+
+// HostUser -- ingress using host
+//
+// swagger:model
+type HostUser struct {
+	NamespaceID string `json:"namespaceid"`
+	Ingress     string `json:"ingress"`
+	Owner       string `json:"owner,omitempty"`
+}
+
+// HostConflict -- host used by several ingresses
+//
+// swagger:model
+type HostConflict struct {
+	Host      string     `json:"host" bson:"_id"`
+	Ingresses []HostUser `json:"ingresses"`
+}
+
+// HostConflictList -- list of hosts used by several ingresses
+//
+// swagger:model
+type HostConflictList []HostConflict
+
+// Hosts returns unique hosts of ingress rules
+func (ingr IngressResource) Hosts() []string {
+	var seen = make(map[string]struct{}, len(ingr.Rules))
+	var hosts = make([]string, 0, len(ingr.Rules))
+	for _, rule := range ingr.Rules {
+		if _, ok := seen[rule.Host]; !ok {
+			seen[rule.Host] = struct{}{}
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	return hosts
+}
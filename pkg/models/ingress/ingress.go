@@ -1,6 +1,8 @@
 package ingress
 
 import (
+	"time"
+
 	"github.com/containerum/kube-client/pkg/model"
 	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
@@ -11,9 +13,25 @@ import (
 // swagger:model
 type IngressResource struct {
 	model.Ingress
-	ID          string `json:"_id" bson:"_id,omitempty"`
-	Deleted     bool   `json:"deleted"`
-	NamespaceID string `json:"namespaceid"`
+	ID          string     `json:"_id" bson:"_id,omitempty"`
+	Deleted     bool       `json:"deleted"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	NamespaceID string     `json:"namespaceid"`
+	Options     *Options   `json:"options,omitempty"`
+	// incremented on each write, used as ETag
+	Revision int64 `json:"revision"`
+	// weighted backends of ingress paths
+	Splits []Split `json:"splits,omitempty"`
+	// automatic certificate issuance state
+	ACME *ACMEStatus `json:"acme,omitempty"`
+}
+
+// KubeIngress -- ingress model for kube-api with ingress controller annotations
+type KubeIngress struct {
+	model.Ingress
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// controller-agnostic weighted backends, translated by kube-api to controller specific resources
+	Splits []Split `json:"splits,omitempty"`
 }
 
 // IngressList -- ingresses list
@@ -28,6 +46,11 @@ func (ingr IngressResource) Copy() IngressResource {
 		rule.Path = append(make([]model.Path, 0, len(rule.Path)), rule.Path...)
 		cp.Rules[i] = rule
 	}
+	cp.Splits = append(make([]Split, 0, len(cp.Splits)), cp.Splits...)
+	for i, split := range cp.Splits {
+		split.Backends = append(make([]Backend, 0, len(split.Backends)), split.Backends...)
+		cp.Splits[i] = split
+	}
 	return cp
 }
 
@@ -49,6 +72,16 @@ func (ingr IngressResource) OneSelectQuery() interface{} {
 	}
 }
 
+// OneRevisionSelectQuery selects resource like OneSelectQuery,
+// non-zero revision must also match stored one
+func (ingr IngressResource) OneRevisionSelectQuery() interface{} {
+	var query = ingr.OneSelectQuery().(bson.M)
+	if ingr.Revision != 0 {
+		query["revision"] = ingr.Revision
+	}
+	return query
+}
+
 func (ingr IngressResource) OneSelectDeletedQuery() interface{} {
 	return bson.M{
 		"namespaceid":  ingr.NamespaceID,
@@ -57,6 +90,14 @@ func (ingr IngressResource) OneSelectDeletedQuery() interface{} {
 	}
 }
 
+// AllSelectDeletedQuery selects deleted resources in namespace
+func (ingr IngressResource) AllSelectDeletedQuery() interface{} {
+	return bson.M{
+		"namespaceid": ingr.NamespaceID,
+		"deleted":     true,
+	}
+}
+
 func (ingr IngressResource) AllSelectQuery() interface{} {
 	return bson.M{
 		"namespaceid": ingr.NamespaceID,
@@ -100,7 +141,21 @@ func (ingr IngressResource) UpdateQuery() interface{} {
 	return bson.M{
 		"$set": bson.M{
 			"ingress": ingr.Ingress,
+			"options": ingr.Options,
+			"splits":  ingr.Splits,
 		},
+		"$inc": bson.M{
+			"revision": 1,
+		},
+	}
+}
+
+// ToKube converts ingress to kube-api model
+func (ingr IngressResource) ToKube() KubeIngress {
+	return KubeIngress{
+		Ingress:     ingr.Ingress,
+		Annotations: ingr.Options.Annotations(),
+		Splits:      ingr.Splits,
 	}
 }
 
@@ -0,0 +1,315 @@
+package ingress
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/containerum/kube-client/pkg/model"
+)
+
+// AnnotationPrefix -- prefix of ingress controller annotations
+const AnnotationPrefix = "nginx.ingress.kubernetes.io/"
+
+// BasicAuthSecretKey -- secret key holding htpasswd file
+const BasicAuthSecretKey = "auth"
+
+// Annotation values are inserted into nginx configuration by ingress controller,
+// so values of string options are restricted to characters which can't break it
+var (
+	rewriteTarget = regexp.MustCompile(`^/[A-Za-z0-9._~/$-]*$`)
+	headerToken   = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+)
+
+// ValidRewriteTarget checks that rewrite target is absolute path with optional $N capture group references
+func ValidRewriteTarget(target string) bool {
+	return rewriteTarget.MatchString(target)
+}
+
+// ValidHeaderName checks that header name is RFC 7230 token
+func ValidHeaderName(name string) bool {
+	return headerToken.MatchString(name)
+}
+
+// ValidRealm checks that realm has only printable characters and no quotes or backslashes
+func ValidRealm(realm string) bool {
+	for _, r := range realm {
+		if !unicode.IsPrint(r) || r == '"' || r == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidBasicAuthUsername checks that username can be written to htpasswd file line
+func ValidBasicAuthUsername(username string) bool {
+	if username == "" {
+		return false
+	}
+	for _, r := range username {
+		if unicode.IsControl(r) || r == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// IngressRequest -- model for ingress creation and update
+//
+// swagger:model
+type IngressRequest struct {
+	model.Ingress
+	Options *Options `json:"options,omitempty"`
+	Splits  []Split  `json:"splits,omitempty" binding:"dive"`
+}
+
+// Options -- ingress routing options
+//
+// swagger:model
+type Options struct {
+	// path requests are rewritten to before passing to service, e.g. "/" or "/$1".
+	// Only letters, digits and ._~/$- characters are allowed.
+	RewriteTarget string `json:"rewrite_target,omitempty"`
+	// redirect HTTP requests to HTTPS even if ingress has no TLS secret
+	ForceSSLRedirect bool `json:"force_ssl_redirect,omitempty"`
+	// maximum request body size in megabytes, controller default if 0
+	MaxBodySize int        `json:"max_body_size,omitempty"`
+	RateLimit   *RateLimit `json:"rate_limit,omitempty"`
+	CORS        *CORS      `json:"cors,omitempty"`
+	BasicAuth   *BasicAuth `json:"basic_auth,omitempty"`
+}
+
+// RateLimit -- per client IP limits
+//
+// swagger:model
+type RateLimit struct {
+	// requests per second
+	RPS int `json:"rps,omitempty"`
+	// concurrent connections
+	Connections int `json:"connections,omitempty"`
+}
+
+// CORS -- cross-origin resource sharing settings
+//
+// swagger:model
+type CORS struct {
+	// allowed origin, "*" if empty
+	AllowOrigin  string   `json:"allow_origin,omitempty"`
+	AllowMethods []string `json:"allow_methods,omitempty"`
+	// header names, RFC 7230 tokens
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	// preflight response cache time in seconds
+	MaxAge int `json:"max_age,omitempty"`
+}
+
+// BasicAuth -- HTTP basic authentication settings
+//
+// swagger:model
+type BasicAuth struct {
+	// printable characters except quotes and backslashes
+	Realm string `json:"realm,omitempty"`
+	// users with passwords, accepted only in requests and never stored.
+	// If empty on update users from previous version are kept.
+	Users []BasicAuthUser `json:"users,omitempty" bson:"-"`
+	// names of users allowed to access ingress
+	Usernames []string `json:"usernames,omitempty"`
+	// name of secret with htpasswd file
+	Secret string `json:"secret,omitempty"`
+}
+
+// BasicAuthUser -- HTTP basic authentication credentials
+//
+// swagger:model
+type BasicAuthUser struct {
+	// without colons and control characters
+	// required: true
+	Username string `json:"username"`
+	// required: true
+	Password string `json:"password"`
+}
+
+// Annotations converts options to ingress controller annotations.
+// Values which may inject controller configuration are skipped, requests are validated before.
+func (opts *Options) Annotations() map[string]string {
+	if opts == nil {
+		return nil
+	}
+	var annotations = make(map[string]string)
+	if opts.RewriteTarget != "" && ValidRewriteTarget(opts.RewriteTarget) {
+		annotations[AnnotationPrefix+"rewrite-target"] = opts.RewriteTarget
+	}
+	if opts.ForceSSLRedirect {
+		annotations[AnnotationPrefix+"force-ssl-redirect"] = "true"
+	}
+	if opts.MaxBodySize > 0 {
+		annotations[AnnotationPrefix+"proxy-body-size"] = strconv.Itoa(opts.MaxBodySize) + "m"
+	}
+	if opts.RateLimit != nil {
+		if opts.RateLimit.RPS > 0 {
+			annotations[AnnotationPrefix+"limit-rps"] = strconv.Itoa(opts.RateLimit.RPS)
+		}
+		if opts.RateLimit.Connections > 0 {
+			annotations[AnnotationPrefix+"limit-connections"] = strconv.Itoa(opts.RateLimit.Connections)
+		}
+	}
+	if opts.CORS != nil {
+		annotations[AnnotationPrefix+"enable-cors"] = "true"
+		if opts.CORS.AllowOrigin != "" {
+			annotations[AnnotationPrefix+"cors-allow-origin"] = opts.CORS.AllowOrigin
+		}
+		if len(opts.CORS.AllowMethods) > 0 {
+			annotations[AnnotationPrefix+"cors-allow-methods"] = strings.Join(opts.CORS.AllowMethods, ", ")
+		}
+		var headers []string
+		for _, header := range opts.CORS.AllowHeaders {
+			if ValidHeaderName(header) {
+				headers = append(headers, header)
+			}
+		}
+		if len(headers) > 0 {
+			annotations[AnnotationPrefix+"cors-allow-headers"] = strings.Join(headers, ", ")
+		}
+		if opts.CORS.AllowCredentials {
+			annotations[AnnotationPrefix+"cors-allow-credentials"] = "true"
+		}
+		if opts.CORS.MaxAge > 0 {
+			annotations[AnnotationPrefix+"cors-max-age"] = strconv.Itoa(opts.CORS.MaxAge)
+		}
+	}
+	if opts.BasicAuth != nil && opts.BasicAuth.Secret != "" {
+		annotations[AnnotationPrefix+"auth-type"] = "basic"
+		annotations[AnnotationPrefix+"auth-secret"] = opts.BasicAuth.Secret
+		if opts.BasicAuth.Realm != "" && ValidRealm(opts.BasicAuth.Realm) {
+			annotations[AnnotationPrefix+"auth-realm"] = opts.BasicAuth.Realm
+		}
+	}
+	return annotations
+}
+
+// BasicAuthSecret returns name of basic auth secret if it is set
+func (opts *Options) BasicAuthSecret() string {
+	if opts == nil || opts.BasicAuth == nil {
+		return ""
+	}
+	return opts.BasicAuth.Secret
+}
+
+// OptionsFromAnnotations converts ingress controller annotations to options.
+// Annotations which have no options field or invalid values are returned as unsupported, sorted.
+// Values rejected by request validation are unsupported too.
+// Basic auth usernames are not known from annotations, only secret is set.
+func OptionsFromAnnotations(annotations map[string]string) (*Options, []string) {
+	var opts Options
+	var unsupported []string
+	var positive = func(name, value string) int {
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			unsupported = append(unsupported, name)
+			return 0
+		}
+		return number
+	}
+	var list = func(value string) []string {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	var cors = func() *CORS {
+		if opts.CORS == nil {
+			opts.CORS = &CORS{}
+		}
+		return opts.CORS
+	}
+	var rateLimit = func() *RateLimit {
+		if opts.RateLimit == nil {
+			opts.RateLimit = &RateLimit{}
+		}
+		return opts.RateLimit
+	}
+	var basicAuth = func() *BasicAuth {
+		if opts.BasicAuth == nil {
+			opts.BasicAuth = &BasicAuth{}
+		}
+		return opts.BasicAuth
+	}
+
+	for name, value := range annotations {
+		var key = strings.TrimPrefix(name, AnnotationPrefix)
+		if key == name {
+			unsupported = append(unsupported, name)
+			continue
+		}
+		switch key {
+		case "rewrite-target":
+			if !ValidRewriteTarget(value) {
+				unsupported = append(unsupported, name)
+				continue
+			}
+			opts.RewriteTarget = value
+		case "force-ssl-redirect":
+			opts.ForceSSLRedirect = value == "true"
+		case "proxy-body-size":
+			if !strings.HasSuffix(value, "m") {
+				// only sizes in megabytes are represented
+				unsupported = append(unsupported, name)
+				continue
+			}
+			opts.MaxBodySize = positive(name, strings.TrimSuffix(value, "m"))
+		case "limit-rps":
+			rateLimit().RPS = positive(name, value)
+		case "limit-connections":
+			rateLimit().Connections = positive(name, value)
+		case "enable-cors":
+			if value == "true" {
+				cors()
+			}
+		case "cors-allow-origin":
+			cors().AllowOrigin = value
+		case "cors-allow-methods":
+			cors().AllowMethods = list(value)
+		case "cors-allow-headers":
+			var headers = list(value)
+			for _, header := range headers {
+				if !ValidHeaderName(header) {
+					unsupported = append(unsupported, name)
+					headers = nil
+					break
+				}
+			}
+			if headers != nil {
+				cors().AllowHeaders = headers
+			}
+		case "cors-allow-credentials":
+			cors().AllowCredentials = value == "true"
+		case "cors-max-age":
+			cors().MaxAge = positive(name, value)
+		case "auth-type":
+			if value != "basic" {
+				unsupported = append(unsupported, name)
+			}
+		case "auth-secret":
+			basicAuth().Secret = value
+		case "auth-realm":
+			if !ValidRealm(value) {
+				unsupported = append(unsupported, name)
+				continue
+			}
+			basicAuth().Realm = value
+		default:
+			unsupported = append(unsupported, name)
+		}
+	}
+	sort.Strings(unsupported)
+	if reflect.DeepEqual(opts, Options{}) {
+		return nil, unsupported
+	}
+	return &opts, unsupported
+}
@@ -0,0 +1,100 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotations(t *testing.T) {
+	var opts = &Options{
+		RewriteTarget:    "/$1",
+		ForceSSLRedirect: true,
+		MaxBodySize:      8,
+		RateLimit:        &RateLimit{RPS: 10, Connections: 5},
+		CORS: &CORS{AllowOrigin: "https://example.com", AllowMethods: []string{"GET", "POST"},
+			AllowHeaders: []string{"X-Token", "Content-Type"}, AllowCredentials: true, MaxAge: 600},
+		BasicAuth: &BasicAuth{Realm: "Staging", Usernames: []string{"user"}, Secret: "basic-auth-1234"},
+	}
+	var annotations = map[string]string{
+		AnnotationPrefix + "rewrite-target":         "/$1",
+		AnnotationPrefix + "force-ssl-redirect":     "true",
+		AnnotationPrefix + "proxy-body-size":        "8m",
+		AnnotationPrefix + "limit-rps":              "10",
+		AnnotationPrefix + "limit-connections":      "5",
+		AnnotationPrefix + "enable-cors":            "true",
+		AnnotationPrefix + "cors-allow-origin":      "https://example.com",
+		AnnotationPrefix + "cors-allow-methods":     "GET, POST",
+		AnnotationPrefix + "cors-allow-headers":     "X-Token, Content-Type",
+		AnnotationPrefix + "cors-allow-credentials": "true",
+		AnnotationPrefix + "cors-max-age":           "600",
+		AnnotationPrefix + "auth-type":              "basic",
+		AnnotationPrefix + "auth-secret":            "basic-auth-1234",
+		AnnotationPrefix + "auth-realm":             "Staging",
+	}
+	assert.Equal(t, annotations, opts.Annotations())
+
+	// usernames are not kept in annotations
+	parsed, unsupported := OptionsFromAnnotations(annotations)
+	assert.Empty(t, unsupported)
+	var expected = *opts
+	var basicAuth = *opts.BasicAuth
+	basicAuth.Usernames = nil
+	expected.BasicAuth = &basicAuth
+	assert.Equal(t, &expected, parsed)
+
+	assert.Nil(t, (*Options)(nil).Annotations())
+	// basic auth without secret is not applied yet
+	assert.Empty(t, (&Options{BasicAuth: &BasicAuth{Realm: "Staging"}}).Annotations())
+}
+
+func TestAnnotationsInjection(t *testing.T) {
+	// options stored before validation was strict must not reach controller configuration
+	var opts = &Options{
+		RewriteTarget: "/; return 200",
+		CORS:          &CORS{AllowHeaders: []string{"X-Token", "X\"; proxy_pass http://evil;"}},
+		BasicAuth:     &BasicAuth{Realm: "a\" evil", Secret: "basic-auth-1234"},
+	}
+	assert.Equal(t, map[string]string{
+		AnnotationPrefix + "enable-cors":        "true",
+		AnnotationPrefix + "cors-allow-headers": "X-Token",
+		AnnotationPrefix + "auth-type":          "basic",
+		AnnotationPrefix + "auth-secret":        "basic-auth-1234",
+	}, opts.Annotations())
+
+	parsed, unsupported := OptionsFromAnnotations(map[string]string{
+		AnnotationPrefix + "rewrite-target":     "/\nmore_set_headers x",
+		AnnotationPrefix + "cors-allow-headers": "X-Token, X Token",
+		AnnotationPrefix + "auth-realm":         "a\\",
+		AnnotationPrefix + "limit-rps":          "10",
+	})
+	assert.Equal(t, []string{AnnotationPrefix + "auth-realm", AnnotationPrefix + "cors-allow-headers", AnnotationPrefix + "rewrite-target"}, unsupported)
+	assert.Equal(t, &Options{RateLimit: &RateLimit{RPS: 10}}, parsed)
+}
+
+func TestValidOptionValues(t *testing.T) {
+	for _, target := range []string{"/", "/$1", "/api/v1.2/~user_name-x"} {
+		assert.True(t, ValidRewriteTarget(target), target)
+	}
+	for _, target := range []string{"", "api", "//evil.com?x", "/a b", "/a'b", "/{a}", "/a\\b"} {
+		assert.False(t, ValidRewriteTarget(target), target)
+	}
+	for _, header := range []string{"X-Token", "Content-Type", "x_custom!#$%&'*+.^`|~"} {
+		assert.True(t, ValidHeaderName(header), header)
+	}
+	for _, header := range []string{"", "X Token", "X,Token", "X\"Token", "X;Token", "Токен"} {
+		assert.False(t, ValidHeaderName(header), header)
+	}
+	for _, realm := range []string{"", "Staging area, v2 (private)", "Зона"} {
+		assert.True(t, ValidRealm(realm), realm)
+	}
+	for _, realm := range []string{"a\"b", "a\\b", "a\nb", "a\x00b"} {
+		assert.False(t, ValidRealm(realm), realm)
+	}
+	for _, username := range []string{"user", "jöhn.doe@example.com"} {
+		assert.True(t, ValidBasicAuthUsername(username), username)
+	}
+	for _, username := range []string{"", "us:er", "user\nroot", "us\ter", "us\x7fer"} {
+		assert.False(t, ValidBasicAuthUsername(username), username)
+	}
+}
@@ -0,0 +1,95 @@
+package ingress
+
+import (
+	"github.com/containerum/kube-client/pkg/model"
+)
+
+// TotalWeight -- sum of backend weights in every traffic split
+const TotalWeight = 100
+
+// Split -- traffic split of ingress path between several services.
+// Path in ingress rules points to backend with the largest weight,
+// so controllers without weighted routing support still send traffic to primary service.
+//
+// swagger:model
+type Split struct {
+	// required: true
+	Host string `json:"host"`
+	// "/" if empty
+	Path string `json:"path"`
+	// required: true
+	Backends []Backend `json:"backends"`
+}
+
+// Backend -- weighted service backend of ingress path
+//
+// swagger:model
+type Backend struct {
+	// required: true
+	ServiceName string `json:"service_name"`
+	// required: true
+	ServicePort int `json:"service_port"`
+	// share of traffic in percents
+	// required: true
+	Weight int `json:"weight"`
+}
+
+// UpdateWeights -- model for changing traffic split weights only
+//
+// swagger:model
+type UpdateWeights struct {
+	// required: true
+	Host string `json:"host"`
+	// "/" if empty
+	Path string `json:"path"`
+	// weights by service name
+	// required: true
+	Weights map[string]int `json:"weights"`
+}
+
+// Primary returns backend with the largest weight, first one wins on ties
+func (split Split) Primary() Backend {
+	var primary Backend
+	for i, backend := range split.Backends {
+		if i == 0 || backend.Weight > primary.Weight {
+			primary = backend
+		}
+	}
+	return primary
+}
+
+// PrimaryPath converts primary backend to ingress path
+func (split Split) PrimaryPath() model.Path {
+	var primary = split.Primary()
+	return model.Path{
+		Path:        split.Path,
+		ServiceName: primary.ServiceName,
+		ServicePort: primary.ServicePort,
+	}
+}
+
+// ApplySplits points every split path in ingress rules to primary backend of split
+func (ingr *IngressResource) ApplySplits() {
+	for _, split := range ingr.Splits {
+		for i, rule := range ingr.Rules {
+			if rule.Host != split.Host {
+				continue
+			}
+			for j, path := range rule.Path {
+				if path.Path == split.Path {
+					ingr.Rules[i].Path[j] = split.PrimaryPath()
+				}
+			}
+		}
+	}
+}
+
+// FindSplit returns index of traffic split for host and path or -1 if there is no such split
+func (ingr IngressResource) FindSplit(host, path string) int {
+	for i, split := range ingr.Splits {
+		if split.Host == host && split.Path == path {
+			return i
+		}
+	}
+	return -1
+}
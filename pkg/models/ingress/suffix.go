@@ -0,0 +1,55 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HostSuffix -- base domain available for ingress hosts
+//
+// swagger:model
+type HostSuffix struct {
+	// suffix appended to ingress host label, e.g. ".hub.containerum.io"
+	// required: true
+	Suffix string `json:"suffix"`
+	// group from domains collection serving this suffix, returned to admins only
+	DomainGroup string `json:"domain_group,omitempty"`
+	// ip addresses of domain group, returned to admins only
+	IPs []string `json:"ips,omitempty"`
+}
+
+// HostSuffixList -- list of available ingress host suffixes
+//
+// swagger:model
+type HostSuffixList []HostSuffix
+
+// ParseHostSuffix parses suffix in form "suffix" or "domain_group=suffix"
+func ParseHostSuffix(str string) (HostSuffix, error) {
+	var hostSuffix HostSuffix
+	if sep := strings.Index(str, "="); sep >= 0 {
+		hostSuffix.DomainGroup = strings.TrimSpace(str[:sep])
+		str = str[sep+1:]
+	}
+	hostSuffix.Suffix = strings.ToLower(strings.Trim(strings.TrimSpace(str), "."))
+	if hostSuffix.Suffix == "" {
+		return hostSuffix, fmt.Errorf("empty ingress host suffix")
+	}
+	hostSuffix.Suffix = "." + hostSuffix.Suffix
+	return hostSuffix, nil
+}
+
+// Label returns host label if host ends with suffix
+func (hostSuffix HostSuffix) Label(host string) (string, bool) {
+	if !strings.HasSuffix(host, hostSuffix.Suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(host, hostSuffix.Suffix), true
+}
+
+func (list HostSuffixList) Suffixes() []string {
+	var suffixes = make([]string, 0, len(list))
+	for _, hostSuffix := range list {
+		suffixes = append(suffixes, hostSuffix.Suffix)
+	}
+	return suffixes
+}
@@ -0,0 +1,65 @@
+package ingress
+
+import (
+	"strings"
+)
+
+// WildcardPrefix -- leading label of wildcard host
+const WildcardPrefix = "*."
+
+// WildcardBase returns host without leading wildcard label and true if host is wildcard
+func WildcardBase(host string) (string, bool) {
+	if strings.HasPrefix(host, WildcardPrefix) {
+		return strings.TrimPrefix(host, WildcardPrefix), true
+	}
+	return host, false
+}
+
+// WildcardParent returns wildcard host covering concrete host, e.g. "*.example.com" for "www.example.com"
+func WildcardParent(host string) (string, bool) {
+	if _, wildcard := WildcardBase(host); wildcard {
+		return "", false
+	}
+	dot := strings.Index(host, ".")
+	if dot <= 0 || dot == len(host)-1 {
+		return "", false
+	}
+	return WildcardPrefix + host[dot+1:], true
+}
+
+// HostsOverlap checks if hosts are equal or one of them is wildcard covering another
+func HostsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if parent, ok := WildcardParent(a); ok && parent == b {
+		return true
+	}
+	if parent, ok := WildcardParent(b); ok && parent == a {
+		return true
+	}
+	return false
+}
+
+// HostInDomain checks if host is domain itself or any of its subdomains, including wildcard ones
+func HostInDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// IngressName generates ingress name from its first host. Wildcard label is replaced because it can't be used in resource names.
+func IngressName(host string) string {
+	if base, wildcard := WildcardBase(host); wildcard {
+		return "wildcard." + base
+	}
+	return host
+}
+
+// HasWildcardHosts checks if any of ingress rules uses wildcard host
+func (ingr IngressResource) HasWildcardHosts() bool {
+	for _, host := range ingr.Hosts() {
+		if _, wildcard := WildcardBase(host); wildcard {
+			return true
+		}
+	}
+	return false
+}
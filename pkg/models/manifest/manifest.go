@@ -0,0 +1,578 @@
+// Package manifest converts native kubernetes manifests of deployments, services and ingresses to bundle.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/util/yamljson"
+	"github.com/containerum/kube-client/pkg/model"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	kindDeployment = "Deployment"
+	kindService    = "Service"
+	kindIngress    = "Ingress"
+	kindList       = "List"
+
+	mebibyte = 1 << 20
+)
+
+var supportedAPIVersions = map[string][]string{
+	kindDeployment: {"apps/v1", "apps/v1beta2", "apps/v1beta1", "extensions/v1beta1"},
+	kindService:    {"v1"},
+	kindIngress:    {"networking.k8s.io/v1", "networking.k8s.io/v1beta1", "extensions/v1beta1"},
+}
+
+// defaultFields -- fields filled by kubernetes which are not reported if they have default values.
+// Indexes of lists in paths are replaced by [].
+var defaultFields = map[string]interface{}{
+	"spec.template.spec.restartPolicy":                         "Always",
+	"spec.template.spec.dnsPolicy":                             "ClusterFirst",
+	"spec.template.spec.schedulerName":                         "default-scheduler",
+	"spec.template.spec.containers[].terminationMessagePath":   "/dev/termination-log",
+	"spec.template.spec.containers[].terminationMessagePolicy": "File",
+	"spec.sessionAffinity":                                     "None",
+}
+
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// Conversion -- result of manifests conversion
+//
+// swagger:model ManifestsConversion
+type Conversion struct {
+	Bundle bundle.Bundle `json:"bundle"`
+	// fields and resources of manifests which can't be represented by platform resources and were not converted
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+type converter struct {
+	Conversion
+	deployments []deploymentManifest
+	services    []serviceManifest
+	ingresses   []ingressManifest
+	// service name -> index in bundle
+	serviceIndex map[string]int
+}
+
+// Convert converts multi-document YAML or JSON stream with deployments, services, ingresses and lists of them to bundle.
+// Services select deployments from the same manifests, services used as ingress backends are converted to external services.
+// Error is returned if manifests are malformed or can't be converted at all, not converted fields are listed in Unsupported.
+func Convert(data []byte) (*Conversion, error) {
+	docs, err := yamljson.ToJSONDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("manifests are empty")
+	}
+
+	var c = converter{
+		Conversion:   Conversion{Bundle: bundle.Bundle{Version: bundle.FormatVersion}},
+		serviceIndex: make(map[string]int),
+	}
+	for i, doc := range docs {
+		if err := c.add(doc); err != nil {
+			return nil, fmt.Errorf("document %d: %v", i+1, err)
+		}
+	}
+
+	for _, manifest := range c.deployments {
+		depl, err := c.convertDeployment(manifest)
+		if err != nil {
+			return nil, err
+		}
+		c.Bundle.Deployments = append(c.Bundle.Deployments, depl)
+	}
+	for _, manifest := range c.services {
+		svc, ok, err := c.convertService(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.serviceIndex[svc.Name] = len(c.Bundle.Services)
+			c.Bundle.Services = append(c.Bundle.Services, svc)
+		}
+	}
+	for _, manifest := range c.ingresses {
+		ingr, err := c.convertIngress(manifest)
+		if err != nil {
+			return nil, err
+		}
+		c.Bundle.Ingresses = append(c.Bundle.Ingresses, bundle.Ingress{IngressRequest: ingr})
+	}
+
+	sort.Strings(c.Unsupported)
+	return &c.Conversion, nil
+}
+
+func (c *converter) unsupported(ref, format string, args ...interface{}) {
+	c.Unsupported = append(c.Unsupported, ref+": "+fmt.Sprintf(format, args...))
+}
+
+// add decodes kubernetes object and reports its unsupported fields
+func (c *converter) add(doc []byte) error {
+	var meta struct {
+		typeMeta
+		Metadata objectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return err
+	}
+	var ref = meta.Kind + " " + meta.Metadata.Name
+
+	var manifest interface{}
+	switch meta.Kind {
+	case kindList:
+		var items list
+		if err := json.Unmarshal(doc, &items); err != nil {
+			return err
+		}
+		for _, item := range items.Items {
+			if err := c.add(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case kindDeployment:
+		manifest = &deploymentManifest{}
+	case kindService:
+		manifest = &serviceManifest{}
+	case kindIngress:
+		manifest = &ingressManifest{}
+	case "":
+		return fmt.Errorf("kind is required")
+	default:
+		c.unsupported(ref, "kind %s is not supported", meta.Kind)
+		return nil
+	}
+
+	if !isSupportedAPIVersion(meta.Kind, meta.APIVersion) {
+		c.unsupported(ref, "apiVersion %s is not supported, supported versions are %s",
+			meta.APIVersion, strings.Join(supportedAPIVersions[meta.Kind], ", "))
+		return nil
+	}
+	if meta.Metadata.Name == "" {
+		return fmt.Errorf("%s: metadata.name is required", meta.Kind)
+	}
+	if err := json.Unmarshal(doc, manifest); err != nil {
+		return fmt.Errorf("%s: %v", ref, err)
+	}
+	if err := c.checkFields(ref, doc, manifest); err != nil {
+		return err
+	}
+
+	switch manifest := manifest.(type) {
+	case *deploymentManifest:
+		c.deployments = append(c.deployments, *manifest)
+	case *serviceManifest:
+		c.services = append(c.services, *manifest)
+	case *ingressManifest:
+		c.ingresses = append(c.ingresses, *manifest)
+	}
+	return nil
+}
+
+func isSupportedAPIVersion(kind, apiVersion string) bool {
+	for _, supported := range supportedAPIVersions[kind] {
+		if apiVersion == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFields reports fields of document which are lost when document is decoded to manifest
+func (c *converter) checkFields(ref string, doc []byte, manifest interface{}) error {
+	var raw, decoded interface{}
+	if err := json.Unmarshal(doc, &raw); err != nil {
+		return err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	for _, path := range unsupportedFields("", raw, decoded) {
+		c.unsupported(ref, "%s", path)
+	}
+	return nil
+}
+
+// unsupportedFields returns paths of non-empty fields which are present in raw document but absent in decoded one
+func unsupportedFields(path string, raw, decoded interface{}) []string {
+	var ret []string
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		decoded, _ := decoded.(map[string]interface{})
+		for key, value := range raw {
+			var fieldPath = key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			decodedValue, ok := decoded[key]
+			switch {
+			case ok:
+				ret = append(ret, unsupportedFields(fieldPath, value, decodedValue)...)
+			case isEmpty(value):
+			case reflect.DeepEqual(defaultFields[listIndex.ReplaceAllString(fieldPath, "[]")], value):
+			default:
+				ret = append(ret, fieldPath)
+			}
+		}
+	case []interface{}:
+		decoded, _ := decoded.([]interface{})
+		for i := 0; i < len(raw) && i < len(decoded); i++ {
+			ret = append(ret, unsupportedFields(fmt.Sprintf("%s[%d]", path, i), raw[i], decoded[i])...)
+		}
+	}
+	return ret
+}
+
+func isEmpty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func (c *converter) convertDeployment(manifest deploymentManifest) (model.Deployment, error) {
+	var ref = kindDeployment + " " + manifest.Metadata.Name
+	var spec = manifest.Spec.Template.Spec
+
+	var ret = model.Deployment{
+		Name:     manifest.Metadata.Name,
+		Replicas: 1,
+	}
+	if manifest.Spec.Replicas != nil {
+		ret.Replicas = *manifest.Spec.Replicas
+	}
+
+	var volumes = make(map[string]volume, len(spec.Volumes))
+	for _, vol := range spec.Volumes {
+		volumes[vol.Name] = vol
+	}
+
+	for i, cont := range spec.Containers {
+		var path = fmt.Sprintf("spec.template.spec.containers[%d]", i)
+		var converted = model.Container{
+			Name:  cont.Name,
+			Image: cont.Image,
+		}
+
+		if len(cont.Args) > 0 && len(cont.Command) == 0 {
+			c.unsupported(ref, "%s.args: arguments without command are not supported", path)
+		} else if len(cont.Command) > 0 {
+			converted.Commands = append(append([]string(nil), cont.Command...), cont.Args...)
+		}
+
+		for _, env := range cont.Env {
+			converted.Env = append(converted.Env, model.Env{Name: env.Name, Value: env.Value})
+		}
+
+		for _, port := range cont.Ports {
+			var protocol = portProtocol(port.Protocol)
+			var name = port.Name
+			if name == "" {
+				name = defaultPortName(protocol, port.ContainerPort)
+			}
+			converted.Ports = append(converted.Ports, model.ContainerPort{
+				Name:     name,
+				Port:     port.ContainerPort,
+				Protocol: protocol,
+			})
+		}
+
+		limits, err := c.convertResources(ref, path, cont.Resources)
+		if err != nil {
+			return ret, err
+		}
+		converted.Limits = limits
+
+		for j, mount := range cont.VolumeMounts {
+			vol, ok := volumes[mount.Name]
+			if !ok {
+				return ret, fmt.Errorf("%s: %s.volumeMounts[%d]: volume %s is not defined", ref, path, j, mount.Name)
+			}
+			var mounted = model.ContainerVolume{
+				MountPath: mount.MountPath,
+			}
+			if mount.SubPath != "" {
+				var subPath = mount.SubPath
+				mounted.SubPath = &subPath
+			}
+			switch {
+			case vol.PersistentVolumeClaim != nil:
+				var claim = vol.PersistentVolumeClaim.ClaimName
+				mounted.Name = claim
+				mounted.PersistentVolumeClaimName = &claim
+				converted.VolumeMounts = append(converted.VolumeMounts, mounted)
+			case vol.ConfigMap != nil:
+				mounted.Name = vol.ConfigMap.Name
+				if vol.ConfigMap.DefaultMode != nil {
+					var mode = fmt.Sprintf("%#o", *vol.ConfigMap.DefaultMode)
+					mounted.Mode = &mode
+				}
+				converted.ConfigMaps = append(converted.ConfigMaps, mounted)
+			default:
+				c.unsupported(ref, "%s.volumeMounts[%d]: volume %s is not persistent volume claim or config map", path, j, mount.Name)
+			}
+		}
+
+		ret.Containers = append(ret.Containers, converted)
+	}
+	return ret, nil
+}
+
+// convertResources converts CPU and memory limits to millicores and mebibytes.
+// Requests are used if limits are not set, platform containers always have requests equal to limits.
+func (c *converter) convertResources(ref, path string, res resources) (model.Resource, error) {
+	for _, list := range []struct {
+		name       string
+		quantities map[string]resource.Quantity
+	}{{"limits", res.Limits}, {"requests", res.Requests}} {
+		for name := range list.quantities {
+			if name != "cpu" && name != "memory" {
+				c.unsupported(ref, "%s.resources.%s.%s", path, list.name, name)
+			}
+		}
+	}
+
+	var values = make(map[string]int64, 2)
+	for _, name := range []string{"cpu", "memory"} {
+		quantity, ok := res.Limits[name]
+		if request, isSet := res.Requests[name]; isSet && !ok {
+			quantity, ok = request, true
+		} else if isSet && request.Cmp(quantity) != 0 {
+			c.unsupported(ref, "%s.resources.requests.%s: requests different from limits are not supported", path, name)
+		}
+		if !ok {
+			return model.Resource{}, fmt.Errorf("%s: %s.resources.limits.%s is required", ref, path, name)
+		}
+		if quantity.Sign() <= 0 {
+			return model.Resource{}, fmt.Errorf("%s: %s.resources.limits.%s must be positive", ref, path, name)
+		}
+		if name == "cpu" {
+			values[name] = quantity.MilliValue()
+		} else {
+			values[name] = (quantity.Value() + mebibyte - 1) / mebibyte
+		}
+	}
+	return model.Resource{
+		CPU:    uint(values["cpu"]),
+		Memory: uint(values["memory"]),
+	}, nil
+}
+
+func portProtocol(protocol string) model.Protocol {
+	if protocol == "" {
+		return model.TCP
+	}
+	return model.Protocol(protocol)
+}
+
+func defaultPortName(protocol model.Protocol, port int) string {
+	return fmt.Sprintf("%s-%d", strings.ToLower(string(protocol)), port)
+}
+
+// convertService converts service, ok is false if service can't be converted and is reported as unsupported
+func (c *converter) convertService(manifest serviceManifest) (svc bundle.Service, ok bool, err error) {
+	var ref = kindService + " " + manifest.Metadata.Name
+
+	svc.Name = manifest.Metadata.Name
+	switch manifest.Spec.Type {
+	case "", "ClusterIP":
+		svc.Type = service.ServiceInternal
+	case "NodePort", "LoadBalancer":
+		svc.Type = service.ServiceExternal
+	default:
+		c.unsupported(ref, "spec.type: %s services are not supported", manifest.Spec.Type)
+		return svc, false, nil
+	}
+	if manifest.Spec.ClusterIP == "None" {
+		c.unsupported(ref, "spec.clusterIP: headless services are not supported")
+	}
+
+	depl, err := c.selectDeployment(ref, manifest.Spec.Selector)
+	if err != nil {
+		return svc, false, err
+	}
+	svc.Deploy = depl.Metadata.Name
+
+	for i, port := range manifest.Spec.Ports {
+		var protocol = portProtocol(port.Protocol)
+		var name = port.Name
+		if name == "" {
+			name = defaultPortName(protocol, port.Port)
+		}
+		targetPort, err := containerPortNumber(depl, port)
+		if err != nil {
+			return svc, false, fmt.Errorf("%s: spec.ports[%d]: %v", ref, i, err)
+		}
+		var number = port.Port
+		svc.Ports = append(svc.Ports, model.ServicePort{
+			Name:       name,
+			Port:       &number,
+			TargetPort: targetPort,
+			Protocol:   protocol,
+		})
+	}
+	return svc, true, nil
+}
+
+// selectDeployment returns the only deployment with pod template labels matching selector
+func (c *converter) selectDeployment(ref string, selector map[string]string) (deploymentManifest, error) {
+	if len(selector) == 0 {
+		return deploymentManifest{}, fmt.Errorf("%s: spec.selector is required", ref)
+	}
+	var selected []deploymentManifest
+	for _, depl := range c.deployments {
+		var labels = depl.Spec.Template.Metadata.Labels
+		var matches = true
+		for key, value := range selector {
+			if labels[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, depl)
+		}
+	}
+	if len(selected) != 1 {
+		return deploymentManifest{}, fmt.Errorf("%s: spec.selector must match exactly one deployment in manifests, matches %d", ref, len(selected))
+	}
+	return selected[0], nil
+}
+
+// containerPortNumber resolves target port of service port, target port is port number or name of container port
+func containerPortNumber(depl deploymentManifest, port servicePort) (int, error) {
+	switch target := port.TargetPort.(type) {
+	case nil:
+		return port.Port, nil
+	case float64:
+		return int(target), nil
+	case string:
+		for _, cont := range depl.Spec.Template.Spec.Containers {
+			for _, containerPort := range cont.Ports {
+				if containerPort.Name == target {
+					return containerPort.ContainerPort, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("port %s is not found in containers of deployment %s", target, depl.Metadata.Name)
+	default:
+		return 0, fmt.Errorf("targetPort must be number or name")
+	}
+}
+
+func (c *converter) convertIngress(manifest ingressManifest) (ingress.IngressRequest, error) {
+	var ref = kindIngress + " " + manifest.Metadata.Name
+	var ret = ingress.IngressRequest{
+		Ingress: model.Ingress{Name: manifest.Metadata.Name},
+	}
+
+	var hosts = make(map[string]bool, len(manifest.Spec.Rules))
+	for _, rule := range manifest.Spec.Rules {
+		hosts[rule.Host] = true
+	}
+	var secrets = make(map[string]string)
+	for i, tls := range manifest.Spec.TLS {
+		for _, host := range tls.Hosts {
+			switch {
+			case tls.SecretName == "":
+				c.unsupported(ref, "spec.tls[%d]: TLS without secret is not supported", i)
+			case !hosts[host]:
+				c.unsupported(ref, "spec.tls[%d]: TLS for host %s without rules is not supported", i, host)
+			default:
+				secrets[host] = tls.SecretName
+			}
+		}
+	}
+
+	for i, rule := range manifest.Spec.Rules {
+		if rule.Host == "" {
+			return ret, fmt.Errorf("%s: spec.rules[%d].host is required", ref, i)
+		}
+		if len(rule.HTTP.Paths) == 0 {
+			return ret, fmt.Errorf("%s: spec.rules[%d].http.paths are required", ref, i)
+		}
+		var converted = model.Rule{Host: rule.Host}
+		if secret, ok := secrets[rule.Host]; ok {
+			converted.TLSSecret = &secret
+		}
+		for j, path := range rule.HTTP.Paths {
+			if path.PathType == "Exact" {
+				c.unsupported(ref, "spec.rules[%d].http.paths[%d].pathType: exact paths are not supported", i, j)
+				continue
+			}
+			serviceName, servicePort, err := c.ingressBackend(path.Backend)
+			if err != nil {
+				return ret, fmt.Errorf("%s: spec.rules[%d].http.paths[%d].backend: %v", ref, i, j, err)
+			}
+			if path.Path == "" {
+				path.Path = "/"
+			}
+			converted.Path = append(converted.Path, model.Path{
+				Path:        path.Path,
+				ServiceName: serviceName,
+				ServicePort: servicePort,
+			})
+		}
+		if len(converted.Path) > 0 {
+			ret.Rules = append(ret.Rules, converted)
+		}
+	}
+	return ret, nil
+}
+
+// ingressBackend resolves backend service and port.
+// Backend services from manifests are converted to external services, ingresses can route only to them.
+func (c *converter) ingressBackend(backend ingressBackend) (string, int, error) {
+	var name, port = backend.ServiceName, backend.ServicePort
+	if backend.Service != nil {
+		name, port = backend.Service.Name, float64(backend.Service.Port.Number)
+		if backend.Service.Port.Name != "" {
+			port = backend.Service.Port.Name
+		}
+	}
+	if name == "" {
+		return "", 0, fmt.Errorf("service is required")
+	}
+
+	index, inManifests := c.serviceIndex[name]
+	if inManifests {
+		c.Bundle.Services[index].Type = service.ServiceExternal
+	}
+	switch port := port.(type) {
+	case float64:
+		return name, int(port), nil
+	case string:
+		if !inManifests {
+			return "", 0, fmt.Errorf("named port %s can be resolved only for services from manifests", port)
+		}
+		for _, servicePort := range c.Bundle.Services[index].Ports {
+			if servicePort.Name == port {
+				return name, *servicePort.Port, nil
+			}
+		}
+		return "", 0, fmt.Errorf("port %s is not found in service %s", port, name)
+	default:
+		return "", 0, fmt.Errorf("service port is required")
+	}
+}
@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      restartPolicy: Always
+      containers:
+      - name: nginx
+        image: nginx:1.15
+        ports:
+        - name: http
+          containerPort: 8080
+        resources:
+          limits:
+            cpu: "0.5"
+            memory: 1G
+        livenessProbe:
+          httpGet:
+            path: /
+            port: 8080
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+  ports:
+  - port: 80
+    targetPort: http
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  annotations:
+    nginx.ingress.kubernetes.io/ssl-redirect: "false"
+spec:
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`
+
+func TestConvert(t *testing.T) {
+	conversion, err := Convert([]byte(testManifests))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, conversion.Bundle.Deployments, 1) {
+		depl := conversion.Bundle.Deployments[0]
+		assert.Equal(t, "web", depl.Name)
+		assert.Equal(t, 2, depl.Replicas)
+		if assert.Len(t, depl.Containers, 1) {
+			assert.Equal(t, model.Resource{CPU: 500, Memory: 954}, depl.Containers[0].Limits)
+			assert.Equal(t, []model.ContainerPort{{Name: "http", Port: 8080, Protocol: model.TCP}}, depl.Containers[0].Ports)
+		}
+	}
+
+	if assert.Len(t, conversion.Bundle.Services, 1) {
+		svc := conversion.Bundle.Services[0]
+		assert.Equal(t, "web", svc.Deploy)
+		assert.Equal(t, service.ServiceExternal, svc.Type, "ingress backend must be external service")
+		if assert.Len(t, svc.Ports, 1) {
+			assert.Equal(t, "tcp-80", svc.Ports[0].Name)
+			assert.Equal(t, 8080, svc.Ports[0].TargetPort)
+		}
+	}
+
+	if assert.Len(t, conversion.Bundle.Ingresses, 1) && assert.Len(t, conversion.Bundle.Ingresses[0].Rules, 1) {
+		assert.Equal(t, []model.Path{{Path: "/", ServiceName: "web", ServicePort: 80}}, conversion.Bundle.Ingresses[0].Rules[0].Path)
+	}
+
+	assert.Equal(t, []string{
+		"ConfigMap settings: kind ConfigMap is not supported",
+		"Deployment web: spec.template.spec.containers[0].livenessProbe",
+		"Ingress web: metadata.annotations",
+	}, conversion.Unsupported)
+}
+
+func TestConvertErrors(t *testing.T) {
+	_, err := Convert([]byte(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "db"}, "spec": {"selector": {"app": "db"}}}`))
+	assert.Error(t, err, "service without deployment")
+
+	_, err = Convert([]byte(`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "db"},
+		"spec": {"template": {"spec": {"containers": [{"name": "db", "image": "postgres"}]}}}}`))
+	assert.Error(t, err, "container without limits")
+}
@@ -0,0 +1,168 @@
+package manifest
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Kubernetes objects are decoded to types below. Only declared fields are converted or knowingly ignored,
+// other fields of manifests are reported as unsupported, so fields must not be omitted on encoding.
+
+type typeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+type objectMeta struct {
+	Name string `json:"name"`
+	// resources are created in target namespace
+	Namespace string `json:"namespace"`
+	// used only to match services to deployments
+	Labels map[string]string `json:"labels"`
+	// set by kubernetes
+	UID               interface{} `json:"uid"`
+	ResourceVersion   interface{} `json:"resourceVersion"`
+	Generation        interface{} `json:"generation"`
+	CreationTimestamp interface{} `json:"creationTimestamp"`
+	SelfLink          interface{} `json:"selfLink"`
+	ManagedFields     interface{} `json:"managedFields"`
+}
+
+type list struct {
+	typeMeta
+	Metadata interface{}       `json:"metadata"`
+	Items    []json.RawMessage `json:"items"`
+}
+
+type deploymentManifest struct {
+	typeMeta
+	Metadata objectMeta     `json:"metadata"`
+	Spec     deploymentSpec `json:"spec"`
+	Status   interface{}    `json:"status"`
+}
+
+type deploymentSpec struct {
+	Replicas *int `json:"replicas"`
+	// platform selects pods by deployment name
+	Selector interface{} `json:"selector"`
+	Template struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     podSpec    `json:"spec"`
+	} `json:"template"`
+}
+
+type podSpec struct {
+	Containers []container `json:"containers"`
+	Volumes    []volume    `json:"volumes"`
+}
+
+type container struct {
+	Name         string          `json:"name"`
+	Image        string          `json:"image"`
+	Command      []string        `json:"command"`
+	Args         []string        `json:"args"`
+	Env          []envVar        `json:"env"`
+	Ports        []containerPort `json:"ports"`
+	Resources    resources       `json:"resources"`
+	VolumeMounts []volumeMount   `json:"volumeMounts"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type containerPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type resources struct {
+	Limits   map[string]resource.Quantity `json:"limits"`
+	Requests map[string]resource.Quantity `json:"requests"`
+}
+
+type volumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath"`
+}
+
+type volume struct {
+	Name                  string `json:"name"`
+	PersistentVolumeClaim *struct {
+		ClaimName string `json:"claimName"`
+	} `json:"persistentVolumeClaim"`
+	ConfigMap *struct {
+		Name        string `json:"name"`
+		DefaultMode *int   `json:"defaultMode"`
+	} `json:"configMap"`
+}
+
+type serviceManifest struct {
+	typeMeta
+	Metadata objectMeta  `json:"metadata"`
+	Spec     serviceSpec `json:"spec"`
+	Status   interface{} `json:"status"`
+}
+
+type serviceSpec struct {
+	Type     string            `json:"type"`
+	Selector map[string]string `json:"selector"`
+	// assigned by kubernetes, headless services are reported
+	ClusterIP  string        `json:"clusterIP"`
+	ClusterIPs interface{}   `json:"clusterIPs"`
+	Ports      []servicePort `json:"ports"`
+}
+
+type servicePort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	// number or name of container port
+	TargetPort interface{} `json:"targetPort"`
+	Protocol   string      `json:"protocol"`
+}
+
+type ingressManifest struct {
+	typeMeta
+	Metadata objectMeta  `json:"metadata"`
+	Spec     ingressSpec `json:"spec"`
+	Status   interface{} `json:"status"`
+}
+
+type ingressSpec struct {
+	TLS []struct {
+		Hosts      []string `json:"hosts"`
+		SecretName string   `json:"secretName"`
+	} `json:"tls"`
+	Rules []ingressRule `json:"rules"`
+}
+
+type ingressRule struct {
+	Host string `json:"host"`
+	HTTP struct {
+		Paths []ingressPath `json:"paths"`
+	} `json:"http"`
+}
+
+type ingressPath struct {
+	Path     string         `json:"path"`
+	PathType string         `json:"pathType"`
+	Backend  ingressBackend `json:"backend"`
+}
+
+type ingressBackend struct {
+	// networking.k8s.io/v1
+	Service *struct {
+		Name string `json:"name"`
+		Port struct {
+			Number int    `json:"number"`
+			Name   string `json:"name"`
+		} `json:"port"`
+	} `json:"service"`
+	// extensions/v1beta1 and networking.k8s.io/v1beta1, port is number or name
+	ServiceName string      `json:"serviceName"`
+	ServicePort interface{} `json:"servicePort"`
+}
@@ -0,0 +1,139 @@
+package operation
+
+import (
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/globalsign/mgo/bson"
+)
+
+// Kind -- type of operation, defines kube-api request and storage compensation
+type Kind string
+
+const (
+	CreateDeployment       Kind = "create_deployment"
+	UpdateDeployment       Kind = "update_deployment"
+	SetDeploymentReplicas  Kind = "set_deployment_replicas"
+	ChangeActiveDeployment Kind = "change_active_deployment"
+	DeleteDeployment       Kind = "delete_deployment"
+
+	CreateService Kind = "create_service"
+	UpdateService Kind = "update_service"
+	DeleteService Kind = "delete_service"
+
+	CreateIngress Kind = "create_ingress"
+	UpdateIngress Kind = "update_ingress"
+	DeleteIngress Kind = "delete_ingress"
+
+	CreateCertificate Kind = "create_certificate"
+	DeleteCertificate Kind = "delete_certificate"
+)
+
+// Status -- operation state in journal
+type Status string
+
+const (
+	// StatusPending -- intent is recorded, storage write may be not done yet
+	StatusPending Status = "pending"
+	// StatusStored -- storage write is done, kube-api request is not confirmed yet
+	StatusStored Status = "stored"
+	// StatusCompensating -- kube-api request failed, storage write must be reverted
+	StatusCompensating Status = "compensating"
+	// StatusDone -- both storage and kube-api are updated
+	StatusDone Status = "done"
+	// StatusCompensated -- storage write is reverted
+	StatusCompensated Status = "compensated"
+	// StatusCancelled -- storage write failed, nothing was changed
+	StatusCancelled Status = "cancelled"
+	// StatusFailed -- compensation failed too many times, stores must be synced manually
+	StatusFailed Status = "failed"
+)
+
+// ActiveStatuses -- statuses of operations which are not finished yet
+func ActiveStatuses() []Status {
+	return []Status{StatusPending, StatusStored, StatusCompensating}
+}
+
+// IsActive reports if operation is not finished yet
+func (status Status) IsActive() bool {
+	for _, active := range ActiveStatuses() {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
+
+// Operation -- journal record of action which writes to storage and kube-api.
+// Record is stored before any side effect, so operation interrupted by crash
+// may be finished or compensated later.
+//
+// swagger:model
+type Operation struct {
+	ID          string `json:"_id" bson:"_id,omitempty"`
+	Kind        Kind   `json:"kind"`
+	Status      Status `json:"status"`
+	NamespaceID string `json:"namespaceid"`
+	Name        string `json:"name"`
+	Owner       string `json:"owner"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+	// irreversible operation is never compensated: recovery finishes it or marks it failed after the last attempt
+	Irreversible bool `json:"irreversible,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// resource states before and after operation, only fields of operation kind are set
+	Deployment    *deployment.DeploymentResource `json:"deployment,omitempty"`
+	OldDeployment *deployment.DeploymentResource `json:"old_deployment,omitempty"`
+	Service       *service.ServiceResource       `json:"service,omitempty"`
+	OldService    *service.ServiceResource       `json:"old_service,omitempty"`
+	Ingress       *ingress.IngressResource       `json:"ingress,omitempty"`
+	OldIngress    *ingress.IngressResource       `json:"old_ingress,omitempty"`
+	Certificate   *certificate.Certificate       `json:"certificate,omitempty"`
+
+	// Secret holds private keys, so it is never stored and operations with secret can't be finished after restart
+	Secret *kubtypes.Secret `json:"-" bson:"-"`
+}
+
+// OperationList -- operations list
+//
+// swagger:model
+type OperationList []Operation
+
+// StaleSelectQuery selects not finished operations not updated since time
+func StaleSelectQuery(before time.Time) interface{} {
+	return bson.M{
+		"status":    bson.M{"$in": ActiveStatuses()},
+		"updatedat": bson.M{"$lt": before},
+	}
+}
+
+// ActiveSelectQuery selects not finished operations
+func ActiveSelectQuery() interface{} {
+	return bson.M{
+		"status": bson.M{"$in": ActiveStatuses()},
+	}
+}
+
+// ClaimSelectQuery selects operation if it is still stale
+func ClaimSelectQuery(id string, before time.Time) interface{} {
+	return bson.M{
+		"_id":       id,
+		"status":    bson.M{"$in": ActiveStatuses()},
+		"updatedat": bson.M{"$lt": before},
+	}
+}
+
+// FinishedSelectQuery selects finished operations not updated since time
+func FinishedSelectQuery(before time.Time) interface{} {
+	return bson.M{
+		"status":    bson.M{"$nin": ActiveStatuses()},
+		"updatedat": bson.M{"$lt": before},
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/containerum/kube-client/pkg/model"
 	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
@@ -11,9 +13,12 @@ import (
 // swagger:model
 type ServiceResource struct {
 	model.Service
-	ID          string `json:"_id" bson:"_id,omitempty"`
-	Deleted     bool   `json:"deleted"`
-	NamespaceID string `json:"namespaceid"`
+	ID          string     `json:"_id" bson:"_id,omitempty"`
+	Deleted     bool       `json:"deleted"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	NamespaceID string     `json:"namespaceid"`
+	// incremented on each write, used as ETag
+	Revision int64 `json:"revision"`
 }
 
 // ServiceList -- services list
@@ -52,6 +57,16 @@ func (serv ServiceResource) OneSelectQuery() interface{} {
 	}
 }
 
+// OneRevisionSelectQuery selects resource like OneSelectQuery,
+// non-zero revision must also match stored one
+func (serv ServiceResource) OneRevisionSelectQuery() interface{} {
+	var query = serv.OneSelectQuery().(bson.M)
+	if serv.Revision != 0 {
+		query["revision"] = serv.Revision
+	}
+	return query
+}
+
 func (serv ServiceResource) OneSelectDeletedQuery() interface{} {
 	return bson.M{
 		"namespaceid":  serv.NamespaceID,
@@ -60,6 +75,14 @@ func (serv ServiceResource) OneSelectDeletedQuery() interface{} {
 	}
 }
 
+// AllSelectDeletedQuery selects deleted resources in namespace
+func (serv ServiceResource) AllSelectDeletedQuery() interface{} {
+	return bson.M{
+		"namespaceid": serv.NamespaceID,
+		"deleted":     true,
+	}
+}
+
 func (serv ServiceResource) AllSelectQuery() interface{} {
 	return bson.M{
 		"namespaceid": serv.NamespaceID,
@@ -79,6 +102,9 @@ func (serv ServiceResource) UpdateQuery() interface{} {
 		"$set": bson.M{
 			"service": serv.Service,
 		},
+		"$inc": bson.M{
+			"revision": 1,
+		},
 	}
 }
 
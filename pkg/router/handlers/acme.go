@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+type ACMEHandlers struct {
+	server.ACMEActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /.well-known/acme-challenge/{token} ACME GetACMEChallengeHandler
+// Get ACME HTTP-01 challenge response. Requests to this path for ingress hosts must be routed to resource-service.
+//
+// ---
+// x-method-visibility: private
+// parameters:
+//  - name: token
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '200':
+//    description: key authorization
+//  default:
+//    $ref: '#/responses/error'
+func (h *ACMEHandlers) GetACMEChallengeHandler(ctx *gin.Context) {
+	resp, err := h.GetACMEChallengeResponse(ctx.Request.Context(), ctx.Request.Host, ctx.Param("token"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.String(http.StatusOK, resp)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+type AdoptHandlers struct {
+	server.AdoptActions
+	*m.TranslateValidate
+}
+
+// swagger:operation POST /namespaces/{namespace}/adopt Adoption AdoptNamespaceHandler
+// Create records for deployments, services and ingresses which exist in kube-api but are unknown to resource-service.
+// Records get version 1.0.0 and specified owner, kube-api resources are not changed.
+// External ports of adopted services are reserved, services with used ports are skipped.
+// Resources which can't be represented by records are skipped, dropped fields of adopted resources are listed.
+//
+// ---
+// x-method-visibility: private
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: owner
+//    in: query
+//    type: string
+//    format: uuid
+//    required: true
+//    description: owner of adopted resources
+//  - name: dry_run
+//    in: query
+//    type: boolean
+//    required: false
+//    default: false
+// responses:
+//  '200':
+//    description: adoption result
+//    schema:
+//      $ref: '#/definitions/AdoptionResult'
+//  default:
+//    $ref: '#/responses/error'
+func (h *AdoptHandlers) AdoptNamespaceHandler(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	if err := h.Validate.Var(owner, "required,uuid"); err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(rserrors.ErrValidation().AddDetails("owner must be user ID")))
+		return
+	}
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	result, err := h.AdoptNamespace(ctx.Request.Context(), ctx.Param("namespace"), owner, dryRun)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
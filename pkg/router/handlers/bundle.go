@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/manifest"
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"git.containerum.net/ch/resource-service/pkg/util/yamljson"
+	"github.com/gin-gonic/gin"
+)
+
+const yamlContentType = "application/x-yaml"
+
+type BundleHandlers struct {
+	server.BundleActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /namespaces/{namespace}/export Bundle ExportNamespaceHandler
+// Export active deployments, services and ingresses of namespace as bundle.
+// Basic auth users and automatically issued certificates are not exported.
+//
+// ---
+// x-method-visibility: public
+// produces:
+//  - application/json
+//  - application/x-yaml
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: format
+//    in: query
+//    type: string
+//    enum: [json, yaml]
+//    required: false
+//    description: bundle format, YAML is also returned if Accept header contains yaml
+// responses:
+//  '200':
+//    description: namespace bundle
+//    schema:
+//      $ref: '#/definitions/Bundle'
+//  default:
+//    $ref: '#/responses/error'
+func (h *BundleHandlers) ExportNamespaceHandler(ctx *gin.Context) {
+	format := ctx.Query("format")
+	switch {
+	case format == "" && strings.Contains(ctx.GetHeader("Accept"), "yaml"):
+		format = "yaml"
+	case format == "":
+		format = "json"
+	case format != "json" && format != "yaml":
+		ctx.AbortWithStatusJSON(h.HandleError(rserrors.ErrValidation().AddDetailF("unknown bundle format %q", format)))
+		return
+	}
+
+	exported, err := h.ExportNamespace(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ctx.Param("namespace")+"."+format))
+	if format == "json" {
+		ctx.JSON(http.StatusOK, exported)
+		return
+	}
+	data, err := yamljson.Marshal(exported)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+	ctx.Data(http.StatusOK, yamlContentType, data)
+}
+
+// swagger:operation POST /namespaces/{namespace}/import Bundle ImportNamespaceHandler
+// Create resources from bundle in namespace in dependency order: deployments, services, ingresses.
+// External services get new ports and domains, ingress backends are updated to use new ports.
+// Names, ingress hosts and namespace quotas are checked before anything is created.
+// In atomic mode (default) created resources are deleted if any resource fails and error is returned,
+// else import continues and result contains errors of failed resources.
+// Ingresses exported without basic auth are rejected unless allow_unprotected is set,
+// then they are created without it and dropped settings are listed in result.
+//
+// ---
+// x-method-visibility: public
+// consumes:
+//  - application/json
+//  - application/x-yaml
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: atomic
+//    in: query
+//    type: boolean
+//    required: false
+//    default: true
+//  - name: allow_unprotected
+//    in: query
+//    type: boolean
+//    required: false
+//    default: false
+//    description: import ingresses which basic auth was dropped on export without it
+//  - name: body
+//    in: body
+//    schema:
+//      $ref: '#/definitions/Bundle'
+// responses:
+//  '201':
+//    description: all resources imported
+//    schema:
+//      $ref: '#/definitions/ImportResult'
+//  '207':
+//    description: some resources not imported in non-atomic mode
+//    schema:
+//      $ref: '#/definitions/ImportResult'
+//  default:
+//    $ref: '#/responses/error'
+func (h *BundleHandlers) ImportNamespaceHandler(ctx *gin.Context) {
+	var flags = make(map[string]bool, 2)
+	for name, defValue := range map[string]string{"atomic": "true", "allow_unprotected": "false"} {
+		value, err := strconv.ParseBool(ctx.DefaultQuery(name, defValue))
+		if err != nil {
+			ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+			return
+		}
+		flags[name] = value
+	}
+
+	data, err := ctx.GetRawData()
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+	// JSON is valid YAML, so both formats are decoded the same way
+	var req bundle.Bundle
+	if err := yamljson.Unmarshal(data, &req); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+	if err := h.Validate.Struct(req); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	result, err := h.ImportNamespace(ctx.Request.Context(), ctx.Param("namespace"), req, flags["atomic"], flags["allow_unprotected"])
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	if result.Failed() {
+		ctx.JSON(http.StatusMultiStatus, result)
+		return
+	}
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// swagger:operation POST /namespaces/{namespace}/import/manifests Bundle ImportManifestsHandler
+// Create resources from native kubernetes manifests of deployments (apps/v1), services (v1) and ingresses (networking.k8s.io).
+// Manifests are multi-document YAML or JSON, kind List is accepted too.
+// Manifests are converted to bundle and imported in the same way as bundle.
+// Services select deployments from the same manifests by pod template labels,
+// services used as ingress backends are imported as external services.
+// CPU and memory limits are used as container resources, requests are used if limits are not set.
+// Fields and resources which can't be converted are rejected unless allow_unsupported is set,
+// then they are skipped and listed in result.
+//
+// ---
+// x-method-visibility: public
+// consumes:
+//  - application/json
+//  - application/x-yaml
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: atomic
+//    in: query
+//    type: boolean
+//    required: false
+//    default: true
+//  - name: allow_unsupported
+//    in: query
+//    type: boolean
+//    required: false
+//    default: false
+//    description: skip fields and resources which can't be converted
+//  - name: dry_run
+//    in: query
+//    type: boolean
+//    required: false
+//    default: false
+//    description: return conversion result without importing
+//  - name: body
+//    in: body
+//    schema:
+//      type: string
+// responses:
+//  '200':
+//    description: conversion result in dry run mode
+//    schema:
+//      $ref: '#/definitions/ManifestsConversion'
+//  '201':
+//    description: all resources imported
+//    schema:
+//      $ref: '#/definitions/ImportResult'
+//  '207':
+//    description: some resources not imported in non-atomic mode
+//    schema:
+//      $ref: '#/definitions/ImportResult'
+//  default:
+//    $ref: '#/responses/error'
+func (h *BundleHandlers) ImportManifestsHandler(ctx *gin.Context) {
+	var flags = make(map[string]bool, 3)
+	for name, defValue := range map[string]string{"atomic": "true", "allow_unsupported": "false", "dry_run": "false"} {
+		value, err := strconv.ParseBool(ctx.DefaultQuery(name, defValue))
+		if err != nil {
+			ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+			return
+		}
+		flags[name] = value
+	}
+
+	data, err := ctx.GetRawData()
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+	conversion, err := manifest.Convert(data)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+	if flags["dry_run"] {
+		ctx.JSON(http.StatusOK, conversion)
+		return
+	}
+	if len(conversion.Unsupported) > 0 && !flags["allow_unsupported"] {
+		ctx.AbortWithStatusJSON(h.HandleError(rserrors.ErrValidation().
+			AddDetails("manifests contain unsupported fields, use allow_unsupported to skip them").
+			AddDetails(conversion.Unsupported...)))
+		return
+	}
+	if err := h.Validate.Struct(conversion.Bundle); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	// converted manifests have no dropped settings, basic auth annotations are listed as unsupported
+	result, err := h.ImportNamespace(ctx.Request.Context(), ctx.Param("namespace"), conversion.Bundle, flags["atomic"], false)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+	result.Unsupported = conversion.Unsupported
+
+	if result.Failed() {
+		ctx.JSON(http.StatusMultiStatus, result)
+		return
+	}
+	ctx.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type CertificateHandlers struct {
+	server.CertificateActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /namespaces/{namespace}/certificates Certificate GetCertificatesListHandler
+// Get TLS certificates list.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
+// responses:
+//  '200':
+//    description: certificates list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
+//    schema:
+//      $ref: '#/definitions/CertificateList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CertificateHandlers) GetCertificatesListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
+	resp, err := h.GetCertificatesList(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	list, page, err := query.QueryCertificates(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation GET /namespaces/{namespace}/certificates/{certificate} Certificate GetCertificateHandler
+// Get TLS certificate metadata.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: certificate
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '200':
+//    description: certificate
+//    schema:
+//      $ref: '#/definitions/Certificate'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CertificateHandlers) GetCertificateHandler(ctx *gin.Context) {
+	resp, err := h.GetCertificate(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("certificate"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// swagger:operation POST /namespaces/{namespace}/certificates Certificate CreateCertificateHandler
+// Upload TLS certificate. Certificate may be used in ingress rules by name.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: body
+//    in: body
+//    schema:
+//      $ref: '#/definitions/CertificateRequest'
+// responses:
+//  '201':
+//    description: certificate created
+//    schema:
+//      $ref: '#/definitions/Certificate'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CertificateHandlers) CreateCertificateHandler(ctx *gin.Context) {
+	var req certificate.CertificateRequest
+	if err := ctx.ShouldBindWith(&req, binding.JSON); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	created, err := h.CreateCertificate(ctx.Request.Context(), ctx.Param("namespace"), req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+// swagger:operation DELETE /namespaces/{namespace}/certificates/{certificate} Certificate DeleteCertificateHandler
+// Delete TLS certificate.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: certificate
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '202':
+//    description: certificate deleted
+//  default:
+//    $ref: '#/responses/error'
+func (h *CertificateHandlers) DeleteCertificateHandler(ctx *gin.Context) {
+	if err := h.DeleteCertificate(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("certificate")); err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
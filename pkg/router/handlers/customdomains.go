@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"net/http"
+
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type CustomDomainHandlers struct {
+	server.CustomDomainActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /namespaces/{namespace}/custom_domains CustomDomain GetCustomDomainsListHandler
+// Get custom domains list.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
+// responses:
+//  '200':
+//    description: custom domains list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
+//    schema:
+//      $ref: '#/definitions/CustomDomainList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CustomDomainHandlers) GetCustomDomainsListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
+	resp, err := h.GetCustomDomainsList(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	list, page, err := query.QueryCustomDomains(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation GET /namespaces/{namespace}/custom_domains/{domain} CustomDomain GetCustomDomainHandler
+// Get custom domain.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: domain
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '200':
+//    description: custom domain
+//    schema:
+//      $ref: '#/definitions/CustomDomain'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CustomDomainHandlers) GetCustomDomainHandler(ctx *gin.Context) {
+	resp, err := h.GetCustomDomain(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("domain"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// swagger:operation POST /namespaces/{namespace}/custom_domains CustomDomain AddCustomDomainHandler
+// Register custom domain. Response contains TXT record name and token which prove domain ownership.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: body
+//    in: body
+//    schema:
+//      $ref: '#/definitions/CustomDomain'
+// responses:
+//  '201':
+//    description: custom domain registered
+//    schema:
+//      $ref: '#/definitions/CustomDomain'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CustomDomainHandlers) AddCustomDomainHandler(ctx *gin.Context) {
+	var req customdomain.CustomDomain
+	if err := ctx.ShouldBindWith(&req, binding.JSON); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	created, err := h.AddCustomDomain(ctx.Request.Context(), ctx.Param("namespace"), req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+// swagger:operation POST /namespaces/{namespace}/custom_domains/{domain}/verify CustomDomain VerifyCustomDomainHandler
+// Verify custom domain ownership by TXT record.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: domain
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '202':
+//    description: custom domain verified
+//    schema:
+//      $ref: '#/definitions/CustomDomain'
+//  default:
+//    $ref: '#/responses/error'
+func (h *CustomDomainHandlers) VerifyCustomDomainHandler(ctx *gin.Context) {
+	resp, err := h.VerifyCustomDomain(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("domain"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, resp)
+}
+
+// swagger:operation DELETE /namespaces/{namespace}/custom_domains/{domain} CustomDomain DeleteCustomDomainHandler
+// Delete custom domain.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: domain
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '202':
+//    description: custom domain deleted
+//  default:
+//    $ref: '#/responses/error'
+func (h *CustomDomainHandlers) DeleteCustomDomainHandler(ctx *gin.Context) {
+	if err := h.DeleteCustomDomain(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("domain")); err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
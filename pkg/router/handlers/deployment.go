@@ -29,31 +29,66 @@ type DeployHandlers struct {
 //    in: path
 //    type: string
 //    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListImage'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
 // responses:
 //  '200':
 //    description: deployments list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
 //    schema:
 //      $ref: '#/definitions/DeploymentList'
 //  default:
 //    $ref: '#/responses/error'
 func (h *DeployHandlers) GetDeploymentsListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
 	resp, err := h.GetDeploymentsList(ctx.Request.Context(), ctx.Param("namespace"))
 	if err != nil {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	list, page, err := query.QueryDeployments(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
 }
 
 func (h *DeployHandlers) GetDeploymentVersionsListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
 	resp, err := h.GetDeploymentVersionsList(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("deployment"))
 	if err != nil {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	list, page, err := query.QueryDeployments(resp, "-" + server.SortByVersion)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
 }
 
 // swagger:operation GET /namespaces/{namespace}/deployments/{deployment} Deployment GetDeploymentHandler
@@ -65,6 +100,7 @@ func (h *DeployHandlers) GetDeploymentVersionsListHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfNoneMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -78,6 +114,8 @@ func (h *DeployHandlers) GetDeploymentVersionsListHandler(ctx *gin.Context) {
 //    description: deployment
 //    schema:
 //      $ref: '#/definitions/DeploymentResource'
+//  '304':
+//    description: resource is not modified
 //  default:
 //    $ref: '#/responses/error'
 func (h *DeployHandlers) GetDeploymentHandler(ctx *gin.Context) {
@@ -87,7 +125,7 @@ func (h *DeployHandlers) GetDeploymentHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	writeResource(ctx, http.StatusOK, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation GET /namespaces/{namespace}/deployments/{deployment}/versions/{version} Deployment GetDeploymentVersionHandler
@@ -99,6 +137,7 @@ func (h *DeployHandlers) GetDeploymentHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfNoneMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -116,6 +155,8 @@ func (h *DeployHandlers) GetDeploymentHandler(ctx *gin.Context) {
 //    description: deployment
 //    schema:
 //      $ref: '#/definitions/DeploymentResource'
+//  '304':
+//    description: resource is not modified
 //  default:
 //    $ref: '#/responses/error'
 func (h *DeployHandlers) GetDeploymentVersionHandler(ctx *gin.Context) {
@@ -125,7 +166,7 @@ func (h *DeployHandlers) GetDeploymentVersionHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	writeResource(ctx, http.StatusOK, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation POST /namespaces/{namespace}/deployments Deployment CreateDeploymentHandler
@@ -165,7 +206,7 @@ func (h *DeployHandlers) CreateDeploymentHandler(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
-	ctx.JSON(http.StatusCreated, deploy)
+	writeResource(ctx, http.StatusCreated, server.ETag(deploy.ID, deploy.Revision), deploy)
 }
 
 // swagger:operation POST /namespaces/{namespace}/deployments/{deployment}/versions/{version} Deployment ChangeActiveDeploymentHandler
@@ -177,6 +218,7 @@ func (h *DeployHandlers) CreateDeploymentHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -203,7 +245,7 @@ func (h *DeployHandlers) ChangeActiveDeploymentHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, resp)
+	writeResource(ctx, http.StatusAccepted, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/deployments/{deployment}/versions/{version} Deployment RenameVersionHandler
@@ -215,6 +257,7 @@ func (h *DeployHandlers) ChangeActiveDeploymentHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -257,7 +300,7 @@ func (h *DeployHandlers) RenameVersionHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, resp)
+	writeResource(ctx, http.StatusAccepted, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/deployments/{deployment} Deployment UpdateDeployment
@@ -269,6 +312,7 @@ func (h *DeployHandlers) RenameVersionHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -302,7 +346,7 @@ func (h *DeployHandlers) UpdateDeploymentHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, updDeploy)
+	writeResource(ctx, http.StatusAccepted, server.ETag(updDeploy.ID, updDeploy.Revision), updDeploy)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/deployments/{deployment}/image Deployment SetContainerImageHandler
@@ -313,6 +357,7 @@ func (h *DeployHandlers) UpdateDeploymentHandler(ctx *gin.Context) {
 // parameters:
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //
 //  - name: namespace
 //    in: path
@@ -346,7 +391,7 @@ func (h *DeployHandlers) SetContainerImageHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, updatedDeploy)
+	writeResource(ctx, http.StatusAccepted, server.ETag(updatedDeploy.ID, updatedDeploy.Revision), updatedDeploy)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/deployments/{deployment}/replicas Deployment SetReplicasHandler
@@ -358,6 +403,7 @@ func (h *DeployHandlers) SetContainerImageHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -389,7 +435,7 @@ func (h *DeployHandlers) SetReplicasHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, updatedDeploy)
+	writeResource(ctx, http.StatusAccepted, server.ETag(updatedDeploy.ID, updatedDeploy.Revision), updatedDeploy)
 }
 
 // swagger:operation DELETE /namespaces/{namespace}/deployments/{deployment} Deployment DeleteDeploymentHandler
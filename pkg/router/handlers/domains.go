@@ -26,25 +26,50 @@ type DomainHandlers struct {
 //    in: query
 //    type: string
 //    required: false
+//    description: deprecated, use limit and cursor
 //  - name: per_page
 //    in: query
 //    type: string
 //    required: false
+//    description: deprecated, use limit and cursor
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
 // responses:
 //  '200':
 //    description: domains list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
 //    schema:
 //      $ref: '#/definitions/DomainsList'
 //  default:
 //    $ref: '#/responses/error'
 func (h *DomainHandlers) GetDomainsListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
 	resp, err := h.GetDomainsList(ctx.Request.Context(), ctx.Query("page"), ctx.Query("per_page"))
 	if err != nil {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	list, page, err := query.QueryDomains(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
 }
 
 // swagger:operation GET /domains/{domain} Domain GetDomainHandler
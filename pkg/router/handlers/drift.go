@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+type DriftHandlers struct {
+	server.DriftActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /drift Drift GetDriftHandler
+// Compare active deployments, services and ingresses with resources in kube-api.
+// Reports resources missing in kube-api, extra resources in kube-api and resources which differ from records.
+// All namespaces with active resources are checked if namespace is not specified.
+//
+// ---
+// x-method-visibility: private
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - name: namespace
+//    in: query
+//    type: string
+//    required: false
+// responses:
+//  '200':
+//    description: drift report
+//    schema:
+//      $ref: '#/definitions/DriftReport'
+//  default:
+//    $ref: '#/responses/error'
+func (h *DriftHandlers) GetDriftHandler(ctx *gin.Context) {
+	resp, err := h.DetectDrift(ctx.Request.Context(), ctx.Query("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+// writeResource responds with resource and its ETag.
+// GET request with matching If-None-Match header gets 304 without body.
+func writeResource(ctx *gin.Context, status int, etag string, resource interface{}) {
+	ctx.Header("ETag", etag)
+	if ctx.Request.Method == http.MethodGet && server.MatchETag(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.JSON(status, resource)
+}
@@ -8,7 +8,7 @@ import (
 
 	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
 	"git.containerum.net/ch/resource-service/pkg/server"
-	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 )
 
 type IngressHandlers struct {
@@ -29,20 +29,70 @@ type IngressHandlers struct {
 //    in: path
 //    type: string
 //    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
 // responses:
 //  '200':
 //    description: ingresses list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
 //    schema:
 //      $ref: '#/definitions/IngressList'
 //  default:
 //    $ref: '#/responses/error'
 func (h *IngressHandlers) GetIngressesListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
 	resp, err := h.GetIngressesList(ctx.Request.Context(), ctx.Param("namespace"))
 	if err != nil {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
 
+	list, page, err := query.QueryIngresses(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation GET /ingress_suffixes Ingress GetIngressSuffixesHandler
+// Get available ingress host suffixes.
+// Domain groups and their IPs are returned to admins only.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+// responses:
+//  '200':
+//    description: ingress host suffixes list
+//    schema:
+//      $ref: '#/definitions/HostSuffixList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *IngressHandlers) GetIngressSuffixesHandler(ctx *gin.Context) {
+	resp, err := h.GetIngressSuffixes(ctx.Request.Context())
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
 	ctx.JSON(http.StatusOK, resp)
 }
 
@@ -55,6 +105,7 @@ func (h *IngressHandlers) GetIngressesListHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfNoneMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -68,6 +119,8 @@ func (h *IngressHandlers) GetIngressesListHandler(ctx *gin.Context) {
 //    description: ingresses
 //    schema:
 //      $ref: '#/definitions/IngressResource'
+//  '304':
+//    description: resource is not modified
 //  default:
 //    $ref: '#/responses/error'
 func (h *IngressHandlers) GetIngressHandler(ctx *gin.Context) {
@@ -77,7 +130,7 @@ func (h *IngressHandlers) GetIngressHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	writeResource(ctx, http.StatusOK, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation POST /namespaces/{namespace}/ingresses Ingress CreateIngressHandler
@@ -96,7 +149,7 @@ func (h *IngressHandlers) GetIngressHandler(ctx *gin.Context) {
 //  - name: body
 //    in: body
 //    schema:
-//      $ref: '#/definitions/Ingress'
+//      $ref: '#/definitions/IngressRequest'
 // responses:
 //  '201':
 //    description: ingress created
@@ -105,7 +158,7 @@ func (h *IngressHandlers) GetIngressHandler(ctx *gin.Context) {
 //  default:
 //    $ref: '#/responses/error'
 func (h *IngressHandlers) CreateIngressHandler(ctx *gin.Context) {
-	var req kubtypes.Ingress
+	var req ingress.IngressRequest
 	if err := ctx.ShouldBindWith(&req, binding.JSON); err != nil {
 		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
 		return
@@ -117,7 +170,7 @@ func (h *IngressHandlers) CreateIngressHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, createdIngress)
+	writeResource(ctx, http.StatusCreated, server.ETag(createdIngress.ID, createdIngress.Revision), createdIngress)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/ingresses/{ingress} Ingress UpdateIngressHandler
@@ -129,6 +182,7 @@ func (h *IngressHandlers) CreateIngressHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -140,7 +194,7 @@ func (h *IngressHandlers) CreateIngressHandler(ctx *gin.Context) {
 //  - name: body
 //    in: body
 //    schema:
-//      $ref: '#/definitions/Ingress'
+//      $ref: '#/definitions/IngressRequest'
 // responses:
 //  '202':
 //    description: ingress updated
@@ -149,7 +203,7 @@ func (h *IngressHandlers) CreateIngressHandler(ctx *gin.Context) {
 //  default:
 //    $ref: '#/responses/error'
 func (h *IngressHandlers) UpdateIngressHandler(ctx *gin.Context) {
-	var req kubtypes.Ingress
+	var req ingress.IngressRequest
 	if err := ctx.ShouldBindWith(&req, binding.JSON); err != nil {
 		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
 		return
@@ -162,7 +216,52 @@ func (h *IngressHandlers) UpdateIngressHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, updatedIngress)
+	writeResource(ctx, http.StatusAccepted, server.ETag(updatedIngress.ID, updatedIngress.Revision), updatedIngress)
+}
+
+// swagger:operation PUT /namespaces/{namespace}/ingresses/{ingress}/weights Ingress UpdateIngressWeightsHandler
+// Change weights of ingress path split between several services. Set of services can't be changed.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: ingress
+//    in: path
+//    type: string
+//    required: true
+//  - name: body
+//    in: body
+//    schema:
+//      $ref: '#/definitions/UpdateWeights'
+// responses:
+//  '202':
+//    description: ingress weights updated
+//    schema:
+//      $ref: '#/definitions/IngressResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *IngressHandlers) UpdateIngressWeightsHandler(ctx *gin.Context) {
+	var req ingress.UpdateWeights
+	if err := ctx.ShouldBindWith(&req, binding.JSON); err != nil {
+		ctx.AbortWithStatusJSON(h.BadRequest(ctx, err))
+		return
+	}
+
+	updatedIngress, err := h.UpdateIngressWeights(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("ingress"), req)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeResource(ctx, http.StatusAccepted, server.ETag(updatedIngress.ID, updatedIngress.Revision), updatedIngress)
 }
 
 // swagger:operation DELETE /namespaces/{namespace}/ingresses/{ingress} Ingress DeleteIngressHandler
@@ -219,3 +318,99 @@ func (h *IngressHandlers) DeleteAllIngressesHandler(ctx *gin.Context) {
 
 	ctx.Status(http.StatusAccepted)
 }
+
+// swagger:operation POST /namespaces/{namespace}/ingresses/{ingress}/acme Ingress EnableIngressACMEHandler
+// Enable automatic certificates for ingress hosts without TLS secret.
+// Issuance state is reported in "acme" field of ingress.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: ingress
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '202':
+//    description: automatic certificates enabled
+//    schema:
+//      $ref: '#/definitions/IngressResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *IngressHandlers) EnableIngressACMEHandler(ctx *gin.Context) {
+	resp, err := h.SetIngressACME(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("ingress"), true)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeResource(ctx, http.StatusAccepted, server.ETag(resp.ID, resp.Revision), resp)
+}
+
+// swagger:operation DELETE /namespaces/{namespace}/ingresses/{ingress}/acme Ingress DisableIngressACMEHandler
+// Disable automatic certificates for ingress. Issued certificate stays attached but is not renewed.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: ingress
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '202':
+//    description: automatic certificates disabled
+//    schema:
+//      $ref: '#/definitions/IngressResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *IngressHandlers) DisableIngressACMEHandler(ctx *gin.Context) {
+	resp, err := h.SetIngressACME(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("ingress"), false)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeResource(ctx, http.StatusAccepted, server.ETag(resp.ID, resp.Revision), resp)
+}
+
+// swagger:operation GET /ingress_host_conflicts Ingress GetIngressHostConflictsHandler
+// Get hosts used by more than one ingress.
+//
+// ---
+// x-method-visibility: private
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+// responses:
+//  '200':
+//    description: ingress host conflicts
+//    schema:
+//      $ref: '#/definitions/HostConflictList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *IngressHandlers) GetIngressHostConflictsHandler(ctx *gin.Context) {
+	resp, err := h.GetIngressHostConflicts(ctx.Request.Context())
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	totalCountHeader = "X-Total-Count"
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// bindListQuery parses filtering, sorting and pagination parameters of list request.
+// Responds with error and returns false if parameters are invalid.
+func bindListQuery(ctx *gin.Context, tv *m.TranslateValidate) (server.ListQuery, bool) {
+	var query server.ListQuery
+	if err := ctx.ShouldBindWith(&query, binding.Form); err != nil {
+		ctx.AbortWithStatusJSON(tv.BadRequest(ctx, err))
+		return query, false
+	}
+	return query, true
+}
+
+// writeList responds with list page, total count of matching resources and next page cursor are sent in headers
+func writeList(ctx *gin.Context, page server.ListPage, list interface{}) {
+	ctx.Header(totalCountHeader, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		ctx.Header(nextCursorHeader, page.NextCursor)
+	}
+	ctx.JSON(http.StatusOK, list)
+}
@@ -28,21 +28,46 @@ type ServiceHandlers struct {
 //    in: path
 //    type: string
 //    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListServiceType'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
 // responses:
 //  '200':
 //    description: services list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
 //    schema:
 //      $ref: '#/definitions/ServiceList'
 //  default:
 //    $ref: '#/responses/error'
 func (h *ServiceHandlers) GetServicesListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
 	resp, err := h.GetServices(ctx.Request.Context(), ctx.Param("namespace"))
 	if err != nil {
 		ctx.AbortWithStatusJSON(h.HandleError(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	list, page, err := query.QueryServices(resp, server.SortByName)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
 }
 
 // swagger:operation GET /namespaces/{namespace}/services/{service} Service GetServiceHandler
@@ -54,6 +79,7 @@ func (h *ServiceHandlers) GetServicesListHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfNoneMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -67,6 +93,8 @@ func (h *ServiceHandlers) GetServicesListHandler(ctx *gin.Context) {
 //    description: service
 //    schema:
 //     $ref: '#/definitions/ServiceResource'
+//  '304':
+//    description: resource is not modified
 //  default:
 //    $ref: '#/responses/error'
 func (h *ServiceHandlers) GetServiceHandler(ctx *gin.Context) {
@@ -77,7 +105,7 @@ func (h *ServiceHandlers) GetServiceHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	writeResource(ctx, http.StatusOK, server.ETag(resp.ID, resp.Revision), resp)
 }
 
 // swagger:operation POST /namespaces/{namespace}/services Service CreateServiceHandler
@@ -117,7 +145,7 @@ func (h *ServiceHandlers) CreateServiceHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, createdService)
+	writeResource(ctx, http.StatusCreated, server.ETag(createdService.ID, createdService.Revision), createdService)
 }
 
 // swagger:operation PUT /namespaces/{namespace}/services/{service} Service UpdateServiceHandler
@@ -129,6 +157,7 @@ func (h *ServiceHandlers) CreateServiceHandler(ctx *gin.Context) {
 //  - $ref: '#/parameters/UserIDHeader'
 //  - $ref: '#/parameters/UserRoleHeader'
 //  - $ref: '#/parameters/UserNamespaceHeader'
+//  - $ref: '#/parameters/IfMatchHeader'
 //  - name: namespace
 //    in: path
 //    type: string
@@ -162,7 +191,7 @@ func (h *ServiceHandlers) UpdateServiceHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusAccepted, updatedService)
+	writeResource(ctx, http.StatusAccepted, server.ETag(updatedService.ID, updatedService.Revision), updatedService)
 }
 
 // swagger:operation DELETE /namespaces/{namespace}/services/{service} Service DeleteServiceHandler
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"net/http"
+
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandlers struct {
+	server.TrashActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /namespaces/{namespace}/trash/deployments Trash GetDeletedDeploymentsListHandler
+// Get deleted deployments list, the latest deleted first.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListImage'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
+// responses:
+//  '200':
+//    description: deleted deployments list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
+//    schema:
+//      $ref: '#/definitions/DeploymentList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) GetDeletedDeploymentsListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
+	resp, err := h.GetDeletedDeploymentsList(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	list, page, err := query.QueryDeployments(resp, "-" + server.SortByDeletedAt)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation POST /namespaces/{namespace}/trash/deployments/{deployment}/restore Trash RestoreDeploymentHandler
+// Restore the latest deleted deployment and create it in kubernetes.
+// Inactive versions deleted together with it are restored too, except versions which numbers are used again.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: deployment
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '201':
+//    description: deployment restored
+//    schema:
+//      $ref: '#/definitions/DeploymentResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) RestoreDeploymentHandler(ctx *gin.Context) {
+	restored, err := h.RestoreDeployment(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("deployment"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, restored)
+}
+
+// swagger:operation GET /namespaces/{namespace}/trash/services Trash GetDeletedServicesListHandler
+// Get deleted services list, the latest deleted first.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListServiceType'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
+// responses:
+//  '200':
+//    description: deleted services list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
+//    schema:
+//      $ref: '#/definitions/ServiceList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) GetDeletedServicesListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
+	resp, err := h.GetDeletedServicesList(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	list, page, err := query.QueryServices(resp, "-" + server.SortByDeletedAt)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation POST /namespaces/{namespace}/trash/services/{service}/restore Trash RestoreServiceHandler
+// Restore the latest deleted service and create it in kubernetes.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: service
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '201':
+//    description: service restored
+//    schema:
+//      $ref: '#/definitions/ServiceResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) RestoreServiceHandler(ctx *gin.Context) {
+	restored, err := h.RestoreService(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("service"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, restored)
+}
+
+// swagger:operation GET /namespaces/{namespace}/trash/ingresses Trash GetDeletedIngressesListHandler
+// Get deleted ingresses list, the latest deleted first.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - $ref: '#/parameters/ListNamePrefix'
+//  - $ref: '#/parameters/ListOwner'
+//  - $ref: '#/parameters/ListDomain'
+//  - $ref: '#/parameters/ListSort'
+//  - $ref: '#/parameters/ListCursor'
+//  - $ref: '#/parameters/ListLimit'
+// responses:
+//  '200':
+//    description: deleted ingresses list
+//    headers:
+//      X-Total-Count:
+//        type: integer
+//        description: number of resources matching filters
+//      X-Next-Cursor:
+//        type: string
+//        description: cursor of next page, not set on the last page
+//    schema:
+//      $ref: '#/definitions/IngressList'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) GetDeletedIngressesListHandler(ctx *gin.Context) {
+	query, ok := bindListQuery(ctx, h.TranslateValidate)
+	if !ok {
+		return
+	}
+
+	resp, err := h.GetDeletedIngressesList(ctx.Request.Context(), ctx.Param("namespace"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	list, page, err := query.QueryIngresses(resp, "-" + server.SortByDeletedAt)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	writeList(ctx, page, list)
+}
+
+// swagger:operation POST /namespaces/{namespace}/trash/ingresses/{ingress}/restore Trash RestoreIngressHandler
+// Restore the latest deleted ingress and create it in kubernetes.
+//
+// ---
+// x-method-visibility: public
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: ingress
+//    in: path
+//    type: string
+//    required: true
+// responses:
+//  '201':
+//    description: ingress restored
+//    schema:
+//      $ref: '#/definitions/IngressResource'
+//  default:
+//    $ref: '#/responses/error'
+func (h *TrashHandlers) RestoreIngressHandler(ctx *gin.Context) {
+	restored, err := h.RestoreIngress(ctx.Request.Context(), ctx.Param("namespace"), ctx.Param("ingress"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, restored)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// period of SSE comments and websocket pings keeping idle connection open through proxies
+	watchKeepAlive = 30 * time.Second
+	watchWriteWait = 10 * time.Second
+)
+
+var watchUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type WatchHandlers struct {
+	server.WatchActions
+	*m.TranslateValidate
+}
+
+// swagger:operation GET /namespaces/{namespace}/watch Watch WatchHandler
+// Watch changes of deployments, services and ingresses in namespace.
+// Events are streamed as Server-Sent Events, event name is event type and data is event JSON.
+// If request is websocket upgrade, events are sent as JSON text messages.
+// To resume after reconnection pass ID of last received event. If events after it are not kept anymore, "reset" event is sent first and resources should be listed again.
+//
+// ---
+// x-method-visibility: public
+// produces:
+//  - text/event-stream
+// parameters:
+//  - $ref: '#/parameters/UserIDHeader'
+//  - $ref: '#/parameters/UserRoleHeader'
+//  - $ref: '#/parameters/UserNamespaceHeader'
+//  - name: namespace
+//    in: path
+//    type: string
+//    required: true
+//  - name: Last-Event-ID
+//    in: header
+//    type: string
+//    required: false
+//  - name: last_event_id
+//    in: query
+//    type: string
+//    required: false
+//    description: used if Last-Event-ID header is not set, e.g. for websocket
+// responses:
+//  '200':
+//    description: events stream
+//    schema:
+//      $ref: '#/definitions/Event'
+//  '101':
+//    description: switched to websocket
+//  default:
+//    $ref: '#/responses/error'
+func (h *WatchHandlers) WatchHandler(ctx *gin.Context) {
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("last_event_id")
+	}
+
+	events, err := h.Watch(ctx.Request.Context(), ctx.Param("namespace"), lastEventID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(h.HandleError(err))
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		watchWebsocket(ctx, events)
+		return
+	}
+	watchSSE(ctx, events)
+}
+
+func watchSSE(ctx *gin.Context, events <-chan event.Event) {
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no") // disable nginx response buffering
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	// stream ends when client disconnects or events channel is closed
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			ctx.Render(-1, sse.Event{Id: ev.ID, Event: string(ev.Type), Data: ev})
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func watchWebsocket(ctx *gin.Context, events <-chan event.Event) {
+	conn, err := watchUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// upgrader already responded with error
+		logrus.WithError(err).Debugln("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	// client messages are not expected, reading is needed to process control frames and detect close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "watch stopped, resume from last event"),
+					time.Now().Add(watchWriteWait))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(watchWriteWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
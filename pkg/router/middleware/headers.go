@@ -5,8 +5,6 @@ import (
 
 	"net/textproto"
 
-	"encoding/base64"
-
 	"errors"
 
 	"git.containerum.net/ch/resource-service/pkg/models/headers"
@@ -14,7 +12,6 @@ import (
 	"github.com/containerum/cherry/adaptors/gonic"
 	"github.com/containerum/utils/httputil"
 	"github.com/gin-gonic/gin"
-	"github.com/json-iterator/go"
 	"github.com/sirupsen/logrus"
 )
 
@@ -88,16 +85,10 @@ func checkUserNamespace(userNamespace string) (*UserHeaderDataMap, error) {
 
 //ParseUserHeaderData decodes headers for substitutions
 func ParseUserHeaderData(str string) (*UserHeaderDataMap, error) {
-	data, err := base64.StdEncoding.DecodeString(str)
-	if err != nil {
-		logrus.WithError(err).WithField("Value", str).Warn("unable to decode user header data")
-		return nil, errors.New("unable to decode user header data")
-	}
-	var userData []headers.UserHeaderData
-	err = jsoniter.Unmarshal(data, &userData)
+	userData, err := headers.DecodeUserHeaderData(str)
 	if err != nil {
-		logrus.WithError(err).WithField("Value", string(data)).Warn("unable to unmarshal user header data")
-		return nil, errors.New("unable to unmarshal user header data")
+		logrus.WithError(err).WithField("Value", str).Warn("unable to parse user header data")
+		return nil, err
 	}
 	result := UserHeaderDataMap{}
 	for _, v := range userData {
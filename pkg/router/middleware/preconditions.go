@@ -0,0 +1,13 @@
+package middleware
+
+import (
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/gin-gonic/gin"
+)
+
+// SavePreconditions saves If-Match header to request context, so update actions can reject stale writes
+func SavePreconditions(ctx *gin.Context) {
+	if ifMatch := GetHeader(ctx, "If-Match"); ifMatch != "" {
+		ctx.Request = ctx.Request.WithContext(server.WithIfMatch(ctx.Request.Context(), ifMatch))
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"git.containerum.net/ch/resource-service/pkg/clients"
 	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/metrics"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	h "git.containerum.net/ch/resource-service/pkg/router/handlers"
 	m "git.containerum.net/ch/resource-service/pkg/router/middleware"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
@@ -24,25 +26,47 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func CreateRouter(mongo *db.MongoStorage, permissions *clients.Permissions, kube *clients.Kube, tv *m.TranslateValidate, enableCORS bool) http.Handler {
+// CreateRouter creates resource-service router. acme may be nil if automatic certificates are not configured.
+func CreateRouter(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube, dns *clients.DNS, acme *impl.ACMEActionsImpl, ingressSuffixes ingress.HostSuffixList, watcher db.Watcher, drift *impl.DriftActionsImpl, tv *m.TranslateValidate, enableCORS bool) http.Handler {
 	e := gin.New()
+	e.GET("/metrics", gin.WrapH(metrics.Handler())) // scraped by prometheus without user headers
+	if acme != nil {
+		acmeHandlersSetup(e, tv, acme) // challenges are requested by certificate authority without user headers
+	}
 	initMiddlewares(e, tv, enableCORS)
-	deployHandlersSetup(e, tv, impl.NewDeployActionsImpl(mongo, permissions, kube))
-	domainHandlersSetup(e, tv, impl.NewDomainActionsImpl(mongo))
-	ingressHandlersSetup(e, tv, impl.NewIngressActionsImpl(mongo, kube))
-	serviceHandlersSetup(e, tv, impl.NewServiceActionsImpl(mongo, permissions, kube))
-	resourceCountHandlersSetup(e, tv, impl.NewResourcesActionsImpl(mongo))
+	deployHandlersSetup(e, tv, impl.NewDeployActionsImpl(storage, permissions, kube))
+	domainHandlersSetup(e, tv, impl.NewDomainActionsImpl(storage))
+	ingressHandlersSetup(e, tv, impl.NewIngressActionsImpl(storage, permissions, kube, ingressSuffixes, acme))
+	serviceHandlersSetup(e, tv, impl.NewServiceActionsImpl(storage, permissions, kube))
+	customDomainHandlersSetup(e, tv, impl.NewCustomDomainActionsImpl(storage, dns))
+	certificateHandlersSetup(e, tv, impl.NewCertificateActionsImpl(storage, kube))
+	resourceCountHandlersSetup(e, tv, impl.NewResourcesActionsImpl(storage, kube))
+	trashHandlersSetup(e, tv, impl.NewTrashActionsImpl(storage, permissions, kube))
+	watchHandlersSetup(e, tv, impl.NewWatchActionsImpl(watcher))
+	bundleHandlersSetup(e, tv, impl.NewBundleActionsImpl(storage, permissions, kube, ingressSuffixes, acme))
+	driftHandlersSetup(e, tv, drift)
+	adoptHandlersSetup(e, tv, impl.NewAdoptActionsImpl(storage, kube))
 
 	return e
 }
 
+// CreateACMEChallengeRouter creates router serving only ACME HTTP-01 challenges, e.g. on port 80
+func CreateACMEChallengeRouter(acme *impl.ACMEActionsImpl, tv *m.TranslateValidate) http.Handler {
+	e := gin.New()
+	e.Use(gonic.Recovery(rserrors.ErrInternal, cherrylog.NewLogrusAdapter(logrus.WithField("component", "gin_recovery"))))
+	e.Use(ginrus.Ginrus(logrus.StandardLogger(), time.RFC3339, true))
+	acmeHandlersSetup(e, tv, acme)
+	return e
+}
+
 func initMiddlewares(e gin.IRouter, tv *m.TranslateValidate, enableCORS bool) {
 	/* CORS */
 	if enableCORS {
 		cfg := cors.DefaultConfig()
 		cfg.AllowAllOrigins = true
 		cfg.AddAllowMethods(http.MethodDelete)
-		cfg.AddAllowHeaders(httputil.UserRoleXHeader, httputil.UserIDXHeader, httputil.UserNamespacesXHeader)
+		cfg.AddAllowHeaders(httputil.UserRoleXHeader, httputil.UserIDXHeader, httputil.UserNamespacesXHeader, "If-Match", "If-None-Match", "Last-Event-ID")
+		cfg.AddExposeHeaders("ETag", "X-Total-Count", "X-Next-Cursor")
 		e.Use(cors.New(cfg))
 	}
 	e.Group("/static").
@@ -60,6 +84,7 @@ func initMiddlewares(e gin.IRouter, tv *m.TranslateValidate, enableCORS bool) {
 	}))
 	e.Use(httputil.SubstituteUserMiddleware(tv.Validate, tv.UniversalTranslator, rserrors.ErrValidation))
 	e.Use(m.RequiredUserHeaders())
+	e.Use(m.SavePreconditions)
 }
 
 func deployHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.DeployActions) {
@@ -106,6 +131,10 @@ func domainHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend se
 func ingressHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.IngressActions) {
 	ingressHandlers := h.IngressHandlers{IngressActions: backend, TranslateValidate: tv}
 
+	// suffixes are available to every user, domain groups and their IPs are returned to admins only
+	router.GET("/ingress_suffixes", ingressHandlers.GetIngressSuffixesHandler)
+	router.GET("/ingress_host_conflicts", httputil.RequireAdminRole(rserrors.ErrPermissionDenied), ingressHandlers.GetIngressHostConflictsHandler)
+
 	ingress := router.Group("/namespaces/:namespace/ingresses")
 	{
 		ingress.GET("", m.ReadAccess, ingressHandlers.GetIngressesListHandler)
@@ -114,6 +143,10 @@ func ingressHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend s
 		ingress.POST("", m.WriteAccess, ingressHandlers.CreateIngressHandler)
 
 		ingress.PUT("/:ingress", m.WriteAccess, ingressHandlers.UpdateIngressHandler)
+		ingress.PUT("/:ingress/weights", m.WriteAccess, ingressHandlers.UpdateIngressWeightsHandler)
+
+		ingress.POST("/:ingress/acme", m.WriteAccess, ingressHandlers.EnableIngressACMEHandler)
+		ingress.DELETE("/:ingress/acme", m.WriteAccess, ingressHandlers.DisableIngressACMEHandler)
 
 		ingress.DELETE("/:ingress", m.WriteAccess, ingressHandlers.DeleteIngressHandler)
 		ingress.DELETE("", ingressHandlers.DeleteAllIngressesHandler)
@@ -137,9 +170,85 @@ func serviceHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend s
 	}
 }
 
+func customDomainHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.CustomDomainActions) {
+	customDomainHandlers := h.CustomDomainHandlers{CustomDomainActions: backend, TranslateValidate: tv}
+
+	customDomain := router.Group("/namespaces/:namespace/custom_domains")
+	{
+		customDomain.GET("", m.ReadAccess, customDomainHandlers.GetCustomDomainsListHandler)
+		customDomain.GET("/:domain", m.ReadAccess, customDomainHandlers.GetCustomDomainHandler)
+
+		customDomain.POST("", m.WriteAccess, customDomainHandlers.AddCustomDomainHandler)
+		customDomain.POST("/:domain/verify", m.WriteAccess, customDomainHandlers.VerifyCustomDomainHandler)
+
+		customDomain.DELETE("/:domain", m.WriteAccess, customDomainHandlers.DeleteCustomDomainHandler)
+	}
+}
+
+func acmeHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.ACMEActions) {
+	acmeHandlers := h.ACMEHandlers{ACMEActions: backend, TranslateValidate: tv}
+
+	router.GET("/.well-known/acme-challenge/:token", acmeHandlers.GetACMEChallengeHandler)
+}
+
+func certificateHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.CertificateActions) {
+	certificateHandlers := h.CertificateHandlers{CertificateActions: backend, TranslateValidate: tv}
+
+	cert := router.Group("/namespaces/:namespace/certificates")
+	{
+		cert.GET("", m.ReadAccess, certificateHandlers.GetCertificatesListHandler)
+		cert.GET("/:certificate", m.ReadAccess, certificateHandlers.GetCertificateHandler)
+
+		cert.POST("", m.WriteAccess, certificateHandlers.CreateCertificateHandler)
+
+		cert.DELETE("/:certificate", m.WriteAccess, certificateHandlers.DeleteCertificateHandler)
+	}
+}
+
 func resourceCountHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.ResourcesActions) {
 	resourceHandlers := h.ResourceHandlers{ResourcesActions: backend, TranslateValidate: tv}
 	router.DELETE("/namespaces/:namespace", resourceHandlers.DeleteAllResourcesInNamespaceHandler)
 	router.DELETE("/namespaces", resourceHandlers.DeleteAllResourcesHandler)
 	router.GET("/resources", resourceHandlers.GetResourcesCountHandler)
 }
+
+func trashHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.TrashActions) {
+	trashHandlers := h.TrashHandlers{TrashActions: backend, TranslateValidate: tv}
+
+	trash := router.Group("/namespaces/:namespace/trash")
+	{
+		trash.GET("/deployments", m.ReadAccess, trashHandlers.GetDeletedDeploymentsListHandler)
+		trash.GET("/services", m.ReadAccess, trashHandlers.GetDeletedServicesListHandler)
+		trash.GET("/ingresses", m.ReadAccess, trashHandlers.GetDeletedIngressesListHandler)
+
+		trash.POST("/deployments/:deployment/restore", m.WriteAccess, trashHandlers.RestoreDeploymentHandler)
+		trash.POST("/services/:service/restore", m.WriteAccess, trashHandlers.RestoreServiceHandler)
+		trash.POST("/ingresses/:ingress/restore", m.WriteAccess, trashHandlers.RestoreIngressHandler)
+	}
+}
+
+func watchHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.WatchActions) {
+	watchHandlers := h.WatchHandlers{WatchActions: backend, TranslateValidate: tv}
+
+	router.GET("/namespaces/:namespace/watch", m.ReadAccess, watchHandlers.WatchHandler)
+}
+
+func bundleHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.BundleActions) {
+	bundleHandlers := h.BundleHandlers{BundleActions: backend, TranslateValidate: tv}
+
+	router.GET("/namespaces/:namespace/export", m.ReadAccess, bundleHandlers.ExportNamespaceHandler)
+	router.POST("/namespaces/:namespace/import", m.WriteAccess, bundleHandlers.ImportNamespaceHandler)
+	router.POST("/namespaces/:namespace/import/manifests", m.WriteAccess, bundleHandlers.ImportManifestsHandler)
+}
+
+func driftHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.DriftActions) {
+	driftHandlers := h.DriftHandlers{DriftActions: backend, TranslateValidate: tv}
+
+	router.GET("/drift", httputil.RequireAdminRole(rserrors.ErrPermissionDenied), driftHandlers.GetDriftHandler)
+}
+
+func adoptHandlersSetup(router gin.IRouter, tv *m.TranslateValidate, backend server.AdoptActions) {
+	adoptHandlers := h.AdoptHandlers{AdoptActions: backend, TranslateValidate: tv}
+
+	router.POST("/namespaces/:namespace/adopt", httputil.RequireAdminRole(rserrors.ErrPermissionDenied), adoptHandlers.AdoptNamespaceHandler)
+}
@@ -238,6 +238,133 @@ func ErrOnlyOneDeploymentVersion(params ...func(*cherry.Err)) *cherry.Err {
 	}
 	return err
 }
+func ErrDomainNotVerified(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Domain ownership is not verified", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x15}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrDomainVerificationFailed(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Domain ownership verification failed", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x16}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrDomainHasIngresses(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Can`t delete domain used by ingresses", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x17}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrInvalidCertificate(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Invalid TLS certificate", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x18}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrCertificateInUse(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Can`t delete certificate used by ingresses", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x19}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrACMENotConfigured(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Automatic certificates are not configured", StatusHTTP: 400, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1a}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrIngressHostConflict(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Ingress host is already used", StatusHTTP: 409, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1b}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrWildcardHostsNotAllowed(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Wildcard hosts are not allowed in namespace", StatusHTTP: 403, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1c}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+func ErrPortAlreadyUsed(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Port is already used", StatusHTTP: 409, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1d}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+
+// ErrResourceModified error
+// revision of stored resource differs from expected
+func ErrResourceModified(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Resource was modified by another request", StatusHTTP: 412, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1e}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
+
+// ErrServiceUnavailable error
+// requests to service are rejected by circuit breaker after repeated failures
+func ErrServiceUnavailable(params ...func(*cherry.Err)) *cherry.Err {
+	err := &cherry.Err{Message: "Service is temporarily unavailable", StatusHTTP: 503, ID: cherry.ErrID{SID: "resource-service", Kind: 0x1f}, Details: []string(nil), Fields: cherry.Fields(nil)}
+	for _, param := range params {
+		param(err)
+	}
+	for i, detail := range err.Details {
+		det := renderTemplate(detail)
+		err.Details[i] = det
+	}
+	return err
+}
 func renderTemplate(templText string) string {
 	buf := &bytes.Buffer{}
 	templ, err := template.New("").Parse(templText)
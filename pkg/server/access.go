@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+
+	"git.containerum.net/ch/resource-service/pkg/models/headers"
+	"github.com/containerum/utils/httputil"
+)
+
+// IsAdmin checks if request is made by admin. Requests of other services are made on behalf of admin too.
+func IsAdmin(ctx context.Context) bool {
+	return httputil.MustGetUserRole(ctx) != "user"
+}
+
+// CanReadNamespace checks if user from request context has read access to namespace. Admins can read any namespace.
+func CanReadNamespace(ctx context.Context, nsID string) bool {
+	if IsAdmin(ctx) {
+		return true
+	}
+	namespaces, err := headers.DecodeUserHeaderData(httputil.RequestHeaders(ctx).Get(httputil.UserNamespacesXHeader))
+	if err != nil {
+		return false
+	}
+	for _, ns := range namespaces {
+		if ns.ID == nsID {
+			return ns.CanRead()
+		}
+	}
+	return false
+}
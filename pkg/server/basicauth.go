@@ -0,0 +1,27 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+)
+
+const sshaSaltLength = 8
+
+// HTPasswd generates htpasswd file for basic auth users with salted SHA-1 ({SSHA}) password hashes supported by nginx
+func HTPasswd(users []ingress.BasicAuthUser) (string, error) {
+	var lines = make([]string, 0, len(users))
+	for _, user := range users {
+		var salt = make([]byte, sshaSaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		hash := sha1.Sum(append([]byte(user.Password), salt...))
+		lines = append(lines, fmt.Sprintf("%s:{SSHA}%s", user.Username, base64.StdEncoding.EncodeToString(append(hash[:], salt...))))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
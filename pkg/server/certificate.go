@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+)
+
+// ParseCertificate parses PEM certificate chain and private key.
+// It checks that key matches leaf certificate, every certificate in chain is signed by the next one
+// and all certificates are valid at the moment.
+func ParseCertificate(certPEM, keyPEM string, moment time.Time) ([]*x509.Certificate, error) {
+	keyPair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, rserrors.ErrInvalidCertificate().AddDetailsErr(err)
+	}
+
+	var chain = make([]*x509.Certificate, 0, len(keyPair.Certificate))
+	for _, der := range keyPair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, rserrors.ErrInvalidCertificate().AddDetailsErr(err)
+		}
+		chain = append(chain, cert)
+	}
+
+	for i, cert := range chain {
+		if moment.Before(cert.NotBefore) {
+			return nil, rserrors.ErrInvalidCertificate().AddDetailF("certificate %q is not valid before %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339))
+		}
+		if moment.After(cert.NotAfter) {
+			return nil, rserrors.ErrInvalidCertificate().AddDetailF("certificate %q expired at %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+		}
+		if i+1 < len(chain) {
+			if err := cert.CheckSignatureFrom(chain[i+1]); err != nil {
+				return nil, rserrors.ErrInvalidCertificate().AddDetailF("certificate %q is not signed by %q: %v", cert.Subject.CommonName, chain[i+1].Subject.CommonName, err)
+			}
+		}
+	}
+
+	if len(chain[0].DNSNames) == 0 {
+		return nil, rserrors.ErrInvalidCertificate().AddDetails("certificate has no DNS names in subject alternative names")
+	}
+
+	return chain, nil
+}
+
+// CertificateMetadata extracts certificate metadata stored in db from leaf certificate
+func CertificateMetadata(leaf *x509.Certificate) certificate.Certificate {
+	fingerprint := sha256.Sum256(leaf.Raw)
+	return certificate.Certificate{
+		Hosts:        append([]string(nil), leaf.DNSNames...),
+		Issuer:       leaf.Issuer.CommonName,
+		SerialNumber: leaf.SerialNumber.Text(16),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		NotBefore:    leaf.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:     leaf.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
+
+// CheckCertificateHost checks that certificate may be used for ingress host at the moment
+func CheckCertificateHost(cert certificate.Certificate, host string, moment time.Time) error {
+	if !cert.Covers(host) {
+		return rserrors.ErrInvalidCertificate().AddDetailF("certificate %s does not cover host %s", cert.Name, host)
+	}
+	if !cert.ValidAt(moment) {
+		return rserrors.ErrInvalidCertificate().AddDetailF("certificate %s is not valid now, validity period %s - %s", cert.Name, cert.NotBefore, cert.NotAfter)
+	}
+	return nil
+}
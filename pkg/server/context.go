@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/containerum/utils/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BackgroundContext prepares context for actions which are not caused by user request, e.g. periodic jobs.
+// Context carries the same values as request context of admin acting on behalf of user so clients may pass headers to other services.
+func BackgroundContext(ctx context.Context, userID string) context.Context {
+	var header = make(http.Header)
+	header.Set(httputil.UserIDXHeader, userID)
+	header.Set(httputil.UserRoleXHeader, "admin")
+	header.Set(httputil.RequestIDXHeader, uuid.New().String())
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header = header
+	var gctx = &gin.Context{Request: req.WithContext(ctx)}
+	httputil.SaveHeaders(gctx)
+	httputil.PrepareContext(gctx)
+	return gctx.Request.Context()
+}
@@ -0,0 +1,288 @@
+package impl
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	acmeCertificatePrefix = "acme-"
+	// acmeLockName -- storage lock name, only one replica issues certificates at once
+	acmeLockName = "acme"
+	// acmeLockTTL -- lock of crashed replica is taken by another one after this time
+	acmeLockTTL = 10 * time.Minute
+	// acmeRetryMin and acmeRetryMax limit exponential backoff after failed issuance
+	acmeRetryMin = 15 * time.Minute
+	acmeRetryMax = 24 * time.Hour
+)
+
+type ACMEActionsImpl struct {
+	acme        clients.ACME
+	kube        clients.Kube
+	storage     db.Storage
+	certs       *CertificateActionsImpl
+	renewBefore time.Duration
+	trigger     chan struct{}
+	lockOwner   string
+	log         *cherrylog.LogrusAdapter
+}
+
+func NewACMEActionsImpl(storage db.Storage, kube *clients.Kube, acme *clients.ACME, renewBefore time.Duration) *ACMEActionsImpl {
+	return &ACMEActionsImpl{
+		acme:        *acme,
+		kube:        *kube,
+		storage:     storage,
+		certs:       NewCertificateActionsImpl(storage, kube),
+		renewBefore: renewBefore,
+		trigger:     make(chan struct{}, 1),
+		lockOwner:   uuid.New().String(),
+		log:         cherrylog.NewLogrusAdapter(logrus.WithField("component", "acme_actions")),
+	}
+}
+
+func (aa *ACMEActionsImpl) GetACMEChallengeResponse(ctx context.Context, host, token string) (string, error) {
+	aa.log.WithFields(logrus.Fields{
+		"host":  host,
+		"token": token,
+	}).Info("get acme challenge response")
+
+	challenge, err := aa.storage.GetACMEChallenge(token)
+	if err != nil {
+		return "", err
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if host != "" && host != challenge.Host {
+		return "", rserrors.ErrResourceNotExists().AddDetails(token)
+	}
+
+	return challenge.KeyAuth, nil
+}
+
+// Present publishes HTTP-01 challenge response, it implements clients.ACMEChallengeResponder
+func (aa *ACMEActionsImpl) Present(ctx context.Context, host, token, keyAuth string) error {
+	return aa.storage.CreateACMEChallenge(certificate.ACMEChallenge{
+		Token:     token,
+		Host:      host,
+		KeyAuth:   keyAuth,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// CleanUp removes HTTP-01 challenge response, it implements clients.ACMEChallengeResponder
+func (aa *ACMEActionsImpl) CleanUp(ctx context.Context, token string) error {
+	return aa.storage.DeleteACMEChallenge(token)
+}
+
+// Trigger wakes up issuance loop without waiting for next period
+func (aa *ACMEActionsImpl) Trigger() {
+	select {
+	case aa.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run issues and renews certificates for ingresses with automatic certificates enabled until context is done
+func (aa *ACMEActionsImpl) Run(ctx context.Context, period time.Duration) {
+	aa.log.WithField("period", period).Info("starting acme issuance loop")
+	var ticker = time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		aa.processIngresses(ctx)
+		select {
+		case <-ctx.Done():
+			aa.log.Info("stopping acme issuance loop")
+			if err := aa.storage.ReleaseLock(acmeLockName, aa.lockOwner); err != nil {
+				aa.log.WithError(err).Warn("unable to release acme lock")
+			}
+			return
+		case <-ticker.C:
+		case <-aa.trigger:
+		}
+	}
+}
+
+// acquireLock takes or prolongs issuance lock, loop is skipped while lock is held by another replica
+func (aa *ACMEActionsImpl) acquireLock() bool {
+	acquired, err := aa.storage.AcquireLock(acmeLockName, aa.lockOwner, acmeLockTTL)
+	if err != nil {
+		aa.log.WithError(err).Error("unable to acquire acme lock")
+		return false
+	}
+	if !acquired {
+		aa.log.Debug("acme lock is held by another replica")
+	}
+	return acquired
+}
+
+func (aa *ACMEActionsImpl) processIngresses(ctx context.Context) {
+	if !aa.acquireLock() {
+		return
+	}
+	ingresses, err := aa.storage.GetACMEIngressList()
+	if err != nil {
+		aa.log.WithError(err).Error("unable to get ingresses with automatic certificates")
+		return
+	}
+	for _, ingr := range ingresses {
+		// issuance may take long, lock is prolonged so it doesn't expire
+		if ctx.Err() != nil || !aa.acquireLock() {
+			return
+		}
+		if err := aa.processIngress(ctx, ingr); err != nil {
+			aa.log.WithError(err).WithFields(logrus.Fields{
+				"ns_id":   ingr.NamespaceID,
+				"ingress": ingr.Name,
+			}).Error("unable to issue certificate")
+		}
+	}
+}
+
+func (aa *ACMEActionsImpl) processIngress(ctx context.Context, ingr ingress.IngressResource) error {
+	var now = time.Now().UTC()
+	var hosts = ingr.ACMEHosts()
+	if len(hosts) == 0 || !aa.needsCertificate(*ingr.ACME, hosts, now) {
+		return nil
+	}
+
+	aa.log.WithFields(logrus.Fields{
+		"ns_id":   ingr.NamespaceID,
+		"ingress": ingr.Name,
+		"hosts":   hosts,
+	}).Info("issuing certificate")
+
+	ctx = server.BackgroundContext(ctx, ingr.Owner)
+
+	var status = *ingr.ACME
+	cert, err := aa.issueCertificate(ctx, ingr, hosts, now)
+	if err != nil {
+		status.State = ingress.ACMEFailed
+		status.Error = err.Error()
+		status.Failures++
+		status.NextAttemptAt = now.Add(acmeRetryDelay(status.Failures)).Format(time.RFC3339)
+	} else {
+		status.State = ingress.ACMEIssued
+		status.Error = ""
+		status.Failures = 0
+		status.NextAttemptAt = ""
+		status.Hosts = hosts
+		status.Certificate = cert.Name
+		status.NotAfter = cert.NotAfter
+	}
+	status.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	ingr.ACME = &status
+
+	if updErr := aa.storage.UpdateIngressACME(ingr); updErr != nil {
+		return updErr
+	}
+	return err
+}
+
+// acmeRetryDelay doubles delay after every failure in a row, so failing orders don't hit CA rate limits
+func acmeRetryDelay(failures int) time.Duration {
+	var delay = acmeRetryMin
+	for i := 1; i < failures && delay < acmeRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > acmeRetryMax {
+		delay = acmeRetryMax
+	}
+	return delay
+}
+
+func (aa *ACMEActionsImpl) needsCertificate(status ingress.ACMEStatus, hosts []string, now time.Time) bool {
+	if status.State == ingress.ACMEFailed && status.NextAttemptAt != "" {
+		if next, err := time.Parse(time.RFC3339, status.NextAttemptAt); err == nil && now.Before(next) {
+			return false
+		}
+	}
+	if status.State != ingress.ACMEIssued || len(status.Hosts) != len(hosts) {
+		return true
+	}
+	for i := range hosts {
+		if status.Hosts[i] != hosts[i] {
+			return true
+		}
+	}
+	notAfter, err := time.Parse(time.RFC3339, status.NotAfter)
+	if err != nil {
+		return true
+	}
+	return now.Add(aa.renewBefore).After(notAfter)
+}
+
+// issueCertificate obtains certificate, stores it and attaches to ingress rules replacing previous automatic certificate
+func (aa *ACMEActionsImpl) issueCertificate(ctx context.Context, ingr ingress.IngressResource, hosts []string, now time.Time) (certificate.Certificate, error) {
+	certPEM, keyPEM, err := aa.acme.ObtainCertificate(ctx, hosts, aa)
+	if err != nil {
+		return certificate.Certificate{}, err
+	}
+
+	chain, err := server.ParseCertificate(certPEM, keyPEM, now)
+	if err != nil {
+		return certificate.Certificate{}, err
+	}
+
+	cert := server.CertificateMetadata(chain[0])
+	cert.Name = acmeCertificatePrefix + cert.Fingerprint[:16]
+	cert.NamespaceID = ingr.NamespaceID
+	cert.Owner = ingr.Owner
+	cert.CreatedAt = now.Format(time.RFC3339)
+
+	// ingress may be changed by user while certificate was being issued
+	current, err := aa.storage.GetIngress(ingr.NamespaceID, ingr.Name)
+	if err != nil {
+		return certificate.Certificate{}, err
+	}
+
+	created, err := aa.certs.storeCertificate(ctx, cert, certPEM, keyPEM)
+	if err != nil {
+		return created, err
+	}
+
+	updated := current.Copy()
+	updated.SetTLSSecret(created.Name, hosts)
+
+	if err := aa.certs.journal.execute(ctx, operation.Operation{
+		Kind:        operation.UpdateIngress,
+		NamespaceID: updated.NamespaceID,
+		Name:        updated.Name,
+		Ingress:     &updated,
+		OldIngress:  &current,
+	}, func() error {
+		_, err := aa.storage.UpdateIngress(updated)
+		return err
+	}); err != nil {
+		aa.removeCertificate(ctx, created.NamespaceID, created.Name)
+		return created, err
+	}
+
+	if old := ingr.ACME.Certificate; old != "" && old != created.Name {
+		aa.removeCertificate(ctx, ingr.NamespaceID, old)
+	}
+
+	return created, nil
+}
+
+func (aa *ACMEActionsImpl) removeCertificate(ctx context.Context, nsID, certName string) {
+	if err := aa.storage.DeleteCertificate(nsID, certName); err != nil {
+		aa.log.WithError(err).WithField("certificate", certName).Warn("unable to delete certificate")
+	}
+	if err := aa.kube.DeleteSecret(ctx, nsID, certName); err != nil {
+		aa.log.WithError(err).WithField("certificate", certName).Warn("unable to delete certificate secret")
+	}
+}
@@ -0,0 +1,70 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingACME counts orders and rejects all of them
+type failingACME struct {
+	orders int
+}
+
+func (acme *failingACME) ObtainCertificate(ctx context.Context, hosts []string, _ clients.ACMEChallengeResponder) (string, string, error) {
+	acme.orders++
+	return "", "", errors.New("rate limited")
+}
+
+func TestACMEBackoff(t *testing.T) {
+	var storage = db.NewMemory()
+	var kube clients.Kube = clients.NewFakeKube()
+	var stub = &failingACME{}
+	var client clients.ACME = stub
+	var actions = NewACMEActionsImpl(storage, &kube, &client, time.Hour)
+
+	_, err := storage.CreateIngress(ingress.IngressResource{NamespaceID: "ns",
+		ACME: &ingress.ACMEStatus{Enabled: true, State: ingress.ACMEPending},
+		Ingress: model.Ingress{Name: "web", Owner: "owner", Rules: []model.Rule{
+			{Host: "web.example.com", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 80}}}}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// failed issuance is not retried until backoff passes
+	actions.processIngresses(context.Background())
+	actions.processIngresses(context.Background())
+	assert.Equal(t, 1, stub.orders)
+	ingr, err := storage.GetIngress("ns", "web")
+	if assert.NoError(t, err) {
+		assert.Equal(t, ingress.ACMEFailed, ingr.ACME.State)
+		assert.Equal(t, 1, ingr.ACME.Failures)
+		assert.True(t, actions.needsCertificate(*ingr.ACME, ingr.ACMEHosts(), time.Now().Add(acmeRetryMin+time.Second)))
+	}
+
+	assert.Equal(t, acmeRetryMin, acmeRetryDelay(1))
+	assert.Equal(t, 4*acmeRetryMin, acmeRetryDelay(3))
+	assert.Equal(t, acmeRetryMax, acmeRetryDelay(100))
+
+	// enabling again resets backoff
+	assert.NoError(t, storage.UpdateIngressACME(ingress.IngressResource{NamespaceID: "ns", Ingress: model.Ingress{Name: "web"},
+		ACME: &ingress.ACMEStatus{Enabled: true, State: ingress.ACMEPending}}))
+	// lock held by another replica skips issuance
+	assert.NoError(t, storage.ReleaseLock(acmeLockName, actions.lockOwner))
+	acquired, err := storage.AcquireLock(acmeLockName, "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	actions.processIngresses(context.Background())
+	assert.Equal(t, 1, stub.orders)
+
+	assert.NoError(t, storage.ReleaseLock(acmeLockName, "other"))
+	actions.processIngresses(context.Background())
+	assert.Equal(t, 2, stub.orders)
+}
@@ -0,0 +1,246 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/adoption"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/blang/semver"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+// AdoptActionsImpl creates records for kube-api resources created behind resource-service
+type AdoptActionsImpl struct {
+	storage db.Storage
+	kube    clients.Kube
+	log     *cherrylog.LogrusAdapter
+}
+
+func NewAdoptActionsImpl(storage db.Storage, kube *clients.Kube) *AdoptActionsImpl {
+	return &AdoptActionsImpl{
+		storage: storage,
+		kube:    *kube,
+		log:     cherrylog.NewLogrusAdapter(logrus.WithField("component", "adopt_actions")),
+	}
+}
+
+// adoptedNames -- names of resources with active records, including adopted ones
+type adoptedNames map[drift.Kind]map[string]bool
+
+func (names adoptedNames) add(kind drift.Kind, name string) {
+	if names[kind] == nil {
+		names[kind] = make(map[string]bool)
+	}
+	names[kind][name] = true
+}
+
+// AdoptNamespace creates records of version 1.0.0 for kube-api deployments, services and ingresses without active records.
+// Only storage is written, kube-api resources are not changed. External ports of adopted services are reserved.
+// Resources which can't be represented by records are skipped and reported.
+func (aa *AdoptActionsImpl) AdoptNamespace(ctx context.Context, nsID, owner string, dryRun bool) (*adoption.Result, error) {
+	userID := httputil.MustGetUserID(ctx)
+	aa.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"owner":   owner,
+		"dry_run": dryRun,
+	}).Info("adopt namespace")
+
+	deployments, err := aa.kube.GetDeploymentList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	services, err := aa.kube.GetServiceList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	ingresses, err := aa.kube.GetIngressList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	known, err := aa.activeNames(nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = adoption.Result{
+		NamespaceID: nsID,
+		DryRun:      dryRun,
+		Resources:   make([]adoption.Resource, 0, len(deployments)+len(services)+len(ingresses)),
+	}
+	var createdAt = time.Now().UTC().Format(time.RFC3339)
+	// adopt runs storage write unless it is dry run and reports result of resource
+	var adopt = func(res adoption.Resource, store func() error) {
+		var log = aa.log.WithFields(logrus.Fields{
+			"ns_id": nsID,
+			"kind":  res.Kind,
+			"name":  res.Name,
+		})
+		switch {
+		case known[res.Kind][res.Name]:
+			res.Status, res.Error, res.Unsupported = adoption.Exists, nil, nil
+		case res.Error == nil && !dryRun:
+			res.Error = adoptionError(store())
+		}
+		if res.Status == "" && res.Error == nil {
+			res.Status = adoption.Adopted
+			known.add(res.Kind, res.Name)
+			log.WithField("unsupported", res.Unsupported).Info("resource adopted")
+		} else if res.Status == "" {
+			res.Status = adoption.Skipped
+			log.WithError(res.Error).Warn("resource is not adopted")
+		}
+		result.Resources = append(result.Resources, res)
+	}
+
+	// dependency order: services refer to deployments, ingresses refer to services
+	for _, depl := range deployments {
+		var res = adoption.Resource{Kind: drift.KindDeployment, Name: depl.Name}
+		for _, container := range depl.Containers {
+			if container.Limits.CPU == 0 || container.Limits.Memory == 0 {
+				res.Error = rserrors.ErrValidation().AddDetailF("container %q has no CPU or memory limits", container.Name)
+			}
+		}
+		depl.Status = nil
+		depl.Version = semver.MustParse("1.0.0")
+		depl.Active = true
+		server.CalculateDeployResources(&depl)
+		var record = deployment.DeploymentFromKube(nsID, owner, depl)
+		record.CreatedAt = &createdAt
+		adopt(res, func() error {
+			_, err := aa.storage.CreateDeployment(record)
+			return err
+		})
+	}
+
+	// domain/protocol/port -> service name, ports of services adopted in dry run are not stored
+	var reserved = make(map[string]string)
+	for _, svc := range services {
+		var res = adoption.Resource{Kind: drift.KindService, Name: svc.Name}
+		if !known[drift.KindDeployment][svc.Deploy] {
+			res.Error = rserrors.ErrResourceNotExists().AddDetailF("service selects no known deployment, selected %q", svc.Deploy)
+		} else if svc.Domain != "" {
+			res.Error = adoptionError(aa.reservePorts(svc, reserved))
+		}
+		if svc.CreatedAt == nil {
+			svc.CreatedAt = &createdAt
+		}
+		var record = service.ServiceFromKube(nsID, owner, svc)
+		adopt(res, func() error {
+			_, err := aa.storage.CreateService(record)
+			return err
+		})
+	}
+
+	for _, ingr := range ingresses {
+		var res = adoption.Resource{Kind: drift.KindIngress, Name: ingr.Name}
+		var record = ingress.IngressFromKube(nsID, owner, ingr.Ingress)
+		record.Splits = ingr.Splits
+		var backends []string
+		for _, path := range record.Paths() {
+			backends = append(backends, path.ServiceName)
+		}
+		for _, split := range record.Splits {
+			for _, backend := range split.Backends {
+				backends = append(backends, backend.ServiceName)
+			}
+		}
+		for _, backend := range backends {
+			if !known[drift.KindService][backend] {
+				res.Error = rserrors.ErrResourceNotExists().AddDetailF("backend service %q is not known", backend)
+			}
+		}
+		var unsupported []string
+		record.Options, unsupported = ingress.OptionsFromAnnotations(ingr.Annotations)
+		for _, annotation := range unsupported {
+			res.Unsupported = append(res.Unsupported, fmt.Sprintf("annotations[%s]", annotation))
+		}
+		if record.CreatedAt == nil {
+			record.CreatedAt = &createdAt
+		}
+		adopt(res, func() error {
+			_, err := aa.storage.CreateIngress(record)
+			return err
+		})
+	}
+
+	return &result, nil
+}
+
+// activeNames returns names of namespace resources with active records
+func (aa *AdoptActionsImpl) activeNames(nsID string) (adoptedNames, error) {
+	var names = make(adoptedNames)
+	deployments, err := aa.storage.GetDeploymentList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, depl := range deployments {
+		names.add(drift.KindDeployment, depl.Name)
+	}
+	services, err := aa.storage.GetServiceList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		names.add(drift.KindService, svc.Name)
+	}
+	ingresses, err := aa.storage.GetIngressList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ingr := range ingresses {
+		names.add(drift.KindIngress, ingr.Name)
+	}
+	return names, nil
+}
+
+// reservePorts checks if external ports of service are free in allocator.
+// Ports are reserved by stored record, reserved map keeps ports of services adopted before record is stored.
+func (aa *AdoptActionsImpl) reservePorts(svc kubtypes.Service, reserved map[string]string) error {
+	var keys []string
+	for _, port := range svc.Ports {
+		if port.Port == nil {
+			return rserrors.ErrValidation().AddDetailF("external port %q has no port number", port.Name)
+		}
+		var key = fmt.Sprintf("%s/%s/%d", svc.Domain, port.Protocol, *port.Port)
+		if used, ok := reserved[key]; ok {
+			return rserrors.ErrPortAlreadyUsed().AddDetailF("%s port %d on domain %s is used by service %s", port.Protocol, *port.Port, svc.Domain, used)
+		}
+		free, err := aa.storage.IsPortFree(svc.Domain, *port.Port, port.Protocol)
+		if err != nil {
+			return err
+		}
+		if !free {
+			return rserrors.ErrPortAlreadyUsed().AddDetailF("%s port %d on domain %s is used by another service", port.Protocol, *port.Port, svc.Domain)
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		reserved[key] = svc.Name
+	}
+	return nil
+}
+
+func adoptionError(err error) *cherry.Err {
+	if err == nil {
+		return nil
+	}
+	cherryErr, ok := err.(*cherry.Err)
+	if !ok {
+		cherryErr = rserrors.ErrInternal().AddDetailsErr(err)
+	}
+	return cherryErr
+}
@@ -0,0 +1,97 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/adoption"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdoptNamespace(t *testing.T) {
+	var owner = uuid.New().String()
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var kube clients.Kube = clients.NewFakeKube()
+
+	var container = model.Container{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}
+	var port = 30000
+	for _, depl := range []model.Deployment{
+		{Name: "web", Replicas: 2, Containers: []model.Container{container}},
+		{Name: "unlimited", Replicas: 1, Containers: []model.Container{{Name: "app", Image: "app"}}},
+		{Name: "known", Replicas: 1, Containers: []model.Container{container}},
+	} {
+		assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+	}
+	_, err := storage.CreateDeployment(deployment.DeploymentFromKube("ns", owner, model.Deployment{Name: "known", Replicas: 1, Active: true, Containers: []model.Container{container}}))
+	assert.NoError(t, err)
+	for _, svc := range []model.Service{
+		{Name: "web", Deploy: "web", Domain: "example.com", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}},
+		{Name: "web-copy", Deploy: "web", Domain: "example.com", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 8080, Protocol: model.TCP}}},
+		{Name: "orphan", Ports: []model.ServicePort{{Name: "http", TargetPort: 80, Protocol: model.TCP}}},
+	} {
+		assert.NoError(t, kube.CreateService(ctx, "ns", svc))
+	}
+	assert.NoError(t, kube.CreateIngress(ctx, "ns", ingress.KubeIngress{
+		Ingress: model.Ingress{Name: "web", Rules: []model.Rule{{Host: "web.example.com", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 30000}}}}},
+		Annotations: map[string]string{
+			ingress.AnnotationPrefix + "rewrite-target": "/",
+			"example.com/custom":                        "value",
+		},
+	}))
+
+	var adopt = NewAdoptActionsImpl(storage, &kube)
+	var expected = []adoption.Resource{
+		{Kind: drift.KindDeployment, Name: "known", Status: adoption.Exists},
+		{Kind: drift.KindDeployment, Name: "unlimited", Status: adoption.Skipped},
+		{Kind: drift.KindDeployment, Name: "web", Status: adoption.Adopted},
+		{Kind: drift.KindService, Name: "orphan", Status: adoption.Skipped},
+		{Kind: drift.KindService, Name: "web", Status: adoption.Adopted},
+		{Kind: drift.KindService, Name: "web-copy", Status: adoption.Skipped},
+		{Kind: drift.KindIngress, Name: "web", Status: adoption.Adopted, Unsupported: []string{"annotations[example.com/custom]"}},
+	}
+	for _, dryRun := range []bool{true, false} {
+		result, err := adopt.AdoptNamespace(ctx, "ns", owner, dryRun)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Len(t, result.Resources, len(expected))
+		for i, res := range result.Resources {
+			res.Error = nil
+			assert.Equal(t, expected[i], res)
+		}
+	}
+
+	depl, err := storage.GetDeployment("ns", "web")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1.0.0", depl.Version.String())
+		assert.Equal(t, owner, depl.Owner)
+		assert.Equal(t, 2, depl.Replicas)
+	}
+	free, err := storage.IsPortFree("example.com", port, model.TCP)
+	assert.NoError(t, err)
+	assert.False(t, free, "external port of adopted service must be reserved")
+	ingr, err := storage.GetIngress("ns", "web")
+	if assert.NoError(t, err) && assert.NotNil(t, ingr.Options) {
+		assert.Equal(t, "/", ingr.Options.RewriteTarget)
+	}
+
+	// skipped resources are left extra, dropped annotation is the only difference of adopted resources
+	report, err := NewDriftActionsImpl(storage, &kube).DetectDrift(ctx, "ns")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []drift.Resource{
+			{NamespaceID: "ns", Kind: drift.KindDeployment, Name: "unlimited", Type: drift.Extra},
+			{NamespaceID: "ns", Kind: drift.KindService, Name: "orphan", Type: drift.Extra},
+			{NamespaceID: "ns", Kind: drift.KindService, Name: "web-copy", Type: drift.Extra},
+			{NamespaceID: "ns", Kind: drift.KindIngress, Name: "web", Type: drift.Differs, Fields: []string{"annotations"}},
+		}, report.Resources)
+	}
+}
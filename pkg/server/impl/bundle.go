@@ -0,0 +1,369 @@
+package impl
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+// BundleActionsImpl exports namespace resources to bundle and creates them from bundle using deployment, service and ingress actions
+type BundleActionsImpl struct {
+	storage     db.Storage
+	permissions clients.Permissions
+	deployments *DeployActionsImpl
+	services    *ServiceActionsImpl
+	ingresses   *IngressActionsImpl
+	log         *cherrylog.LogrusAdapter
+}
+
+func NewBundleActionsImpl(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube, ingressSuffixes ingress.HostSuffixList, acme *ACMEActionsImpl) *BundleActionsImpl {
+	return &BundleActionsImpl{
+		storage:     storage,
+		permissions: *permissions,
+		deployments: NewDeployActionsImpl(storage, permissions, kube),
+		services:    NewServiceActionsImpl(storage, permissions, kube),
+		ingresses:   NewIngressActionsImpl(storage, permissions, kube, ingressSuffixes, acme),
+		log:         cherrylog.NewLogrusAdapter(logrus.WithField("component", "bundle_actions")),
+	}
+}
+
+func (ba *BundleActionsImpl) ExportNamespace(ctx context.Context, nsID string) (*bundle.Bundle, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ba.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+	}).Info("export namespace")
+
+	deployments, err := ba.storage.GetDeploymentList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	services, err := ba.storage.GetServiceList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	ingresses, err := ba.storage.GetIngressList(nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret = bundle.Bundle{
+		Version:     bundle.FormatVersion,
+		NamespaceID: nsID,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, depl := range deployments {
+		var exported = depl.Copy().Deployment
+		exported.Status = nil
+		exported.Owner = ""
+		exported.TotalCPU, exported.TotalMemory = 0, 0
+		ret.Deployments = append(ret.Deployments, exported)
+	}
+	for _, svc := range services {
+		// ports of external service are kept to map ingress backends to reallocated ports on import
+		var exported = bundle.Service{
+			Service: svc.Copy().Service,
+			Type:    server.DetermineServiceType(svc.Service),
+		}
+		exported.Owner = ""
+		exported.CreatedAt = nil
+		exported.Domain, exported.IPs = "", nil
+		ret.Services = append(ret.Services, exported)
+	}
+	for _, ingr := range ingresses {
+		ret.Ingresses = append(ret.Ingresses, exportIngress(ingr))
+	}
+	return &ret, nil
+}
+
+// exportIngress converts ingress to creation request.
+// Basic auth passwords are not stored, so basic auth is not exported and is listed as dropped.
+// Certificates issued automatically are not exported too.
+func exportIngress(ingr ingress.IngressResource) bundle.Ingress {
+	var exported = bundle.Ingress{IngressRequest: ingress.IngressRequest{
+		Ingress: kubtypes.Ingress{Name: ingr.Name},
+		Splits:  append([]ingress.Split(nil), ingr.Splits...),
+	}}
+	for _, rule := range ingr.Rules {
+		if rule.TLSSecret != nil && ingr.ACME != nil && *rule.TLSSecret == ingr.ACME.Certificate {
+			rule.TLSSecret = nil
+		}
+		rule.Path = append([]kubtypes.Path(nil), rule.Path...)
+		exported.Rules = append(exported.Rules, rule)
+	}
+	if ingr.Options != nil {
+		var options = *ingr.Options
+		if options.BasicAuth != nil {
+			options.BasicAuth = nil
+			exported.Dropped = append(exported.Dropped, bundle.DroppedBasicAuth)
+		}
+		exported.Options = &options
+	}
+	return exported
+}
+
+func (ba *BundleActionsImpl) ImportNamespace(ctx context.Context, nsID string, req bundle.Bundle, atomic, allowUnprotected bool) (*bundle.ImportResult, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ba.log.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"ns_id":             nsID,
+		"atomic":            atomic,
+		"allow_unprotected": allowUnprotected,
+	}).Info("import namespace")
+
+	if err := ba.checkBundle(ctx, nsID, req, allowUnprotected); err != nil {
+		return nil, err
+	}
+
+	var result bundle.ImportResult
+	var created []bundle.ImportedResource
+	// failed returns true if import must be stopped and rolled back
+	var failed = func(kind bundle.Kind, name string, err error, dropped ...string) bool {
+		var imported = bundle.ImportedResource{Kind: kind, Name: name, Dropped: dropped}
+		if err != nil {
+			cherryErr, ok := err.(*cherry.Err)
+			if !ok {
+				cherryErr = rserrors.ErrInternal().AddDetailsErr(err)
+			}
+			imported.Error = cherryErr
+		} else {
+			created = append(created, imported)
+		}
+		result.Resources = append(result.Resources, imported)
+		return err != nil && atomic
+	}
+
+	// dependency order: services refer to deployments, ingresses refer to services
+	for _, depl := range req.Deployments {
+		_, err := ba.deployments.CreateDeployment(ctx, nsID, depl)
+		if failed(bundle.KindDeployment, depl.Name, err) {
+			return nil, ba.rollback(ctx, nsID, created, bundle.KindDeployment, depl.Name, err)
+		}
+	}
+
+	// service name -> exported external port -> reallocated port
+	var ports = make(map[string]map[int]int)
+	for _, svc := range req.Services {
+		var exportedPorts = make([]*int, len(svc.Ports))
+		svc.Ports = append([]kubtypes.ServicePort(nil), svc.Ports...)
+		if bundleServiceType(svc) == service.ServiceExternal {
+			for i := range svc.Ports {
+				exportedPorts[i], svc.Ports[i].Port = svc.Ports[i].Port, nil
+			}
+			svc.Domain, svc.IPs = "", nil
+		}
+		createdSvc, err := ba.services.CreateService(ctx, nsID, svc.Service)
+		if failed(bundle.KindService, svc.Name, err) {
+			return nil, ba.rollback(ctx, nsID, created, bundle.KindService, svc.Name, err)
+		}
+		if err == nil {
+			ports[svc.Name] = reallocatedPorts(exportedPorts, createdSvc.Ports)
+		}
+	}
+
+	for _, ingr := range req.Ingresses {
+		ingr = remapIngressPorts(ingr, ports)
+		_, err := ba.ingresses.CreateIngress(ctx, nsID, ingr.IngressRequest)
+		if failed(bundle.KindIngress, ingr.Name, err, ingr.Dropped...) {
+			return nil, ba.rollback(ctx, nsID, created, bundle.KindIngress, ingr.Name, err)
+		}
+	}
+
+	return &result, nil
+}
+
+// checkBundle checks bundle before anything is created:
+// names are unique and free in namespace, services refer to existing deployments,
+// namespace quotas are enough for all resources, ingress hosts are not used in other namespaces,
+// ingresses exported without basic auth are imported unprotected only if it is allowed.
+func (ba *BundleActionsImpl) checkBundle(ctx context.Context, nsID string, req bundle.Bundle, allowUnprotected bool) error {
+	if req.Version != bundle.FormatVersion {
+		return rserrors.ErrValidation().AddDetailF("unsupported bundle version %q, expected %q", req.Version, bundle.FormatVersion)
+	}
+
+	var conflicts = rserrors.ErrResourceAlreadyExists()
+	var deployments = make(map[string]struct{}, len(req.Deployments))
+	for _, depl := range req.Deployments {
+		if _, ok := deployments[depl.Name]; ok {
+			return rserrors.ErrValidation().AddDetailF("deployment %s is in bundle more than once", depl.Name)
+		}
+		deployments[depl.Name] = struct{}{}
+		if _, err := ba.storage.GetDeployment(nsID, depl.Name); err == nil {
+			conflicts.AddDetailF("deployment %s", depl.Name)
+		}
+	}
+
+	var services = make(map[string]struct{}, len(req.Services))
+	for _, svc := range req.Services {
+		if _, ok := services[svc.Name]; ok {
+			return rserrors.ErrValidation().AddDetailF("service %s is in bundle more than once", svc.Name)
+		}
+		services[svc.Name] = struct{}{}
+		if _, err := ba.storage.GetService(nsID, svc.Name); err == nil {
+			conflicts.AddDetailF("service %s", svc.Name)
+		}
+		if _, ok := deployments[svc.Deploy]; !ok {
+			if _, err := ba.storage.GetDeployment(nsID, svc.Deploy); err != nil {
+				return rserrors.ErrResourceNotExists().AddDetailF("deployment %s of service %s is not in bundle and not exists", svc.Deploy, svc.Name)
+			}
+		}
+	}
+
+	for _, ingr := range req.Ingresses {
+		if len(ingr.Rules) == 0 {
+			return rserrors.ErrValidation().AddDetailF("ingress %s has no rules", ingr.Name)
+		}
+		if len(ingr.Dropped) > 0 && !allowUnprotected {
+			return rserrors.ErrValidation().AddDetailF("ingress %s was exported without %s, use allow_unprotected to import it anyway",
+				ingr.Name, strings.Join(ingr.Dropped, ", "))
+		}
+		var rules = make([]kubtypes.Rule, 0, len(ingr.Rules))
+		for _, rule := range ingr.Rules {
+			var err error
+			if rule.Host, err = ba.ingresses.ingressHost(nsID, rule.Host); err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		var name = ingress.IngressName(rules[0].Host)
+		if _, err := ba.storage.GetIngress(nsID, name); err == nil {
+			conflicts.AddDetailF("ingress %s", name)
+		}
+		if err := checkHostsFree(ctx, ba.storage, nsID, name, rules); err != nil {
+			return err
+		}
+	}
+
+	if len(conflicts.Details) > 0 {
+		return conflicts
+	}
+
+	return ba.checkBundleQuotas(ctx, nsID, req)
+}
+
+// checkBundleQuotas checks that namespace quotas are enough for all bundle deployments and services
+func (ba *BundleActionsImpl) checkBundleQuotas(ctx context.Context, nsID string, req bundle.Bundle) error {
+	nsLimits, err := ba.permissions.GetNamespaceLimits(ctx, nsID)
+	if err != nil {
+		return err
+	}
+
+	nsUsage, err := ba.storage.GetNamespaceResourcesLimits(nsID)
+	if err != nil {
+		return err
+	}
+	for _, depl := range req.Deployments {
+		if err := server.CheckDeploymentCreateQuotas(nsLimits, nsUsage, depl); err != nil {
+			return err.(*cherry.Err).AddDetailF("deployment %s", depl.Name)
+		}
+		server.CalculateDeployResources(&depl)
+		nsUsage.CPU += depl.TotalCPU
+		nsUsage.Memory += depl.TotalMemory
+	}
+
+	svcUsage, err := ba.storage.CountServicesInNamespace(nsID)
+	if err != nil {
+		return err
+	}
+	for _, svc := range req.Services {
+		var serviceType = bundleServiceType(svc)
+		if err := server.CheckServiceCreateQuotas(nsLimits, svcUsage, serviceType); err != nil {
+			return err.(*cherry.Err).AddDetailF("service %s", svc.Name)
+		}
+		if serviceType == service.ServiceExternal {
+			svcUsage.External++
+		} else {
+			svcUsage.Internal++
+		}
+	}
+	return nil
+}
+
+func bundleServiceType(svc bundle.Service) service.ServiceType {
+	if svc.Type != "" {
+		return svc.Type
+	}
+	return server.DetermineServiceType(svc.Service)
+}
+
+// rollback deletes created resources in reverse order after failed import, deleted resources are moved to trash
+func (ba *BundleActionsImpl) rollback(ctx context.Context, nsID string, created []bundle.ImportedResource, kind bundle.Kind, name string, importErr error) error {
+	for i := len(created) - 1; i >= 0; i-- {
+		var err error
+		switch created[i].Kind {
+		case bundle.KindIngress:
+			err = ba.ingresses.DeleteIngress(ctx, nsID, created[i].Name)
+		case bundle.KindService:
+			err = ba.services.DeleteService(ctx, nsID, created[i].Name)
+		case bundle.KindDeployment:
+			err = ba.deployments.DeleteDeployment(ctx, nsID, created[i].Name)
+		}
+		if err != nil {
+			ba.log.WithError(err).Errorf("unable to roll back import of %s %s", created[i].Kind, created[i].Name)
+		}
+	}
+
+	cherryErr, ok := importErr.(*cherry.Err)
+	if !ok {
+		cherryErr = rserrors.ErrInternal().AddDetailsErr(importErr)
+	}
+	return cherryErr.AddDetailF("unable to import %s %s, import is rolled back", kind, name)
+}
+
+// reallocatedPorts maps exported external ports to ports allocated on import
+func reallocatedPorts(exported []*int, created []kubtypes.ServicePort) map[int]int {
+	var ports = make(map[int]int, len(exported))
+	for i, port := range exported {
+		if port != nil && i < len(created) && created[i].Port != nil {
+			ports[*port] = *created[i].Port
+		}
+	}
+	return ports
+}
+
+// remapIngressPorts replaces exported external service ports in ingress paths and splits with reallocated ones
+func remapIngressPorts(ingr bundle.Ingress, ports map[string]map[int]int) bundle.Ingress {
+	var remap = func(serviceName string, port int) int {
+		if newPort, ok := ports[serviceName][port]; ok {
+			return newPort
+		}
+		return port
+	}
+	var rules = make([]kubtypes.Rule, 0, len(ingr.Rules))
+	for _, rule := range ingr.Rules {
+		var paths = make([]kubtypes.Path, 0, len(rule.Path))
+		for _, path := range rule.Path {
+			path.ServicePort = remap(path.ServiceName, path.ServicePort)
+			paths = append(paths, path)
+		}
+		rule.Path = paths
+		rules = append(rules, rule)
+	}
+	ingr.Rules = rules
+
+	var splits = make([]ingress.Split, 0, len(ingr.Splits))
+	for _, split := range ingr.Splits {
+		var backends = make([]ingress.Backend, 0, len(split.Backends))
+		for _, backend := range split.Backends {
+			backend.ServicePort = remap(backend.ServiceName, backend.ServicePort)
+			backends = append(backends, backend)
+		}
+		split.Backends = backends
+		splits = append(splits, split)
+	}
+	ingr.Splits = splits
+	return ingr
+}
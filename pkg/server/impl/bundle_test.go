@@ -0,0 +1,263 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitsPermissions -- permissions client returning the same limits for every namespace,
+// wildcard hosts are allowed only in listed namespaces
+type limitsPermissions struct {
+	limits    model.Namespace
+	wildcards []string
+}
+
+func (perm *limitsPermissions) GetNamespaceLimits(context.Context, string) (model.Namespace, error) {
+	return perm.limits, nil
+}
+
+func (perm *limitsPermissions) WildcardHostsAllowed(_ context.Context, nsID string) (bool, error) {
+	for _, ns := range perm.wildcards {
+		if ns == nsID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type bundleTest struct {
+	ctx         context.Context
+	storage     db.Storage
+	fake        *clients.FakeKube
+	permissions *limitsPermissions
+	bundles     *BundleActionsImpl
+}
+
+func newBundleTest(t *testing.T, limits model.Namespace) bundleTest {
+	var test = bundleTest{
+		ctx:         server.BackgroundContext(context.Background(), uuid.New().String()),
+		storage:     db.NewMemory(),
+		fake:        clients.NewFakeKube(),
+		permissions: &limitsPermissions{limits: limits},
+	}
+	_, err := test.storage.CreateDomain(domain.Domain{Domain: "example.com", DomainGroup: "default", IP: []string{"192.0.2.1"}})
+	assert.NoError(t, err)
+	suffix, err := ingress.ParseHostSuffix(".hub.containerum.io")
+	assert.NoError(t, err)
+
+	var kube clients.Kube = test.fake
+	var permissions clients.Permissions = test.permissions
+	test.bundles = NewBundleActionsImpl(test.storage, &permissions, &kube, ingress.HostSuffixList{suffix}, nil)
+	return test
+}
+
+// sourceBundle creates deployment, two external services and ingress split between them in namespace and exports it
+func (test bundleTest) sourceBundle(t *testing.T, nsID string) *bundle.Bundle {
+	var depl = model.Deployment{Name: "web", Replicas: 1,
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	_, err := test.bundles.deployments.CreateDeployment(test.ctx, nsID, depl)
+	assert.NoError(t, err)
+	var ports = make(map[string]int)
+	for _, name := range []string{"web", "canary"} {
+		svc, err := test.bundles.services.CreateService(test.ctx, nsID, model.Service{Name: name, Deploy: "web",
+			Ports: []model.ServicePort{{Name: "http", TargetPort: 80, Protocol: model.TCP}}})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		ports[name] = *svc.Ports[0].Port
+	}
+	_, err = test.bundles.ingresses.CreateIngress(test.ctx, nsID, ingress.IngressRequest{
+		Ingress: model.Ingress{Rules: []model.Rule{{Host: "web", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: ports["web"]}}}}},
+		Splits: []ingress.Split{{Host: "web", Path: "/", Backends: []ingress.Backend{
+			{ServiceName: "web", ServicePort: ports["web"], Weight: 80},
+			{ServiceName: "canary", ServicePort: ports["canary"], Weight: 20},
+		}}},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	exported, err := test.bundles.ExportNamespace(test.ctx, nsID)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return exported
+}
+
+func (test bundleTest) assertEmpty(t *testing.T, nsID string) {
+	deployments, err := test.storage.GetDeploymentList(nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, deployments)
+	services, err := test.storage.GetServiceList(nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, services)
+	ingresses, err := test.storage.GetIngressList(nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, ingresses)
+
+	kubeDeployments, err := test.fake.GetDeploymentList(test.ctx, nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, kubeDeployments)
+	kubeServices, err := test.fake.GetServiceList(test.ctx, nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, kubeServices)
+	kubeIngresses, err := test.fake.GetIngressList(test.ctx, nsID)
+	assert.NoError(t, err)
+	assert.Empty(t, kubeIngresses)
+}
+
+var bundleLimits = model.Namespace{
+	MaxExtService: 5,
+	MaxIntService: 5,
+	Resources:     model.Resources{Hard: model.Resource{CPU: 1000, Memory: 1024}},
+}
+
+func TestBundleImport(t *testing.T) {
+	var test = newBundleTest(t, bundleLimits)
+	var exported = test.sourceBundle(t, "src")
+	// ingress host is unique among namespaces, so source ingress is removed before import
+	assert.NoError(t, test.bundles.ingresses.DeleteIngress(test.ctx, "src", exported.Ingresses[0].Name))
+
+	result, err := test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// dependency order
+	assert.Equal(t, []bundle.ImportedResource{
+		{Kind: bundle.KindDeployment, Name: "web"},
+		{Kind: bundle.KindService, Name: "web"},
+		{Kind: bundle.KindService, Name: "canary"},
+		{Kind: bundle.KindIngress, Name: exported.Ingresses[0].Name},
+	}, result.Resources)
+
+	// ports used by source services are reallocated and ingress backends follow them
+	var ports = make(map[string]int)
+	var remapped = make(map[string]map[int]int)
+	for _, svc := range exported.Services {
+		imported, err := test.storage.GetService("dst", svc.Name)
+		if assert.NoError(t, err) {
+			assert.NotEqual(t, *svc.Ports[0].Port, *imported.Ports[0].Port)
+			assert.Equal(t, "example.com", imported.Domain)
+			ports[svc.Name] = *imported.Ports[0].Port
+			remapped[svc.Name] = map[int]int{*svc.Ports[0].Port: *imported.Ports[0].Port}
+		}
+	}
+	imported, err := test.storage.GetIngress("dst", exported.Ingresses[0].Name)
+	if assert.NoError(t, err) && assert.Len(t, imported.Splits, 1) {
+		assert.Equal(t, []model.Path{{Path: "/", ServiceName: "web", ServicePort: ports["web"]}}, imported.Rules[0].Path)
+		assert.Equal(t, []ingress.Backend{
+			{ServiceName: "web", ServicePort: ports["web"], Weight: 80},
+			{ServiceName: "canary", ServicePort: ports["canary"], Weight: 20},
+		}, imported.Splits[0].Backends)
+	}
+	kubeIngresses, err := test.fake.GetIngressList(test.ctx, "dst")
+	if assert.NoError(t, err) && assert.Len(t, kubeIngresses, 1) {
+		assert.Equal(t, ports["web"], kubeIngresses[0].Rules[0].Path[0].ServicePort)
+	}
+
+	// export of imported namespace is the same as source one except ports
+	reexported, err := test.bundles.ExportNamespace(test.ctx, "dst")
+	if assert.NoError(t, err) {
+		assert.Equal(t, exported.Deployments, reexported.Deployments)
+		assert.Len(t, reexported.Services, len(exported.Services))
+		assert.Equal(t, remapIngressPorts(exported.Ingresses[0], remapped), reexported.Ingresses[0])
+	}
+
+	// names are already used
+	_, err = test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, false)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceAlreadyExists()), "%v", err)
+}
+
+func TestBundleImportQuota(t *testing.T) {
+	var test = newBundleTest(t, bundleLimits)
+	var exported = test.sourceBundle(t, "src")
+	assert.NoError(t, test.bundles.ingresses.DeleteIngress(test.ctx, "src", exported.Ingresses[0].Name))
+
+	// the second external service exceeds quota, so nothing is created
+	test.permissions.limits.MaxExtService = 1
+	test.fake.ClearFaults()
+	_, err := test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, false)
+	assert.True(t, cherry.Equals(err, rserrors.ErrQuotaExceeded()), "%v", err)
+	assert.Equal(t, 0, test.fake.Calls(""))
+	test.assertEmpty(t, "dst")
+}
+
+func TestBundleImportRollback(t *testing.T) {
+	var test = newBundleTest(t, bundleLimits)
+	var exported = test.sourceBundle(t, "src")
+	assert.NoError(t, test.bundles.ingresses.DeleteIngress(test.ctx, "src", exported.Ingresses[0].Name))
+
+	var fault = rserrors.ErrServiceUnavailable()
+	test.fake.FailMethod("CreateIngress", fault)
+	_, err := test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, false)
+	assert.True(t, cherry.Equals(err, fault), "%v", err)
+	test.assertEmpty(t, "dst")
+	// source namespace is not touched
+	services, err := test.storage.GetServiceList("src")
+	assert.NoError(t, err)
+	assert.Len(t, services, 2)
+
+	// without atomic import created resources are kept and failure is reported
+	result, err := test.bundles.ImportNamespace(test.ctx, "dst", *exported, false, false)
+	if assert.NoError(t, err) && assert.Len(t, result.Resources, 4) {
+		assert.NotNil(t, result.Resources[3].Error)
+	}
+	services, err = test.storage.GetServiceList("dst")
+	assert.NoError(t, err)
+	assert.Len(t, services, 2)
+	_, err = test.storage.GetIngress("dst", exported.Ingresses[0].Name)
+	assert.Error(t, err)
+}
+
+func TestBundleImportBasicAuth(t *testing.T) {
+	var test = newBundleTest(t, bundleLimits)
+	var source = test.sourceBundle(t, "src")
+	_, err := test.bundles.ingresses.CreateIngress(test.ctx, "src", ingress.IngressRequest{
+		Ingress: model.Ingress{Rules: []model.Rule{{Host: "admin", Path: []model.Path{
+			{Path: "/", ServiceName: "web", ServicePort: source.Ingresses[0].Rules[0].Path[0].ServicePort}}}}},
+		Options: &ingress.Options{BasicAuth: &ingress.BasicAuth{Users: []ingress.BasicAuthUser{{Username: "admin", Password: "secret"}}}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	exported, err := test.bundles.ExportNamespace(test.ctx, "src")
+	if !assert.NoError(t, err) || !assert.Len(t, exported.Ingresses, 2) {
+		return
+	}
+	var dropped = make(map[string][]string)
+	for _, ingr := range exported.Ingresses {
+		assert.True(t, ingr.Options == nil || ingr.Options.BasicAuth == nil)
+		dropped[ingr.Name] = ingr.Dropped
+		assert.NoError(t, test.bundles.ingresses.DeleteIngress(test.ctx, "src", ingr.Name))
+	}
+	var admin = ingress.IngressName("admin.hub.containerum.io")
+	assert.Equal(t, map[string][]string{source.Ingresses[0].Name: nil, admin: {bundle.DroppedBasicAuth}}, dropped)
+
+	// ingress can't be protected on import, so it is imported only if allowed
+	_, err = test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, false)
+	assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v", err)
+	test.assertEmpty(t, "dst")
+
+	result, err := test.bundles.ImportNamespace(test.ctx, "dst", *exported, true, true)
+	if assert.NoError(t, err) {
+		for _, res := range result.Resources {
+			assert.Equal(t, dropped[res.Name], res.Dropped, "%s %s", res.Kind, res.Name)
+		}
+	}
+	imported, err := test.storage.GetIngress("dst", admin)
+	if assert.NoError(t, err) {
+		assert.Empty(t, imported.Options.BasicAuthSecret())
+	}
+}
@@ -0,0 +1,138 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/utils/httputil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type CertificateActionsImpl struct {
+	kube    clients.Kube
+	storage db.Storage
+	journal journal
+	log     *cherrylog.LogrusAdapter
+}
+
+func NewCertificateActionsImpl(storage db.Storage, kube *clients.Kube) *CertificateActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "certificate_actions"))
+	return &CertificateActionsImpl{
+		kube:    *kube,
+		storage: storage,
+		journal: newJournal(storage, *kube, log),
+		log:     log,
+	}
+}
+
+func (ca *CertificateActionsImpl) GetCertificatesList(ctx context.Context, nsID string) (certificate.CertificateList, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"namespace": nsID,
+	}).Info("get certificates")
+
+	return ca.storage.GetCertificateList(nsID)
+}
+
+func (ca *CertificateActionsImpl) GetCertificate(ctx context.Context, nsID, certName string) (*certificate.Certificate, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"namespace":   nsID,
+		"certificate": certName,
+	}).Info("get certificate")
+
+	ret, err := ca.storage.GetCertificate(nsID, certName)
+
+	return &ret, err
+}
+
+func (ca *CertificateActionsImpl) CreateCertificate(ctx context.Context, nsID string, req certificate.CertificateRequest) (*certificate.Certificate, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"ns_id":       nsID,
+		"certificate": req.Name,
+	}).Info("create certificate")
+
+	now := time.Now().UTC()
+	chain, err := server.ParseCertificate(req.Cert, req.Key, now)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := server.CertificateMetadata(chain[0])
+	cert.Name = req.Name
+	cert.NamespaceID = nsID
+	cert.Owner = userID
+	cert.CreatedAt = now.Format(time.RFC3339)
+
+	created, err := ca.storeCertificate(ctx, cert, req.Cert, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// storeCertificate saves certificate metadata to db and pushes certificate with key to kube secret
+func (ca *CertificateActionsImpl) storeCertificate(ctx context.Context, cert certificate.Certificate, certPEM, keyPEM string) (certificate.Certificate, error) {
+	cert.ID = uuid.New().String()
+	var created certificate.Certificate
+	err := ca.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateCertificate,
+		NamespaceID: cert.NamespaceID,
+		Name:        cert.Name,
+		Certificate: &cert,
+		Secret: &kubtypes.Secret{
+			Name:  cert.Name,
+			Owner: cert.Owner,
+			Data: map[string]string{
+				certificate.SecretCertKey:    certPEM,
+				certificate.SecretPrivateKey: keyPEM,
+			},
+		},
+	}, func() (err error) {
+		created, err = ca.storage.CreateCertificate(cert)
+		return err
+	})
+	return created, err
+}
+
+func (ca *CertificateActionsImpl) DeleteCertificate(ctx context.Context, nsID, certName string) error {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"ns_id":       nsID,
+		"certificate": certName,
+	}).Info("delete certificate")
+
+	ingr, err := ca.storage.GetIngressByTLSSecret(nsID, certName)
+	switch {
+	case err == nil:
+		return rserrors.ErrCertificateInUse().AddDetailF("certificate is used by ingress %s", ingr.Name)
+	case cherry.Equals(err, rserrors.ErrResourceNotExists()):
+		// pass
+	default:
+		return err
+	}
+
+	return ca.journal.execute(ctx, operation.Operation{
+		Kind:        operation.DeleteCertificate,
+		NamespaceID: nsID,
+		Name:        certName,
+	}, func() error {
+		return ca.storage.DeleteCertificate(nsID, certName)
+	})
+}
@@ -0,0 +1,151 @@
+package impl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/containerum/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+type CustomDomainActionsImpl struct {
+	dns     clients.DNS
+	storage db.Storage
+	log     *cherrylog.LogrusAdapter
+}
+
+func NewCustomDomainActionsImpl(storage db.Storage, dns *clients.DNS) *CustomDomainActionsImpl {
+	return &CustomDomainActionsImpl{
+		dns:     *dns,
+		storage: storage,
+		log:     cherrylog.NewLogrusAdapter(logrus.WithField("component", "custom_domain_actions")),
+	}
+}
+
+func (ca *CustomDomainActionsImpl) GetCustomDomainsList(ctx context.Context, nsID string) (customdomain.CustomDomainList, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"namespace": nsID,
+	}).Info("get custom domains")
+
+	return ca.storage.GetCustomDomainList(nsID)
+}
+
+func (ca *CustomDomainActionsImpl) GetCustomDomain(ctx context.Context, nsID, domain string) (*customdomain.CustomDomain, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"namespace": nsID,
+		"domain":    domain,
+	}).Info("get custom domain")
+
+	ret, err := ca.storage.GetCustomDomain(nsID, domain)
+
+	return &ret, err
+}
+
+func (ca *CustomDomainActionsImpl) AddCustomDomain(ctx context.Context, nsID string, req customdomain.CustomDomain) (*customdomain.CustomDomain, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+	}).Infof("add custom domain %#v", req)
+
+	domain, err := server.NormalizeHost(req.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, rserrors.ErrInternal().Log(err, ca.log)
+	}
+
+	created, err := ca.storage.CreateCustomDomain(customdomain.CustomDomain{
+		Domain:      domain,
+		NamespaceID: nsID,
+		Owner:       userID,
+		Token:       token,
+		Record:      customdomain.VerificationRecord(domain),
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (ca *CustomDomainActionsImpl) VerifyCustomDomain(ctx context.Context, nsID, domain string) (*customdomain.CustomDomain, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"domain":  domain,
+	}).Info("verify custom domain")
+
+	customDomain, err := ca.storage.GetCustomDomain(nsID, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if customDomain.Verified {
+		return &customDomain, nil
+	}
+
+	if err := clients.VerifyTXT(ctx, ca.dns, customDomain.Record, customDomain.Token); err != nil {
+		ca.log.WithError(err).Debug("domain verification failed")
+		return nil, rserrors.ErrDomainVerificationFailed().AddDetailsErr(err)
+	}
+
+	if err := ca.storage.VerifyCustomDomain(nsID, domain, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	verified, err := ca.storage.GetCustomDomain(nsID, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verified, nil
+}
+
+func (ca *CustomDomainActionsImpl) DeleteCustomDomain(ctx context.Context, nsID, domain string) error {
+	userID := httputil.MustGetUserID(ctx)
+	ca.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"domain":  domain,
+	}).Info("delete custom domain")
+
+	ingr, err := ca.storage.GetIngressByDomain(nsID, domain)
+	switch {
+	case err == nil:
+		return rserrors.ErrDomainHasIngresses().AddDetailF("domain is used by ingress %s", ingr.Name)
+	case cherry.Equals(err, rserrors.ErrResourceNotExists()):
+		// pass
+	default:
+		return err
+	}
+
+	return ca.storage.DeleteCustomDomain(nsID, domain)
+}
+
+// randomToken returns hex encoded random bytes
+func randomToken(length int) (string, error) {
+	var token = make([]byte, length)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,94 @@
+package impl
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCustomDomain(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var dns = clients.NewDummyDNS(nil)
+	var actions = NewCustomDomainActionsImpl(storage, &dns)
+
+	first, err := actions.AddCustomDomain(ctx, "first", customdomain.CustomDomain{Domain: "Example.COM."})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "example.com", first.Domain)
+	assert.Equal(t, "_containerum-challenge.example.com", first.Record)
+	assert.False(t, first.Verified)
+
+	// no record and record with other token
+	_, err = actions.VerifyCustomDomain(ctx, "first", "example.com")
+	assert.True(t, cherry.Equals(err, rserrors.ErrDomainVerificationFailed()), "%v", err)
+	dns = clients.NewDummyDNS(map[string][]string{first.Record: {"other-token"}})
+	_, err = NewCustomDomainActionsImpl(storage, &dns).VerifyCustomDomain(ctx, "first", "example.com")
+	assert.True(t, cherry.Equals(err, rserrors.ErrDomainVerificationFailed()), "%v", err)
+
+	second, err := actions.AddCustomDomain(ctx, "second", customdomain.CustomDomain{Domain: "example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dns = clients.NewDummyDNS(map[string][]string{first.Record: {"other-token", first.Token, second.Token}})
+	actions = NewCustomDomainActionsImpl(storage, &dns)
+
+	verified, err := actions.VerifyCustomDomain(ctx, "first", "example.com")
+	if assert.NoError(t, err) {
+		assert.True(t, verified.Verified)
+		assert.NotNil(t, verified.VerifiedAt)
+	}
+	// verification is idempotent
+	_, err = actions.VerifyCustomDomain(ctx, "first", "example.com")
+	assert.NoError(t, err)
+
+	// domain verified by first namespace can't be claimed by second one
+	_, err = actions.VerifyCustomDomain(ctx, "second", "example.com")
+	if cherr, ok := err.(*cherry.Err); assert.True(t, ok, "%v", err) {
+		assert.Equal(t, http.StatusConflict, cherr.StatusHTTP)
+	}
+	claimed, err := storage.GetCustomDomain("second", "example.com")
+	if assert.NoError(t, err) {
+		assert.False(t, claimed.Verified)
+	}
+	owner, err := storage.GetVerifiedCustomDomain("example.com")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "first", owner.NamespaceID)
+	}
+
+	_, err = actions.VerifyCustomDomain(ctx, "third", "example.com")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+}
+
+func TestDeleteCustomDomain(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var dns = clients.NewDummyDNS(nil)
+	var actions = NewCustomDomainActionsImpl(storage, &dns)
+
+	_, err := actions.AddCustomDomain(ctx, "ns", customdomain.CustomDomain{Domain: "example.com"})
+	assert.NoError(t, err)
+	// subdomain of any depth uses domain
+	_, err = storage.CreateIngress(ingress.IngressFromKube("ns", "owner", model.Ingress{Name: "deep", Rules: []model.Rule{
+		{Host: "a.b.example.com", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 80}}}}}))
+	assert.NoError(t, err)
+	err = actions.DeleteCustomDomain(ctx, "ns", "example.com")
+	assert.True(t, cherry.Equals(err, rserrors.ErrDomainHasIngresses()), "%v", err)
+
+	assert.NoError(t, storage.DeleteIngress("ns", "deep"))
+	assert.NoError(t, actions.DeleteCustomDomain(ctx, "ns", "example.com"))
+	_, err = storage.GetCustomDomain("ns", "example.com")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+}
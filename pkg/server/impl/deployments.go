@@ -2,10 +2,12 @@ package impl
 
 import (
 	"context"
+	"time"
 
 	"git.containerum.net/ch/resource-service/pkg/clients"
 	"git.containerum.net/ch/resource-service/pkg/db"
 	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"git.containerum.net/ch/resource-service/pkg/server"
 	"github.com/blang/semver"
@@ -13,22 +15,26 @@ import (
 	"github.com/containerum/kube-client/pkg/diff"
 	kubtypes "github.com/containerum/kube-client/pkg/model"
 	"github.com/containerum/utils/httputil"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type DeployActionsImpl struct {
 	kube        clients.Kube
 	permissions clients.Permissions
-	mongo       *db.MongoStorage
+	storage     db.Storage
+	journal     journal
 	log         *cherrylog.LogrusAdapter
 }
 
-func NewDeployActionsImpl(mongo *db.MongoStorage, permissions *clients.Permissions, kube *clients.Kube) *DeployActionsImpl {
+func NewDeployActionsImpl(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube) *DeployActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "deploy_actions"))
 	return &DeployActionsImpl{
 		kube:        *kube,
-		mongo:       mongo,
+		storage:     storage,
 		permissions: *permissions,
-		log:         cherrylog.NewLogrusAdapter(logrus.WithField("component", "deploy_actions")),
+		journal:     newJournal(storage, *kube, log),
+		log:         log,
 	}
 }
 
@@ -39,7 +45,7 @@ func (da *DeployActionsImpl) GetDeploymentsList(ctx context.Context, nsID string
 		"namespace": nsID,
 	}).Info("get deployments")
 
-	return da.mongo.GetDeploymentList(nsID)
+	return da.storage.GetDeploymentList(nsID)
 }
 
 func (da *DeployActionsImpl) GetDeployment(ctx context.Context, nsID, deplName string) (*deployment.DeploymentResource, error) {
@@ -50,7 +56,7 @@ func (da *DeployActionsImpl) GetDeployment(ctx context.Context, nsID, deplName s
 		"deploy_name": deplName,
 	}).Info("get deployment by label")
 
-	ret, err := da.mongo.GetDeployment(nsID, deplName)
+	ret, err := da.storage.GetDeployment(nsID, deplName)
 
 	return &ret, err
 }
@@ -67,7 +73,7 @@ func (da *DeployActionsImpl) GetDeploymentVersion(ctx context.Context, nsID, dep
 	if err != nil {
 		return nil, err
 	}
-	ret, err := da.mongo.GetDeploymentVersion(nsID, deplName, deplVersion)
+	ret, err := da.storage.GetDeploymentVersion(nsID, deplName, deplVersion)
 
 	return &ret, err
 }
@@ -80,7 +86,7 @@ func (da *DeployActionsImpl) GetDeploymentVersionsList(ctx context.Context, nsID
 		"deployment": deployName,
 	}).Info("get deployments")
 
-	return da.mongo.GetDeploymentVersionsList(nsID, deployName)
+	return da.storage.GetDeploymentVersionsList(nsID, deployName)
 }
 
 func (da *DeployActionsImpl) CreateDeployment(ctx context.Context, nsID string, deploy kubtypes.Deployment) (*deployment.DeploymentResource, error) {
@@ -95,7 +101,7 @@ func (da *DeployActionsImpl) CreateDeployment(ctx context.Context, nsID string,
 		return nil, err
 	}
 
-	nsUsage, err := da.mongo.GetNamespaceResourcesLimits(nsID)
+	nsUsage, err := da.storage.GetNamespaceResourcesLimits(nsID)
 	if err != nil {
 		return nil, err
 	}
@@ -109,16 +115,19 @@ func (da *DeployActionsImpl) CreateDeployment(ctx context.Context, nsID string,
 	deploy.Version = semver.MustParse("1.0.0")
 	deploy.Active = true
 
-	createdDeploy, err := da.mongo.CreateDeployment(deployment.DeploymentFromKube(nsID, userID, deploy))
-	if err != nil {
-		return nil, err
-	}
-
-	if err := da.kube.CreateDeployment(ctx, nsID, deploy); err != nil {
-		da.log.Debug("Kube-API error! Deleting deployment from DB.")
-		if err := da.mongo.DeleteDeployment(nsID, deploy.Name); err != nil {
-			return nil, err
-		}
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	newDeploy := deployment.DeploymentFromKube(nsID, userID, deploy)
+	newDeploy.CreatedAt = &createdAt
+	var createdDeploy deployment.DeploymentResource
+	if err := da.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateDeployment,
+		NamespaceID: nsID,
+		Name:        deploy.Name,
+		Deployment:  &newDeploy,
+	}, func() (err error) {
+		createdDeploy, err = da.storage.CreateDeployment(newDeploy)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -140,69 +149,67 @@ func (da *DeployActionsImpl) UpdateDeployment(ctx context.Context, nsID string,
 		return nil, err
 	}
 
-	nsUsage, err := da.mongo.GetNamespaceResourcesLimits(nsID)
+	nsUsage, err := da.storage.GetNamespaceResourcesLimits(nsID)
 	if err != nil {
 		return nil, err
 	}
 
-	oldDeploy, err := da.mongo.GetDeployment(nsID, deploy.Name)
+	oldDeploy, err := da.storage.GetDeployment(nsID, deploy.Name)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := server.CheckRevision(ctx, oldDeploy.ID, oldDeploy.Revision); err != nil {
+		return nil, err
+	}
+
 	if err := server.CheckDeploymentReplaceQuotas(nsLimits, nsUsage, oldDeploy.Deployment, deploy); err != nil {
 		return nil, err
 	}
 
-	oldLatestDeploy, err := da.mongo.GetDeploymentLatestVersion(nsID, deploy.Name)
+	oldLatestDeploy, err := da.storage.GetDeploymentLatestVersion(nsID, deploy.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	oldversion := oldLatestDeploy.Deployment.Version
-
 	deploy.Version = diff.NewVersion(oldLatestDeploy.Deployment, deploy)
 	deploy.Active = true
 
 	newversion := deploy.Version
 
-	var updatedDeploy deployment.DeploymentResource
-	if !newversion.Equals(oldversion) {
-		if err := da.mongo.DeactivateDeployment(nsID, deploy.Name); err != nil {
-			return nil, err
-		}
-
-		updatedDeploy, err = da.mongo.CreateDeployment(deployment.DeploymentFromKube(nsID, userID, deploy))
-		if err != nil {
-			return nil, err
-		}
-
-		if err := da.kube.UpdateDeployment(ctx, nsID, deploy); err != nil {
-			da.log.Debug("Kube-API error! Reverting changes.")
-			if err := da.mongo.DeleteDeploymentVersion(nsID, deploy.Name, newversion); err != nil {
-				return nil, err
-			}
-			if err := da.mongo.ActivateDeployment(nsID, deploy.Name, oldDeploy.Version); err != nil {
-				return nil, err
-			}
-			return nil, err
-		}
+	// active version is updated in place if version number is not bumped
+	inPlace := newversion.Equals(oldDeploy.Version)
+	newDeploy := deployment.DeploymentFromKube(nsID, userID, deploy)
+	newDeploy.CreatedAt = oldDeploy.CreatedAt
+	if inPlace {
+		newDeploy.ID = oldDeploy.ID
+		newDeploy.Revision = oldDeploy.Revision
 	} else {
-		if err := da.mongo.UpdateActiveDeployment(deployment.DeploymentFromKube(nsID, userID, deploy)); err != nil {
-			return nil, err
-		}
-		updatedDeploy, err = da.mongo.GetDeployment(nsID, deploy.Name)
-		if err != nil {
-			return nil, err
-		}
+		newDeploy.Revision = oldDeploy.Revision + 1
+	}
 
-		if err := da.kube.UpdateDeployment(ctx, nsID, deploy); err != nil {
-			da.log.Debug("Kube-API error! Reverting changes.")
-			if err := da.mongo.UpdateActiveDeployment(oldDeploy); err != nil {
-				return nil, err
+	var updatedDeploy deployment.DeploymentResource
+	if err := da.journal.execute(ctx, operation.Operation{
+		Kind:          operation.UpdateDeployment,
+		NamespaceID:   nsID,
+		Name:          deploy.Name,
+		Deployment:    &newDeploy,
+		OldDeployment: &oldDeploy,
+	}, func() (err error) {
+		if !inPlace {
+			if err := da.storage.DeactivateDeployment(nsID, deploy.Name, oldDeploy.Revision); err != nil {
+				return err
 			}
-			return nil, err
+			updatedDeploy, err = da.storage.CreateDeployment(newDeploy)
+			return err
 		}
+		if err := da.storage.UpdateActiveDeployment(newDeploy); err != nil {
+			return err
+		}
+		updatedDeploy, err = da.storage.GetDeployment(nsID, deploy.Name)
+		return err
+	}); err != nil {
+		return nil, err
 	}
 
 	return &updatedDeploy, nil
@@ -221,17 +228,21 @@ func (da *DeployActionsImpl) SetDeploymentReplicas(ctx context.Context, nsID, de
 		return nil, err
 	}
 
-	nsUsage, err := da.mongo.GetNamespaceResourcesLimits(nsID)
+	nsUsage, err := da.storage.GetNamespaceResourcesLimits(nsID)
 	if err != nil {
 		return nil, err
 	}
 
-	oldDeploy, err := da.mongo.GetDeployment(nsID, deplName)
+	oldDeploy, err := da.storage.GetDeployment(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
 
-	newDeploy := oldDeploy
+	if err := server.CheckRevision(ctx, oldDeploy.ID, oldDeploy.Revision); err != nil {
+		return nil, err
+	}
+
+	newDeploy := oldDeploy.Copy()
 	newDeploy.Replicas = req.Replicas
 	newDeploy.Active = true
 	if err := server.CheckDeploymentReplicasChangeQuotas(nsLimits, nsUsage, oldDeploy.Deployment, req.Replicas); err != nil {
@@ -240,19 +251,19 @@ func (da *DeployActionsImpl) SetDeploymentReplicas(ctx context.Context, nsID, de
 
 	server.CalculateDeployResources(&newDeploy.Deployment)
 
-	if err := da.mongo.UpdateActiveDeployment(newDeploy); err != nil {
+	if err := da.journal.execute(ctx, operation.Operation{
+		Kind:          operation.SetDeploymentReplicas,
+		NamespaceID:   nsID,
+		Name:          deplName,
+		Deployment:    &newDeploy,
+		OldDeployment: &oldDeploy,
+	}, func() error {
+		return da.storage.UpdateActiveDeployment(newDeploy)
+	}); err != nil {
 		return nil, err
 	}
 
-	if err := da.kube.SetDeploymentReplicas(ctx, nsID, newDeploy.Name, req.Replicas); err != nil {
-		da.log.Debug("Kube-API error! Reverting changes.")
-		if err := da.mongo.UpdateActiveDeployment(oldDeploy); err != nil {
-			return nil, err
-		}
-		return nil, err
-	}
-
-	updatedDeploy, err := da.mongo.GetDeployment(nsID, deplName)
+	updatedDeploy, err := da.storage.GetDeployment(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
@@ -280,11 +291,20 @@ func (da *DeployActionsImpl) RenameDeploymentVersion(ctx context.Context, nsID,
 		return nil, err
 	}
 
-	if err := da.mongo.UpdateDeploymentVersion(nsID, deplName, oldDeplVersion, newDeplVersion); err != nil {
+	oldDeploy, err := da.storage.GetDeploymentVersion(nsID, deplName, oldDeplVersion)
+	if err != nil {
 		return nil, err
 	}
 
-	updatedDeploy, err := da.mongo.GetDeploymentVersion(nsID, deplName, newDeplVersion)
+	if err := server.CheckRevision(ctx, oldDeploy.ID, oldDeploy.Revision); err != nil {
+		return nil, err
+	}
+
+	if err := da.storage.UpdateDeploymentVersion(nsID, deplName, oldDeplVersion, newDeplVersion, oldDeploy.Revision); err != nil {
+		return nil, err
+	}
+
+	updatedDeploy, err := da.storage.GetDeploymentVersion(nsID, deplName, newDeplVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -300,12 +320,18 @@ func (da *DeployActionsImpl) SetDeploymentContainerImage(ctx context.Context, ns
 		"deploy_name": deplName,
 	}).Infof("set container image %#v", req)
 
-	oldDeploy, err := da.mongo.GetDeployment(nsID, deplName)
+	oldDeploy, err := da.storage.GetDeployment(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
 
-	newDeploy := oldDeploy
+	if err := server.CheckRevision(ctx, oldDeploy.ID, oldDeploy.Revision); err != nil {
+		return nil, err
+	}
+
+	newDeploy := oldDeploy.Copy()
+	newDeploy.ID = uuid.New().String()
+	newDeploy.Revision = oldDeploy.Revision + 1
 
 	updated := false
 	for i, c := range newDeploy.Containers {
@@ -319,30 +345,27 @@ func (da *DeployActionsImpl) SetDeploymentContainerImage(ctx context.Context, ns
 		return nil, rserrors.ErrNoContainer()
 	}
 
-	oldLatestDeploy, err := da.mongo.GetDeploymentLatestVersion(nsID, deplName)
+	oldLatestDeploy, err := da.storage.GetDeploymentLatestVersion(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
 
 	newDeploy.Version = diff.NewVersion(oldLatestDeploy.Deployment, newDeploy.Deployment)
 
-	if err := da.mongo.DeactivateDeployment(nsID, newDeploy.Name); err != nil {
-		return nil, err
-	}
-
-	updatedDeploy, err := da.mongo.CreateDeployment(newDeploy)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := da.kube.UpdateDeployment(ctx, nsID, newDeploy.Deployment); err != nil {
-		da.log.Debug("Kube-API error! Reverting changes.")
-		if err := da.mongo.DeactivateDeployment(nsID, newDeploy.Name); err != nil {
-			return nil, err
-		}
-		if err := da.mongo.ActivateDeployment(nsID, newDeploy.Name, oldDeploy.Version); err != nil {
-			return nil, err
+	var updatedDeploy deployment.DeploymentResource
+	if err := da.journal.execute(ctx, operation.Operation{
+		Kind:          operation.UpdateDeployment,
+		NamespaceID:   nsID,
+		Name:          deplName,
+		Deployment:    &newDeploy,
+		OldDeployment: &oldDeploy,
+	}, func() (err error) {
+		if err := da.storage.DeactivateDeployment(nsID, newDeploy.Name, oldDeploy.Revision); err != nil {
+			return err
 		}
+		updatedDeploy, err = da.storage.CreateDeployment(newDeploy)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -357,42 +380,47 @@ func (da *DeployActionsImpl) ChangeActiveDeployment(ctx context.Context, nsID, d
 		"deploy_name": deplName,
 	}).Infof("change active version %v", version)
 
-	oldDeploy, err := da.mongo.GetDeployment(nsID, deplName)
+	oldDeploy, err := da.storage.GetDeployment(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
 
-	deplVersion, err := semver.Parse(version)
-	if err != nil {
+	if err := server.CheckRevision(ctx, oldDeploy.ID, oldDeploy.Revision); err != nil {
 		return nil, err
 	}
 
-	newDeploy, err := da.mongo.GetDeploymentVersion(nsID, deplName, deplVersion)
+	deplVersion, err := semver.Parse(version)
 	if err != nil {
 		return nil, err
 	}
-	newDeploy.Active = true
 
-	if err := da.mongo.DeactivateDeployment(nsID, newDeploy.Name); err != nil {
+	newDeploy, err := da.storage.GetDeploymentVersion(nsID, deplName, deplVersion)
+	if err != nil {
 		return nil, err
 	}
+	newDeploy.Active = true
 
-	if err := da.kube.UpdateDeployment(ctx, nsID, newDeploy.Deployment); err != nil {
-		da.log.Debug("Kube-API error! Reverting changes.")
-		if err := da.mongo.DeactivateDeployment(nsID, newDeploy.Name); err != nil {
-			return nil, err
-		}
-		if err := da.mongo.ActivateDeployment(nsID, oldDeploy.Name, oldDeploy.Version); err != nil {
-			return nil, err
+	if err := da.journal.execute(ctx, operation.Operation{
+		Kind:          operation.ChangeActiveDeployment,
+		NamespaceID:   nsID,
+		Name:          deplName,
+		Deployment:    &newDeploy,
+		OldDeployment: &oldDeploy,
+	}, func() error {
+		if err := da.storage.DeactivateDeployment(nsID, newDeploy.Name, oldDeploy.Revision); err != nil {
+			return err
 		}
+		return da.storage.ActivateDeployment(nsID, newDeploy.Name, newDeploy.Version)
+	}); err != nil {
 		return nil, err
 	}
 
-	if err := da.mongo.ActivateDeployment(nsID, newDeploy.Name, newDeploy.Version); err != nil {
+	activeDeploy, err := da.storage.GetDeployment(nsID, deplName)
+	if err != nil {
 		return nil, err
 	}
 
-	return &newDeploy, nil
+	return &activeDeploy, nil
 }
 
 func (da *DeployActionsImpl) DeleteDeployment(ctx context.Context, nsID, deplName string) error {
@@ -403,19 +431,13 @@ func (da *DeployActionsImpl) DeleteDeployment(ctx context.Context, nsID, deplNam
 		"deploy_name": deplName,
 	}).Info("delete deployment")
 
-	if err := da.mongo.DeleteDeployment(nsID, deplName); err != nil {
-		return err
-	}
-
-	if err := da.kube.DeleteDeployment(ctx, nsID, deplName); err != nil {
-		da.log.Debug("Kube-API error! Reverting changes.")
-		if err := da.mongo.RestoreDeployment(nsID, deplName); err != nil {
-			return err
-		}
-		return err
-	}
-
-	return nil
+	return da.journal.execute(ctx, operation.Operation{
+		Kind:        operation.DeleteDeployment,
+		NamespaceID: nsID,
+		Name:        deplName,
+	}, func() error {
+		return da.storage.DeleteDeployment(nsID, deplName)
+	})
 }
 
 func (da *DeployActionsImpl) DeleteDeploymentVersion(ctx context.Context, nsID, deplName, version string) error {
@@ -431,14 +453,14 @@ func (da *DeployActionsImpl) DeleteDeploymentVersion(ctx context.Context, nsID,
 		return err
 	}
 
-	activeDeploy, err := da.mongo.GetDeployment(nsID, deplName)
+	activeDeploy, err := da.storage.GetDeployment(nsID, deplName)
 	if err == nil {
 		if activeDeploy.Version.Equals(deplVersion) {
 			return rserrors.ErrUnableDeleteActiveDeploymentVersion()
 		}
 	}
 
-	return da.mongo.DeleteDeploymentVersion(nsID, deplName, deplVersion)
+	return da.storage.DeleteDeploymentVersion(nsID, deplName, deplVersion)
 }
 
 func (da *DeployActionsImpl) DeleteAllDeployments(ctx context.Context, nsID string) error {
@@ -446,7 +468,7 @@ func (da *DeployActionsImpl) DeleteAllDeployments(ctx context.Context, nsID stri
 		"ns_id": nsID,
 	}).Info("delete all deployments")
 
-	if err := da.mongo.DeleteAllDeploymentsInNamespace(nsID); err != nil {
+	if err := da.storage.DeleteAllDeploymentsInNamespace(nsID); err != nil {
 		return err
 	}
 	return nil
@@ -470,12 +492,12 @@ func (da *DeployActionsImpl) DiffDeployments(ctx context.Context, nsID, deplName
 		return nil, err
 	}
 
-	depl1, err := da.mongo.GetDeploymentVersion(nsID, deplName, v1)
+	depl1, err := da.storage.GetDeploymentVersion(nsID, deplName, v1)
 	if err != nil {
 		return nil, err
 	}
 
-	depl2, err := da.mongo.GetDeploymentVersion(nsID, deplName, v2)
+	depl2, err := da.storage.GetDeploymentVersion(nsID, deplName, v2)
 	if err != nil {
 		return nil, err
 	}
@@ -496,7 +518,7 @@ func (da *DeployActionsImpl) DiffDeploymentsPrevious(ctx context.Context, nsID,
 		return nil, err
 	}
 
-	deplList, err := da.mongo.GetDeploymentVersionsList(nsID, deplName)
+	deplList, err := da.storage.GetDeploymentVersionsList(nsID, deplName)
 	if err != nil {
 		return nil, err
 	}
@@ -527,12 +549,12 @@ func (da *DeployActionsImpl) DiffDeploymentsPrevious(ctx context.Context, nsID,
 		return nil, rserrors.ErrResourceNotExists().AddDetails("no previous version found")
 	}
 
-	depl1, err := da.mongo.GetDeploymentVersion(nsID, deplName, v1)
+	depl1, err := da.storage.GetDeploymentVersion(nsID, deplName, v1)
 	if err != nil {
 		return nil, err
 	}
 
-	depl2, err := da.mongo.GetDeploymentVersion(nsID, deplName, v2)
+	depl2, err := da.storage.GetDeploymentVersion(nsID, deplName, v2)
 	if err != nil {
 		return nil, err
 	}
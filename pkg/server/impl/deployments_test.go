@@ -0,0 +1,115 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/blang/semver"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployActionsRevert(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var permissions clients.Permissions
+
+	var depl = model.Deployment{Name: "web", Replicas: 1, Active: true, Version: semver.MustParse("1.0.0"),
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	_, err := storage.CreateDeployment(deployment.DeploymentResource{ID: uuid.New().String(), NamespaceID: "ns", Deployment: depl})
+	assert.NoError(t, err)
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+	var deploy = NewDeployActionsImpl(storage, &permissions, &kube)
+
+	// failed kube-api update reverts new version
+	var fault = errors.New("fault")
+	fake.FailMethod("UpdateDeployment", fault)
+	_, err = deploy.SetDeploymentContainerImage(ctx, "ns", "web", model.UpdateImage{Container: "nginx", Image: "nginx:2"})
+	assert.Equal(t, fault, err)
+	active, err := storage.GetDeployment("ns", "web")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1.0.0", active.Version.String())
+		assert.Equal(t, "nginx", active.Containers[0].Image)
+	}
+
+	// failed kube-api deletion restores record, next attempt deletes deployment
+	fake.ClearFaults()
+	fake.FailCall("DeleteDeployment", 1, fault)
+	assert.Equal(t, fault, deploy.DeleteDeployment(ctx, "ns", "web"))
+	_, err = storage.GetDeployment("ns", "web")
+	assert.NoError(t, err)
+	assert.NoError(t, deploy.DeleteDeployment(ctx, "ns", "web"))
+	list, err := kube.GetDeploymentList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Equal(t, 2, fake.Calls("DeleteDeployment"))
+}
+
+// racingStorage runs concurrent change once after active deployment is read
+type racingStorage struct {
+	db.Storage
+	race func()
+}
+
+func (storage *racingStorage) GetDeployment(namespaceID, deploymentName string) (deployment.DeploymentResource, error) {
+	depl, err := storage.Storage.GetDeployment(namespaceID, deploymentName)
+	if race := storage.race; race != nil {
+		storage.race = nil
+		race()
+	}
+	return depl, err
+}
+
+func TestDeployActionsConcurrentUpdate(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = &racingStorage{Storage: db.NewMemory()}
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var permissions clients.Permissions
+
+	var container = model.Container{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}
+	for _, depl := range []model.Deployment{
+		{Name: "web", Replicas: 1, Version: semver.MustParse("1.0.0"), Containers: []model.Container{container}},
+		{Name: "web", Replicas: 1, Active: true, Version: semver.MustParse("1.0.1"), Containers: []model.Container{container}},
+	} {
+		_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: depl})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", model.Deployment{Name: "web", Replicas: 1, Containers: []model.Container{container}}))
+	var deploy = NewDeployActionsImpl(storage, &permissions, &kube)
+	var concurrentUpdate = func() {
+		active, err := storage.Storage.GetDeployment("ns", "web")
+		assert.NoError(t, err)
+		active.Replicas++
+		assert.NoError(t, storage.Storage.UpdateActiveDeployment(active))
+	}
+
+	// version changed after it is read is not overwritten
+	storage.race = concurrentUpdate
+	_, err := deploy.SetDeploymentContainerImage(ctx, "ns", "web", model.UpdateImage{Container: "nginx", Image: "nginx:2"})
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceModified()), "%v", err)
+	storage.race = concurrentUpdate
+	_, err = deploy.ChangeActiveDeployment(ctx, "ns", "web", "1.0.0")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceModified()), "%v", err)
+
+	active, err := storage.GetDeployment("ns", "web")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1.0.1", active.Version.String())
+		assert.Equal(t, 3, active.Replicas)
+		assert.Equal(t, "nginx", active.Containers[0].Image)
+	}
+	versions, err := storage.GetDeploymentVersionsList("ns", "web")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 0, fake.Calls("UpdateDeployment"))
+}
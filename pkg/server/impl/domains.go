@@ -13,15 +13,15 @@ import (
 )
 
 type DomainActionsImpl struct {
-	kube  *clients.Kube
-	mongo *db.MongoStorage
-	log   *cherrylog.LogrusAdapter
+	kube    *clients.Kube
+	storage db.Storage
+	log     *cherrylog.LogrusAdapter
 }
 
-func NewDomainActionsImpl(mongo *db.MongoStorage) *DomainActionsImpl {
+func NewDomainActionsImpl(storage db.Storage) *DomainActionsImpl {
 	return &DomainActionsImpl{
-		mongo: mongo,
-		log:   cherrylog.NewLogrusAdapter(logrus.WithField("component", "domain_actions")),
+		storage: storage,
+		log:     cherrylog.NewLogrusAdapter(logrus.WithField("component", "domain_actions")),
 	}
 }
 
@@ -33,34 +33,32 @@ func (da *DomainActionsImpl) GetDomainsList(ctx context.Context, page, per_page
 
 	if pageerr == nil && perpageerr == nil {
 		if pagei > 0 && perpagei > 0 {
-			return da.mongo.GetDomainsList(&db.PageInfo{
+			return da.storage.GetDomainsList(&db.PageInfo{
 				Page:    pagei,
 				PerPage: perpagei,
 			})
 		}
 	}
 
-	return da.mongo.GetDomainsList(&db.PageInfo{
-		Page:    1,
-		PerPage: 100,
-	})
+	// without page parameters all domains are returned, they are paginated by list query
+	return da.storage.GetDomainsList(nil)
 }
 
 func (da *DomainActionsImpl) GetDomain(ctx context.Context, domain string) (*domain.Domain, error) {
 	da.log.WithField("domain", domain).Info("get domain")
-	return da.mongo.GetDomain(domain)
+	return da.storage.GetDomain(domain)
 }
 
 func (da *DomainActionsImpl) AddDomain(ctx context.Context, req domain.Domain) (*domain.Domain, error) {
 	da.log.Infof("add domain %#v", req)
 
-	return da.mongo.CreateDomain(req)
+	return da.storage.CreateDomain(req)
 }
 
 func (da *DomainActionsImpl) DeleteDomain(ctx context.Context, domain string) error {
 	da.log.WithField("domain", domain).Info("delete domain")
 
-	err := da.mongo.DeleteDomain(domain)
+	err := da.storage.DeleteDomain(domain)
 
 	return err
 }
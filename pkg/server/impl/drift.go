@@ -0,0 +1,146 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/metrics"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// DriftActionsImpl detects resources which differ in storage and kube-api.
+// Writes go to both systems and are reverted on best-effort basis, so they may drift apart.
+type DriftActionsImpl struct {
+	storage db.Storage
+	kube    clients.Kube
+	log     *cherrylog.LogrusAdapter
+}
+
+func NewDriftActionsImpl(storage db.Storage, kube *clients.Kube) *DriftActionsImpl {
+	return &DriftActionsImpl{
+		storage: storage,
+		kube:    *kube,
+		log:     cherrylog.NewLogrusAdapter(logrus.WithField("component", "drift_actions")),
+	}
+}
+
+// DetectDrift compares namespace resources or resources of all namespaces with active resources if nsID is empty.
+// Namespaces which could not be checked are listed in report. Only checks of all namespaces update metrics.
+func (da *DriftActionsImpl) DetectDrift(ctx context.Context, nsID string) (*drift.Report, error) {
+	da.log.WithField("ns_id", nsID).Info("detect drift")
+
+	var namespaces = []string{nsID}
+	if nsID == "" {
+		var err error
+		if namespaces, err = da.storage.GetNamespacesList(); err != nil {
+			return nil, err
+		}
+	}
+
+	var report = drift.Report{
+		CheckedAt:  time.Now().UTC().Format(time.RFC3339),
+		Namespaces: len(namespaces),
+		Resources:  make([]drift.Resource, 0),
+	}
+	for _, ns := range namespaces {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		resources, err := da.detectNamespaceDrift(ctx, ns)
+		if err != nil {
+			cherryErr, ok := err.(*cherry.Err)
+			if !ok {
+				cherryErr = rserrors.ErrInternal().AddDetailsErr(err)
+			}
+			da.log.WithError(err).WithField("ns_id", ns).Warn("unable to detect namespace drift")
+			report.Errors = append(report.Errors, drift.NamespaceError{NamespaceID: ns, Error: cherryErr})
+			continue
+		}
+		report.Resources = append(report.Resources, resources...)
+	}
+
+	if nsID == "" {
+		updateDriftMetrics(report)
+	}
+	return &report, nil
+}
+
+func (da *DriftActionsImpl) detectNamespaceDrift(ctx context.Context, nsID string) ([]drift.Resource, error) {
+	var ret []drift.Resource
+
+	deployments, err := da.storage.GetDeploymentList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	kubeDeployments, err := da.kube.GetDeploymentList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, drift.CompareDeployments(nsID, deployments, kubeDeployments)...)
+
+	services, err := da.storage.GetServiceList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	kubeServices, err := da.kube.GetServiceList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, drift.CompareServices(nsID, services, kubeServices)...)
+
+	ingresses, err := da.storage.GetIngressList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	kubeIngresses, err := da.kube.GetIngressList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, drift.CompareIngresses(nsID, ingresses, kubeIngresses)...)
+
+	return ret, nil
+}
+
+func updateDriftMetrics(report drift.Report) {
+	for _, kind := range drift.Kinds {
+		for _, typ := range drift.Types {
+			metrics.DriftResources.WithLabelValues(string(kind), string(typ)).Set(float64(report.Count(kind, typ)))
+		}
+	}
+	metrics.DriftNamespaceErrors.Set(float64(len(report.Errors)))
+	metrics.DriftLastCheck.SetToCurrentTime()
+}
+
+// Run checks all namespaces for drift periodically until context is done
+func (da *DriftActionsImpl) Run(ctx context.Context, period time.Duration) {
+	da.log.WithField("period", period).Info("starting drift detection loop")
+	var ticker = time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		// checks are made by service itself, not on behalf of some user
+		report, err := da.DetectDrift(server.BackgroundContext(ctx, uuid.Nil.String()), "")
+		switch {
+		case err != nil:
+			da.log.WithError(err).Error("unable to detect drift")
+		case len(report.Resources) > 0 || len(report.Errors) > 0:
+			da.log.WithFields(logrus.Fields{
+				"drifted":          len(report.Resources),
+				"namespace_errors": len(report.Errors),
+			}).Warn("resources drifted from storage records")
+		}
+		select {
+		case <-ctx.Done():
+			da.log.Info("stopping drift detection loop")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/blang/semver"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDrift(t *testing.T) {
+	var ctx = context.Background()
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+
+	var port = 80
+	var depl = model.Deployment{Name: "web", Replicas: 1, Active: true, Version: semver.MustParse("1.0.0"),
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	var svc = model.Service{Name: "web", Deploy: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+	_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: depl})
+	assert.NoError(t, err)
+	_, err = storage.CreateService(service.ServiceResource{NamespaceID: "ns", Service: svc})
+	assert.NoError(t, err)
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+
+	// changes made behind resource-service
+	assert.NoError(t, kube.SetDeploymentReplicas(ctx, "ns", "web", 3))
+	assert.NoError(t, kube.CreateService(ctx, "ns", model.Service{Name: "manual", Deploy: "web"}))
+
+	report, err := NewDriftActionsImpl(storage, &kube).DetectDrift(ctx, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, report.Namespaces)
+		assert.Empty(t, report.Errors)
+		assert.Equal(t, []drift.Resource{
+			{NamespaceID: "ns", Kind: drift.KindDeployment, Name: "web", Type: drift.Differs, Fields: []string{"replicas"}},
+			{NamespaceID: "ns", Kind: drift.KindService, Name: "manual", Type: drift.Extra},
+			{NamespaceID: "ns", Kind: drift.KindService, Name: "web", Type: drift.Missing},
+		}, report.Resources)
+	}
+}
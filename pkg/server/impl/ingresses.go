@@ -2,36 +2,71 @@ package impl
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"git.containerum.net/ch/resource-service/pkg/clients"
 	"git.containerum.net/ch/resource-service/pkg/db"
 	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
 	"git.containerum.net/ch/resource-service/pkg/models/service"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
 	"github.com/containerum/cherry/adaptors/cherrylog"
 	kubtypes "github.com/containerum/kube-client/pkg/model"
 	"github.com/containerum/utils/httputil"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/net/idna"
 )
 
-const (
-	ingressHostSuffix = ".hub.containerum.io"
-)
+const basicAuthSecretPrefix = "basic-auth-"
 
 type IngressActionsImpl struct {
-	kube  clients.Kube
-	mongo *db.MongoStorage
-	log   *cherrylog.LogrusAdapter
+	kube        clients.Kube
+	storage     db.Storage
+	permissions clients.Permissions
+	suffixes    ingress.HostSuffixList
+	acme        *ACMEActionsImpl
+	journal     journal
+	log         *cherrylog.LogrusAdapter
 }
 
-func NewIngressActionsImpl(mongo *db.MongoStorage, kube *clients.Kube) *IngressActionsImpl {
+// NewIngressActionsImpl creates ingress actions. acme may be nil if automatic certificates are not configured.
+func NewIngressActionsImpl(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube, suffixes ingress.HostSuffixList, acme *ACMEActionsImpl) *IngressActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "ingress_actions"))
 	return &IngressActionsImpl{
-		kube:  *kube,
-		mongo: mongo,
-		log:   cherrylog.NewLogrusAdapter(logrus.WithField("component", "ingress_actions")),
+		kube:        *kube,
+		storage:     storage,
+		permissions: *permissions,
+		suffixes:    suffixes,
+		acme:        acme,
+		journal:     newJournal(storage, *kube, log),
+		log:         log,
+	}
+}
+
+// GetIngressSuffixes returns configured host suffixes. IPs of domain groups are infrastructure details, so they are returned to admins only.
+func (ia *IngressActionsImpl) GetIngressSuffixes(ctx context.Context) (ingress.HostSuffixList, error) {
+	ia.log.Info("get ingress host suffixes")
+
+	var admin = server.IsAdmin(ctx)
+	var suffixes = make(ingress.HostSuffixList, 0, len(ia.suffixes))
+	for _, hostSuffix := range ia.suffixes {
+		if !admin {
+			hostSuffix.DomainGroup, hostSuffix.IPs = "", nil
+		} else if hostSuffix.DomainGroup != "" {
+			domains, err := ia.storage.GetDomainGroup(hostSuffix.DomainGroup)
+			if err != nil {
+				return nil, err
+			}
+			hostSuffix.IPs = nil
+			for _, domain := range domains {
+				hostSuffix.IPs = append(hostSuffix.IPs, domain.IP...)
+			}
+		}
+		suffixes = append(suffixes, hostSuffix)
 	}
+	return suffixes, nil
 }
 
 func (ia *IngressActionsImpl) GetIngressesList(ctx context.Context, nsID string) (ingress.IngressList, error) {
@@ -41,118 +76,522 @@ func (ia *IngressActionsImpl) GetIngressesList(ctx context.Context, nsID string)
 		"namespace": nsID,
 	}).Info("get user ingresses")
 
-	return ia.mongo.GetIngressList(nsID)
+	return ia.storage.GetIngressList(nsID)
 }
 
 func (ia *IngressActionsImpl) GetIngress(ctx context.Context, nsID, ingressName string) (*ingress.IngressResource, error) {
 	ia.log.Info("get all ingresses")
 
-	resp, err := ia.mongo.GetIngress(nsID, ingressName)
+	resp, err := ia.storage.GetIngress(nsID, ingressName)
 
 	return &resp, err
 }
 
-func (ia *IngressActionsImpl) CreateIngress(ctx context.Context, nsID string, req kubtypes.Ingress) (*ingress.IngressResource, error) {
+func (ia *IngressActionsImpl) CreateIngress(ctx context.Context, nsID string, req ingress.IngressRequest) (*ingress.IngressResource, error) {
 	userID := httputil.MustGetUserID(ctx)
 	ia.log.WithFields(logrus.Fields{
 		"user_id": userID,
 		"ns_id":   nsID,
-	}).Infof("create ingress %#v", req)
+	}).Infof("create ingress %#v", req.Ingress)
 
-	//Convert host to dns-label, validate it and append ".hub.containerum.io"
 	var err error
-	req.Rules[0].Host, err = idna.Lookup.ToASCII(req.Rules[0].Host)
+	req.Rules, req.Splits, err = ia.prepareRules(ctx, nsID, req.Rules, req.Splits)
 	if err != nil {
-		return nil, rserrors.ErrValidation().AddDetailsErr(err)
+		return nil, err
 	}
+	req.Name = ingress.IngressName(req.Rules[0].Host)
 
-	req.Rules[0].Host = req.Rules[0].Host + ingressHostSuffix
-	req.Name = req.Rules[0].Host
+	if err := checkHostsFree(ctx, ia.storage, nsID, req.Name, req.Rules); err != nil {
+		return nil, err
+	}
 
-	if req.Rules[0].Path[0].Path == "" {
-		req.Rules[0].Path[0].Path = "/"
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	newIngress := ingress.IngressFromKube(nsID, userID, req.Ingress)
+	newIngress.CreatedAt = &createdAt
+	newIngress.Options = req.Options
+	newIngress.Splits = req.Splits
+	if err := ia.setupBasicAuth(ctx, &newIngress, nil); err != nil {
+		return nil, err
 	}
 
-	svc, err := ia.mongo.GetService(nsID, req.Rules[0].Path[0].ServiceName)
-	if err != nil {
-		ia.log.Error(err)
-		return nil, rserrors.ErrResourceNotExists().AddDetailF("service '%v' not exists", req.Rules[0].Path[0].ServiceName)
+	var createdIngress ingress.IngressResource
+	if err := ia.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateIngress,
+		NamespaceID: nsID,
+		Name:        req.Name,
+		Ingress:     &newIngress,
+	}, func() (err error) {
+		createdIngress, err = ia.storage.CreateIngress(newIngress)
+		return err
+	}); err != nil {
+		ia.deleteBasicAuthSecret(ctx, nsID, newIngress.Options.BasicAuthSecret())
+		return nil, err
 	}
 
-	req.Rules[0].Path, err = server.IngressPaths(svc.Service, req.Rules[0].Path[0].Path, req.Rules[0].Path[0].ServicePort)
+	return &createdIngress, nil
+}
+
+func (ia *IngressActionsImpl) UpdateIngress(ctx context.Context, nsID string, req ingress.IngressRequest) (*ingress.IngressResource, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ia.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"ingress": req.Ingress,
+	}).Info("update ingress")
+
+	oldIngress, err := ia.storage.GetIngress(nsID, req.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	if server.DetermineServiceType(svc.Service) != service.ServiceExternal {
-		return nil, rserrors.ErrServiceNotExternal()
+	if err := server.CheckRevision(ctx, oldIngress.ID, oldIngress.Revision); err != nil {
+		return nil, err
 	}
 
-	createdIngress, err := ia.mongo.CreateIngress(ingress.IngressFromKube(nsID, userID, req))
+	req.Rules, req.Splits, err = ia.prepareRules(ctx, nsID, req.Rules, req.Splits)
 	if err != nil {
 		return nil, err
 	}
+	req.Name = oldIngress.Name
+
+	if err := checkHostsFree(ctx, ia.storage, nsID, req.Name, req.Rules); err != nil {
+		return nil, err
+	}
 
-	if err := ia.kube.CreateIngress(ctx, nsID, req); err != nil {
-		ia.log.Debug("Kube-API error! Deleting ingress from DB.")
-		if err := ia.mongo.DeleteIngress(nsID, req.Name); err != nil {
-			return nil, err
+	newIngress := ingress.IngressFromKube(nsID, userID, req.Ingress)
+	newIngress.ID = oldIngress.ID
+	newIngress.Revision = oldIngress.Revision
+	newIngress.CreatedAt = oldIngress.CreatedAt
+	newIngress.Options = req.Options
+	newIngress.Splits = req.Splits
+	newIngress.ACME = oldIngress.ACME
+	if newIngress.ACME != nil && newIngress.ACME.Certificate != "" {
+		// keep automatic certificate for hosts it was issued for, other hosts will get new one
+		newIngress.SetTLSSecret(newIngress.ACME.Certificate, untlsHosts(newIngress, newIngress.ACME.Hosts))
+	}
+	if err := ia.setupBasicAuth(ctx, &newIngress, oldIngress.Options); err != nil {
+		return nil, err
+	}
+	var newSecret, oldSecret = newIngress.Options.BasicAuthSecret(), oldIngress.Options.BasicAuthSecret()
+
+	var ingres ingress.IngressResource
+	if err := ia.journal.execute(ctx, operation.Operation{
+		Kind:        operation.UpdateIngress,
+		NamespaceID: nsID,
+		Name:        req.Name,
+		Ingress:     &newIngress,
+		OldIngress:  &oldIngress,
+	}, func() (err error) {
+		ingres, err = ia.storage.UpdateIngress(newIngress)
+		return err
+	}); err != nil {
+		if newSecret != oldSecret {
+			ia.deleteBasicAuthSecret(ctx, nsID, newSecret)
 		}
 		return nil, err
 	}
 
-	return &createdIngress, nil
+	if newSecret != oldSecret {
+		ia.deleteBasicAuthSecret(ctx, nsID, oldSecret)
+	}
+
+	if ingres.ACME != nil && ingres.ACME.Enabled && ia.acme != nil {
+		ia.acme.Trigger()
+	}
+
+	return &ingres, nil
 }
 
-func (ia *IngressActionsImpl) UpdateIngress(ctx context.Context, nsID string, req kubtypes.Ingress) (*ingress.IngressResource, error) {
+// setupBasicAuth creates secret with htpasswd file if basic auth users are provided,
+// otherwise secret and users of previous ingress version are kept
+func (ia *IngressActionsImpl) setupBasicAuth(ctx context.Context, ingr *ingress.IngressResource, oldOptions *ingress.Options) error {
+	if ingr.Options == nil || ingr.Options.BasicAuth == nil {
+		return nil
+	}
+	var basicAuth = *ingr.Options.BasicAuth
+	var options = *ingr.Options
+	options.BasicAuth = &basicAuth
+	ingr.Options = &options
+
+	if len(basicAuth.Users) == 0 {
+		if oldOptions.BasicAuthSecret() == "" {
+			return rserrors.ErrValidation().AddDetails("basic auth users are required")
+		}
+		basicAuth.Secret = oldOptions.BasicAuth.Secret
+		basicAuth.Usernames = oldOptions.BasicAuth.Usernames
+		return nil
+	}
+
+	htpasswd, err := server.HTPasswd(basicAuth.Users)
+	if err != nil {
+		return rserrors.ErrInternal().Log(err, ia.log)
+	}
+	suffix, err := randomToken(4)
+	if err != nil {
+		return rserrors.ErrInternal().Log(err, ia.log)
+	}
+
+	basicAuth.Secret = basicAuthSecretPrefix + suffix
+	basicAuth.Usernames = make([]string, 0, len(basicAuth.Users))
+	for _, user := range basicAuth.Users {
+		basicAuth.Usernames = append(basicAuth.Usernames, user.Username)
+	}
+	basicAuth.Users = nil
+
+	return ia.kube.CreateSecret(ctx, ingr.NamespaceID, kubtypes.Secret{
+		Name:  basicAuth.Secret,
+		Owner: ingr.Owner,
+		Data: map[string]string{
+			ingress.BasicAuthSecretKey: htpasswd,
+		},
+	})
+}
+
+func (ia *IngressActionsImpl) deleteBasicAuthSecret(ctx context.Context, nsID, secret string) {
+	if secret == "" {
+		return
+	}
+	if err := ia.kube.DeleteSecret(ctx, nsID, secret); err != nil {
+		ia.log.WithError(err).WithField("secret", secret).Warn("unable to delete basic auth secret")
+	}
+}
+
+func (ia *IngressActionsImpl) SetIngressACME(ctx context.Context, nsID, ingressName string, enabled bool) (*ingress.IngressResource, error) {
 	userID := httputil.MustGetUserID(ctx)
 	ia.log.WithFields(logrus.Fields{
 		"user_id": userID,
 		"ns_id":   nsID,
-		"ingress": req,
-	}).Info("update ingress")
+		"ingress": ingressName,
+		"enabled": enabled,
+	}).Info("set ingress automatic certificates")
 
-	oldIngress, err := ia.mongo.GetIngress(nsID, req.Name)
+	if ia.acme == nil && enabled {
+		return nil, rserrors.ErrACMENotConfigured()
+	}
+
+	ingr, err := ia.storage.GetIngress(nsID, ingressName)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Rules[0].Path[0].ServiceName = oldIngress.Rules[0].Path[0].ServiceName
+	if err := server.CheckRevision(ctx, ingr.ID, ingr.Revision); err != nil {
+		return nil, err
+	}
 
-	req.Rules[0].Host = req.Rules[0].Host + ingressHostSuffix
-	req.Name = req.Rules[0].Host
+	var status ingress.ACMEStatus
+	if ingr.ACME != nil {
+		status = *ingr.ACME
+	}
+	if status.Enabled == enabled {
+		return &ingr, nil
+	}
+	status.Enabled = enabled
+	if enabled {
+		status.State = ingress.ACMEPending
+		status.Error = ""
+		status.Failures = 0
+		status.NextAttemptAt = ""
+	}
+	status.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	ingr.ACME = &status
+
+	if err := ia.storage.UpdateIngressACME(ingr); err != nil {
+		return nil, err
+	}
 
-	if req.Rules[0].Path[0].Path == "" {
-		req.Rules[0].Path[0].Path = "/"
+	if enabled {
+		ia.acme.Trigger()
 	}
 
-	svc, err := ia.mongo.GetService(nsID, req.Rules[0].Path[0].ServiceName)
+	updated, err := ia.storage.GetIngress(nsID, ingressName)
 	if err != nil {
-		ia.log.Error(err)
-		return nil, rserrors.ErrResourceNotExists().AddDetails("service '%v' not exists", req.Rules[0].Path[0].ServiceName)
+		return nil, err
 	}
 
-	req.Rules[0].Path, err = server.IngressPaths(svc.Service, req.Rules[0].Path[0].Path, req.Rules[0].Path[0].ServicePort)
+	return &updated, nil
+}
+
+func (ia *IngressActionsImpl) UpdateIngressWeights(ctx context.Context, nsID, ingressName string, req ingress.UpdateWeights) (*ingress.IngressResource, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ia.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"ingress": ingressName,
+		"weights": req,
+	}).Info("update ingress weights")
+
+	oldIngress, err := ia.storage.GetIngress(nsID, ingressName)
 	if err != nil {
 		return nil, err
 	}
 
-	ingres, err := ia.mongo.UpdateIngress(ingress.IngressFromKube(nsID, userID, req))
+	if err := server.CheckRevision(ctx, oldIngress.ID, oldIngress.Revision); err != nil {
+		return nil, err
+	}
+
+	host, err := ia.ingressHost(nsID, req.Host)
 	if err != nil {
 		return nil, err
 	}
+	if req.Path == "" {
+		req.Path = "/"
+	}
+
+	newIngress := oldIngress.Copy()
+	splitIndex := newIngress.FindSplit(host, req.Path)
+	if splitIndex < 0 {
+		return nil, rserrors.ErrResourceNotExists().AddDetailF("ingress %s has no split path %s for host %s", ingressName, req.Path, host)
+	}
 
-	if err := ia.kube.UpdateIngress(ctx, nsID, req); err != nil {
-		ia.log.Debug("Kube-API error! Reverting changes.")
-		if _, err := ia.mongo.UpdateIngress(oldIngress); err != nil {
-			return nil, err
+	var split = newIngress.Splits[splitIndex]
+	if len(req.Weights) != len(split.Backends) {
+		return nil, rserrors.ErrValidation().AddDetailF("weights must be set for all %d backends", len(split.Backends))
+	}
+	var names = make([]string, 0, len(split.Backends))
+	for i, backend := range split.Backends {
+		weight, ok := req.Weights[backend.ServiceName]
+		if !ok {
+			return nil, rserrors.ErrValidation().AddDetailF("weight for service %s is not set", backend.ServiceName)
 		}
+		split.Backends[i].Weight = weight
+		names = append(names, backend.ServiceName)
+	}
+
+	var services = make(map[string]kubtypes.Service)
+	if err := ia.loadExternalServices(nsID, services, names...); err != nil {
+		return nil, err
+	}
+	if _, err := server.IngressSplitPaths(services, split); err != nil {
+		return nil, err
+	}
+	newIngress.ApplySplits()
+
+	var ingres ingress.IngressResource
+	if err := ia.journal.execute(ctx, operation.Operation{
+		Kind:        operation.UpdateIngress,
+		NamespaceID: nsID,
+		Name:        ingressName,
+		Ingress:     &newIngress,
+		OldIngress:  &oldIngress,
+	}, func() (err error) {
+		ingres, err = ia.storage.UpdateIngress(newIngress)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
 	return &ingres, nil
 }
 
+func (ia *IngressActionsImpl) GetIngressHostConflicts(ctx context.Context) (ingress.HostConflictList, error) {
+	ia.log.Info("get ingress host conflicts")
+
+	return ia.storage.GetIngressHostConflicts()
+}
+
+// untlsHosts returns hosts from list used by ingress rules without TLS secret
+func untlsHosts(ingr ingress.IngressResource, hosts []string) []string {
+	var allowed = make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		allowed[host] = struct{}{}
+	}
+	var ret []string
+	for _, rule := range ingr.Rules {
+		if _, ok := allowed[rule.Host]; ok && rule.TLSSecret == nil {
+			ret = append(ret, rule.Host)
+		}
+	}
+	return ret
+}
+
+// checkHostsFree checks that rule hosts are not used by other ingresses in any namespace.
+// Namespace holding host is reported only if user can read it.
+func checkHostsFree(ctx context.Context, storage db.Storage, nsID, ingressName string, rules []kubtypes.Rule) error {
+	var hosts = ingress.IngressResource{Ingress: kubtypes.Ingress{Rules: rules}}.Hosts()
+	ingresses, err := storage.GetIngressesByHostsOverlap(hosts)
+	if err != nil {
+		return err
+	}
+	for _, ingr := range ingresses {
+		if ingr.NamespaceID == nsID && ingr.Name == ingressName {
+			continue
+		}
+		for _, usedHost := range ingr.Hosts() {
+			for _, host := range hosts {
+				switch {
+				case usedHost == host:
+				case ingr.NamespaceID != nsID && ingress.HostsOverlap(usedHost, host):
+					// wildcard and concrete hosts may overlap only inside one namespace
+				default:
+					continue
+				}
+				if server.CanReadNamespace(ctx, ingr.NamespaceID) {
+					return rserrors.ErrIngressHostConflict().AddDetailF("host %s overlaps host %s used by ingress %s in namespace %s", host, usedHost, ingr.Name, ingr.NamespaceID)
+				}
+				return rserrors.ErrIngressHostConflict().AddDetailF("host %s overlaps host used in another namespace", host)
+			}
+		}
+	}
+	return nil
+}
+
+// ingressHost returns host as is if it is a custom domain verified in namespace, else adds hosting suffix
+func (ia *IngressActionsImpl) ingressHost(nsID, host string) (string, error) {
+	if base, wildcard := ingress.WildcardBase(host); wildcard {
+		if strings.Contains(base, "*") {
+			return "", rserrors.ErrValidation().AddDetailF("host %s may have only one leading wildcard label", host)
+		}
+		base, err := ia.ingressHost(nsID, base)
+		if err != nil {
+			return "", err
+		}
+		return ingress.WildcardPrefix + base, nil
+	}
+
+	host, err := server.NormalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+	customDomain, err := ia.storage.GetCustomDomain(nsID, host)
+	switch {
+	case err == nil:
+		if !customDomain.Verified {
+			return "", rserrors.ErrDomainNotVerified().AddDetails(host)
+		}
+		return host, nil
+	case cherry.Equals(err, rserrors.ErrResourceNotExists()):
+		return server.IngressHost(host, ia.suffixes)
+	default:
+		return "", err
+	}
+}
+
+// prepareRules converts rule and split hosts to ingress hosts and checks every path and split backend against its service
+func (ia *IngressActionsImpl) prepareRules(ctx context.Context, nsID string, rules []kubtypes.Rule, splits []ingress.Split) ([]kubtypes.Rule, []ingress.Split, error) {
+	var services = make(map[string]kubtypes.Service)
+	var prepared = make([]kubtypes.Rule, 0, len(rules))
+	for _, rule := range rules {
+		var err error
+		rule.Host, err = ia.ingressHost(nsID, rule.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rule.TLSSecret != nil && *rule.TLSSecret != "" {
+			cert, err := ia.storage.GetCertificate(nsID, *rule.TLSSecret)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := server.CheckCertificateHost(cert, rule.Host, time.Now()); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			rule.TLSSecret = nil
+		}
+
+		var paths = make([]kubtypes.Path, 0, len(rule.Path))
+		for _, path := range rule.Path {
+			if path.Path == "" {
+				path.Path = "/"
+			}
+
+			if err := ia.loadExternalServices(nsID, services, path.ServiceName); err != nil {
+				return nil, nil, err
+			}
+
+			servicePaths, err := server.IngressPaths(services, path.Path, ingress.Backend{
+				ServiceName: path.ServiceName,
+				ServicePort: path.ServicePort,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			paths = append(paths, servicePaths...)
+		}
+		rule.Path = paths
+		prepared = append(prepared, rule)
+	}
+
+	if err := server.CheckIngressPathsUnique(prepared); err != nil {
+		return nil, nil, err
+	}
+
+	var preparedIngress = ingress.IngressResource{Ingress: kubtypes.Ingress{Rules: prepared}}
+	if preparedIngress.HasWildcardHosts() {
+		allowed, err := ia.permissions.WildcardHostsAllowed(ctx, nsID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !allowed {
+			return nil, nil, rserrors.ErrWildcardHostsNotAllowed()
+		}
+	}
+	for _, split := range splits {
+		var err error
+		split.Host, err = ia.ingressHost(nsID, split.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		if split.Path == "" {
+			split.Path = "/"
+		}
+		if preparedIngress.FindSplit(split.Host, split.Path) >= 0 {
+			return nil, nil, rserrors.ErrValidation().AddDetailF("path %s for host %s is split more than once", split.Path, split.Host)
+		}
+		if !hasRulePath(prepared, split.Host, split.Path) {
+			return nil, nil, rserrors.ErrValidation().AddDetailF("split path %s for host %s not exists in ingress rules", split.Path, split.Host)
+		}
+
+		var names = make([]string, 0, len(split.Backends))
+		for _, backend := range split.Backends {
+			names = append(names, backend.ServiceName)
+		}
+		if err := ia.loadExternalServices(nsID, services, names...); err != nil {
+			return nil, nil, err
+		}
+		if _, err := server.IngressSplitPaths(services, split); err != nil {
+			return nil, nil, err
+		}
+		preparedIngress.Splits = append(preparedIngress.Splits, split)
+	}
+	preparedIngress.ApplySplits()
+
+	return preparedIngress.Rules, preparedIngress.Splits, nil
+}
+
+// loadExternalServices gets services missing in cache and checks that they are external
+func (ia *IngressActionsImpl) loadExternalServices(nsID string, services map[string]kubtypes.Service, names ...string) error {
+	for _, name := range names {
+		if _, ok := services[name]; ok {
+			continue
+		}
+		svc, err := ia.storage.GetService(nsID, name)
+		if err != nil {
+			ia.log.Error(err)
+			return rserrors.ErrResourceNotExists().AddDetailF("service '%v' not exists", name)
+		}
+		if server.DetermineServiceType(svc.Service) != service.ServiceExternal {
+			return rserrors.ErrServiceNotExternal().AddDetailF("service '%v' is not external", name)
+		}
+		services[name] = svc.Service
+	}
+	return nil
+}
+
+func hasRulePath(rules []kubtypes.Rule, host, path string) bool {
+	for _, rule := range rules {
+		if rule.Host != host {
+			continue
+		}
+		for _, rulePath := range rule.Path {
+			if rulePath.Path == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (ia *IngressActionsImpl) DeleteIngress(ctx context.Context, nsID, ingressName string) error {
 	userID := httputil.MustGetUserID(ctx)
 	ia.log.WithFields(logrus.Fields{
@@ -161,18 +600,23 @@ func (ia *IngressActionsImpl) DeleteIngress(ctx context.Context, nsID, ingressNa
 		"domain":  ingressName,
 	}).Info("delete ingress")
 
-	if err := ia.mongo.DeleteIngress(nsID, ingressName); err != nil {
+	ingr, err := ia.storage.GetIngress(nsID, ingressName)
+	if err != nil {
 		return err
 	}
 
-	if err := ia.kube.DeleteIngress(ctx, nsID, ingressName); err != nil {
-		ia.log.Debug("Kube-API error! Reverting changes.")
-		if err := ia.mongo.RestoreIngress(nsID, ingressName); err != nil {
-			return err
-		}
+	if err := ia.journal.execute(ctx, operation.Operation{
+		Kind:        operation.DeleteIngress,
+		NamespaceID: nsID,
+		Name:        ingressName,
+	}, func() error {
+		return ia.storage.DeleteIngress(nsID, ingressName)
+	}); err != nil {
 		return err
 	}
 
+	ia.deleteBasicAuthSecret(ctx, nsID, ingr.Options.BasicAuthSecret())
+
 	return nil
 }
 
@@ -181,7 +625,7 @@ func (ia *IngressActionsImpl) DeleteAllIngresses(ctx context.Context, nsID strin
 		"ns_id": nsID,
 	}).Info("delete all ingresses")
 
-	if err := ia.mongo.DeleteAllIngressesInNamespace(nsID); err != nil {
+	if err := ia.storage.DeleteAllIngressesInNamespace(nsID); err != nil {
 		return err
 	}
 
@@ -0,0 +1,360 @@
+package impl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/headers"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/containerum/utils/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type ingressTest struct {
+	ctx         context.Context
+	storage     db.Storage
+	fake        *clients.FakeKube
+	permissions *limitsPermissions
+	ingresses   *IngressActionsImpl
+}
+
+func newIngressTest(t *testing.T) ingressTest {
+	var test = ingressTest{
+		ctx:         server.BackgroundContext(context.Background(), uuid.New().String()),
+		storage:     db.NewMemory(),
+		fake:        clients.NewFakeKube(),
+		permissions: &limitsPermissions{},
+	}
+	suffix, err := ingress.ParseHostSuffix(".hub.containerum.io")
+	assert.NoError(t, err)
+
+	var kube clients.Kube = test.fake
+	var permissions clients.Permissions = test.permissions
+	test.ingresses = NewIngressActionsImpl(test.storage, &permissions, &kube, ingress.HostSuffixList{suffix}, nil)
+	return test
+}
+
+// userContext prepares request context of user with access to namespaces
+func userContext(t *testing.T, namespaces ...headers.UserHeaderData) context.Context {
+	data, err := json.Marshal(namespaces)
+	assert.NoError(t, err)
+	var header = make(http.Header)
+	header.Set(httputil.UserIDXHeader, uuid.New().String())
+	header.Set(httputil.UserRoleXHeader, "user")
+	header.Set(httputil.UserNamespacesXHeader, base64.StdEncoding.EncodeToString(data))
+	header.Set(httputil.RequestIDXHeader, uuid.New().String())
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header = header
+	var gctx = &gin.Context{Request: req}
+	httputil.SaveHeaders(gctx)
+	httputil.PrepareContext(gctx)
+	return gctx.Request.Context()
+}
+
+// externalService stores external service with port in namespace, domain is unique per namespace
+func (test ingressTest) externalService(t *testing.T, nsID, name string, port int) {
+	_, err := test.storage.CreateService(service.ServiceFromKube(nsID, "owner", model.Service{Name: name, Domain: nsID + ".example.com",
+		Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
+
+func TestCreateIngressRules(t *testing.T) {
+	var test = newIngressTest(t)
+	test.externalService(t, "ns", "web", 30080)
+	test.externalService(t, "ns", "api", 30081)
+
+	created, err := test.ingresses.CreateIngress(test.ctx, "ns", ingress.IngressRequest{Ingress: model.Ingress{Rules: []model.Rule{
+		{Host: "web", Path: []model.Path{
+			{ServiceName: "web", ServicePort: 30080},
+			{Path: "/api", ServiceName: "api", ServicePort: 30081},
+		}},
+		{Host: "API.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "api", ServicePort: 30081}}},
+	}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// ingress is named by the first host, hosts get suffix and empty path is root
+	assert.Equal(t, "web.hub.containerum.io", created.Name)
+	assert.Equal(t, []model.Rule{
+		{Host: "web.hub.containerum.io", Path: []model.Path{
+			{Path: "/", ServiceName: "web", ServicePort: 30080},
+			{Path: "/api", ServiceName: "api", ServicePort: 30081},
+		}},
+		{Host: "api.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "api", ServicePort: 30081}}},
+	}, created.Rules)
+	kubeIngresses, err := test.fake.GetIngressList(test.ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, kubeIngresses, 1) {
+		assert.Equal(t, created.Rules, kubeIngresses[0].Rules)
+	}
+
+	for _, rules := range [][]model.Rule{
+		// empty path and root path are the same
+		{{Host: "other", Path: []model.Path{{ServiceName: "web", ServicePort: 30080}, {Path: "/", ServiceName: "api", ServicePort: 30081}}}},
+		// host with suffix and its label are the same
+		{
+			{Host: "other", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 30080}}},
+			{Host: "other.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "api", ServicePort: 30081}}},
+		},
+	} {
+		_, err := test.ingresses.CreateIngress(test.ctx, "ns", ingress.IngressRequest{Ingress: model.Ingress{Rules: rules}})
+		assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v", err)
+	}
+	// port of service must exist
+	_, err = test.ingresses.CreateIngress(test.ctx, "ns", ingress.IngressRequest{Ingress: model.Ingress{Rules: []model.Rule{
+		{Host: "other", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 30081}}}}}})
+	assert.True(t, cherry.Equals(err, rserrors.ErrTCPPortNotFound()), "%v", err)
+
+	ingresses, err := test.storage.GetIngressList("ns")
+	assert.NoError(t, err)
+	assert.Len(t, ingresses, 1)
+}
+
+func TestGetIngressSuffixes(t *testing.T) {
+	var test = newIngressTest(t)
+	_, err := test.storage.CreateDomain(domain.Domain{Domain: "apps.example.com", DomainGroup: "apps", IP: []string{"192.0.2.1"}})
+	assert.NoError(t, err)
+	apps, err := ingress.ParseHostSuffix("apps=apps.example.com")
+	assert.NoError(t, err)
+	test.ingresses.suffixes = append(test.ingresses.suffixes, apps)
+
+	suffixes, err := test.ingresses.GetIngressSuffixes(test.ctx)
+	if assert.NoError(t, err) && assert.Len(t, suffixes, 2) {
+		assert.Equal(t, ingress.HostSuffix{Suffix: ".apps.example.com", DomainGroup: "apps", IPs: []string{"192.0.2.1"}}, suffixes[1])
+	}
+
+	// users see only suffixes
+	suffixes, err = test.ingresses.GetIngressSuffixes(userContext(t))
+	if assert.NoError(t, err) {
+		assert.Equal(t, ingress.HostSuffixList{{Suffix: ".hub.containerum.io"}, {Suffix: ".apps.example.com"}}, suffixes)
+	}
+}
+
+func TestCheckHostsFree(t *testing.T) {
+	var test = newIngressTest(t)
+	test.externalService(t, "first", "web", 30080)
+	test.externalService(t, "second", "web", 30081)
+	var request = func(host string, port int) ingress.IngressRequest {
+		return ingress.IngressRequest{Ingress: model.Ingress{Rules: []model.Rule{
+			{Host: host, Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: port}}}}}}
+	}
+	_, err := test.ingresses.CreateIngress(test.ctx, "first", request("web", 30080))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// host is unique among all namespaces, holder is reported to admin
+	_, err = test.ingresses.CreateIngress(test.ctx, "second", request("web", 30081))
+	if assert.True(t, cherry.Equals(err, rserrors.ErrIngressHostConflict()), "%v", err) {
+		assert.Contains(t, err.(*cherry.Err).Details[0], "namespace first")
+	}
+
+	// user which can read namespace holding host sees it
+	var reader = userContext(t, headers.UserHeaderData{ID: "first", Access: "read"}, headers.UserHeaderData{ID: "second", Access: "owner"})
+	_, err = test.ingresses.CreateIngress(reader, "second", request("web", 30081))
+	if assert.True(t, cherry.Equals(err, rserrors.ErrIngressHostConflict()), "%v", err) {
+		assert.Contains(t, err.(*cherry.Err).Details[0], "namespace first")
+	}
+
+	// foreign namespace is hidden from other users
+	var stranger = userContext(t, headers.UserHeaderData{ID: "second", Access: "owner"})
+	_, err = test.ingresses.CreateIngress(stranger, "second", request("web.hub.containerum.io", 30081))
+	if assert.True(t, cherry.Equals(err, rserrors.ErrIngressHostConflict()), "%v", err) {
+		assert.Contains(t, err.(*cherry.Err).Details[0], "another namespace")
+		assert.NotContains(t, err.(*cherry.Err).Details[0], "first")
+	}
+
+	// ingress may keep its own hosts on update
+	var update = request("web", 30080)
+	update.Name = "web.hub.containerum.io"
+	_, err = test.ingresses.UpdateIngress(test.ctx, "first", update)
+	assert.NoError(t, err)
+
+	ingresses, err := test.storage.GetIngressList("second")
+	assert.NoError(t, err)
+	assert.Empty(t, ingresses)
+}
+
+func TestSetupBasicAuth(t *testing.T) {
+	var test = newIngressTest(t)
+	var ingr = ingress.IngressResource{NamespaceID: "ns", Options: &ingress.Options{BasicAuth: &ingress.BasicAuth{
+		Realm: "Staging", Users: []ingress.BasicAuthUser{{Username: "first", Password: "secret"}, {Username: "second", Password: "secret"}}}}}
+	var request = ingr.Options
+
+	assert.NoError(t, test.ingresses.setupBasicAuth(test.ctx, &ingr, nil))
+	var basicAuth = ingr.Options.BasicAuth
+	// passwords are kept only in secret, request options are not changed
+	assert.Equal(t, []string{"first", "second"}, basicAuth.Usernames)
+	assert.Empty(t, basicAuth.Users)
+	assert.Len(t, request.BasicAuth.Users, 2)
+	assert.Empty(t, request.BasicAuth.Secret)
+	secret, ok := test.fake.GetSecret("ns", basicAuth.Secret)
+	if assert.True(t, ok, basicAuth.Secret) {
+		htpasswd := secret.Data[ingress.BasicAuthSecretKey]
+		assert.Contains(t, htpasswd, "first:{SSHA}")
+		assert.Contains(t, htpasswd, "\nsecond:{SSHA}")
+		assert.NotContains(t, htpasswd, "secret")
+	}
+	assert.Equal(t, basicAuth.Secret, ingr.ToKube().Annotations[ingress.AnnotationPrefix+"auth-secret"])
+	assert.Equal(t, "Staging", ingr.ToKube().Annotations[ingress.AnnotationPrefix+"auth-realm"])
+
+	// update without users keeps previous secret and users
+	var updated = ingress.IngressResource{NamespaceID: "ns", Options: &ingress.Options{BasicAuth: &ingress.BasicAuth{Realm: "Other"}}}
+	assert.NoError(t, test.ingresses.setupBasicAuth(test.ctx, &updated, ingr.Options))
+	assert.Equal(t, &ingress.BasicAuth{Realm: "Other", Usernames: []string{"first", "second"}, Secret: basicAuth.Secret}, updated.Options.BasicAuth)
+	assert.Equal(t, 1, test.fake.Calls("CreateSecret"))
+
+	// users are required for new basic auth
+	var noUsers = ingress.IngressResource{NamespaceID: "ns", Options: &ingress.Options{BasicAuth: &ingress.BasicAuth{Realm: "Other"}}}
+	err := test.ingresses.setupBasicAuth(test.ctx, &noUsers, nil)
+	assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v", err)
+	err = test.ingresses.setupBasicAuth(test.ctx, &noUsers, &ingress.Options{})
+	assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v", err)
+
+	// no basic auth requested
+	var plain = ingress.IngressResource{NamespaceID: "ns", Options: &ingress.Options{MaxBodySize: 1}}
+	assert.NoError(t, test.ingresses.setupBasicAuth(test.ctx, &plain, ingr.Options))
+	assert.Nil(t, plain.Options.BasicAuth)
+	assert.Equal(t, 1, test.fake.Calls("CreateSecret"))
+}
+
+// verifiedDomain stores custom domain verified in namespace, so its hosts are used without suffix
+func (test ingressTest) verifiedDomain(t *testing.T, nsID, name string) {
+	_, err := test.storage.CreateCustomDomain(customdomain.CustomDomain{Domain: name, NamespaceID: nsID, Token: uuid.New().String()})
+	if !assert.NoError(t, err) || !assert.NoError(t, test.storage.VerifyCustomDomain(nsID, name, "2018-01-01T00:00:00Z")) {
+		t.FailNow()
+	}
+}
+
+func TestWildcardHosts(t *testing.T) {
+	var test = newIngressTest(t)
+	test.permissions.wildcards = []string{"first", "second"}
+	for _, nsID := range []string{"first", "second", "other"} {
+		test.externalService(t, nsID, "web", 30080)
+	}
+	test.verifiedDomain(t, "first", "apps.example.com")
+	test.verifiedDomain(t, "first", "web.apps.example.com")
+	test.verifiedDomain(t, "second", "api.apps.example.com")
+	test.verifiedDomain(t, "second", "exact.example.com")
+	test.verifiedDomain(t, "first", "x.exact.example.com")
+	test.verifiedDomain(t, "other", "a.web.apps.example.com")
+	test.verifiedDomain(t, "other", "free.example.com")
+	var request = func(hosts ...string) ingress.IngressRequest {
+		var rules []model.Rule
+		for _, host := range hosts {
+			rules = append(rules, model.Rule{Host: host, Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: 30080}}})
+		}
+		return ingress.IngressRequest{Ingress: model.Ingress{Rules: rules}}
+	}
+
+	// wildcards are not allowed in namespace without permission, even for host free in all namespaces
+	_, err := test.ingresses.CreateIngress(test.ctx, "other", request("*.free.example.com"))
+	assert.True(t, cherry.Equals(err, rserrors.ErrWildcardHostsNotAllowed()), "%v", err)
+	_, err = test.ingresses.CreateIngress(test.ctx, "other", request("plain", "*.free.example.com"))
+	assert.True(t, cherry.Equals(err, rserrors.ErrWildcardHostsNotAllowed()), "%v", err)
+	_, err = test.ingresses.CreateIngress(test.ctx, "other", request("*.plain"))
+	assert.True(t, cherry.Equals(err, rserrors.ErrWildcardHostsNotAllowed()), "%v", err)
+
+	_, err = test.ingresses.CreateIngress(test.ctx, "first", request("*.apps.example.com"))
+	assert.NoError(t, err)
+	// wildcard and exact host may overlap inside one namespace
+	_, err = test.ingresses.CreateIngress(test.ctx, "first", request("web.apps.example.com"))
+	assert.NoError(t, err)
+	// exact host covered by wildcard of another namespace
+	_, err = test.ingresses.CreateIngress(test.ctx, "second", request("api.apps.example.com"))
+	assert.True(t, cherry.Equals(err, rserrors.ErrIngressHostConflict()), "%v", err)
+
+	// wildcard covering exact host of another namespace
+	_, err = test.ingresses.CreateIngress(test.ctx, "second", request("exact.example.com"))
+	assert.NoError(t, err)
+	_, err = test.ingresses.CreateIngress(test.ctx, "first", request("x.exact.example.com"))
+	assert.NoError(t, err)
+	_, err = test.ingresses.CreateIngress(test.ctx, "second", request("*.exact.example.com"))
+	assert.True(t, cherry.Equals(err, rserrors.ErrIngressHostConflict()), "%v", err)
+
+	// wildcard covers only one label
+	_, err = test.ingresses.CreateIngress(test.ctx, "other", request("a.web.apps.example.com"))
+	assert.NoError(t, err)
+
+	ingresses, err := test.storage.GetIngressList("second")
+	assert.NoError(t, err)
+	assert.Len(t, ingresses, 1)
+}
+
+func TestIngressActionsRevert(t *testing.T) {
+	var test = newIngressTest(t)
+	test.externalService(t, "ns", "web", 30080)
+	test.externalService(t, "ns", "api", 30081)
+	var name = ingress.IngressName("web.hub.containerum.io")
+	var request = func(serviceName string, port int) ingress.IngressRequest {
+		return ingress.IngressRequest{
+			Ingress: model.Ingress{Rules: []model.Rule{{Host: "web", Path: []model.Path{{Path: "/", ServiceName: serviceName, ServicePort: port}}}}},
+			Options: &ingress.Options{BasicAuth: &ingress.BasicAuth{Users: []ingress.BasicAuthUser{{Username: "admin", Password: "secret"}}}},
+		}
+	}
+
+	// failed kube-api creation removes record and basic auth secret
+	var fault = errors.New("fault")
+	test.fake.FailMethod("CreateIngress", fault)
+	_, err := test.ingresses.CreateIngress(test.ctx, "ns", request("web", 30080))
+	assert.Equal(t, fault, err)
+	_, err = test.storage.GetIngress("ns", name)
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+	assert.Equal(t, 1, test.fake.Calls("CreateSecret"))
+	assert.Equal(t, 1, test.fake.Calls("DeleteSecret"))
+
+	test.fake.ClearFaults()
+	created, err := test.ingresses.CreateIngress(test.ctx, "ns", request("web", 30080))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var secret = created.Options.BasicAuthSecret()
+
+	// failed kube-api update restores previous record, new secret is removed and old one is kept
+	test.fake.ClearFaults()
+	test.fake.FailMethod("UpdateIngress", fault)
+	var updated = request("api", 30081)
+	updated.Name = name
+	_, err = test.ingresses.UpdateIngress(test.ctx, "ns", updated)
+	assert.Equal(t, fault, err)
+	stored, err := test.storage.GetIngress("ns", name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "web", stored.Rules[0].Path[0].ServiceName)
+		assert.Equal(t, secret, stored.Options.BasicAuthSecret())
+	}
+	_, ok := test.fake.GetSecret("ns", secret)
+	assert.True(t, ok)
+	assert.Equal(t, 1, test.fake.Calls("CreateSecret"))
+	assert.Equal(t, 1, test.fake.Calls("DeleteSecret"))
+
+	// failed kube-api deletion restores record and keeps secret
+	test.fake.ClearFaults()
+	test.fake.FailMethod("DeleteIngress", fault)
+	assert.Equal(t, fault, test.ingresses.DeleteIngress(test.ctx, "ns", name))
+	_, err = test.storage.GetIngress("ns", name)
+	assert.NoError(t, err)
+	_, ok = test.fake.GetSecret("ns", secret)
+	assert.True(t, ok)
+	assert.Equal(t, 0, test.fake.Calls("DeleteSecret"))
+	kubeIngresses, err := test.fake.GetIngressList(test.ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, kubeIngresses, 1) {
+		assert.Equal(t, "web", kubeIngresses[0].Rules[0].Path[0].ServiceName)
+	}
+}
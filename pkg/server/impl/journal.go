@@ -0,0 +1,487 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/containerum/utils/httputil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// operations not updated for this time are considered interrupted, longer time is used if kube-api calls may last longer
+	minOperationStaleAfter = 2 * time.Minute
+	// kube-api request or compensation of interrupted operation is retried this number of times
+	operationMaxAttempts = 5
+	// finished operations are kept in journal for this time
+	operationRetention = 7 * 24 * time.Hour
+)
+
+var errSecretNotJournaled = errors.New("secret data is not stored in journal, operation can't be finished")
+
+// journal records actions which write to both storage and kube-api as operations.
+// Intent is stored before any side effect, so interrupted operations can be finished or compensated by recovery.
+type journal struct {
+	storage db.Storage
+	kube    clients.Kube
+	log     *cherrylog.LogrusAdapter
+}
+
+func newJournal(storage db.Storage, kube clients.Kube, log *cherrylog.LogrusAdapter) journal {
+	return journal{
+		storage: storage,
+		kube:    kube,
+		log:     log,
+	}
+}
+
+// execute records operation, runs storage write and applies kube-api request.
+// If kube-api request fails storage write is compensated, failed compensation is left for recovery.
+func (j journal) execute(ctx context.Context, op operation.Operation, store func() error) error {
+	var now = time.Now().UTC()
+	op.ID = uuid.New().String()
+	op.Status = operation.StatusPending
+	op.Owner = httputil.MustGetUserID(ctx)
+	op.CreatedAt, op.UpdatedAt = now, now
+	if _, err := j.storage.CreateOperation(op); err != nil {
+		return err
+	}
+	var log = j.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"kind":      op.Kind,
+		"ns_id":     op.NamespaceID,
+		"name":      op.Name,
+	})
+
+	if err := store(); err != nil {
+		j.setStatus(op, operation.StatusCancelled, err)
+		return err
+	}
+	if err := j.storage.SetOperationStatus(op.ID, operation.StatusStored, ""); err != nil {
+		// without stored status recovery would revert operation, so it must not reach kube-api
+		log.WithError(err).Error("unable to mark operation stored, reverting")
+		j.compensateNow(op, err)
+		return err
+	}
+
+	err := j.apply(ctx, op)
+	if err != nil && isDeletion(op.Kind) && kubeNotFound(err) {
+		// deletion retried after gateway error may find object deleted by the first attempt
+		log.WithError(err).Debug("object is already deleted")
+		err = nil
+	}
+	if err != nil {
+		log.WithError(err).Debug("Kube-API error! Reverting changes.")
+		j.setStatus(op, operation.StatusCompensating, err)
+		j.compensateNow(op, err)
+		return err
+	}
+	j.setStatus(op, operation.StatusDone, nil)
+	return nil
+}
+
+// enqueue records operation which kube-api request is left for recovery.
+// Operation is recorded as never updated, so it is stale for any threshold and is applied by the next recovery run.
+// Storage write of irreversible operation may be done after enqueue, recovery repeats it.
+func (j journal) enqueue(op operation.Operation) error {
+	var now = time.Now().UTC()
+	op.ID = uuid.New().String()
+	op.Status = operation.StatusStored
+	op.CreatedAt, op.UpdatedAt = now, time.Unix(0, 0).UTC()
+	if _, err := j.storage.CreateOperation(op); err != nil {
+		return err
+	}
+	j.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"kind":      op.Kind,
+		"ns_id":     op.NamespaceID,
+		"name":      op.Name,
+	}).Debug("operation enqueued")
+	return nil
+}
+
+// compensateNow reverts storage write, operation is left for recovery if compensation fails
+func (j journal) compensateNow(op operation.Operation, cause error) {
+	if err := j.compensate(op); err != nil {
+		j.log.WithError(err).WithField("operation", op.ID).Error("unable to revert changes, leaving operation for recovery")
+		return
+	}
+	j.setStatus(op, operation.StatusCompensated, cause)
+}
+
+func (j journal) setStatus(op operation.Operation, status operation.Status, cause error) {
+	var opErr string
+	if cause != nil {
+		opErr = cause.Error()
+	}
+	if err := j.storage.SetOperationStatus(op.ID, status, opErr); err != nil {
+		j.log.WithError(err).WithFields(logrus.Fields{
+			"operation": op.ID,
+			"status":    status,
+		}).Error("unable to set operation status")
+	}
+}
+
+// apply sends kube-api request of operation
+func (j journal) apply(ctx context.Context, op operation.Operation) error {
+	switch op.Kind {
+	case operation.CreateDeployment:
+		return j.kube.CreateDeployment(ctx, op.NamespaceID, op.Deployment.Deployment)
+	case operation.UpdateDeployment, operation.ChangeActiveDeployment:
+		return j.kube.UpdateDeployment(ctx, op.NamespaceID, op.Deployment.Deployment)
+	case operation.SetDeploymentReplicas:
+		return j.kube.SetDeploymentReplicas(ctx, op.NamespaceID, op.Name, op.Deployment.Replicas)
+	case operation.DeleteDeployment:
+		return j.kube.DeleteDeployment(ctx, op.NamespaceID, op.Name)
+	case operation.CreateService:
+		return j.kube.CreateService(ctx, op.NamespaceID, op.Service.Service)
+	case operation.UpdateService:
+		return j.kube.UpdateService(ctx, op.NamespaceID, op.Service.Service)
+	case operation.DeleteService:
+		return j.kube.DeleteService(ctx, op.NamespaceID, op.Name)
+	case operation.CreateIngress:
+		return j.kube.CreateIngress(ctx, op.NamespaceID, op.Ingress.ToKube())
+	case operation.UpdateIngress:
+		return j.kube.UpdateIngress(ctx, op.NamespaceID, op.Ingress.ToKube())
+	case operation.DeleteIngress:
+		return j.kube.DeleteIngress(ctx, op.NamespaceID, op.Name)
+	case operation.CreateCertificate:
+		if op.Secret == nil {
+			return errSecretNotJournaled
+		}
+		return j.kube.CreateSecret(ctx, op.NamespaceID, *op.Secret)
+	case operation.DeleteCertificate:
+		return j.kube.DeleteSecret(ctx, op.NamespaceID, op.Name)
+	default:
+		return rserrors.ErrInternal().AddDetailF("unknown operation kind %q", op.Kind)
+	}
+}
+
+// replay sends kube-api request of interrupted operation.
+// Request may be already applied before interruption, so existing objects are updated and missing objects are not deleted.
+func (j journal) replay(ctx context.Context, op operation.Operation) error {
+	err := j.apply(ctx, op)
+	if err == nil {
+		return nil
+	}
+	switch op.Kind {
+	case operation.CreateDeployment:
+		return j.kube.UpdateDeployment(ctx, op.NamespaceID, op.Deployment.Deployment)
+	case operation.CreateService:
+		return j.kube.UpdateService(ctx, op.NamespaceID, op.Service.Service)
+	case operation.CreateIngress:
+		return j.kube.UpdateIngress(ctx, op.NamespaceID, op.Ingress.ToKube())
+	}
+	if isDeletion(op.Kind) && kubeNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isDeletion reports if operation deletes kube-api object, missing object means it is already done then
+func isDeletion(kind operation.Kind) bool {
+	switch kind {
+	case operation.DeleteDeployment, operation.DeleteService, operation.DeleteIngress, operation.DeleteCertificate:
+		return true
+	default:
+		return false
+	}
+}
+
+// deleteStored repeats storage deletion of irreversible operation, resource deleted before is not an error.
+// Returns false if resource was created again after operation was recorded, its kube-api object must be kept then.
+func (j journal) deleteStored(op operation.Operation) (bool, error) {
+	switch op.Kind {
+	case operation.DeleteDeployment:
+		current, err := j.storage.GetDeployment(op.NamespaceID, op.Name)
+		if err != nil {
+			return true, ignoreNotExists(err)
+		}
+		if op.Deployment != nil && current.ID != op.Deployment.ID {
+			return false, nil
+		}
+		return true, j.storage.DeleteDeployment(op.NamespaceID, op.Name)
+	case operation.DeleteService:
+		current, err := j.storage.GetService(op.NamespaceID, op.Name)
+		if err != nil {
+			return true, ignoreNotExists(err)
+		}
+		if op.Service != nil && current.ID != op.Service.ID {
+			return false, nil
+		}
+		return true, j.storage.DeleteService(op.NamespaceID, op.Name)
+	case operation.DeleteIngress:
+		current, err := j.storage.GetIngress(op.NamespaceID, op.Name)
+		if err != nil {
+			return true, ignoreNotExists(err)
+		}
+		if op.Ingress != nil && current.ID != op.Ingress.ID {
+			return false, nil
+		}
+		return true, j.storage.DeleteIngress(op.NamespaceID, op.Name)
+	default:
+		return false, rserrors.ErrInternal().AddDetailF("operation kind %q can't be irreversible", op.Kind)
+	}
+}
+
+func kubeNotFound(err error) bool {
+	cherr, ok := err.(*cherry.Err)
+	return ok && cherr.StatusHTTP == http.StatusNotFound
+}
+
+// compensate reverts storage write of operation.
+// Storage write may be not done yet, so compensation must not break anything in this case.
+// Old states are written with zero revision, so compensation is not rejected as stale write.
+func (j journal) compensate(op operation.Operation) error {
+	switch op.Kind {
+	case operation.CreateDeployment:
+		current, err := j.storage.GetDeployment(op.NamespaceID, op.Name)
+		if err != nil || current.ID != op.Deployment.ID {
+			return ignoreNotExists(err)
+		}
+		return j.storage.DeleteDeployment(op.NamespaceID, op.Name)
+	case operation.UpdateDeployment, operation.ChangeActiveDeployment:
+		if op.Kind == operation.UpdateDeployment && op.Deployment.Version.Equals(op.OldDeployment.Version) {
+			return j.storage.UpdateActiveDeployment(withoutRevision(*op.OldDeployment))
+		}
+		if err := j.storage.DeactivateDeployment(op.NamespaceID, op.Name, 0); ignoreNotExists(err) != nil {
+			return err
+		}
+		if op.Kind == operation.UpdateDeployment {
+			// version was created by this operation
+			err := j.storage.DeleteDeploymentVersion(op.NamespaceID, op.Name, op.Deployment.Version)
+			if ignoreNotExists(err) != nil {
+				return err
+			}
+		}
+		return j.storage.ActivateDeployment(op.NamespaceID, op.Name, op.OldDeployment.Version)
+	case operation.SetDeploymentReplicas:
+		return j.storage.UpdateActiveDeployment(withoutRevision(*op.OldDeployment))
+	case operation.DeleteDeployment:
+		if _, err := j.storage.GetDeployment(op.NamespaceID, op.Name); err == nil {
+			return nil
+		}
+		return j.storage.RestoreDeployment(op.NamespaceID, op.Name)
+	case operation.CreateService:
+		current, err := j.storage.GetService(op.NamespaceID, op.Name)
+		if err != nil || current.ID != op.Service.ID {
+			return ignoreNotExists(err)
+		}
+		return j.storage.DeleteService(op.NamespaceID, op.Name)
+	case operation.UpdateService:
+		var old = *op.OldService
+		old.Revision = 0
+		_, err := j.storage.UpdateService(old)
+		return err
+	case operation.DeleteService:
+		if _, err := j.storage.GetService(op.NamespaceID, op.Name); err == nil {
+			return nil
+		}
+		return j.storage.RestoreService(op.NamespaceID, op.Name)
+	case operation.CreateIngress:
+		current, err := j.storage.GetIngress(op.NamespaceID, op.Name)
+		if err != nil || current.ID != op.Ingress.ID {
+			return ignoreNotExists(err)
+		}
+		return j.storage.DeleteIngress(op.NamespaceID, op.Name)
+	case operation.UpdateIngress:
+		var old = *op.OldIngress
+		old.Revision = 0
+		_, err := j.storage.UpdateIngress(old)
+		return err
+	case operation.DeleteIngress:
+		if _, err := j.storage.GetIngress(op.NamespaceID, op.Name); err == nil {
+			return nil
+		}
+		return j.storage.RestoreIngress(op.NamespaceID, op.Name)
+	case operation.CreateCertificate:
+		current, err := j.storage.GetCertificate(op.NamespaceID, op.Name)
+		if err != nil || current.ID != op.Certificate.ID {
+			return ignoreNotExists(err)
+		}
+		return j.storage.DeleteCertificate(op.NamespaceID, op.Name)
+	case operation.DeleteCertificate:
+		if _, err := j.storage.GetCertificate(op.NamespaceID, op.Name); err == nil {
+			return nil
+		}
+		return j.storage.RestoreCertificate(op.NamespaceID, op.Name)
+	default:
+		return rserrors.ErrInternal().AddDetailF("unknown operation kind %q", op.Kind)
+	}
+}
+
+func ignoreNotExists(err error) error {
+	if err != nil && cherry.Equals(err, rserrors.ErrResourceNotExists()) {
+		return nil
+	}
+	return err
+}
+
+type JournalActionsImpl struct {
+	journal    journal
+	staleAfter time.Duration
+	log        *cherrylog.LogrusAdapter
+}
+
+// NewJournalActionsImpl creates recovery of operations not updated for staleAfter, see OperationStaleAfter
+func NewJournalActionsImpl(storage db.Storage, kube *clients.Kube, staleAfter time.Duration) *JournalActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "journal_actions"))
+	return &JournalActionsImpl{
+		journal:    newJournal(storage, *kube, log),
+		staleAfter: staleAfter,
+		log:        log,
+	}
+}
+
+// OperationStaleAfter returns time after which not updated operation is considered interrupted.
+// Operation makes one kube-api call, so it must be longer than the call with all retries, twice as long for margin.
+// Calls without timeout may last forever, so they can't be told from interrupted ones.
+func OperationStaleAfter(kubePolicy clients.CallPolicy) (time.Duration, error) {
+	var call = kubePolicy.MaxCallDuration()
+	if call <= 0 {
+		return 0, errors.New("kube-api timeout is required to detect interrupted operations")
+	}
+	if 2*call > minOperationStaleAfter {
+		return 2 * call, nil
+	}
+	return minOperationStaleAfter, nil
+}
+
+// Run finishes or compensates interrupted operations on start and then periodically until context is done
+func (ja *JournalActionsImpl) Run(ctx context.Context, period time.Duration) {
+	ja.log.WithField("period", period).Info("starting operation recovery loop")
+	var ticker = time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		ja.Recover(ctx)
+		select {
+		case <-ctx.Done():
+			ja.log.Info("stopping operation recovery loop")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover processes operations not updated for staleAfter and removes old finished operations
+func (ja *JournalActionsImpl) Recover(ctx context.Context) {
+	var staleBefore = time.Now().UTC().Add(-ja.staleAfter)
+	ops, err := ja.journal.storage.GetStaleOperations(staleBefore)
+	if err != nil {
+		ja.log.WithError(err).Error("unable to get interrupted operations")
+		return
+	}
+	for _, op := range ops {
+		if ctx.Err() != nil {
+			return
+		}
+		claimed, err := ja.journal.storage.ClaimOperation(op.ID, staleBefore)
+		if err != nil {
+			ja.log.WithError(err).WithField("operation", op.ID).Error("unable to claim operation")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		op.Attempts++
+		ja.recoverOperation(ctx, op)
+	}
+	if err := ja.journal.storage.DeleteFinishedOperations(time.Now().UTC().Add(-operationRetention)); err != nil {
+		ja.log.WithError(err).Error("unable to delete finished operations")
+	}
+}
+
+// recoverOperation finishes operation if storage write is done, otherwise storage write is compensated
+func (ja *JournalActionsImpl) recoverOperation(ctx context.Context, op operation.Operation) {
+	var log = ja.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"kind":      op.Kind,
+		"status":    op.Status,
+		"ns_id":     op.NamespaceID,
+		"name":      op.Name,
+		"attempt":   op.Attempts,
+	})
+	log.Info("recovering operation")
+
+	var j = ja.journal
+	if op.Irreversible {
+		ja.finishIrreversible(ctx, op)
+		return
+	}
+	switch op.Status {
+	case operation.StatusStored:
+		err := j.replay(server.BackgroundContext(ctx, op.Owner), op)
+		switch {
+		case err == nil:
+			log.Info("operation finished")
+			j.setStatus(op, operation.StatusDone, nil)
+			return
+		case err != errSecretNotJournaled && op.Attempts < operationMaxAttempts:
+			log.WithError(err).Warn("unable to finish operation, will retry")
+			j.setStatus(op, operation.StatusStored, err)
+			return
+		}
+		log.WithError(err).Warn("unable to finish operation, reverting changes")
+	case operation.StatusPending:
+		// process stopped before kube-api request, storage write may be done or not
+		log.Info("operation was interrupted before kube-api request, reverting changes")
+	}
+
+	if err := j.compensate(op); err != nil {
+		if op.Attempts < operationMaxAttempts {
+			log.WithError(err).Warn("unable to revert changes, will retry")
+			j.setStatus(op, operation.StatusCompensating, err)
+			return
+		}
+		log.WithError(err).Error("unable to revert changes, storage and kube-api must be synced manually")
+		j.setStatus(op, operation.StatusFailed, err)
+		return
+	}
+	log.Info("operation reverted")
+	j.setStatus(op, operation.StatusCompensated, nil)
+}
+
+// finishIrreversible repeats storage write and kube-api request of operation which must not be reverted.
+// Operation is retried until attempts are exhausted and then marked failed.
+func (ja *JournalActionsImpl) finishIrreversible(ctx context.Context, op operation.Operation) {
+	var log = ja.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"kind":      op.Kind,
+		"ns_id":     op.NamespaceID,
+		"name":      op.Name,
+		"attempt":   op.Attempts,
+	})
+
+	var j = ja.journal
+	deleted, err := j.deleteStored(op)
+	if err == nil && deleted {
+		err = j.replay(server.BackgroundContext(ctx, op.Owner), op)
+	}
+	switch {
+	case err == nil:
+		log.Info("operation finished")
+		j.setStatus(op, operation.StatusDone, nil)
+	case op.Attempts < operationMaxAttempts:
+		log.WithError(err).Warn("unable to finish irreversible operation, will retry")
+		j.setStatus(op, operation.StatusStored, err)
+	default:
+		log.WithError(err).Error("unable to finish irreversible operation, storage and kube-api must be synced manually")
+		j.setStatus(op, operation.StatusFailed, err)
+	}
+}
+
+func withoutRevision(depl deployment.DeploymentResource) deployment.DeploymentResource {
+	depl.Revision = 0
+	return depl
+}
@@ -0,0 +1,247 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// staleOperation records operation as interrupted long ago, so it is processed by the next recovery run
+func staleOperation(t *testing.T, storage db.Storage, op operation.Operation) string {
+	var past = time.Now().UTC().Add(-2 * minOperationStaleAfter)
+	op.ID = uuid.New().String()
+	op.Owner = "owner"
+	op.CreatedAt, op.UpdatedAt = past, past
+	_, err := storage.CreateOperation(op)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return op.ID
+}
+
+func TestJournalRecover(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var journal = NewJournalActionsImpl(storage, &kube, minOperationStaleAfter)
+
+	var container = model.Container{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}
+	var port = 80
+	var svc = model.Service{Name: "web", Deploy: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+
+	// pending operation with storage write done: record is removed
+	pendingDepl, err := storage.CreateDeployment(deployment.DeploymentFromKube("ns", "owner",
+		model.Deployment{Name: "pending", Replicas: 1, Active: true, Containers: []model.Container{container}}))
+	assert.NoError(t, err)
+	pendingCreated := staleOperation(t, storage, operation.Operation{Kind: operation.CreateDeployment, Status: operation.StatusPending,
+		NamespaceID: "ns", Name: "pending", Deployment: &pendingDepl})
+	// pending operation without storage write: nothing to revert
+	var notStored = service.ServiceFromKube("ns", "owner", model.Service{Name: "not-stored", Ports: svc.Ports})
+	pendingNotStored := staleOperation(t, storage, operation.Operation{Kind: operation.CreateService, Status: operation.StatusPending,
+		NamespaceID: "ns", Name: "not-stored", Service: &notStored})
+
+	// stored creation already applied to kube-api: existing object is updated
+	var updated = model.Deployment{Name: "web", Replicas: 2, Active: true, Containers: []model.Container{container}}
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", model.Deployment{Name: "web", Replicas: 1, Containers: []model.Container{container}}))
+	webDepl, err := storage.CreateDeployment(deployment.DeploymentFromKube("ns", "owner", updated))
+	assert.NoError(t, err)
+	storedCreated := staleOperation(t, storage, operation.Operation{Kind: operation.CreateDeployment, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "web", Deployment: &webDepl})
+	// stored creation not applied yet
+	var ingr = ingress.IngressFromKube("ns", "owner", model.Ingress{Name: "web", Rules: []model.Rule{
+		{Host: "web.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: port}}}}})
+	storedIngress := staleOperation(t, storage, operation.Operation{Kind: operation.CreateIngress, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "web", Ingress: &ingr})
+	// stored deletion already applied to kube-api: not found is success
+	storedDeleted := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteService, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "gone"})
+
+	// certificate secret is not journaled, so creation can only be reverted
+	cert, err := storage.CreateCertificate(certificate.Certificate{ID: uuid.New().String(), Name: "tls", NamespaceID: "ns"})
+	assert.NoError(t, err)
+	storedCert := staleOperation(t, storage, operation.Operation{Kind: operation.CreateCertificate, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "tls", Certificate: &cert})
+
+	journal.Recover(ctx)
+
+	var status = func(id string) operation.Operation {
+		op, err := storage.GetOperation(id)
+		assert.NoError(t, err)
+		return op
+	}
+	assert.Equal(t, operation.StatusCompensated, status(pendingCreated).Status)
+	_, err = storage.GetDeployment("ns", "pending")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+	assert.Equal(t, operation.StatusCompensated, status(pendingNotStored).Status)
+	assert.Equal(t, 0, fake.Calls("CreateService"))
+
+	assert.Equal(t, operation.StatusDone, status(storedCreated).Status)
+	deployments, err := kube.GetDeploymentList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, deployments, 1) {
+		assert.Equal(t, 2, deployments[0].Replicas)
+	}
+	assert.Equal(t, operation.StatusDone, status(storedIngress).Status)
+	ingresses, err := kube.GetIngressList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Len(t, ingresses, 1)
+	assert.Equal(t, operation.StatusDone, status(storedDeleted).Status)
+
+	certOp := status(storedCert)
+	assert.Equal(t, operation.StatusCompensated, certOp.Status)
+	assert.Equal(t, 1, certOp.Attempts)
+	_, err = storage.GetCertificate("ns", "tls")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+	assert.Equal(t, 0, fake.Calls("CreateSecret"))
+
+	// finished operations are not processed again
+	fake.ClearFaults()
+	journal.Recover(ctx)
+	assert.Equal(t, 0, fake.Calls(""))
+}
+
+func TestJournalRecoverRetry(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var journal = NewJournalActionsImpl(storage, &kube, minOperationStaleAfter)
+
+	var port = 80
+	var svc = model.Service{Name: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+	for _, name := range []string{"retried", "reverted"} {
+		svc.Name = name
+		_, err := storage.CreateService(service.ServiceFromKube("ns", "owner", svc))
+		assert.NoError(t, err)
+		assert.NoError(t, kube.CreateService(ctx, "ns", svc))
+		assert.NoError(t, storage.DeleteService("ns", name))
+	}
+	fake.FailMethod("DeleteService", rserrors.ErrServiceUnavailable())
+
+	// kube-api failure is retried by next runs
+	retried := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteService, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "retried"})
+	// the last attempt fails too, so deletion is reverted
+	reverted := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteService, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "reverted", Attempts: operationMaxAttempts - 1})
+	// compensation fails on every attempt, so operation is failed after the last one
+	var missing = service.ServiceFromKube("ns", "owner", model.Service{Name: "missing", Ports: svc.Ports})
+	failed := staleOperation(t, storage, operation.Operation{Kind: operation.UpdateService, Status: operation.StatusCompensating,
+		NamespaceID: "ns", Name: "missing", Service: &missing, OldService: &missing, Attempts: operationMaxAttempts - 1})
+
+	journal.Recover(ctx)
+
+	op, err := storage.GetOperation(retried)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusStored, op.Status)
+		assert.Equal(t, 1, op.Attempts)
+		assert.NotEmpty(t, op.Error)
+	}
+	_, err = storage.GetService("ns", "retried")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+
+	op, err = storage.GetOperation(reverted)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusCompensated, op.Status)
+		assert.Equal(t, operationMaxAttempts, op.Attempts)
+	}
+	_, err = storage.GetService("ns", "reverted")
+	assert.NoError(t, err)
+
+	op, err = storage.GetOperation(failed)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusFailed, op.Status)
+		assert.NotEmpty(t, op.Error)
+	}
+	assert.Equal(t, 2, fake.Calls("DeleteService"))
+}
+
+func TestOperationStaleAfter(t *testing.T) {
+	staleAfter, err := OperationStaleAfter(clients.CallPolicy{Retries: 3, Timeout: 10 * time.Second, Backoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, minOperationStaleAfter, staleAfter)
+
+	// kube-api calls which may last longer than default threshold
+	staleAfter, err = OperationStaleAfter(clients.CallPolicy{Retries: 5, Timeout: time.Minute, Backoff: time.Second, MaxBackoff: time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, 2*(6*time.Minute+5*time.Second), staleAfter)
+
+	_, err = OperationStaleAfter(clients.CallPolicy{Retries: 3})
+	assert.Error(t, err)
+
+	// operation which kube-api call may be still running is not recovered, enqueued one is
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var journal = NewJournalActionsImpl(storage, &kube, time.Hour)
+	var running = ingress.IngressFromKube("ns", "owner", model.Ingress{Name: "running"})
+	runningID := staleOperation(t, storage, operation.Operation{Kind: operation.CreateIngress, Status: operation.StatusStored,
+		NamespaceID: "ns", Name: "running", Ingress: &running})
+	var enqueued = ingress.IngressFromKube("ns", "owner", model.Ingress{Name: "enqueued"})
+	assert.NoError(t, journal.journal.enqueue(operation.Operation{Kind: operation.CreateIngress, NamespaceID: "ns", Name: "enqueued", Ingress: &enqueued}))
+
+	journal.Recover(context.Background())
+	op, err := storage.GetOperation(runningID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusStored, op.Status)
+	}
+	assert.Equal(t, 1, fake.Calls("CreateIngress"))
+}
+
+func TestJournalDeleteRetried(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+
+	// the first attempt deletes object but gateway fails, so retried request finds nothing to delete
+	var calls int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(rserrors.ErrResourceNotExists())
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var kube = clients.NewKubeHTTP(u, clients.CallPolicy{Retries: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Timeout: time.Second})
+	var permissions clients.Permissions
+	var services = NewServiceActionsImpl(storage, &permissions, &kube)
+
+	_, err = storage.CreateService(service.ServiceFromKube("ns", "owner", model.Service{Name: "web"}))
+	assert.NoError(t, err)
+	assert.NoError(t, services.DeleteService(ctx, "ns", "web"))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	_, err = storage.GetService("ns", "web")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "deletion must not be reverted, got %v", err)
+	deleted, err := storage.GetDeletedServiceList("ns")
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+}
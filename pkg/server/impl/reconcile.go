@@ -0,0 +1,379 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/metrics"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// reconcileLockName -- storage lock name, only one replica reconciles resources at once
+	reconcileLockName = "reconcile"
+	// reconcileLockTTL -- lock of crashed replica is taken by another one after this time
+	reconcileLockTTL = 10 * time.Minute
+)
+
+// ReconcileOptions -- limits of automatic repairs
+type ReconcileOptions struct {
+	// namespaces opted out of automatic repairs, their drift is only reported
+	ExcludedNamespaces []string
+	// maximal number of repairs per reconciliation, other drifted resources wait for the next one, 0 means no limit
+	RepairLimit int
+	// delay before repeated repair of the same resource, doubled after each repair up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// ReconcileActionsImpl repairs kube-api resources drifted from active storage records.
+// Storage is the source of truth: missing and changed resources are re-applied from records.
+// Extra resources are deleted only if they have softly deleted records, so resources created behind resource-service are left untouched.
+// Every repair is logged and recorded in operations journal.
+// Resources with not finished operations are left to the operations and to journal recovery.
+type ReconcileActionsImpl struct {
+	drift     *DriftActionsImpl
+	storage   db.Storage
+	kube      clients.Kube
+	opts      ReconcileOptions
+	excluded  map[string]bool
+	lockOwner string
+	log       *cherrylog.LogrusAdapter
+
+	// drifted resource -> repair backoff, removed when resource is not drifted anymore
+	backoff map[string]repairBackoff
+	now     func() time.Time
+}
+
+type repairBackoff struct {
+	attempts int
+	next     time.Time
+}
+
+func NewReconcileActionsImpl(storage db.Storage, kube *clients.Kube, opts ReconcileOptions) *ReconcileActionsImpl {
+	var excluded = make(map[string]bool, len(opts.ExcludedNamespaces))
+	for _, nsID := range opts.ExcludedNamespaces {
+		excluded[nsID] = true
+	}
+	return &ReconcileActionsImpl{
+		drift:     NewDriftActionsImpl(storage, kube),
+		storage:   storage,
+		kube:      *kube,
+		opts:      opts,
+		excluded:  excluded,
+		lockOwner: uuid.New().String(),
+		log:       cherrylog.NewLogrusAdapter(logrus.WithField("component", "reconcile_actions")),
+		backoff:   make(map[string]repairBackoff),
+		now:       time.Now,
+	}
+}
+
+// Reconcile detects drift of all namespaces and repairs drifted resources, returns number of repair attempts.
+// Nothing is done if storage lock is held by another replica.
+func (ra *ReconcileActionsImpl) Reconcile(ctx context.Context) (int, error) {
+	if !ra.acquireLock() {
+		return 0, nil
+	}
+	defer func() {
+		if err := ra.storage.ReleaseLock(reconcileLockName, ra.lockOwner); err != nil {
+			ra.log.WithError(err).Error("unable to release reconcile lock")
+		}
+	}()
+
+	report, err := ra.drift.DetectDrift(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	// operations started before drift detection may be applied to kube-api only partially
+	busy, err := ra.busyResources()
+	if err != nil {
+		return 0, err
+	}
+
+	var now = ra.now()
+	var drifted = make(map[string]bool, len(report.Resources))
+	var repairs int
+	for _, res := range report.Resources {
+		if ctx.Err() != nil {
+			return repairs, ctx.Err()
+		}
+		var key = repairKey(res)
+		drifted[key] = true
+		var log = ra.log.WithFields(logrus.Fields{
+			"ns_id": res.NamespaceID,
+			"kind":  res.Kind,
+			"name":  res.Name,
+			"type":  res.Type,
+		})
+		if ra.excluded[res.NamespaceID] {
+			log.Debug("namespace is excluded from reconciliation")
+			continue
+		}
+		if busy[key] {
+			log.Debug("resource has not finished operation, skipping")
+			continue
+		}
+		if state, ok := ra.backoff[key]; ok && now.Before(state.next) {
+			log.WithField("next_repair", state.next).Debug("repair is delayed")
+			continue
+		}
+		if res.Type == drift.Extra {
+			managed, err := ra.managed(res)
+			if err != nil {
+				log.WithError(err).Warn("unable to check if resource was created by resource-service")
+				continue
+			}
+			if !managed {
+				log.Debug("resource was not created by resource-service, skipping")
+				continue
+			}
+		}
+		if ra.opts.RepairLimit > 0 && repairs >= ra.opts.RepairLimit {
+			log.Debug("repair limit reached, repair is left for next reconciliation")
+			continue
+		}
+
+		// repairs may take long, lock is prolonged so it doesn't expire
+		if !ra.acquireLock() {
+			return repairs, nil
+		}
+
+		repairs++
+		var state = ra.backoff[key]
+		state.attempts++
+		state.next = now.Add(ra.backoffDelay(state.attempts))
+		ra.backoff[key] = state
+		ra.repair(ctx, res, state.attempts)
+	}
+
+	for key := range ra.backoff {
+		if !drifted[key] {
+			delete(ra.backoff, key)
+		}
+	}
+	return repairs, nil
+}
+
+func repairKey(res drift.Resource) string {
+	return fmt.Sprintf("%s/%s/%s", res.NamespaceID, res.Kind, res.Name)
+}
+
+func (ra *ReconcileActionsImpl) acquireLock() bool {
+	acquired, err := ra.storage.AcquireLock(reconcileLockName, ra.lockOwner, reconcileLockTTL)
+	if err != nil {
+		ra.log.WithError(err).Error("unable to acquire reconcile lock")
+		return false
+	}
+	if !acquired {
+		ra.log.Debug("reconcile lock is held by another replica")
+	}
+	return acquired
+}
+
+// busyResources returns repair keys of resources with not finished operations
+func (ra *ReconcileActionsImpl) busyResources() (map[string]bool, error) {
+	ops, err := ra.storage.GetActiveOperations()
+	if err != nil {
+		return nil, err
+	}
+	var busy = make(map[string]bool, len(ops))
+	for _, op := range ops {
+		if kind := operationResourceKind(op.Kind); kind != "" {
+			busy[repairKey(drift.Resource{NamespaceID: op.NamespaceID, Kind: kind, Name: op.Name})] = true
+		}
+	}
+	return busy, nil
+}
+
+// operationResourceKind returns kind of resource changed by operation, empty for resources without drift detection
+func operationResourceKind(kind operation.Kind) drift.Kind {
+	switch kind {
+	case operation.CreateDeployment, operation.UpdateDeployment, operation.SetDeploymentReplicas,
+		operation.ChangeActiveDeployment, operation.DeleteDeployment:
+		return drift.KindDeployment
+	case operation.CreateService, operation.UpdateService, operation.DeleteService:
+		return drift.KindService
+	case operation.CreateIngress, operation.UpdateIngress, operation.DeleteIngress:
+		return drift.KindIngress
+	default:
+		return ""
+	}
+}
+
+func (ra *ReconcileActionsImpl) backoffDelay(attempts int) time.Duration {
+	var delay = ra.opts.Backoff
+	for i := 1; i < attempts && delay < ra.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if ra.opts.MaxBackoff > 0 && delay > ra.opts.MaxBackoff {
+		delay = ra.opts.MaxBackoff
+	}
+	return delay
+}
+
+// managed reports if extra kube-api resource has softly deleted record
+func (ra *ReconcileActionsImpl) managed(res drift.Resource) (bool, error) {
+	var names []string
+	switch res.Kind {
+	case drift.KindDeployment:
+		deleted, err := ra.storage.GetDeletedDeploymentList(res.NamespaceID)
+		if err != nil {
+			return false, err
+		}
+		for _, depl := range deleted {
+			names = append(names, depl.Name)
+		}
+	case drift.KindService:
+		deleted, err := ra.storage.GetDeletedServiceList(res.NamespaceID)
+		if err != nil {
+			return false, err
+		}
+		for _, svc := range deleted {
+			names = append(names, svc.Name)
+		}
+	case drift.KindIngress:
+		deleted, err := ra.storage.GetDeletedIngressList(res.NamespaceID)
+		if err != nil {
+			return false, err
+		}
+		for _, ingr := range deleted {
+			names = append(names, ingr.Name)
+		}
+	}
+	for _, name := range names {
+		if name == res.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// repair applies record to kube-api and records result in journal
+func (ra *ReconcileActionsImpl) repair(ctx context.Context, res drift.Resource, attempt int) {
+	var op = operation.Operation{
+		ID:          uuid.New().String(),
+		NamespaceID: res.NamespaceID,
+		Name:        res.Name,
+		// repairs are made by service itself, not on behalf of some user
+		Owner:    uuid.Nil.String(),
+		Attempts: attempt,
+	}
+	var err = ra.apply(ctx, res, &op)
+
+	var log = ra.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"kind":      op.Kind,
+		"ns_id":     res.NamespaceID,
+		"name":      res.Name,
+		"drift":     res.Type,
+		"fields":    res.Fields,
+		"attempt":   attempt,
+	})
+	var result = "done"
+	op.Status = operation.StatusDone
+	if err != nil {
+		log.WithError(err).Warn("unable to repair drifted resource")
+		result = "failed"
+		op.Status = operation.StatusFailed
+		op.Error = err.Error()
+	} else {
+		log.Info("drifted resource repaired")
+	}
+	metrics.ReconcileRepairs.WithLabelValues(string(res.Kind), string(res.Type), result).Inc()
+
+	op.CreatedAt = time.Now().UTC()
+	op.UpdatedAt = op.CreatedAt
+	if _, err := ra.storage.CreateOperation(op); err != nil {
+		log.WithError(err).Error("unable to record repair in journal")
+	}
+}
+
+// apply sends kube-api request which removes drift, operation kind and resource state are set to op
+func (ra *ReconcileActionsImpl) apply(ctx context.Context, res drift.Resource, op *operation.Operation) error {
+	switch res.Kind {
+	case drift.KindDeployment:
+		if res.Type == drift.Extra {
+			op.Kind = operation.DeleteDeployment
+			return ra.kube.DeleteDeployment(ctx, res.NamespaceID, res.Name)
+		}
+		depl, err := ra.storage.GetDeployment(res.NamespaceID, res.Name)
+		if err != nil {
+			return err
+		}
+		op.Deployment = &depl
+		if res.Type == drift.Missing {
+			op.Kind = operation.CreateDeployment
+			return ra.kube.CreateDeployment(ctx, res.NamespaceID, depl.Deployment)
+		}
+		op.Kind = operation.UpdateDeployment
+		return ra.kube.UpdateDeployment(ctx, res.NamespaceID, depl.Deployment)
+	case drift.KindService:
+		if res.Type == drift.Extra {
+			op.Kind = operation.DeleteService
+			return ra.kube.DeleteService(ctx, res.NamespaceID, res.Name)
+		}
+		svc, err := ra.storage.GetService(res.NamespaceID, res.Name)
+		if err != nil {
+			return err
+		}
+		op.Service = &svc
+		if res.Type == drift.Missing {
+			op.Kind = operation.CreateService
+			return ra.kube.CreateService(ctx, res.NamespaceID, svc.Service)
+		}
+		op.Kind = operation.UpdateService
+		return ra.kube.UpdateService(ctx, res.NamespaceID, svc.Service)
+	case drift.KindIngress:
+		if res.Type == drift.Extra {
+			op.Kind = operation.DeleteIngress
+			return ra.kube.DeleteIngress(ctx, res.NamespaceID, res.Name)
+		}
+		ingr, err := ra.storage.GetIngress(res.NamespaceID, res.Name)
+		if err != nil {
+			return err
+		}
+		op.Ingress = &ingr
+		if res.Type == drift.Missing {
+			op.Kind = operation.CreateIngress
+			return ra.kube.CreateIngress(ctx, res.NamespaceID, ingr.ToKube())
+		}
+		op.Kind = operation.UpdateIngress
+		return ra.kube.UpdateIngress(ctx, res.NamespaceID, ingr.ToKube())
+	default:
+		return rserrors.ErrInternal().AddDetailF("unknown drifted resource kind %q", res.Kind)
+	}
+}
+
+// Run reconciles all namespaces periodically until context is done
+func (ra *ReconcileActionsImpl) Run(ctx context.Context, period time.Duration) {
+	ra.log.WithFields(logrus.Fields{
+		"period":   period,
+		"excluded": ra.opts.ExcludedNamespaces,
+	}).Info("starting reconciliation loop")
+	var ticker = time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		repairs, err := ra.Reconcile(server.BackgroundContext(ctx, uuid.Nil.String()))
+		switch {
+		case err != nil:
+			ra.log.WithError(err).Error("unable to reconcile resources")
+		case repairs > 0:
+			ra.log.WithField("repairs", repairs).Info("drifted resources reconciled")
+		}
+		select {
+		case <-ctx.Done():
+			ra.log.Info("stopping reconciliation loop")
+			return
+		case <-ticker.C:
+		}
+	}
+}
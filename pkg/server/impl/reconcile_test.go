@@ -0,0 +1,146 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/blang/semver"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	var ctx = context.Background()
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+
+	var port = 80
+	var depl = model.Deployment{Name: "web", Replicas: 1, Active: true, Version: semver.MustParse("1.0.0"),
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	var svc = model.Service{Name: "web", Deploy: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+	for _, nsID := range []string{"ns", "excluded"} {
+		_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: nsID, Deployment: depl})
+		assert.NoError(t, err)
+	}
+	_, err := storage.CreateService(service.ServiceResource{NamespaceID: "ns", Service: svc})
+	assert.NoError(t, err)
+	var old = depl
+	old.Name = "old"
+	_, err = storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: old})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteDeployment("ns", "old"))
+
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+	assert.NoError(t, kube.SetDeploymentReplicas(ctx, "ns", "web", 3))
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", old))
+	assert.NoError(t, kube.CreateService(ctx, "ns", model.Service{Name: "manual", Deploy: "web"}))
+
+	var reconciler = NewReconcileActionsImpl(storage, &kube, ReconcileOptions{
+		ExcludedNamespaces: []string{"excluded"},
+		RepairLimit:        2,
+		Backoff:            time.Minute,
+		MaxBackoff:         time.Hour,
+	})
+
+	repairs, err := reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repairs, "repairs must be limited")
+	repairs, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, repairs)
+
+	report, err := reconciler.drift.DetectDrift(ctx, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []drift.Resource{
+			{NamespaceID: "excluded", Kind: drift.KindDeployment, Name: "web", Type: drift.Missing},
+			{NamespaceID: "ns", Kind: drift.KindService, Name: "manual", Type: drift.Extra},
+		}, report.Resources, "only excluded and unmanaged resources must be left")
+	}
+
+	// repeated drift of recently repaired resource waits for backoff
+	assert.NoError(t, kube.DeleteService(ctx, "ns", "web"))
+	repairs, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, repairs)
+	reconciler.now = func() time.Time { return time.Now().Add(time.Minute) }
+	repairs, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, repairs)
+	assert.Equal(t, 2*time.Minute, reconciler.backoffDelay(2))
+}
+
+func TestDeleteAllResourcesInNamespace(t *testing.T) {
+	var ctx = context.Background()
+	var storage = db.NewMemory()
+	var kube clients.Kube = clients.NewFakeKube()
+
+	var depl = model.Deployment{Name: "web", Replicas: 1, Active: true, Version: semver.MustParse("1.0.0"),
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: depl})
+	assert.NoError(t, err)
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", depl))
+
+	assert.NoError(t, NewResourcesActionsImpl(storage, &kube).DeleteAllResourcesInNamespace(ctx, "ns"))
+	NewJournalActionsImpl(storage, &kube, minOperationStaleAfter).Recover(ctx)
+
+	deployments, err := kube.GetDeploymentList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, deployments, "kube-api deployment must be deleted by journal recovery")
+}
+
+func TestReconcileConcurrentOperation(t *testing.T) {
+	var ctx = context.Background()
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+
+	// replicas are updated in storage and kube-api request is still in flight
+	var depl = model.Deployment{Name: "web", Replicas: 3, Active: true, Version: semver.MustParse("1.0.0"),
+		Containers: []model.Container{{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}}}
+	stored, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: depl})
+	assert.NoError(t, err)
+	var old = depl
+	old.Replicas = 1
+	assert.NoError(t, kube.CreateDeployment(ctx, "ns", old))
+	var now = time.Now().UTC()
+	op, err := storage.CreateOperation(operation.Operation{ID: uuid.New().String(), Kind: operation.SetDeploymentReplicas,
+		Status: operation.StatusStored, NamespaceID: "ns", Name: "web", Deployment: &stored, CreatedAt: now, UpdatedAt: now})
+	assert.NoError(t, err)
+
+	var reconciler = NewReconcileActionsImpl(storage, &kube, ReconcileOptions{Backoff: time.Minute, MaxBackoff: time.Hour})
+	repairs, err := reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, repairs, "resource with not finished operation must not be repaired")
+	assert.Equal(t, 0, fake.Calls("UpdateDeployment"))
+
+	// reconciliation of another replica holds lock
+	assert.NoError(t, storage.SetOperationStatus(op.ID, operation.StatusCompensated, ""))
+	acquired, err := storage.AcquireLock(reconcileLockName, "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	repairs, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, repairs)
+
+	assert.NoError(t, storage.ReleaseLock(reconcileLockName, "other"))
+	repairs, err = reconciler.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, repairs)
+	deployments, err := kube.GetDeploymentList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, deployments, 1) {
+		assert.Equal(t, 3, deployments[0].Replicas)
+	}
+	// lock is released after reconciliation
+	acquired, err = storage.AcquireLock(reconcileLockName, "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -3,7 +3,9 @@ package impl
 import (
 	"context"
 
+	"git.containerum.net/ch/resource-service/pkg/clients"
 	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
 	"git.containerum.net/ch/resource-service/pkg/models/resources"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"github.com/containerum/cherry/adaptors/cherrylog"
@@ -12,14 +14,17 @@ import (
 )
 
 type ResourcesActionsImpl struct {
-	mongo *db.MongoStorage
-	log   *cherrylog.LogrusAdapter
+	storage db.Storage
+	journal journal
+	log     *cherrylog.LogrusAdapter
 }
 
-func NewResourcesActionsImpl(mongo *db.MongoStorage) *ResourcesActionsImpl {
+func NewResourcesActionsImpl(storage db.Storage, kube *clients.Kube) *ResourcesActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "resource_service"))
 	return &ResourcesActionsImpl{
-		mongo: mongo,
-		log:   cherrylog.NewLogrusAdapter(logrus.WithField("component", "resource_service")),
+		storage: storage,
+		journal: newJournal(storage, *kube, log),
+		log:     log,
 	}
 }
 
@@ -27,22 +32,22 @@ func (rs *ResourcesActionsImpl) GetResourcesCount(ctx context.Context) (*resourc
 	userID := httputil.MustGetUserID(ctx)
 	rs.log.WithField("user_id", userID).Info("get resources count")
 
-	ingresses, err := rs.mongo.CountIngresses(userID)
+	ingresses, err := rs.storage.CountIngresses(userID)
 	if err != nil {
 		rs.log.Debug(err)
 		return nil, rserrors.ErrUnableCountResources()
 	}
-	deploys, err := rs.mongo.CountDeployments(userID)
+	deploys, err := rs.storage.CountDeployments(userID)
 	if err != nil {
 		rs.log.Debug(err)
 		return nil, rserrors.ErrUnableCountResources()
 	}
-	services, err := rs.mongo.CountServices(userID)
+	services, err := rs.storage.CountServices(userID)
 	if err != nil {
 		rs.log.Debug(err)
 		return nil, rserrors.ErrUnableCountResources()
 	}
-	pods, err := rs.mongo.CountReplicas(userID)
+	pods, err := rs.storage.CountReplicas(userID)
 	if err != nil {
 		rs.log.Debug(err)
 		return nil, rserrors.ErrUnableCountResources()
@@ -59,30 +64,61 @@ func (rs *ResourcesActionsImpl) GetResourcesCount(ctx context.Context) (*resourc
 	return &ret, nil
 }
 
+// DeleteAllResourcesInNamespace enqueues deletion of resources from kube-api in operations journal and deletes them in storage.
+// Operations are recorded first and are irreversible, so deletion is finished by journal recovery even if service is restarted.
 func (rs *ResourcesActionsImpl) DeleteAllResourcesInNamespace(ctx context.Context, nsID string) error {
 	rs.log.WithField("namespace_id", nsID).Info("deleting all resources")
-	if err := rs.mongo.DeleteAllIngressesInNamespace(nsID); err != nil {
+	ingresses, err := rs.storage.GetIngressList(nsID)
+	if err != nil {
 		return err
 	}
-	if err := rs.mongo.DeleteAllServicesInNamespace(nsID); err != nil {
+	services, err := rs.storage.GetServiceList(nsID)
+	if err != nil {
 		return err
 	}
-	if err := rs.mongo.DeleteAllDeploymentsInNamespace(nsID); err != nil {
+	deployments, err := rs.storage.GetDeploymentList(nsID)
+	if err != nil {
 		return err
 	}
-	return nil
+
+	var ops []operation.Operation
+	for i, ingr := range ingresses {
+		ops = append(ops, operation.Operation{Kind: operation.DeleteIngress, NamespaceID: nsID, Name: ingr.Name, Owner: ingr.Owner,
+			Ingress: &ingresses[i], Irreversible: true})
+	}
+	for i, svc := range services {
+		ops = append(ops, operation.Operation{Kind: operation.DeleteService, NamespaceID: nsID, Name: svc.Name, Owner: svc.Owner,
+			Service: &services[i], Irreversible: true})
+	}
+	for i, depl := range deployments {
+		ops = append(ops, operation.Operation{Kind: operation.DeleteDeployment, NamespaceID: nsID, Name: depl.Name, Owner: depl.Owner,
+			Deployment: &deployments[i], Irreversible: true})
+	}
+	for _, op := range ops {
+		if err := rs.journal.enqueue(op); err != nil {
+			return err
+		}
+	}
+
+	if err := rs.storage.DeleteAllIngressesInNamespace(nsID); err != nil {
+		return err
+	}
+	if err := rs.storage.DeleteAllServicesInNamespace(nsID); err != nil {
+		return err
+	}
+	return rs.storage.DeleteAllDeploymentsInNamespace(nsID)
 }
 
 func (rs *ResourcesActionsImpl) DeleteAllUserResources(ctx context.Context) error {
 	userID := httputil.MustGetUserID(ctx)
 	rs.log.WithField("user_id", userID).Info("deleting all user resources")
-	if err := rs.mongo.DeleteAllIngressesByOwner(userID); err != nil {
+	if err := rs.storage.DeleteAllIngressesByOwner(userID); err != nil {
 		return err
 	}
-	if err := rs.mongo.DeleteAllServicesByOwner(userID); err != nil {
+	if err := rs.storage.DeleteAllServicesByOwner(userID); err != nil {
 		return err
 	}
-	if err := rs.mongo.DeleteAllDeploymentsByOwner(userID); err != nil {
+	if err := rs.storage.DeleteAllDeploymentsByOwner(userID); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,129 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAllResourcesInNamespaceRecovery(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var resources = NewResourcesActionsImpl(storage, &kube)
+	var journal = NewJournalActionsImpl(storage, &kube, minOperationStaleAfter)
+
+	var container = model.Container{Name: "nginx", Image: "nginx", Limits: model.Resource{CPU: 100, Memory: 128}}
+	var port = 80
+	var createDeployment = func(nsID, name string) deployment.DeploymentResource {
+		var depl = model.Deployment{Name: name, Replicas: 1, Active: true, Containers: []model.Container{container}}
+		created, err := storage.CreateDeployment(deployment.DeploymentFromKube(nsID, "owner", depl))
+		assert.NoError(t, err)
+		assert.NoError(t, kube.CreateDeployment(ctx, nsID, depl))
+		return created
+	}
+	var createService = func(nsID, name string) service.ServiceResource {
+		// external ports are unique per domain, so every namespace gets its own
+		var svc = model.Service{Name: name, Deploy: "web", Domain: nsID + ".example.com",
+			Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+		created, err := storage.CreateService(service.ServiceFromKube(nsID, "owner", svc))
+		assert.NoError(t, err)
+		assert.NoError(t, kube.CreateService(ctx, nsID, svc))
+		return created
+	}
+	createDeployment("ns", "web")
+	createService("ns", "web")
+	var ingr = model.Ingress{Name: "web", Rules: []model.Rule{
+		{Host: "web.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "web", ServicePort: port}}}}}
+	_, err := storage.CreateIngress(ingress.IngressFromKube("ns", "owner", ingr))
+	assert.NoError(t, err)
+	assert.NoError(t, kube.CreateIngress(ctx, "ns", ingress.KubeIngress{Ingress: ingr}))
+
+	// kube-api deletion of deployment fails, but namespace records are not restored
+	fake.FailMethod("DeleteDeployment", rserrors.ErrServiceUnavailable())
+	assert.NoError(t, resources.DeleteAllResourcesInNamespace(ctx, "ns"))
+	deployments, err := storage.GetDeploymentList("ns")
+	assert.NoError(t, err)
+	assert.Empty(t, deployments)
+	// the last attempt of other deployment deletion fails too
+	var last = createDeployment("last", "web")
+	assert.NoError(t, storage.DeleteDeployment("last", "web"))
+	lastOp := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteDeployment, Status: operation.StatusStored,
+		NamespaceID: "last", Name: "web", Deployment: &last, Irreversible: true, Attempts: operationMaxAttempts - 1})
+
+	// service restarted after operation was recorded but before storage write, recovery deletes record too
+	var crashed = createService("crashed", "web")
+	crashedOp := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteService, Status: operation.StatusStored,
+		NamespaceID: "crashed", Name: "web", Service: &crashed, Irreversible: true})
+	// service was created again before recovery, new one is kept
+	var old = createService("recreated", "web")
+	assert.NoError(t, storage.DeleteService("recreated", "web"))
+	_, err = storage.CreateService(service.ServiceFromKube("recreated", "owner", old.Service))
+	assert.NoError(t, err)
+	recreatedOp := staleOperation(t, storage, operation.Operation{Kind: operation.DeleteService, Status: operation.StatusStored,
+		NamespaceID: "recreated", Name: "web", Service: &old, Irreversible: true})
+
+	journal.Recover(ctx)
+
+	ops, err := storage.GetStaleOperations(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	var pending = make(map[operation.Kind]operation.Operation)
+	for _, op := range ops {
+		if op.NamespaceID == "ns" {
+			pending[op.Kind] = op
+		}
+	}
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, operation.StatusStored, pending[operation.DeleteDeployment].Status)
+		assert.Equal(t, 1, pending[operation.DeleteDeployment].Attempts)
+	}
+	kubeServices, err := kube.GetServiceList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, kubeServices)
+	kubeIngresses, err := kube.GetIngressList(ctx, "ns")
+	assert.NoError(t, err)
+	assert.Empty(t, kubeIngresses)
+	deployments, err = storage.GetDeploymentList("ns")
+	assert.NoError(t, err)
+	assert.Empty(t, deployments)
+
+	op, err := storage.GetOperation(lastOp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusFailed, op.Status)
+	}
+	_, err = storage.GetDeployment("last", "web")
+	assert.Error(t, err, "irreversible deletion must not be compensated")
+
+	op, err = storage.GetOperation(crashedOp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusDone, op.Status)
+	}
+	_, err = storage.GetService("crashed", "web")
+	assert.Error(t, err)
+	kubeServices, err = kube.GetServiceList(ctx, "crashed")
+	assert.NoError(t, err)
+	assert.Empty(t, kubeServices)
+
+	op, err = storage.GetOperation(recreatedOp)
+	if assert.NoError(t, err) {
+		assert.Equal(t, operation.StatusDone, op.Status)
+	}
+	_, err = storage.GetService("recreated", "web")
+	assert.NoError(t, err)
+	kubeServices, err = kube.GetServiceList(ctx, "recreated")
+	assert.NoError(t, err)
+	assert.Len(t, kubeServices, 1)
+}
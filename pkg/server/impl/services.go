@@ -2,9 +2,11 @@ package impl
 
 import (
 	"context"
+	"time"
 
 	"git.containerum.net/ch/resource-service/pkg/clients"
 	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
 	"git.containerum.net/ch/resource-service/pkg/models/service"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	"git.containerum.net/ch/resource-service/pkg/server"
@@ -18,16 +20,19 @@ import (
 type ServiceActionsImpl struct {
 	kube        clients.Kube
 	permissions clients.Permissions
-	mongo       *db.MongoStorage
+	storage     db.Storage
+	journal     journal
 	log         *cherrylog.LogrusAdapter
 }
 
-func NewServiceActionsImpl(mongo *db.MongoStorage, permissions *clients.Permissions, kube *clients.Kube) *ServiceActionsImpl {
+func NewServiceActionsImpl(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube) *ServiceActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "service_actions"))
 	return &ServiceActionsImpl{
 		kube:        *kube,
-		mongo:       mongo,
+		storage:     storage,
 		permissions: *permissions,
-		log:         cherrylog.NewLogrusAdapter(logrus.WithField("component", "service_actions")),
+		journal:     newJournal(storage, *kube, log),
+		log:         log,
 	}
 }
 
@@ -38,7 +43,7 @@ func (sa *ServiceActionsImpl) GetServices(ctx context.Context, nsID string) (ser
 		"namespace": nsID,
 	}).Info("get services")
 
-	return sa.mongo.GetServiceList(nsID)
+	return sa.storage.GetServiceList(nsID)
 }
 
 func (sa *ServiceActionsImpl) GetService(ctx context.Context, nsID, serviceName string) (*service.ServiceResource, error) {
@@ -49,7 +54,7 @@ func (sa *ServiceActionsImpl) GetService(ctx context.Context, nsID, serviceName
 		"service_name": serviceName,
 	}).Info("get service")
 
-	ret, err := sa.mongo.GetService(nsID, serviceName)
+	ret, err := sa.storage.GetService(nsID, serviceName)
 
 	return &ret, err
 }
@@ -61,7 +66,7 @@ func (sa *ServiceActionsImpl) CreateService(ctx context.Context, nsID string, re
 		"ns_id":   nsID,
 	}).Infof("create service %#v", req)
 
-	_, err := sa.mongo.GetDeployment(nsID, req.Deploy)
+	_, err := sa.storage.GetDeployment(nsID, req.Deploy)
 	if err != nil {
 		sa.log.Error(err)
 		return nil, rserrors.ErrResourceNotExists().AddDetailF("deployment '%s' not exists", req.Deploy)
@@ -70,7 +75,7 @@ func (sa *ServiceActionsImpl) CreateService(ctx context.Context, nsID string, re
 	serviceType := server.DetermineServiceType(req)
 
 	if serviceType == service.ServiceExternal {
-		domain, err := sa.mongo.GetRandomDomain()
+		domain, err := sa.storage.GetRandomDomain()
 		if err != nil {
 			return nil, err
 		}
@@ -78,7 +83,7 @@ func (sa *ServiceActionsImpl) CreateService(ctx context.Context, nsID string, re
 		req.Domain = domain.Domain
 		req.IPs = domain.IP
 		for i, port := range req.Ports {
-			externalPort, err := sa.mongo.GetFreePort(domain.Domain, port.Protocol)
+			externalPort, err := sa.storage.GetFreePort(domain.Domain, port.Protocol)
 			if err != nil {
 				return nil, err
 			}
@@ -91,7 +96,7 @@ func (sa *ServiceActionsImpl) CreateService(ctx context.Context, nsID string, re
 		return nil, err
 	}
 
-	nsUsage, err := sa.mongo.CountServicesInNamespace(nsID)
+	nsUsage, err := sa.storage.CountServicesInNamespace(nsID)
 	if err != nil {
 		return nil, err
 	}
@@ -100,16 +105,19 @@ func (sa *ServiceActionsImpl) CreateService(ctx context.Context, nsID string, re
 		return nil, err
 	}
 
-	createdService, err := sa.mongo.CreateService(service.ServiceFromKube(nsID, userID, req))
-	if err != nil {
-		return nil, err
-	}
-
-	if err := sa.kube.CreateService(ctx, nsID, req); err != nil {
-		sa.log.Debug("Kube-API error! Deleting service from DB.")
-		if err := sa.mongo.DeleteService(nsID, req.Name); err != nil {
-			return nil, err
-		}
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	newService := service.ServiceFromKube(nsID, userID, req)
+	newService.CreatedAt = &createdAt
+	var createdService service.ServiceResource
+	if err := sa.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateService,
+		NamespaceID: nsID,
+		Name:        req.Name,
+		Service:     &newService,
+	}, func() (err error) {
+		createdService, err = sa.storage.CreateService(newService)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -124,15 +132,19 @@ func (sa *ServiceActionsImpl) UpdateService(ctx context.Context, nsID string, re
 		"service_name": req.Name,
 	}).Info("update service")
 
-	oldService, err := sa.mongo.GetService(nsID, req.Name)
+	oldService, err := sa.storage.GetService(nsID, req.Name)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := server.CheckRevision(ctx, oldService.ID, oldService.Revision); err != nil {
+		return nil, err
+	}
+
 	serviceType := server.DetermineServiceType(kubtypes.Service(req))
 
 	if serviceType == service.ServiceExternal {
-		domain, err := sa.mongo.GetRandomDomain()
+		domain, err := sa.storage.GetRandomDomain()
 		if err != nil {
 			return nil, err
 		}
@@ -144,7 +156,7 @@ func (sa *ServiceActionsImpl) UpdateService(ctx context.Context, nsID string, re
 			if oldService.Ports[i].Port != nil {
 				externalPort = *oldService.Ports[i].Port
 			} else {
-				externalPort, err = sa.mongo.GetFreePort(domain.Domain, port.Protocol)
+				externalPort, err = sa.storage.GetFreePort(domain.Domain, port.Protocol)
 				if err != nil {
 					return nil, err
 				}
@@ -153,20 +165,25 @@ func (sa *ServiceActionsImpl) UpdateService(ctx context.Context, nsID string, re
 		}
 	}
 
-	createdService, err := sa.mongo.UpdateService(service.ServiceFromKube(nsID, userID, req))
-	if err != nil {
-		return nil, err
-	}
-
-	if err := sa.kube.UpdateService(ctx, nsID, req); err != nil {
-		sa.log.Debug("Kube-API error! Reverting changes.")
-		if _, err := sa.mongo.UpdateService(oldService); err != nil {
-			return nil, err
-		}
+	newService := service.ServiceFromKube(nsID, userID, req)
+	newService.ID = oldService.ID
+	newService.Revision = oldService.Revision
+	newService.CreatedAt = oldService.CreatedAt
+	var updatedService service.ServiceResource
+	if err := sa.journal.execute(ctx, operation.Operation{
+		Kind:        operation.UpdateService,
+		NamespaceID: nsID,
+		Name:        req.Name,
+		Service:     &newService,
+		OldService:  &oldService,
+	}, func() (err error) {
+		updatedService, err = sa.storage.UpdateService(newService)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
-	return &createdService, nil
+	return &updatedService, nil
 }
 
 func (sa *ServiceActionsImpl) DeleteService(ctx context.Context, nsID, serviceName string) error {
@@ -177,7 +194,7 @@ func (sa *ServiceActionsImpl) DeleteService(ctx context.Context, nsID, serviceNa
 		"service_name": serviceName,
 	}).Info("delete service")
 
-	_, err := sa.mongo.GetIngressByService(nsID, serviceName)
+	_, err := sa.storage.GetIngressByService(nsID, serviceName)
 	switch {
 	case err == nil:
 		return rserrors.ErrServiceHasIngresses()
@@ -187,19 +204,13 @@ func (sa *ServiceActionsImpl) DeleteService(ctx context.Context, nsID, serviceNa
 		return err
 	}
 
-	if err := sa.mongo.DeleteService(nsID, serviceName); err != nil {
-		return err
-	}
-
-	if err := sa.kube.DeleteService(ctx, nsID, serviceName); err != nil {
-		sa.log.Debug("Kube-API error! Reverting changes.")
-		if err := sa.mongo.RestoreService(nsID, serviceName); err != nil {
-			return err
-		}
-		return err
-	}
-
-	return nil
+	return sa.journal.execute(ctx, operation.Operation{
+		Kind:        operation.DeleteService,
+		NamespaceID: nsID,
+		Name:        serviceName,
+	}, func() error {
+		return sa.storage.DeleteService(nsID, serviceName)
+	})
 }
 
 func (sa *ServiceActionsImpl) DeleteAllServices(ctx context.Context, nsID string) error {
@@ -207,7 +218,7 @@ func (sa *ServiceActionsImpl) DeleteAllServices(ctx context.Context, nsID string
 		"ns_id": nsID,
 	}).Info("delete all services")
 
-	if err := sa.mongo.DeleteAllServicesInNamespace(nsID); err != nil {
+	if err := sa.storage.DeleteAllServicesInNamespace(nsID); err != nil {
 		return err
 	}
 
@@ -0,0 +1,67 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceActionsRevert(t *testing.T) {
+	var ctx = server.BackgroundContext(context.Background(), uuid.New().String())
+	var storage = db.NewMemory()
+	var fake = clients.NewFakeKube()
+	var kube clients.Kube = fake
+	var permissions clients.Permissions = &limitsPermissions{limits: model.Namespace{MaxIntService: 5}}
+
+	_, err := storage.CreateDeployment(deployment.DeploymentResource{NamespaceID: "ns", Deployment: model.Deployment{Name: "web", Active: true}})
+	assert.NoError(t, err)
+	var services = NewServiceActionsImpl(storage, &permissions, &kube)
+	var port = 8080
+	var svc = model.Service{Name: "web", Deploy: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, TargetPort: 80, Protocol: model.TCP}}}
+
+	// failed kube-api creation removes record
+	var fault = errors.New("fault")
+	fake.FailMethod("CreateService", fault)
+	_, err = services.CreateService(ctx, "ns", svc)
+	assert.Equal(t, fault, err)
+	_, err = storage.GetService("ns", "web")
+	assert.True(t, cherry.Equals(err, rserrors.ErrResourceNotExists()), "%v", err)
+
+	fake.ClearFaults()
+	_, err = services.CreateService(ctx, "ns", svc)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// failed kube-api update restores previous record
+	fake.FailMethod("UpdateService", fault)
+	var updated = svc
+	updated.Ports = []model.ServicePort{{Name: "http", Port: &port, TargetPort: 8000, Protocol: model.TCP}}
+	_, err = services.UpdateService(ctx, "ns", updated)
+	assert.Equal(t, fault, err)
+	stored, err := storage.GetService("ns", "web")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 80, stored.Ports[0].TargetPort)
+	}
+
+	// failed kube-api deletion restores record
+	fake.ClearFaults()
+	fake.FailMethod("DeleteService", fault)
+	assert.Equal(t, fault, services.DeleteService(ctx, "ns", "web"))
+	_, err = storage.GetService("ns", "web")
+	assert.NoError(t, err)
+	kubeServices, err := kube.GetServiceList(ctx, "ns")
+	if assert.NoError(t, err) && assert.Len(t, kubeServices, 1) {
+		assert.Equal(t, 80, kubeServices[0].Ports[0].TargetPort)
+	}
+}
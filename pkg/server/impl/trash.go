@@ -0,0 +1,373 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/clients"
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/operation"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"git.containerum.net/ch/resource-service/pkg/server"
+	"github.com/containerum/cherry"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/containerum/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+// TrashActionsImpl lists and restores softly deleted resources and purges old ones.
+// Only the latest deleted resource with name is shown and may be restored.
+type TrashActionsImpl struct {
+	kube        clients.Kube
+	permissions clients.Permissions
+	storage     db.Storage
+	journal     journal
+	log         *cherrylog.LogrusAdapter
+}
+
+func NewTrashActionsImpl(storage db.Storage, permissions *clients.Permissions, kube *clients.Kube) *TrashActionsImpl {
+	var log = cherrylog.NewLogrusAdapter(logrus.WithField("component", "trash_actions"))
+	return &TrashActionsImpl{
+		kube:        *kube,
+		storage:     storage,
+		permissions: *permissions,
+		journal:     newJournal(storage, *kube, log),
+		log:         log,
+	}
+}
+
+func (ta *TrashActionsImpl) GetDeletedDeploymentsList(ctx context.Context, nsID string) (deployment.DeploymentList, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+	}).Info("get deleted deployments")
+
+	deleted, err := ta.storage.GetDeletedDeploymentList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	var list = make(deployment.DeploymentList, 0, len(deleted))
+	var seen = make(map[string]struct{}, len(deleted))
+	for _, depl := range deleted {
+		if _, ok := seen[depl.Name]; ok {
+			continue
+		}
+		seen[depl.Name] = struct{}{}
+		list = append(list, depl)
+	}
+	return list, nil
+}
+
+func (ta *TrashActionsImpl) GetDeletedServicesList(ctx context.Context, nsID string) (service.ServiceList, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+	}).Info("get deleted services")
+
+	deleted, err := ta.storage.GetDeletedServiceList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	var list = make(service.ServiceList, 0, len(deleted))
+	var seen = make(map[string]struct{}, len(deleted))
+	for _, svc := range deleted {
+		if _, ok := seen[svc.Name]; ok {
+			continue
+		}
+		seen[svc.Name] = struct{}{}
+		list = append(list, svc)
+	}
+	return list, nil
+}
+
+func (ta *TrashActionsImpl) GetDeletedIngressesList(ctx context.Context, nsID string) (ingress.IngressList, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+	}).Info("get deleted ingresses")
+
+	deleted, err := ta.storage.GetDeletedIngressList(nsID)
+	if err != nil {
+		return nil, err
+	}
+	var list = make(ingress.IngressList, 0, len(deleted))
+	var seen = make(map[string]struct{}, len(deleted))
+	for _, ingr := range deleted {
+		if _, ok := seen[ingr.Name]; ok {
+			continue
+		}
+		seen[ingr.Name] = struct{}{}
+		list = append(list, ingr)
+	}
+	return list, nil
+}
+
+// checkNameFree returns error if alive resource with name exists. get must return ErrResourceNotExists for missing resource.
+func checkNameFree(kind, name string, get func() error) error {
+	switch err := get(); {
+	case err == nil:
+		return rserrors.ErrResourceAlreadyExists().AddDetailF("%s %s already exists", kind, name)
+	case cherry.Equals(err, rserrors.ErrResourceNotExists()):
+		return nil
+	default:
+		return err
+	}
+}
+
+func (ta *TrashActionsImpl) RestoreDeployment(ctx context.Context, nsID, deplName string) (*deployment.DeploymentResource, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"ns_id":       nsID,
+		"deploy_name": deplName,
+	}).Info("restore deployment")
+
+	deleted, err := ta.GetDeletedDeploymentsList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	var restored *deployment.DeploymentResource
+	for i := range deleted {
+		if deleted[i].Name == deplName {
+			restored = &deleted[i]
+			break
+		}
+	}
+	if restored == nil {
+		return nil, rserrors.ErrResourceNotExists().AddDetailF("deleted deployment %s not found", deplName)
+	}
+
+	if err := checkNameFree("deployment", deplName, func() error {
+		_, err := ta.storage.GetDeployment(nsID, deplName)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	nsLimits, err := ta.permissions.GetNamespaceLimits(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	nsUsage, err := ta.storage.GetNamespaceResourcesLimits(nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.CheckDeploymentCreateQuotas(nsLimits, nsUsage, restored.Deployment); err != nil {
+		return nil, err
+	}
+
+	var deletedAt = restored.DeletedAt
+	restored.Deleted = false
+	restored.DeletedAt = nil
+	if err := ta.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateDeployment,
+		NamespaceID: nsID,
+		Name:        deplName,
+		Deployment:  restored,
+	}, func() error {
+		return ta.storage.RestoreDeployment(nsID, deplName)
+	}); err != nil {
+		return nil, err
+	}
+
+	// inactive versions deleted together with deployment are not in kubernetes, they are restored only in storage
+	// after deployment is created, so failed creation has nothing else to revert
+	if deletedAt != nil {
+		if err := ta.storage.RestoreDeploymentVersions(nsID, deplName, *deletedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return restored, nil
+}
+
+func (ta *TrashActionsImpl) RestoreService(ctx context.Context, nsID, serviceName string) (*service.ServiceResource, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"ns_id":        nsID,
+		"service_name": serviceName,
+	}).Info("restore service")
+
+	deleted, err := ta.GetDeletedServicesList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	var restored *service.ServiceResource
+	for i := range deleted {
+		if deleted[i].Name == serviceName {
+			restored = &deleted[i]
+			break
+		}
+	}
+	if restored == nil {
+		return nil, rserrors.ErrResourceNotExists().AddDetailF("deleted service %s not found", serviceName)
+	}
+
+	if err := checkNameFree("service", serviceName, func() error {
+		_, err := ta.storage.GetService(nsID, serviceName)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, err := ta.storage.GetDeployment(nsID, restored.Deploy); err != nil {
+		ta.log.Error(err)
+		return nil, rserrors.ErrResourceNotExists().AddDetailF("deployment '%s' not exists", restored.Deploy)
+	}
+
+	nsLimits, err := ta.permissions.GetNamespaceLimits(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	nsUsage, err := ta.storage.CountServicesInNamespace(nsID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.CheckServiceCreateQuotas(nsLimits, nsUsage, server.DetermineServiceType(restored.Service)); err != nil {
+		return nil, err
+	}
+
+	if restored.Domain != "" {
+		for _, port := range restored.Ports {
+			if port.Port == nil {
+				continue
+			}
+			free, err := ta.storage.IsPortFree(restored.Domain, *port.Port, port.Protocol)
+			if err != nil {
+				return nil, err
+			}
+			if !free {
+				return nil, rserrors.ErrPortAlreadyUsed().AddDetailF("%s port %d on domain %s is used by another service", port.Protocol, *port.Port, restored.Domain)
+			}
+		}
+	}
+
+	restored.Deleted = false
+	restored.DeletedAt = nil
+	if err := ta.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateService,
+		NamespaceID: nsID,
+		Name:        serviceName,
+		Service:     restored,
+	}, func() error {
+		return ta.storage.RestoreService(nsID, serviceName)
+	}); err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+func (ta *TrashActionsImpl) RestoreIngress(ctx context.Context, nsID, ingressName string) (*ingress.IngressResource, error) {
+	userID := httputil.MustGetUserID(ctx)
+	ta.log.WithFields(logrus.Fields{
+		"user_id": userID,
+		"ns_id":   nsID,
+		"ingress": ingressName,
+	}).Info("restore ingress")
+
+	deleted, err := ta.GetDeletedIngressesList(ctx, nsID)
+	if err != nil {
+		return nil, err
+	}
+	var restored *ingress.IngressResource
+	for i := range deleted {
+		if deleted[i].Name == ingressName {
+			restored = &deleted[i]
+			break
+		}
+	}
+	if restored == nil {
+		return nil, rserrors.ErrResourceNotExists().AddDetailF("deleted ingress %s not found", ingressName)
+	}
+
+	if restored.Options.BasicAuthSecret() != "" {
+		// htpasswd secret is deleted with ingress and passwords are not stored
+		return nil, rserrors.ErrValidation().AddDetailF("ingress %s with basic auth can't be restored, create it again", ingressName)
+	}
+
+	if err := checkNameFree("ingress", ingressName, func() error {
+		_, err := ta.storage.GetIngress(nsID, ingressName)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range restored.Rules {
+		for _, path := range rule.Path {
+			if _, err := ta.storage.GetService(nsID, path.ServiceName); err != nil {
+				ta.log.Error(err)
+				return nil, rserrors.ErrResourceNotExists().AddDetailF("service '%s' not exists", path.ServiceName)
+			}
+		}
+	}
+	for _, split := range restored.Splits {
+		for _, backend := range split.Backends {
+			if _, err := ta.storage.GetService(nsID, backend.ServiceName); err != nil {
+				ta.log.Error(err)
+				return nil, rserrors.ErrResourceNotExists().AddDetailF("service '%s' not exists", backend.ServiceName)
+			}
+		}
+	}
+
+	if err := checkHostsFree(ctx, ta.storage, nsID, ingressName, restored.Rules); err != nil {
+		return nil, err
+	}
+
+	restored.Deleted = false
+	restored.DeletedAt = nil
+	if err := ta.journal.execute(ctx, operation.Operation{
+		Kind:        operation.CreateIngress,
+		NamespaceID: nsID,
+		Name:        ingressName,
+		Ingress:     restored,
+	}, func() error {
+		return ta.storage.RestoreIngress(nsID, ingressName)
+	}); err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// RunPurge removes resources deleted more than retention ago on start and then periodically until context is done
+func (ta *TrashActionsImpl) RunPurge(ctx context.Context, period, retention time.Duration) {
+	ta.log.WithFields(logrus.Fields{
+		"period":    period,
+		"retention": retention,
+	}).Info("starting trash purge loop")
+	var ticker = time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		ta.Purge(retention)
+		select {
+		case <-ctx.Done():
+			ta.log.Info("stopping trash purge loop")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes resources deleted more than retention ago completely
+func (ta *TrashActionsImpl) Purge(retention time.Duration) {
+	removed, err := ta.storage.PurgeDeleted(time.Now().UTC().Add(-retention))
+	if err != nil {
+		ta.log.WithError(err).Error("unable to purge deleted resources")
+		return
+	}
+	if removed > 0 {
+		ta.log.WithField("removed", removed).Info("deleted resources purged")
+	}
+}
@@ -0,0 +1,40 @@
+package impl
+
+import (
+	"context"
+
+	"git.containerum.net/ch/resource-service/pkg/db"
+	"git.containerum.net/ch/resource-service/pkg/models/event"
+	"github.com/containerum/cherry/adaptors/cherrylog"
+	"github.com/containerum/utils/httputil"
+	"github.com/sirupsen/logrus"
+)
+
+// WatchActionsImpl streams changes of namespace resources published by storage
+type WatchActionsImpl struct {
+	watcher db.Watcher
+	log     *cherrylog.LogrusAdapter
+}
+
+func NewWatchActionsImpl(watcher db.Watcher) *WatchActionsImpl {
+	return &WatchActionsImpl{
+		watcher: watcher,
+		log:     cherrylog.NewLogrusAdapter(logrus.WithField("component", "watch_actions")),
+	}
+}
+
+func (wa *WatchActionsImpl) Watch(ctx context.Context, nsID, lastEventID string) (<-chan event.Event, error) {
+	userID := httputil.MustGetUserID(ctx)
+	wa.log.WithFields(logrus.Fields{
+		"user_id":       userID,
+		"ns_id":         nsID,
+		"last_event_id": lastEventID,
+	}).Info("watch namespace")
+
+	events, cancel := wa.watcher.Watch(nsID, lastEventID)
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return events, nil
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/deployment"
+	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/blang/semver"
+)
+
+// List sort fields, "-" prefix means descending order
+const (
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+	SortByDeletedAt = "deleted_at"
+	SortByVersion   = "version"
+)
+
+const maxListLimit = 1000
+
+// ListQuery -- filtering, sorting and pagination parameters of list requests.
+// Filters not applicable to resource kind match nothing, e.g. image filter of services list.
+type ListQuery struct {
+	NamePrefix  string `form:"name_prefix"`
+	Owner       string `form:"owner"`
+	Image       string `form:"image"`
+	ServiceType string `form:"service_type" binding:"omitempty,eq=internal|eq=external"`
+	// domain or its subdomains
+	Domain string `form:"domain"`
+	Sort   string `form:"sort"`
+	// opaque cursor from previous page
+	Cursor string `form:"cursor"`
+	// page size, all items are returned if not set
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// ListPage -- pagination info of queried list
+type ListPage struct {
+	// number of items matching filters
+	Total int
+	// cursor of next page, empty on the last page
+	NextCursor string
+}
+
+// listEntry -- fields of list item used by ListQuery
+type listEntry struct {
+	index       int
+	ID          string
+	Name        string
+	Owner       string
+	Images      []string
+	ServiceType service.ServiceType
+	Domains     []string
+	Version     semver.Version
+	CreatedAt   time.Time
+	DeletedAt   time.Time
+}
+
+// listCursor -- sort key of last item on page, next page starts after it
+type listCursor struct {
+	Sort      string         `json:"s"`
+	ID        string         `json:"i"`
+	Name      string         `json:"n,omitempty"`
+	Version   semver.Version `json:"v,omitempty"`
+	CreatedAt time.Time      `json:"c,omitempty"`
+	DeletedAt time.Time      `json:"d,omitempty"`
+}
+
+func (cursor listCursor) entry() listEntry {
+	return listEntry{
+		ID:        cursor.ID,
+		Name:      cursor.Name,
+		Version:   cursor.Version,
+		CreatedAt: cursor.CreatedAt,
+		DeletedAt: cursor.DeletedAt,
+	}
+}
+
+func encodeCursor(sortBy string, entry listEntry) string {
+	data, _ := json.Marshal(listCursor{
+		Sort:      sortBy,
+		ID:        entry.ID,
+		Name:      entry.Name,
+		Version:   entry.Version,
+		CreatedAt: entry.CreatedAt,
+		DeletedAt: entry.DeletedAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (listCursor, error) {
+	var decoded listCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &decoded)
+	}
+	if err != nil {
+		return decoded, rserrors.ErrValidation().AddDetails("invalid cursor")
+	}
+	return decoded, nil
+}
+
+// parseTime parses RFC3339 creation date, dates of resources created before it was saved are zero
+func parseTime(date *string) time.Time {
+	if date == nil {
+		return time.Time{}
+	}
+	parsed, _ := time.Parse(time.RFC3339, *date)
+	return parsed
+}
+
+func deletedAt(date *time.Time) time.Time {
+	if date == nil {
+		return time.Time{}
+	}
+	return *date
+}
+
+// less compares entries by sort field, ID makes order total so cursor position is unambiguous
+func less(sortBy string, a, b listEntry) bool {
+	var desc = strings.HasPrefix(sortBy, "-")
+	if desc {
+		a, b = b, a
+	}
+	switch strings.TrimPrefix(sortBy, "-") {
+	case SortByName:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+	case SortByCreatedAt:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	case SortByDeletedAt:
+		if !a.DeletedAt.Equal(b.DeletedAt) {
+			return a.DeletedAt.Before(b.DeletedAt)
+		}
+	case SortByVersion:
+		if !a.Version.Equals(b.Version) {
+			return a.Version.LT(b.Version)
+		}
+	}
+	return a.ID < b.ID
+}
+
+func validSort(sortBy string) bool {
+	switch strings.TrimPrefix(sortBy, "-") {
+	case SortByName, SortByCreatedAt, SortByDeletedAt, SortByVersion:
+		return true
+	}
+	return false
+}
+
+func matchImage(images []string, image string) bool {
+	for _, img := range images {
+		// image without tag matches any tag
+		if img == image || strings.HasPrefix(img, image+":") || strings.HasPrefix(img, image+"@") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchDomain(domains []string, domain string) bool {
+	for _, d := range domains {
+		if d == domain || strings.HasSuffix(d, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (query ListQuery) match(entry listEntry) bool {
+	switch {
+	case query.NamePrefix != "" && !strings.HasPrefix(entry.Name, query.NamePrefix),
+		query.Owner != "" && entry.Owner != query.Owner,
+		query.Image != "" && !matchImage(entry.Images, query.Image),
+		query.ServiceType != "" && string(entry.ServiceType) != query.ServiceType,
+		query.Domain != "" && !matchDomain(entry.Domains, query.Domain):
+		return false
+	}
+	return true
+}
+
+// apply returns indices of list items on requested page in result order
+func (query ListQuery) apply(entries []listEntry, defaultSort string) ([]int, ListPage, error) {
+	var sortBy = query.Sort
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	if !validSort(sortBy) {
+		return nil, ListPage{}, rserrors.ErrValidation().AddDetailF("unknown sort field %q", sortBy)
+	}
+	if query.Limit < 0 || query.Limit > maxListLimit {
+		return nil, ListPage{}, rserrors.ErrValidation().AddDetailF("limit must be between 1 and %d", maxListLimit)
+	}
+
+	var matched = make([]listEntry, 0, len(entries))
+	for _, entry := range entries {
+		if query.match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return less(sortBy, matched[i], matched[j])
+	})
+
+	var page = ListPage{Total: len(matched)}
+	var start int
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, page, err
+		}
+		if cursor.Sort != sortBy {
+			return nil, page, rserrors.ErrValidation().AddDetailF("cursor was issued for sort %q", cursor.Sort)
+		}
+		start = sort.Search(len(matched), func(i int) bool {
+			return less(sortBy, cursor.entry(), matched[i])
+		})
+	}
+	var end = len(matched)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+		page.NextCursor = encodeCursor(sortBy, matched[end-1])
+	}
+
+	var indices = make([]int, 0, end-start)
+	for _, entry := range matched[start:end] {
+		indices = append(indices, entry.index)
+	}
+	return indices, page, nil
+}
+
+// QueryDeployments filters, sorts and paginates deployments list
+func (query ListQuery) QueryDeployments(list deployment.DeploymentList, defaultSort string) (deployment.DeploymentList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, depl := range list {
+		entries = append(entries, listEntry{
+			index:     i,
+			ID:        depl.ID,
+			Name:      depl.Name,
+			Owner:     depl.Owner,
+			Images:    depl.ImagesNames(),
+			Version:   depl.Version,
+			CreatedAt: parseTime(depl.CreatedAt),
+			DeletedAt: deletedAt(depl.DeletedAt),
+		})
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(deployment.DeploymentList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
+
+// QueryServices filters, sorts and paginates services list
+func (query ListQuery) QueryServices(list service.ServiceList, defaultSort string) (service.ServiceList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, svc := range list {
+		var entry = listEntry{
+			index:       i,
+			ID:          svc.ID,
+			Name:        svc.Name,
+			Owner:       svc.Owner,
+			ServiceType: DetermineServiceType(svc.Service),
+			CreatedAt:   parseTime(svc.CreatedAt),
+			DeletedAt:   deletedAt(svc.DeletedAt),
+		}
+		if svc.Domain != "" {
+			entry.Domains = []string{svc.Domain}
+		}
+		entries = append(entries, entry)
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(service.ServiceList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
+
+// QueryIngresses filters, sorts and paginates ingresses list
+func (query ListQuery) QueryIngresses(list ingress.IngressList, defaultSort string) (ingress.IngressList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, ingr := range list {
+		var entry = listEntry{
+			index:     i,
+			ID:        ingr.ID,
+			Name:      ingr.Name,
+			Owner:     ingr.Owner,
+			CreatedAt: parseTime(ingr.CreatedAt),
+			DeletedAt: deletedAt(ingr.DeletedAt),
+		}
+		for _, rule := range ingr.Rules {
+			entry.Domains = append(entry.Domains, rule.Host)
+		}
+		entries = append(entries, entry)
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(ingress.IngressList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
+
+// QueryCustomDomains filters, sorts and paginates custom domains list, domain is used as name
+func (query ListQuery) QueryCustomDomains(list customdomain.CustomDomainList, defaultSort string) (customdomain.CustomDomainList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, dom := range list {
+		entries = append(entries, listEntry{
+			index:     i,
+			ID:        dom.ID,
+			Name:      dom.Domain,
+			Owner:     dom.Owner,
+			Domains:   []string{dom.Domain},
+			CreatedAt: parseTime(&dom.CreatedAt),
+		})
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(customdomain.CustomDomainList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
+
+// QueryCertificates filters, sorts and paginates certificates list, certificate hosts are used as domains
+func (query ListQuery) QueryCertificates(list certificate.CertificateList, defaultSort string) (certificate.CertificateList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, cert := range list {
+		entries = append(entries, listEntry{
+			index:     i,
+			ID:        cert.ID,
+			Name:      cert.Name,
+			Owner:     cert.Owner,
+			Domains:   cert.Hosts,
+			CreatedAt: parseTime(&cert.CreatedAt),
+		})
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(certificate.CertificateList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
+
+// QueryDomains filters, sorts and paginates service domains list, domain is used as name
+func (query ListQuery) QueryDomains(list domain.DomainList, defaultSort string) (domain.DomainList, ListPage, error) {
+	var entries = make([]listEntry, 0, len(list))
+	for i, dom := range list {
+		entries = append(entries, listEntry{
+			index:   i,
+			ID:      dom.ID,
+			Name:    dom.Domain,
+			Domains: []string{dom.Domain},
+		})
+	}
+	indices, page, err := query.apply(entries, defaultSort)
+	if err != nil {
+		return nil, page, err
+	}
+	var result = make(domain.DomainList, 0, len(indices))
+	for _, i := range indices {
+		result = append(result, list[i])
+	}
+	return result, page, nil
+}
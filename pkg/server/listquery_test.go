@@ -0,0 +1,49 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/service"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQuery(t *testing.T) {
+	var port = 80
+	var list service.ServiceList
+	for i, name := range []string{"web-c", "db", "web-a", "web-b"} {
+		list = append(list, service.ServiceResource{
+			Service: model.Service{Name: name, Owner: "owner", Ports: []model.ServicePort{{Name: "http", Port: &port}}},
+			ID:      strconv.Itoa(i),
+		})
+	}
+
+	query := ListQuery{NamePrefix: "web", Limit: 2}
+	page1, info, err := query.QueryServices(list, SortByName)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, info.Total)
+		assert.NotEmpty(t, info.NextCursor)
+		if assert.Len(t, page1, 2) {
+			assert.Equal(t, "web-a", page1[0].Name)
+			assert.Equal(t, "web-b", page1[1].Name)
+		}
+	}
+
+	query.Cursor = info.NextCursor
+	page2, info, err := query.QueryServices(list, SortByName)
+	if assert.NoError(t, err) {
+		assert.Empty(t, info.NextCursor)
+		if assert.Len(t, page2, 1) {
+			assert.Equal(t, "web-c", page2[0].Name)
+		}
+	}
+
+	query.Sort = "-" + SortByName
+	_, _, err = query.QueryServices(list, SortByName)
+	assert.Error(t, err, "cursor must be rejected for another sort")
+
+	external, _, err := ListQuery{ServiceType: string(service.ServiceExternal)}.QueryServices(list, SortByName)
+	assert.NoError(t, err)
+	assert.Empty(t, external)
+}
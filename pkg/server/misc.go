@@ -1,10 +1,19 @@
 package server
 
 import (
+	"strings"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	"git.containerum.net/ch/resource-service/pkg/models/service"
 	"git.containerum.net/ch/resource-service/pkg/models/stats"
 	"git.containerum.net/ch/resource-service/pkg/rsErrors"
 	kubtypes "github.com/containerum/kube-client/pkg/model"
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxDNSNameLength  = 253
+	maxDNSLabelLength = 63
 )
 
 // DetermineServiceType deduces service type from service ports. If we have one or more "Port" set it is internal.
@@ -22,26 +31,121 @@ func DetermineServiceType(svc kubtypes.Service) service.ServiceType {
 	return serviceType
 }
 
-// IngressPaths generates ingress paths by service ports
-func IngressPaths(service kubtypes.Service, path string, servicePort int) ([]kubtypes.Path, error) {
-	var portExist bool
-	for _, port := range service.Ports {
-		if port.Port != nil && *port.Port == servicePort && port.Protocol == kubtypes.TCP {
-			portExist = true
-			break
-		}
+// IngressPaths generates ingress paths by service ports.
+// If path is split between several weighted backends, every backend is checked and
+// generated path points to backend with the largest weight.
+func IngressPaths(services map[string]kubtypes.Service, path string, backends ...ingress.Backend) ([]kubtypes.Path, error) {
+	if len(backends) == 0 {
+		return nil, rserrors.ErrValidation().AddDetailF("no backends for path %s", path)
 	}
-	if !portExist {
-		return nil, rserrors.ErrTCPPortNotFound().AddDetailF("TCP port %d not exists in service %s", servicePort, service.Name)
+
+	var totalWeight int
+	var used = make(map[string]struct{}, len(backends))
+	for _, backend := range backends {
+		service, ok := services[backend.ServiceName]
+		if !ok {
+			return nil, rserrors.ErrResourceNotExists().AddDetailF("service '%v' not exists", backend.ServiceName)
+		}
+		if _, ok := used[backend.ServiceName]; ok {
+			return nil, rserrors.ErrValidation().AddDetailF("service %s is used more than once in path %s", backend.ServiceName, path)
+		}
+		used[backend.ServiceName] = struct{}{}
+
+		var portExist bool
+		for _, port := range service.Ports {
+			if port.Port != nil && *port.Port == backend.ServicePort && port.Protocol == kubtypes.TCP {
+				portExist = true
+				break
+			}
+		}
+		if !portExist {
+			return nil, rserrors.ErrTCPPortNotFound().AddDetailF("TCP port %d not exists in service %s", backend.ServicePort, service.Name)
+		}
+		if backend.Weight < 0 || backend.Weight > ingress.TotalWeight {
+			return nil, rserrors.ErrValidation().AddDetailF("weight of service %s in path %s must be between 0 and %d, got %d", backend.ServiceName, path, ingress.TotalWeight, backend.Weight)
+		}
+		totalWeight += backend.Weight
 	}
 
-	ret := []kubtypes.Path{
-		{Path: path, ServiceName: service.Name, ServicePort: servicePort},
+	if len(backends) > 1 && totalWeight != ingress.TotalWeight {
+		return nil, rserrors.ErrValidation().AddDetailF("sum of backend weights for path %s must be %d, got %d", path, ingress.TotalWeight, totalWeight)
 	}
 
+	var primary = ingress.Split{Path: path, Backends: backends}.PrimaryPath()
+	ret := []kubtypes.Path{primary}
+
 	return ret, nil
 }
 
+// IngressSplitPaths checks that traffic split has at least two backends and generates ingress paths for it
+func IngressSplitPaths(services map[string]kubtypes.Service, split ingress.Split) ([]kubtypes.Path, error) {
+	if len(split.Backends) < 2 {
+		return nil, rserrors.ErrValidation().AddDetailF("split of path %s for host %s must have at least 2 backends, got %d", split.Path, split.Host, len(split.Backends))
+	}
+	return IngressPaths(services, split.Path, split.Backends...)
+}
+
+// NormalizeHost converts host to lower case ASCII dns name
+func NormalizeHost(host string) (string, error) {
+	host, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", rserrors.ErrValidation().AddDetailsErr(err)
+	}
+	if len(host) > maxDNSNameLength {
+		return "", rserrors.ErrValidation().AddDetailF("host %s is longer than %d characters", host, maxDNSNameLength)
+	}
+	return strings.ToLower(host), nil
+}
+
+// IngressHost converts host to ingress host with one of allowed suffixes.
+// Host must either end with one of suffixes or be a single dns label, in this case first suffix is appended.
+func IngressHost(host string, suffixes ingress.HostSuffixList) (string, error) {
+	if len(suffixes) == 0 {
+		return "", rserrors.ErrInternal().AddDetails("no ingress host suffixes configured")
+	}
+
+	host, err := NormalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	var label, suffix = host, suffixes[0].Suffix
+	for _, hostSuffix := range suffixes {
+		if hostLabel, ok := hostSuffix.Label(host); ok {
+			label, suffix = hostLabel, hostSuffix.Suffix
+			break
+		}
+	}
+
+	switch {
+	case label == "" || strings.Contains(label, "."):
+		return "", rserrors.ErrValidation().AddDetailF("host %s must be a dns label or end with one of %v", host, suffixes.Suffixes())
+	case len(label) > maxDNSLabelLength:
+		return "", rserrors.ErrValidation().AddDetailF("host label %s is longer than %d characters", label, maxDNSLabelLength)
+	case len(label)+len(suffix) > maxDNSNameLength:
+		return "", rserrors.ErrValidation().AddDetailF("host %s%s is longer than %d characters", label, suffix, maxDNSNameLength)
+	}
+	return label + suffix, nil
+}
+
+// CheckIngressPathsUnique checks that every host and path pair is used in ingress rules only once
+func CheckIngressPathsUnique(rules []kubtypes.Rule) error {
+	type hostPath struct {
+		host, path string
+	}
+	var used = make(map[hostPath]struct{})
+	for _, rule := range rules {
+		for _, path := range rule.Path {
+			var key = hostPath{host: rule.Host, path: path.Path}
+			if _, ok := used[key]; ok {
+				return rserrors.ErrValidation().AddDetailF("path %s for host %s is used more than once", path.Path, rule.Host)
+			}
+			used[key] = struct{}{}
+		}
+	}
+	return nil
+}
+
 func CheckDeploymentCreateQuotas(ns kubtypes.Namespace, nsUsage kubtypes.Resource, deploy kubtypes.Deployment) error {
 	CalculateDeployResources(&deploy)
 
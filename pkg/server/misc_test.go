@@ -0,0 +1,108 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+	"github.com/containerum/cherry"
+	"github.com/containerum/kube-client/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngressSplitPaths(t *testing.T) {
+	var port = 30000
+	var services = map[string]model.Service{
+		"web":    {Name: "web", Ports: []model.ServicePort{{Name: "http", Port: &port, Protocol: model.TCP}}},
+		"canary": {Name: "canary", Ports: []model.ServicePort{{Name: "http", Port: &port, Protocol: model.TCP}}},
+	}
+	var split = func(weights ...int) ingress.Split {
+		var ret = ingress.Split{Host: "web", Path: "/"}
+		for i, weight := range weights {
+			ret.Backends = append(ret.Backends, ingress.Backend{ServiceName: []string{"web", "canary"}[i], ServicePort: port, Weight: weight})
+		}
+		return ret
+	}
+
+	paths, err := IngressSplitPaths(services, split(10, 90))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []model.Path{{Path: "/", ServiceName: "canary", ServicePort: port}}, paths)
+	}
+
+	for _, invalid := range []ingress.Split{split(100), split(), split(150, -50), split(60, 60)} {
+		_, err := IngressSplitPaths(services, invalid)
+		assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v: %v", invalid.Backends, err)
+	}
+
+	// plain path is a single backend without weight
+	paths, err = IngressPaths(services, "/", ingress.Backend{ServiceName: "web", ServicePort: port})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []model.Path{{Path: "/", ServiceName: "web", ServicePort: port}}, paths)
+	}
+	_, err = IngressPaths(services, "/", ingress.Backend{ServiceName: "web", ServicePort: port, Weight: 150})
+	assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%v", err)
+}
+
+func TestIngressHost(t *testing.T) {
+	hub, err := ingress.ParseHostSuffix(".hub.containerum.io")
+	assert.NoError(t, err)
+	apps, err := ingress.ParseHostSuffix("apps=apps.example.com")
+	assert.NoError(t, err)
+	var suffixes = ingress.HostSuffixList{hub, apps}
+
+	for host, expected := range map[string]string{
+		"web":                     "web.hub.containerum.io",
+		"Web.Hub.Containerum.IO.": "web.hub.containerum.io",
+		"api.apps.example.com":    "api.apps.example.com",
+	} {
+		got, err := IngressHost(host, suffixes)
+		if assert.NoError(t, err, host) {
+			assert.Equal(t, expected, got)
+		}
+	}
+
+	var label = strings.Repeat("a", maxDNSLabelLength)
+	got, err := IngressHost(label, suffixes)
+	if assert.NoError(t, err) {
+		assert.Equal(t, label+hub.Suffix, got)
+	}
+	// generated host must fit in dns name too
+	long, err := ingress.ParseHostSuffix(strings.Repeat("b.", (maxDNSNameLength-maxDNSLabelLength)/2) + "io")
+	assert.NoError(t, err)
+	for _, invalid := range []struct {
+		host     string
+		suffixes ingress.HostSuffixList
+	}{
+		{host: label + "a", suffixes: suffixes},
+		{host: label, suffixes: ingress.HostSuffixList{long}},
+		{host: "a.b.hub.containerum.io", suffixes: suffixes},
+		{host: "web.example.com", suffixes: suffixes},
+		{host: ".hub.containerum.io", suffixes: suffixes},
+		{host: strings.Repeat("c.", maxDNSNameLength/2) + "io", suffixes: suffixes},
+	} {
+		_, err := IngressHost(invalid.host, invalid.suffixes)
+		assert.True(t, cherry.Equals(err, rserrors.ErrValidation()), "%s: %v", invalid.host, err)
+	}
+}
+
+func TestCheckIngressPathsUnique(t *testing.T) {
+	var rules = []model.Rule{
+		{Host: "web.hub.containerum.io", Path: []model.Path{
+			{Path: "/", ServiceName: "web", ServicePort: 80},
+			{Path: "/api", ServiceName: "api", ServicePort: 80},
+		}},
+		// the same path on other host is allowed
+		{Host: "api.hub.containerum.io", Path: []model.Path{{Path: "/", ServiceName: "api", ServicePort: 80}}},
+	}
+	assert.NoError(t, CheckIngressPathsUnique(rules))
+
+	// path repeated in the same rule
+	var duplicate = append([]model.Rule{}, rules...)
+	duplicate[1].Path = append(duplicate[1].Path, model.Path{Path: "/", ServiceName: "web", ServicePort: 80})
+	assert.True(t, cherry.Equals(CheckIngressPathsUnique(duplicate), rserrors.ErrValidation()))
+
+	// path repeated in other rule with the same host
+	duplicate = append(rules, model.Rule{Host: "web.hub.containerum.io", Path: []model.Path{{Path: "/api", ServiceName: "web", ServicePort: 80}}})
+	assert.True(t, cherry.Equals(CheckIngressPathsUnique(duplicate), rserrors.ErrValidation()))
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"git.containerum.net/ch/resource-service/pkg/rsErrors"
+)
+
+type ifMatchKey struct{}
+
+// ETag returns entity tag of resource revision.
+// Deployment versions have the same name, so resource ID is part of tag.
+func ETag(id string, revision int64) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%s:%d", id, revision))
+}
+
+// WithIfMatch saves value of If-Match header to context, update actions check it using CheckRevision
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, ifMatch)
+}
+
+// CheckRevision returns error if If-Match header saved in context doesn't match resource revision.
+// Requests without If-Match are not checked.
+func CheckRevision(ctx context.Context, id string, revision int64) error {
+	ifMatch, _ := ctx.Value(ifMatchKey{}).(string)
+	if ifMatch == "" || MatchETag(ifMatch, ETag(id, revision)) {
+		return nil
+	}
+	return rserrors.ErrResourceModified().AddDetailF("resource revision is %d", revision)
+}
+
+// MatchETag reports if list of entity tags from If-Match or If-None-Match header contains etag.
+// "*" matches any tag, weak tags are compared like strong ones.
+func MatchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
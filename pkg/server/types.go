@@ -3,8 +3,14 @@ package server
 import (
 	"context"
 
+	"git.containerum.net/ch/resource-service/pkg/models/adoption"
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
 	"git.containerum.net/ch/resource-service/pkg/models/deployment"
 	"git.containerum.net/ch/resource-service/pkg/models/domain"
+	"git.containerum.net/ch/resource-service/pkg/models/drift"
+	"git.containerum.net/ch/resource-service/pkg/models/event"
 	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	"git.containerum.net/ch/resource-service/pkg/models/resources"
 	"git.containerum.net/ch/resource-service/pkg/models/service"
@@ -36,13 +42,36 @@ type DomainActions interface {
 	DeleteDomain(ctx context.Context, domain string) error
 }
 
+type CustomDomainActions interface {
+	GetCustomDomainsList(ctx context.Context, nsID string) (customdomain.CustomDomainList, error)
+	GetCustomDomain(ctx context.Context, nsID, domain string) (*customdomain.CustomDomain, error)
+	AddCustomDomain(ctx context.Context, nsID string, req customdomain.CustomDomain) (*customdomain.CustomDomain, error)
+	VerifyCustomDomain(ctx context.Context, nsID, domain string) (*customdomain.CustomDomain, error)
+	DeleteCustomDomain(ctx context.Context, nsID, domain string) error
+}
+
+type CertificateActions interface {
+	GetCertificatesList(ctx context.Context, nsID string) (certificate.CertificateList, error)
+	GetCertificate(ctx context.Context, nsID, certName string) (*certificate.Certificate, error)
+	CreateCertificate(ctx context.Context, nsID string, req certificate.CertificateRequest) (*certificate.Certificate, error)
+	DeleteCertificate(ctx context.Context, nsID, certName string) error
+}
+
+type ACMEActions interface {
+	GetACMEChallengeResponse(ctx context.Context, host, token string) (string, error)
+}
+
 type IngressActions interface {
-	CreateIngress(ctx context.Context, nsID string, req kubtypes.Ingress) (*ingress.IngressResource, error)
+	GetIngressSuffixes(ctx context.Context) (ingress.HostSuffixList, error)
+	CreateIngress(ctx context.Context, nsID string, req ingress.IngressRequest) (*ingress.IngressResource, error)
 	GetIngressesList(ctx context.Context, nsID string) (ingress.IngressList, error)
 	GetIngress(ctx context.Context, nsID, ingressName string) (*ingress.IngressResource, error)
-	UpdateIngress(ctx context.Context, nsID string, req kubtypes.Ingress) (*ingress.IngressResource, error)
+	UpdateIngress(ctx context.Context, nsID string, req ingress.IngressRequest) (*ingress.IngressResource, error)
 	DeleteIngress(ctx context.Context, nsID, ingressName string) error
 	DeleteAllIngresses(ctx context.Context, nsID string) error
+	SetIngressACME(ctx context.Context, nsID, ingressName string, enabled bool) (*ingress.IngressResource, error)
+	UpdateIngressWeights(ctx context.Context, nsID, ingressName string, req ingress.UpdateWeights) (*ingress.IngressResource, error)
+	GetIngressHostConflicts(ctx context.Context) (ingress.HostConflictList, error)
 }
 
 type ServiceActions interface {
@@ -59,3 +88,32 @@ type ResourcesActions interface {
 	DeleteAllResourcesInNamespace(ctx context.Context, nsID string) error
 	DeleteAllUserResources(ctx context.Context) error
 }
+
+type TrashActions interface {
+	GetDeletedDeploymentsList(ctx context.Context, nsID string) (deployment.DeploymentList, error)
+	GetDeletedServicesList(ctx context.Context, nsID string) (service.ServiceList, error)
+	GetDeletedIngressesList(ctx context.Context, nsID string) (ingress.IngressList, error)
+	RestoreDeployment(ctx context.Context, nsID, deplName string) (*deployment.DeploymentResource, error)
+	RestoreService(ctx context.Context, nsID, serviceName string) (*service.ServiceResource, error)
+	RestoreIngress(ctx context.Context, nsID, ingressName string) (*ingress.IngressResource, error)
+}
+
+// WatchActions streams changes of namespace resources until ctx is done
+type WatchActions interface {
+	Watch(ctx context.Context, nsID, lastEventID string) (<-chan event.Event, error)
+}
+
+type BundleActions interface {
+	ExportNamespace(ctx context.Context, nsID string) (*bundle.Bundle, error)
+	ImportNamespace(ctx context.Context, nsID string, req bundle.Bundle, atomic, allowUnprotected bool) (*bundle.ImportResult, error)
+}
+
+// DriftActions compares active resources in storage with resources in kube-api
+type DriftActions interface {
+	DetectDrift(ctx context.Context, nsID string) (*drift.Report, error)
+}
+
+// AdoptActions creates records for kube-api resources created behind resource-service
+type AdoptActions interface {
+	AdoptNamespace(ctx context.Context, nsID, owner string, dryRun bool) (*adoption.Result, error)
+}
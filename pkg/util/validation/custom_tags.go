@@ -62,4 +62,23 @@ func registerCustomTagsENTranslation(v *validator.Validate, t ut.Translator) {
 		}
 		return t
 	})
+
+	// tags reported by struct validations
+	for tag, text := range map[string]string{
+		"rewrite_target":    "{0} must be absolute path of letters, digits and ._~/$- characters",
+		"header_name":       "{0} must be valid HTTP header name",
+		"realm":             "{0} must have only printable characters except quotes and backslashes",
+		"htpasswd_username": "{0} must be non-empty and have no colons or control characters",
+	} {
+		tag, text := tag, text
+		v.RegisterTranslation(tag, t, func(ut ut.Translator) error {
+			return ut.Add(tag, text, false)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, err := ut.T(tag, fe.Field())
+			if err != nil {
+				return err.Error()
+			}
+			return t
+		})
+	}
 }
@@ -3,6 +3,9 @@ package validation
 import (
 	"fmt"
 
+	"git.containerum.net/ch/resource-service/pkg/models/certificate"
+	"git.containerum.net/ch/resource-service/pkg/models/customdomain"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
 	kubtypes "github.com/containerum/kube-client/pkg/model"
 	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/en_US"
@@ -26,12 +29,17 @@ func StandardResourceValidator(uni *ut.UniversalTranslator) (ret *validator.Vali
 	registerCustomTagsENTranslation(ret, enUSTranslator)
 
 	ret.RegisterStructValidation(ingressValidate, kubtypes.Ingress{})
+	ret.RegisterStructValidation(ingressOptionsValidate, ingress.Options{})
+	ret.RegisterStructValidation(ingressSplitValidate, ingress.Split{})
+	ret.RegisterStructValidation(updateWeightsValidate, ingress.UpdateWeights{})
 	ret.RegisterStructValidation(serviceValidate, kubtypes.Service{})
 	ret.RegisterStructValidation(deploymentValidate, kubtypes.Deployment{})
 	ret.RegisterStructValidation(containerVolumeValidate, kubtypes.ContainerVolume{})
 	ret.RegisterStructValidation(containerPortValidate, kubtypes.ContainerPort{})
 	ret.RegisterStructValidation(updateReplicasValidate, kubtypes.UpdateReplicas{})
 	ret.RegisterStructValidation(updateImageValidate, kubtypes.UpdateImage{})
+	ret.RegisterStructValidation(customDomainValidate, customdomain.CustomDomain{})
+	ret.RegisterStructValidation(certificateRequestValidate, certificate.CertificateRequest{})
 
 	return
 }
@@ -41,30 +49,34 @@ func ingressValidate(structLevel validator.StructLevel) {
 
 	v := structLevel.Validator()
 
-	if err := v.Var(req.Rules, "required,len=1"); err != nil {
+	if err := v.Var(req.Rules, "required,min=1"); err != nil {
 		structLevel.ReportValidationErrors("Rules", "", err.(validator.ValidationErrors))
 		return
 	}
 
-	if err := v.Var(req.Rules[0].TLSSecret, "omitempty,dns"); err != nil {
-		structLevel.ReportValidationErrors("Rules[0].TLSSecret", "", err.(validator.ValidationErrors))
-	}
+	for i, rule := range req.Rules {
+		if err := v.Var(rule.TLSSecret, "omitempty,dns"); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Rules[%d].TLSSecret", i), "", err.(validator.ValidationErrors))
+		}
 
-	if err := v.Var(req.Rules[0].Host, "required"); err != nil {
-		structLevel.ReportValidationErrors("Rules[0].Host", "", err.(validator.ValidationErrors))
-	}
+		if err := v.Var(rule.Host, "required"); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Rules[%d].Host", i), "", err.(validator.ValidationErrors))
+		}
 
-	if err := v.Var(req.Rules[0].Path, "required,len=1"); err != nil {
-		structLevel.ReportValidationErrors("Rules[0].Path", "", err.(validator.ValidationErrors))
-		return
-	}
+		if err := v.Var(rule.Path, "required,min=1"); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Rules[%d].Path", i), "", err.(validator.ValidationErrors))
+			continue
+		}
 
-	if err := v.Var(req.Rules[0].Path[0].ServiceName, "dns"); err != nil {
-		structLevel.ReportValidationErrors("Rules[0].Path[0].ServiceName", "", err.(validator.ValidationErrors))
-	}
+		for j, path := range rule.Path {
+			if err := v.Var(path.ServiceName, "dns"); err != nil {
+				structLevel.ReportValidationErrors(fmt.Sprintf("Rules[%d].Path[%d].ServiceName", i, j), "", err.(validator.ValidationErrors))
+			}
 
-	if err := v.Var(req.Rules[0].Path[0].ServicePort, "min=1,max=65535"); err != nil {
-		structLevel.ReportValidationErrors("Rules[0].Path[0].ServicePort", "", err.(validator.ValidationErrors))
+			if err := v.Var(path.ServicePort, "min=1,max=65535"); err != nil {
+				structLevel.ReportValidationErrors(fmt.Sprintf("Rules[%d].Path[%d].ServicePort", i, j), "", err.(validator.ValidationErrors))
+			}
+		}
 	}
 }
 
@@ -199,3 +211,148 @@ func updateImageValidate(structLevel validator.StructLevel) {
 		structLevel.ReportValidationErrors("Container", "", err.(validator.ValidationErrors))
 	}
 }
+
+func customDomainValidate(structLevel validator.StructLevel) {
+	req := structLevel.Current().Interface().(customdomain.CustomDomain)
+
+	v := structLevel.Validator()
+
+	if err := v.Var(req.Domain, "required,fqdn"); err != nil {
+		structLevel.ReportValidationErrors("Domain", "", err.(validator.ValidationErrors))
+	}
+}
+
+func certificateRequestValidate(structLevel validator.StructLevel) {
+	req := structLevel.Current().Interface().(certificate.CertificateRequest)
+
+	v := structLevel.Validator()
+
+	if err := v.Var(req.Name, "required,dns"); err != nil {
+		structLevel.ReportValidationErrors("Name", "", err.(validator.ValidationErrors))
+	}
+
+	if err := v.Var(req.Cert, "required"); err != nil {
+		structLevel.ReportValidationErrors("Cert", "", err.(validator.ValidationErrors))
+	}
+
+	if err := v.Var(req.Key, "required"); err != nil {
+		structLevel.ReportValidationErrors("Key", "", err.(validator.ValidationErrors))
+	}
+}
+
+func ingressOptionsValidate(structLevel validator.StructLevel) {
+	req := structLevel.Current().Interface().(ingress.Options)
+
+	v := structLevel.Validator()
+
+	// values are passed to nginx configuration, so only safe characters are accepted
+	if req.RewriteTarget != "" && !ingress.ValidRewriteTarget(req.RewriteTarget) {
+		structLevel.ReportError(req.RewriteTarget, "RewriteTarget", "RewriteTarget", "rewrite_target", "")
+	}
+
+	if err := v.Var(req.MaxBodySize, "min=0,max=1024"); err != nil {
+		structLevel.ReportValidationErrors("MaxBodySize", "", err.(validator.ValidationErrors))
+	}
+
+	if req.RateLimit != nil {
+		if err := v.Var(req.RateLimit.RPS, "min=0"); err != nil {
+			structLevel.ReportValidationErrors("RateLimit.RPS", "", err.(validator.ValidationErrors))
+		}
+
+		if err := v.Var(req.RateLimit.Connections, "min=0"); err != nil {
+			structLevel.ReportValidationErrors("RateLimit.Connections", "", err.(validator.ValidationErrors))
+		}
+	}
+
+	if req.CORS != nil {
+		if req.CORS.AllowOrigin != "*" {
+			if err := v.Var(req.CORS.AllowOrigin, "omitempty,url"); err != nil {
+				structLevel.ReportValidationErrors("CORS.AllowOrigin", "", err.(validator.ValidationErrors))
+			}
+		}
+
+		for i, method := range req.CORS.AllowMethods {
+			if err := v.Var(method, "eq=GET|eq=HEAD|eq=POST|eq=PUT|eq=PATCH|eq=DELETE|eq=OPTIONS"); err != nil {
+				structLevel.ReportValidationErrors(fmt.Sprintf("CORS.AllowMethods[%d]", i), "", err.(validator.ValidationErrors))
+			}
+		}
+
+		for i, header := range req.CORS.AllowHeaders {
+			if !ingress.ValidHeaderName(header) {
+				field := fmt.Sprintf("CORS.AllowHeaders[%d]", i)
+				structLevel.ReportError(header, field, field, "header_name", "")
+			}
+		}
+
+		if err := v.Var(req.CORS.MaxAge, "min=0"); err != nil {
+			structLevel.ReportValidationErrors("CORS.MaxAge", "", err.(validator.ValidationErrors))
+		}
+	}
+
+	if req.BasicAuth != nil {
+		if !ingress.ValidRealm(req.BasicAuth.Realm) {
+			structLevel.ReportError(req.BasicAuth.Realm, "BasicAuth.Realm", "BasicAuth.Realm", "realm", "")
+		}
+
+		for i, user := range req.BasicAuth.Users {
+			if !ingress.ValidBasicAuthUsername(user.Username) {
+				field := fmt.Sprintf("BasicAuth.Users[%d].Username", i)
+				structLevel.ReportError(user.Username, field, field, "htpasswd_username", "")
+			}
+
+			if err := v.Var(user.Password, "required"); err != nil {
+				structLevel.ReportValidationErrors(fmt.Sprintf("BasicAuth.Users[%d].Password", i), "", err.(validator.ValidationErrors))
+			}
+		}
+	}
+}
+
+func ingressSplitValidate(structLevel validator.StructLevel) {
+	req := structLevel.Current().Interface().(ingress.Split)
+
+	v := structLevel.Validator()
+
+	if err := v.Var(req.Host, "required"); err != nil {
+		structLevel.ReportValidationErrors("Host", "", err.(validator.ValidationErrors))
+	}
+
+	if err := v.Var(req.Backends, "required,min=2"); err != nil {
+		structLevel.ReportValidationErrors("Backends", "", err.(validator.ValidationErrors))
+		return
+	}
+
+	for i, backend := range req.Backends {
+		if err := v.Var(backend.ServiceName, "dns"); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Backends[%d].ServiceName", i), "", err.(validator.ValidationErrors))
+		}
+
+		if err := v.Var(backend.ServicePort, "min=1,max=65535"); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Backends[%d].ServicePort", i), "", err.(validator.ValidationErrors))
+		}
+
+		if err := v.Var(backend.Weight, fmt.Sprintf("min=0,max=%d", ingress.TotalWeight)); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Backends[%d].Weight", i), "", err.(validator.ValidationErrors))
+		}
+	}
+}
+
+func updateWeightsValidate(structLevel validator.StructLevel) {
+	req := structLevel.Current().Interface().(ingress.UpdateWeights)
+
+	v := structLevel.Validator()
+
+	if err := v.Var(req.Host, "required"); err != nil {
+		structLevel.ReportValidationErrors("Host", "", err.(validator.ValidationErrors))
+	}
+
+	if err := v.Var(req.Weights, "required,min=2"); err != nil {
+		structLevel.ReportValidationErrors("Weights", "", err.(validator.ValidationErrors))
+		return
+	}
+
+	for service, weight := range req.Weights {
+		if err := v.Var(weight, fmt.Sprintf("min=0,max=%d", ingress.TotalWeight)); err != nil {
+			structLevel.ReportValidationErrors(fmt.Sprintf("Weights[%s]", service), "", err.(validator.ValidationErrors))
+		}
+	}
+}
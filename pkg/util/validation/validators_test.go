@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"git.containerum.net/ch/resource-service/pkg/models/bundle"
+	"git.containerum.net/ch/resource-service/pkg/models/ingress"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/en_US"
+	"github.com/go-playground/universal-translator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngressSplitValidation(t *testing.T) {
+	binding.Validator = &GinValidatorV9{Validate: StandardResourceValidator(ut.New(en.New(), en.New(), en_US.New()))}
+
+	var bind = func(body string, obj interface{}) error {
+		req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		return binding.JSON.Bind(req, obj)
+	}
+	const rules = `"rules":[{"host":"web","path":[{"path":"/","service_name":"web","service_port":80}]}]`
+	for name, splits := range map[string]string{
+		"single backend":   `[{"host":"web","path":"/","backends":[{"service_name":"web","service_port":80,"weight":100}]}]`,
+		"weight above 100": `[{"host":"web","path":"/","backends":[{"service_name":"web","service_port":80,"weight":150},{"service_name":"canary","service_port":80,"weight":-50}]}]`,
+		"no host":          `[{"path":"/","backends":[{"service_name":"web","service_port":80,"weight":50},{"service_name":"canary","service_port":80,"weight":50}]}]`,
+	} {
+		var req ingress.IngressRequest
+		assert.Error(t, bind(`{"name":"web",`+rules+`,"splits":`+splits+`}`, &req), name)
+
+		var imported bundle.Bundle
+		assert.Error(t, bind(`{"version":"1","ingresses":[{"name":"web",`+rules+`,"splits":`+splits+`}]}`, &imported), name)
+	}
+
+	var req ingress.IngressRequest
+	assert.NoError(t, bind(`{"name":"web",`+rules+`,"splits":[{"host":"web","path":"/","backends":[`+
+		`{"service_name":"web","service_port":80,"weight":90},{"service_name":"canary","service_port":80,"weight":10}]}]}`, &req))
+}
+
+func TestIngressOptionsValidation(t *testing.T) {
+	binding.Validator = &GinValidatorV9{Validate: StandardResourceValidator(ut.New(en.New(), en.New(), en_US.New()))}
+
+	var bind = func(options string) error {
+		req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"name":"web",`+
+			`"rules":[{"host":"web","path":[{"path":"/","service_name":"web","service_port":80}]}],"options":`+options+`}`))
+		var ingr ingress.IngressRequest
+		return binding.JSON.Bind(req, &ingr)
+	}
+	for name, options := range map[string]string{
+		"relative rewrite target":       `{"rewrite_target":"app"}`,
+		"rewrite target with semicolon": `{"rewrite_target":"/; return 200"}`,
+		"rewrite target with space":     `{"rewrite_target":"/a b"}`,
+		"rewrite target with newline":   `{"rewrite_target":"/\nmore_set_headers x"}`,
+		"header with space":             `{"cors":{"allow_headers":["X-Token","X Token"]}}`,
+		"header with quote":             `{"cors":{"allow_headers":["X\"; proxy_pass http://evil;"]}}`,
+		"empty header":                  `{"cors":{"allow_headers":[""]}}`,
+		"realm with quote":              `{"basic_auth":{"realm":"a\" evil","users":[{"username":"user","password":"pass"}]}}`,
+		"realm with backslash":          `{"basic_auth":{"realm":"a\\","users":[{"username":"user","password":"pass"}]}}`,
+		"realm with newline":            `{"basic_auth":{"realm":"a\nb","users":[{"username":"user","password":"pass"}]}}`,
+		"username with colon":           `{"basic_auth":{"users":[{"username":"us:er","password":"pass"}]}}`,
+		"username with newline":         `{"basic_auth":{"users":[{"username":"user\nroot","password":"pass"}]}}`,
+		"username with tab":             `{"basic_auth":{"users":[{"username":"us\ter","password":"pass"}]}}`,
+		"empty username":                `{"basic_auth":{"users":[{"username":"","password":"pass"}]}}`,
+	} {
+		assert.Error(t, bind(options), name)
+	}
+
+	assert.NoError(t, bind(`{"rewrite_target":"/api/$1","cors":{"allow_headers":["X-Token","Content-Type"]},`+
+		`"basic_auth":{"realm":"Staging area, v2 (private)","users":[{"username":"jöhn.doe@example.com","password":"pass"}]}}`))
+}
@@ -0,0 +1,91 @@
+// Package yamljson encodes and decodes YAML using JSON field tags of models
+package yamljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Marshal encodes value as YAML with keys from JSON tags
+func Marshal(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// ToJSON converts YAML document to JSON. JSON documents are valid YAML and are accepted too.
+func ToJSON(data []byte) ([]byte, error) {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(convert(generic))
+}
+
+// ToJSONDocuments splits multi-document YAML stream and converts every document to JSON.
+// Empty documents are skipped.
+func ToJSONDocuments(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var generic interface{}
+		err := decoder.Decode(&generic)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if generic == nil {
+			continue
+		}
+		doc, err := json.Marshal(convert(generic))
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// Unmarshal decodes YAML or JSON document to value using JSON tags
+func Unmarshal(data []byte, value interface{}) error {
+	data, err := ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// convert replaces YAML maps with interface keys by JSON compatible maps
+func convert(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		var converted = make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			strKey, ok := key.(string)
+			if !ok {
+				strKey = fmt.Sprint(key)
+			}
+			converted[strKey] = convert(item)
+		}
+		return converted
+	case []interface{}:
+		var converted = make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			converted = append(converted, convert(item))
+		}
+		return converted
+	default:
+		return value
+	}
+}